@@ -0,0 +1,64 @@
+// Command passwordreport reports how many users have a password hash not made
+// with the configured algorithm and parameters. Those hashes are upgraded when
+// their owners log in, so the count shrinks as users come back.
+//
+// It reads the same .env as the server, from the working directory, and exits
+// with status 1 when legacy hashes remain and -fail is set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ivandersr/products-api-go/configs"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/pkg/passwordhash"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func main() {
+	fail := flag.Bool("fail", false, "exit with status 1 when legacy hashes remain")
+	flag.Parse()
+
+	conf := configs.LoadConfig(".")
+	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var total, legacy int
+	algorithms := map[string]int{}
+	err = database.NewUserDB(db).PasswordHashes(func(hash string) {
+		total++
+		algorithms[passwordhash.Identify(hash)]++
+		if conf.PasswordHasher.NeedsRehash(hash) {
+			legacy++
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALGORITHM\tUSERS")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%d\n", name, algorithms[name])
+	}
+	w.Flush()
+	fmt.Printf("\n%d of %d users have a legacy password hash\n", legacy, total)
+
+	if *fail && legacy > 0 {
+		os.Exit(1)
+	}
+}
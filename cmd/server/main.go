@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -9,13 +14,27 @@ import (
 	"github.com/ivandersr/products-api-go/configs"
 	_ "github.com/ivandersr/products-api-go/docs"
 	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/auth"
 	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/idempotency"
+	"github.com/ivandersr/products-api-go/internal/infra/logger"
+	"github.com/ivandersr/products-api-go/internal/infra/mail"
+	"github.com/ivandersr/products-api-go/internal/infra/ratelimit"
+	"github.com/ivandersr/products-api-go/internal/infra/scheduler"
+	"github.com/ivandersr/products-api-go/internal/infra/storage"
 	"github.com/ivandersr/products-api-go/internal/infra/webserver/handlers"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	"github.com/ivandersr/products-api-go/pkg/signedtoken"
+	"github.com/redis/go-redis/v9"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// docsContentSecurityPolicy lets the Swagger UI run its inline scripts and
+// styles, which the policy of the API forbids.
+const docsContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"
+
 // @title            		   Products API Go
 // @version          		   1.0
 // @description      		   Products API with Authentication
@@ -33,41 +52,582 @@ import (
 // @securityDefinitions.apiKey ApiKeyAuth
 // @in                         header
 // @name                       Authorization
+// @securityDefinitions.apiKey MachineKeyAuth
+// @in                         header
+// @name                       X-API-Key
 func main() {
 	conf := configs.LoadConfig(".")
+	log := logger.New(os.Stdout, conf.LogLevel, conf.LogFormat)
+	entity.SetPasswordHasher(conf.PasswordHasher)
 
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic(err)
 	}
-	db.AutoMigrate(&entity.Product{}, &entity.User{})
+	userDB := database.NewUserDB(db)
+	// Duplicate emails have to be resolved before AutoMigrate indexes them.
+	if db.Migrator().HasTable(&entity.User{}) {
+		normalizeUserEmails(userDB, log)
+	}
+	err = db.AutoMigrate(&entity.Product{}, &entity.User{}, &entity.UserToken{}, &entity.AuditLog{}, &entity.APIKey{}, &entity.RecoveryCode{}, &entity.MFAPolicy{},
+		&entity.OAuthClient{}, &entity.OAuthConsent{}, &entity.OAuthAuthorizationCode{}, &entity.OAuthRefreshToken{}, &entity.Tenant{}, &entity.Membership{}, &entity.Variant{}, &entity.Media{},
+		&entity.Category{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.VariantPrice{},
+		&entity.Promotion{}, &entity.Coupon{}, &entity.CouponRedemption{})
+	if err != nil {
+		panic(err)
+	}
+
+	var redisClient redis.UniversalClient
+	if conf.RateLimitStore == "redis" || conf.IdempotencyStore == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     conf.RedisAddr,
+			Password: conf.RedisPassword,
+			DB:       conf.RedisDB,
+		})
+	}
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if conf.RateLimitStore == "redis" {
+		rateLimitStore = ratelimit.NewRedisStore(redisClient, "products-api:")
+	}
+	var idempotencyStore idempotency.Store = idempotency.NewMemoryStore()
+	if conf.IdempotencyStore == "redis" {
+		idempotencyStore = idempotency.NewRedisStore(redisClient, "products-api:")
+	}
+	defaultPolicy := rateLimitPolicy("default", conf.RateLimitDefault, middlewares.KeyByUser, middlewares.KeyByAPIKey, middlewares.KeyByIP)
+	loginPolicy := rateLimitPolicy("login", conf.RateLimitLogin, middlewares.KeyByIP)
+	signupPolicy := rateLimitPolicy("signup", conf.RateLimitSignup, middlewares.KeyByIP)
+	accountPolicy := rateLimitPolicy("account", conf.RateLimitAccount, middlewares.KeyByIP)
+	oauthPolicy := rateLimitPolicy("oauth", conf.RateLimitOAuth, middlewares.KeyByIP)
+	catalogPolicy := rateLimitPolicy("catalog", conf.RateLimitCatalog, middlewares.KeyByIP)
+	lockout := ratelimit.NewLockout(rateLimitStore, conf.LoginLockoutThreshold, conf.LoginLockoutWindow, conf.LoginLockoutBaseDelay, conf.LoginLockoutMaxDelay, conf.LoginLockoutTrustFor)
+
+	passwordPolicy := entity.NewPasswordPolicy(conf.PasswordMinLength, conf.PasswordRequireUpper, conf.PasswordRequireLower, conf.PasswordRequireDigit, conf.PasswordRequireSymbol)
+	if conf.PasswordDenylistFile != "" {
+		denylist, err := os.Open(conf.PasswordDenylistFile)
+		if err != nil {
+			panic(err)
+		}
+		err = passwordPolicy.LoadDenylist(denylist)
+		denylist.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var mailer mail.Mailer
+	switch conf.MailDriver {
+	case "smtp":
+		mailer = mail.NewSMTPMailer(conf.SMTPHost, conf.SMTPPort, conf.SMTPUsername, conf.SMTPPassword, conf.MailFrom)
+	case "file":
+		mailer = mail.NewFileMailer(conf.MailDir, conf.MailFrom)
+	default:
+		mailer = mail.NewLogMailer(log, conf.MailFrom)
+	}
+	outbox := mail.NewAsyncMailer(mailer, log, 1000)
+	go outbox.Run(context.Background())
 
 	productDB := database.NewProductDB(db)
-	productHandler := handlers.NewProductHandler(productDB)
-	userDB := database.NewUserDB(db)
-	userHandler := handlers.NewUserHandler(userDB)
+	tenantDB := database.NewTenantDB(db)
+	assignLegacyProducts(productDB, tenantDB, conf.LegacyTenant, log)
+	variantDB := database.NewVariantDB(db)
+	categoryDB := database.NewCategoryDB(db)
+	priceDB := database.NewPriceDB(db)
+	if backfilled, err := priceDB.Backfill(context.Background()); err != nil {
+		panic(err)
+	} else if backfilled > 0 {
+		log.Info("recorded the price history of existing products", "products", backfilled)
+	}
+	if backfilled, err := variantDB.BackfillPrices(context.Background()); err != nil {
+		panic(err)
+	} else if backfilled > 0 {
+		log.Info("recorded the price history of existing variants", "variants", backfilled)
+	}
+	var blobs storage.Store
+	switch conf.MediaStore {
+	case "s3":
+		blobs = storage.NewS3Store(conf.S3Endpoint, conf.S3Region, conf.S3Bucket, conf.S3AccessKeyID, conf.S3SecretAccessKey, conf.S3PathStyle)
+	default:
+		blobs = storage.NewLocalStore(conf.MediaDir)
+	}
+	mediaDB := database.NewMediaDB(db)
+	productHandler := handlers.NewProductHandler(productDB, variantDB, categoryDB, priceDB, mediaDB, blobs, handlers.MediaSettings{
+		PublicURL:        conf.MediaPublicURL,
+		MaxImageBytes:    conf.MediaMaxImageBytes,
+		MaxDocumentBytes: conf.MediaMaxDocumentBytes,
+		ThumbnailSizes:   conf.MediaThumbnailSizes,
+	}, log)
+	mediaHandler := handlers.NewMediaHandler(blobs, productDB, conf.CatalogCacheMaxAge, log)
+	categoryHandler := handlers.NewCategoryHandler(categoryDB, productDB, log)
+	promotionDB := database.NewPromotionDB(db)
+	promotionHandler := handlers.NewPromotionHandler(promotionDB, log)
+	pricingHandler := handlers.NewPricingHandler(productDB, variantDB, priceDB, promotionDB, log)
+	couponDB := database.NewCouponDB(db)
+	couponHandler := handlers.NewCouponHandler(couponDB, pricingHandler, log)
+
+	userTokenDB := database.NewUserTokenDB(db)
+	apiKeyDB := database.NewAPIKeyDB(db)
+	mfaPolicyDB := database.NewMFAPolicyDB(db)
+	membershipDB := database.NewMembershipDB(db)
+	userHandler := handlers.NewUserHandler(
+		userDB,
+		userTokenDB,
+		database.NewRecoveryCodeDB(db),
+		mfaPolicyDB,
+		membershipDB,
+		signedtoken.NewSigner(conf.UserTokenSecret),
+		outbox,
+		passwordPolicy,
+		lockout,
+		handlers.UserSettings{
+			AppBaseURL:               conf.AppBaseURL,
+			EmailVerificationTTL:     conf.EmailVerificationTTL,
+			PasswordResetTTL:         conf.PasswordResetTTL,
+			RequireEmailVerification: conf.RequireEmailVerification,
+			MFAIssuer:                conf.MFAIssuer,
+			MFAChallengeTTL:          conf.MFAChallengeTTL,
+		},
+		log,
+	)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyDB, log)
+	auditLogDB := database.NewAuditLogDB(db)
+	oauthClientDB := database.NewOAuthClientDB(db)
+	adminHandler := handlers.NewAdminHandler(userHandler, auditLogDB, oauthClientDB, tenantDB, log)
+	tenantHandler := handlers.NewTenantHandler(userHandler, tenantDB, membershipDB, log)
+	oauthHandler := handlers.NewOAuthHandler(
+		userHandler,
+		oauthClientDB,
+		database.NewOAuthConsentDB(db),
+		database.NewOAuthAuthorizationCodeDB(db),
+		database.NewOAuthRefreshTokenDB(db),
+		handlers.OAuthSettings{
+			CodeTTL:         conf.OAuthCodeTTL,
+			RefreshTokenTTL: conf.OAuthRefreshTokenTTL,
+		},
+		log,
+	)
+	catalogHandler := handlers.NewCatalogHandler(tenantDB, productDB, mediaDB, handlers.CatalogSettings{
+		CacheMaxAge: conf.CatalogCacheMaxAge,
+		PublicURL:   conf.MediaPublicURL,
+	}, log)
+	wellKnownHandler := handlers.NewWellKnownHandler(conf.TokenAuth, log)
+	promoteAdmins(userDB, conf.AdminEmails, log)
+
+	jobs := scheduler.New(conf.SchedulerInterval, log)
+	jobs.Add("product lifecycle", applyProductSchedules(productDB, log))
+	jobs.Add("scheduled prices", applyPriceSchedules(priceDB, log))
+	go jobs.Run(context.Background())
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(middlewares.RequestID)
+	r.Use(middlewares.RequestLogger(log))
 	r.Use(middleware.WithValue("jwt", conf.TokenAuth))
 	r.Use(middleware.WithValue("jwtExpiresIn", conf.JWTExpiresIn))
 	r.Use(middleware.Recoverer) // Graceful panic absorption with stack trace log, keeps API online
+	r.Use(middlewares.SecurityHeaders(middlewares.SecurityHeadersOptions{
+		HSTSMaxAge:            conf.HSTSMaxAge,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: middlewares.APIContentSecurityPolicy,
+	}))
+	apiCORS := middlewares.CORS(middlewares.CORSOptions{
+		AllowedOrigins:   conf.CORSAllowedOrigins,
+		AllowedMethods:   conf.CORSAllowedMethods,
+		AllowedHeaders:   conf.CORSAllowedHeaders,
+		ExposedHeaders:   []string{"X-Request-Id", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Idempotent-Replayed", "Location", "Preference-Applied"},
+		AllowCredentials: conf.CORSAllowCredentials,
+		MaxAge:           conf.CORSMaxAge,
+	})
+	// Uploads get a larger body limit than the rest of /products, so the
+	// limit is set per group, ahead of Idempotency which buffers the body.
+	uploadBodyBytes := max(conf.MediaMaxImageBytes, conf.MediaMaxDocumentBytes) + 64<<10
 	r.Route("/products", func(r chi.Router) {
-		r.Use(jwtauth.Verifier(conf.TokenAuth))
+		r.Use(apiCORS)
+		r.Use(auth.Verifier(conf.TokenAuth))
+		r.Use(middlewares.APIKeyAuth(apiKeyDB, log))
+		r.Use(jwtauth.Authenticator)
+		r.Use(middlewares.Session(userDB))
+		r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+		r.Use(middlewares.RequireTenant(membershipDB, log))
+		r.Use(middlewares.AuditImpersonation(auditLogDB, log))
+		r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+			r.Use(middlewares.RequireScope(entity.ScopeProductsRead))
+			r.Get("/by-sku/{sku}", productHandler.GetProductBySKU)
+			r.Get("/by-barcode/{code}", productHandler.GetProductByBarcode)
+			r.Get("/{id}", productHandler.GetProduct)
+			r.Get("/{id}/variants", productHandler.ListVariants)
+			r.Get("/{id}/variants/{variant_id}", productHandler.GetVariant)
+			r.Get("/{id}/media", productHandler.ListMedia)
+			r.Get("/{id}/media/{media_id}/file", productHandler.DownloadMedia)
+			r.Get("/{id}/prices", productHandler.ListPrices)
+			r.Get("/{id}/prices/at", productHandler.GetPriceAt)
+			r.Get("/", productHandler.GetProducts)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+			r.Use(middlewares.RequireScope(entity.ScopeProductsWrite))
+			r.Use(middlewares.RequireTenantEditor)
+			r.Use(middlewares.Idempotency(idempotencyStore, conf.IdempotencyTTL, log))
+			r.Post("/", productHandler.CreateProduct)
+			r.Put("/{id}", productHandler.UpdateProduct)
+			r.Delete("/{id}", productHandler.DeleteProduct)
+			r.Post("/{id}/publish", productHandler.PublishProduct)
+			r.Post("/{id}/archive", productHandler.ArchiveProduct)
+			r.Post("/{id}/unarchive", productHandler.UnarchiveProduct)
+			r.Post("/{id}/variants", productHandler.CreateVariant)
+			r.Post("/{id}/variants/generate", productHandler.GenerateVariants)
+			r.Put("/{id}/variants/{variant_id}", productHandler.UpdateVariant)
+			r.Delete("/{id}/variants/{variant_id}", productHandler.DeleteVariant)
+			r.Put("/{id}/media/order", productHandler.ReorderMedia)
+			r.Post("/{id}/prices", productHandler.SchedulePrice)
+			r.Delete("/{id}/prices/{price_id}", productHandler.CancelPrice)
+			r.Delete("/{id}/media/{media_id}", productHandler.DeleteMedia)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.MaxBytes(uploadBodyBytes))
+			r.Use(middlewares.RequireScope(entity.ScopeProductsWrite))
+			r.Use(middlewares.RequireTenantEditor)
+			r.Use(middlewares.Idempotency(idempotencyStore, conf.IdempotencyTTL, log))
+			r.Post("/{id}/media", productHandler.UploadMedia)
+		})
+	})
+
+	r.Route("/categories", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+		r.Use(auth.Verifier(conf.TokenAuth))
+		r.Use(middlewares.APIKeyAuth(apiKeyDB, log))
+		r.Use(jwtauth.Authenticator)
+		r.Use(middlewares.Session(userDB))
+		r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+		r.Use(middlewares.RequireTenant(membershipDB, log))
+		r.Use(middlewares.AuditImpersonation(auditLogDB, log))
+		r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireScope(entity.ScopeProductsRead))
+			r.Get("/", categoryHandler.ListCategories)
+			r.Get("/{id}", categoryHandler.GetCategory)
+			r.Get("/{id}/schema", categoryHandler.GetCategorySchema)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireScope(entity.ScopeProductsWrite))
+			r.Use(middlewares.Idempotency(idempotencyStore, conf.IdempotencyTTL, log))
+			r.Post("/", categoryHandler.CreateCategory)
+			r.Put("/{id}", categoryHandler.UpdateCategory)
+			r.Delete("/{id}", categoryHandler.DeleteCategory)
+		})
+	})
+
+	r.Route("/promotions", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+		r.Use(auth.Verifier(conf.TokenAuth))
+		r.Use(middlewares.APIKeyAuth(apiKeyDB, log))
+		r.Use(jwtauth.Authenticator)
+		r.Use(middlewares.Session(userDB))
+		r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+		r.Use(middlewares.RequireTenant(membershipDB, log))
+		r.Use(middlewares.AuditImpersonation(auditLogDB, log))
+		r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireScope(entity.ScopeProductsRead))
+			r.Get("/", promotionHandler.ListPromotions)
+			r.Get("/{id}", promotionHandler.GetPromotion)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireScope(entity.ScopeProductsWrite))
+			r.Use(middlewares.Idempotency(idempotencyStore, conf.IdempotencyTTL, log))
+			r.Post("/", promotionHandler.CreatePromotion)
+			r.Put("/{id}", promotionHandler.UpdatePromotion)
+			r.Delete("/{id}", promotionHandler.DeletePromotion)
+		})
+	})
+
+	// Coupon codes are discounts to whoever holds them, so only tenant owners
+	// manage them while any member may redeem one.
+	r.Route("/coupons", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+		r.Use(auth.Verifier(conf.TokenAuth))
+		r.Use(middlewares.APIKeyAuth(apiKeyDB, log))
+		r.Use(jwtauth.Authenticator)
+		r.Use(middlewares.Session(userDB))
+		r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+		r.Use(middlewares.RequireTenant(membershipDB, log))
+		r.Use(middlewares.AuditImpersonation(auditLogDB, log))
+		r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+		r.With(middlewares.RequireScope(entity.ScopeProductsRead), middlewares.Idempotency(idempotencyStore, conf.IdempotencyTTL, log)).
+			Post("/redeem", couponHandler.RedeemCoupon)
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireTenantOwner)
+			r.Use(middlewares.RequireScope(entity.ScopeProductsWrite))
+			r.Use(middlewares.Idempotency(idempotencyStore, conf.IdempotencyTTL, log))
+			r.Get("/", couponHandler.ListCoupons)
+			r.Post("/", couponHandler.CreateCoupon)
+			r.Post("/generate", couponHandler.GenerateCoupons)
+			r.Get("/{id}", couponHandler.GetCoupon)
+			r.Put("/{id}", couponHandler.UpdateCoupon)
+			r.Delete("/{id}", couponHandler.DeleteCoupon)
+			r.Get("/{id}/redemptions", couponHandler.ListCouponRedemptions)
+		})
+	})
+
+	// Quotes only read, so they are neither idempotency keyed nor limited to
+	// writers.
+	r.Route("/pricing", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+		r.Use(auth.Verifier(conf.TokenAuth))
+		r.Use(middlewares.APIKeyAuth(apiKeyDB, log))
 		r.Use(jwtauth.Authenticator)
-		r.Post("/", productHandler.CreateProduct)
-		r.Get("/{id}", productHandler.GetProduct)
-		r.Put("/{id}", productHandler.UpdateProduct)
-		r.Delete("/{id}", productHandler.DeleteProduct)
-		r.Get("/", productHandler.GetProducts)
+		r.Use(middlewares.Session(userDB))
+		r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+		r.Use(middlewares.RequireTenant(membershipDB, log))
+		r.Use(middlewares.AuditImpersonation(auditLogDB, log))
+		r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+		r.Use(middlewares.RequireScope(entity.ScopeProductsRead))
+		r.Post("/quote", pricingHandler.Quote)
+	})
+
+	// The media of published products are served publicly like the catalog
+	// that links to them; the others only under /products.
+	r.With(middlewares.RateLimit(rateLimitStore, catalogPolicy, log)).Get("/media/*", mediaHandler.ServeMedia)
+
+	// The catalog is public and read-only: writes stay under /products.
+	r.Route("/catalog", func(r chi.Router) {
+		r.Use(middlewares.CORS(middlewares.CORSOptions{
+			AllowedOrigins: conf.CatalogCORSOrigins,
+			AllowedMethods: []string{http.MethodGet},
+			AllowedHeaders: []string{"If-None-Match"},
+			ExposedHeaders: []string{"ETag", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+			MaxAge:         time.Hour,
+		}))
+		r.Use(middlewares.RateLimit(rateLimitStore, catalogPolicy, log))
+		r.Get("/{tenant}/products", catalogHandler.ListCatalogProducts)
+		r.Get("/{tenant}/products/{id}", catalogHandler.GetCatalogProduct)
 	})
 
 	r.Route("/users", func(r chi.Router) {
-		r.Post("/", userHandler.CreateUser)
-		r.Post("/token", userHandler.GetJWT)
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxAuthBodyBytes))
+		r.With(middlewares.RateLimit(rateLimitStore, signupPolicy, log)).Post("/", userHandler.CreateUser)
+		r.With(middlewares.RateLimit(rateLimitStore, loginPolicy, log)).Post("/token", userHandler.GetJWT)
+		r.With(middlewares.RateLimit(rateLimitStore, loginPolicy, log)).Post("/token/mfa", userHandler.VerifyMFA)
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RateLimit(rateLimitStore, accountPolicy, log))
+			r.Post("/verify", userHandler.VerifyEmail)
+			r.Post("/verify/resend", userHandler.ResendVerification)
+			r.Post("/password/forgot", userHandler.ForgotPassword)
+			r.Post("/password/reset", userHandler.ResetPassword)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Verifier(conf.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Session(userDB))
+			r.Use(middlewares.RequireFirstParty)
+			r.Use(middlewares.AuditImpersonation(auditLogDB, log))
+			r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+			// Users required to use MFA can still enroll without it.
+			r.Get("/me", userHandler.GetMe)
+			r.Get("/me/mfa", userHandler.GetMFA)
+			r.Post("/me/mfa/totp", userHandler.EnrollTOTP)
+			r.Post("/me/mfa/totp/confirm", userHandler.ConfirmTOTP)
+			r.Group(func(r chi.Router) {
+				r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+				r.Patch("/me", userHandler.UpdateMe)
+				r.Post("/me/password", userHandler.ChangePassword)
+				r.Delete("/me", userHandler.DeleteMe)
+				r.Delete("/me/mfa/totp", userHandler.DisableMFA)
+				r.Post("/me/mfa/recovery-codes", userHandler.RegenerateRecoveryCodes)
+				r.Get("/me/api-keys", apiKeyHandler.ListAPIKeys)
+				r.Post("/me/api-keys", apiKeyHandler.CreateAPIKey)
+				r.Delete("/me/api-keys/{id}", apiKeyHandler.DeleteAPIKey)
+				r.Get("/me/oauth-consents", oauthHandler.ListConsents)
+				r.Delete("/me/oauth-consents/{client_id}", oauthHandler.RevokeConsent)
+				r.Get("/me/tenants", tenantHandler.ListMyTenants)
+				r.Post("/me/tenant", tenantHandler.SwitchTenant)
+			})
+		})
+	})
+
+	r.Route("/tenant", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+		r.Use(auth.Verifier(conf.TokenAuth))
+		r.Use(jwtauth.Authenticator)
+		r.Use(middlewares.Session(userDB))
+		r.Use(middlewares.RequireFirstParty)
+		r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+		r.Use(middlewares.RequireTenant(membershipDB, log))
+		r.Use(middlewares.AuditImpersonation(auditLogDB, log))
+		r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+		r.Get("/members", tenantHandler.ListMembers)
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RequireTenantOwner)
+			r.Use(middlewares.Idempotency(idempotencyStore, conf.IdempotencyTTL, log))
+			r.Post("/members", tenantHandler.AddMember)
+			r.Delete("/members/{user_id}", tenantHandler.RemoveMember)
+		})
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxBodyBytes))
+		r.Use(auth.Verifier(conf.TokenAuth))
+		r.Use(jwtauth.Authenticator)
+		r.Use(middlewares.Session(userDB))
+		r.Use(middlewares.RequireFirstParty)
+		r.Use(middlewares.RequireRole(entity.RoleAdmin))
+		r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+		r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+		r.Get("/users", adminHandler.ListUsers)
+		r.Get("/users/{id}", adminHandler.GetUser)
+		r.Post("/users/{id}/disable", adminHandler.DisableUser)
+		r.Post("/users/{id}/enable", adminHandler.EnableUser)
+		r.Put("/users/{id}/role", adminHandler.ChangeUserRole)
+		r.Post("/users/{id}/password-reset", adminHandler.ForcePasswordReset)
+		r.Post("/users/{id}/impersonate", adminHandler.ImpersonateUser)
+		r.Post("/users/{id}/mfa/reset", adminHandler.ResetUserMFA)
+		r.Get("/mfa-policies", adminHandler.ListMFAPolicies)
+		r.Put("/mfa-policies/{role}", adminHandler.SetMFAPolicy)
+		r.Get("/oauth-clients", adminHandler.ListOAuthClients)
+		r.Post("/oauth-clients", adminHandler.CreateOAuthClient)
+		r.Delete("/oauth-clients/{id}", adminHandler.DeleteOAuthClient)
+		r.Get("/tenants", adminHandler.ListTenants)
+		r.Post("/tenants", adminHandler.CreateTenant)
+		r.Get("/audit-logs", adminHandler.ListAuditLogs)
+	})
+
+	r.Route("/oauth", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(middlewares.MaxBytes(conf.MaxAuthBodyBytes))
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Verifier(conf.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Session(userDB))
+			r.Use(middlewares.RequireFirstParty)
+			r.Use(middlewares.RequireMFA(mfaPolicyDB, log))
+			r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+			r.Get("/authorize", oauthHandler.GetAuthorize)
+			r.Post("/authorize", oauthHandler.PostAuthorize)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.RateLimit(rateLimitStore, oauthPolicy, log))
+			r.Post("/token", oauthHandler.Token)
+			r.Post("/introspect", oauthHandler.Introspect)
+			r.Post("/revoke", oauthHandler.Revoke)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Verifier(conf.TokenAuth))
+			r.Use(jwtauth.Authenticator)
+			r.Use(middlewares.Session(userDB))
+			r.Use(middlewares.RequireScope(entity.ScopeOpenID))
+			r.Use(middlewares.RateLimit(rateLimitStore, defaultPolicy, log))
+			r.Get("/userinfo", oauthHandler.UserInfo)
+		})
 	})
 
-	r.Get("/docs/*", httpSwagger.Handler(httpSwagger.URL("http://localhost:8000/docs/doc.json")))
+	r.Get("/.well-known/jwks.json", wellKnownHandler.JWKS)
+	r.Get("/.well-known/openid-configuration", wellKnownHandler.OpenIDConfiguration)
 
-	http.ListenAndServe(":8000", r)
+	r.With(middlewares.ContentSecurityPolicy(docsContentSecurityPolicy)).Get("/docs/*", httpSwagger.Handler(httpSwagger.URL("http://localhost:8000/docs/doc.json")))
+
+	log.Info("starting web server", "addr", ":8000")
+	if err := http.ListenAndServe(":8000", r); err != nil {
+		log.Error("web server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// promoteAdmins grants the admin role to the registered users listed in
+// ADMIN_EMAILS, which bootstraps the first administrators.
+func promoteAdmins(userDB *database.User, emails []string, log *slog.Logger) {
+	for _, email := range emails {
+		user, err := userDB.FindByEmail(email)
+		if err != nil || user.IsAdmin() {
+			continue
+		}
+		user.ChangeRole(entity.RoleAdmin)
+		user.InvalidateSessions()
+		if err := userDB.Update(user); err != nil {
+			log.Error("failed to promote admin", "email", email, "error", err)
+			continue
+		}
+		log.Info("promoted user to admin", "user_id", user.ID.String())
+	}
+}
+
+// normalizeUserEmails normalizes the emails of the users created before they
+// were normalized on write. The accounts that lose a duplicate email are
+// logged, so an admin can impersonate them to set a new one.
+func normalizeUserEmails(userDB *database.User, log *slog.Logger) {
+	normalized, renamed, err := userDB.NormalizeEmails()
+	if err != nil {
+		panic(err)
+	}
+	if normalized > 0 {
+		log.Info("normalized the emails of existing users", "users", normalized)
+	}
+	for _, user := range renamed {
+		log.Warn("email taken by another account once normalized, replaced with a placeholder", "user_id", user.ID.String(), "previous_email", user.Email)
+	}
+}
+
+// assignLegacyProducts assigns the products created before tenants existed
+// to the tenant with slug LEGACY_TENANT. Until it is set they stay hidden,
+// as no tenant can see them.
+func assignLegacyProducts(productDB *database.Product, tenantDB *database.Tenant, slug string, log *slog.Logger) {
+	ctx := context.Background()
+	legacy, err := productDB.CountWithoutTenant(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if legacy == 0 {
+		return
+	}
+	if slug == "" {
+		log.Warn("products created before tenants are hidden until LEGACY_TENANT names the tenant to assign them to", "products", legacy)
+		return
+	}
+	tenant, err := tenantDB.FindBySlug(slug)
+	if err != nil {
+		panic(fmt.Errorf("LEGACY_TENANT %q: %w", slug, err))
+	}
+	assigned, err := productDB.AssignTenant(ctx, tenant.ID)
+	if err != nil {
+		panic(err)
+	}
+	log.Info("assigned products created before tenants", "tenant_id", tenant.ID.String(), "products", assigned)
+}
+
+// applyProductSchedules publishes and archives the products whose
+// publish_at or unpublish_at has passed.
+func applyProductSchedules(productDB *database.Product, log *slog.Logger) scheduler.Job {
+	return func(ctx context.Context, now time.Time) error {
+		published, archived, err := productDB.ApplySchedules(ctx, now)
+		if published > 0 || archived > 0 {
+			log.InfoContext(ctx, "applied product schedules", "published", published, "archived", archived)
+		}
+		return err
+	}
+}
+
+// applyPriceSchedules sets the price of the products whose scheduled prices
+// started or ended.
+func applyPriceSchedules(priceDB *database.Price, log *slog.Logger) scheduler.Job {
+	return func(ctx context.Context, now time.Time) error {
+		updated, err := priceDB.ApplySchedules(ctx, now)
+		if updated > 0 {
+			log.InfoContext(ctx, "applied scheduled prices", "products", updated)
+		}
+		return err
+	}
+}
+
+func rateLimitPolicy(name, limit string, keys ...middlewares.KeyFunc) middlewares.RateLimitPolicy {
+	parsed, err := ratelimit.ParseLimit(limit)
+	if err != nil {
+		panic(err)
+	}
+	return middlewares.RateLimitPolicy{Name: name, Limit: parsed, Keys: keys}
 }
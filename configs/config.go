@@ -1,21 +1,107 @@
 package configs
 
 import (
-	"github.com/go-chi/jwtauth"
+	"fmt"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/infra/auth"
+	"github.com/ivandersr/products-api-go/pkg/passwordhash"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// minUserTokenSecretLength is the shortest USER_TOKEN_SECRET accepted. The
+// secret signs email verification and password reset links, so a guessable
+// one would let anyone forge them.
+const minUserTokenSecretLength = 32
+
 type conf struct {
-	DBDriver      string `mapstructure:"DB_DRIVER"`
-	DBHost        string `mapstructure:"DB_HOST"`
-	DBPort        string `mapstructure:"DB_PORT"`
-	DBUser        string `mapstructure:"DB_USER"`
-	DBPassword    string `mapstructure:"DB_PASSWORD"`
-	DBName        string `mapstructure:"DB_NAME"`
-	WebServerPort string `mapstructure:"WEB_SERVER_PORT"`
-	JWTSecret     string `mapstructure:"JWT_SECRET"`
-	JWTExpiresIn  int    `mapstructure:"JWT_EXPIRES_IN"`
-	TokenAuth     *jwtauth.JWTAuth
+	DBDriver                 string        `mapstructure:"DB_DRIVER"`
+	DBHost                   string        `mapstructure:"DB_HOST"`
+	DBPort                   string        `mapstructure:"DB_PORT"`
+	DBUser                   string        `mapstructure:"DB_USER"`
+	DBPassword               string        `mapstructure:"DB_PASSWORD"`
+	DBName                   string        `mapstructure:"DB_NAME"`
+	WebServerPort            string        `mapstructure:"WEB_SERVER_PORT"`
+	JWTSecret                string        `mapstructure:"JWT_SECRET"`
+	JWTExpiresIn             int           `mapstructure:"JWT_EXPIRES_IN"`
+	JWTSigningKeyFile        string        `mapstructure:"JWT_SIGNING_KEY_FILE"`
+	JWTVerificationKeyFiles  []string      `mapstructure:"JWT_VERIFICATION_KEY_FILES"`
+	JWTIssuer                string        `mapstructure:"JWT_ISSUER"`
+	JWTAudience              string        `mapstructure:"JWT_AUDIENCE"`
+	LogLevel                 string        `mapstructure:"LOG_LEVEL"`
+	LogFormat                string        `mapstructure:"LOG_FORMAT"`
+	RateLimitStore           string        `mapstructure:"RATE_LIMIT_STORE"`
+	RateLimitDefault         string        `mapstructure:"RATE_LIMIT_DEFAULT"`
+	RateLimitLogin           string        `mapstructure:"RATE_LIMIT_LOGIN"`
+	RateLimitSignup          string        `mapstructure:"RATE_LIMIT_SIGNUP"`
+	RateLimitAccount         string        `mapstructure:"RATE_LIMIT_ACCOUNT"`
+	RedisAddr                string        `mapstructure:"REDIS_ADDR"`
+	RedisPassword            string        `mapstructure:"REDIS_PASSWORD"`
+	RedisDB                  int           `mapstructure:"REDIS_DB"`
+	LoginLockoutThreshold    int           `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutWindow       time.Duration `mapstructure:"LOGIN_LOCKOUT_WINDOW"`
+	LoginLockoutBaseDelay    time.Duration `mapstructure:"LOGIN_LOCKOUT_BASE_DELAY"`
+	LoginLockoutMaxDelay     time.Duration `mapstructure:"LOGIN_LOCKOUT_MAX_DELAY"`
+	LoginLockoutTrustFor     time.Duration `mapstructure:"LOGIN_LOCKOUT_TRUST_FOR"`
+	PasswordMinLength        int           `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper     bool          `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower     bool          `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit     bool          `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol    bool          `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordDenylistFile     string        `mapstructure:"PASSWORD_DENYLIST_FILE"`
+	PasswordHashAlgorithm    string        `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost               int           `mapstructure:"BCRYPT_COST"`
+	Argon2Memory             uint32        `mapstructure:"ARGON2_MEMORY"`
+	Argon2Iterations         uint32        `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism        uint8         `mapstructure:"ARGON2_PARALLELISM"`
+	UserTokenSecret          string        `mapstructure:"USER_TOKEN_SECRET"`
+	AppBaseURL               string        `mapstructure:"APP_BASE_URL"`
+	EmailVerificationTTL     time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	PasswordResetTTL         time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+	RequireEmailVerification bool          `mapstructure:"REQUIRE_EMAIL_VERIFICATION"`
+	MailDriver               string        `mapstructure:"MAIL_DRIVER"`
+	MailFrom                 string        `mapstructure:"MAIL_FROM"`
+	MailDir                  string        `mapstructure:"MAIL_DIR"`
+	SMTPHost                 string        `mapstructure:"SMTP_HOST"`
+	SMTPPort                 int           `mapstructure:"SMTP_PORT"`
+	SMTPUsername             string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword             string        `mapstructure:"SMTP_PASSWORD"`
+	AdminEmails              []string      `mapstructure:"ADMIN_EMAILS"`
+	LegacyTenant             string        `mapstructure:"LEGACY_TENANT"`
+	MFAIssuer                string        `mapstructure:"MFA_ISSUER"`
+	MFAChallengeTTL          time.Duration `mapstructure:"MFA_CHALLENGE_TTL"`
+	OAuthCodeTTL             time.Duration `mapstructure:"OAUTH_CODE_TTL"`
+	OAuthRefreshTokenTTL     time.Duration `mapstructure:"OAUTH_REFRESH_TOKEN_TTL"`
+	RateLimitOAuth           string        `mapstructure:"RATE_LIMIT_OAUTH"`
+	SchedulerInterval        time.Duration `mapstructure:"SCHEDULER_INTERVAL"`
+	CatalogCacheMaxAge       time.Duration `mapstructure:"CATALOG_CACHE_MAX_AGE"`
+	CatalogCORSOrigins       []string      `mapstructure:"CATALOG_CORS_ORIGINS"`
+	RateLimitCatalog         string        `mapstructure:"RATE_LIMIT_CATALOG"`
+	CORSAllowedOrigins       []string      `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods       []string      `mapstructure:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders       []string      `mapstructure:"CORS_ALLOWED_HEADERS"`
+	CORSAllowCredentials     bool          `mapstructure:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge               time.Duration `mapstructure:"CORS_MAX_AGE"`
+	HSTSMaxAge               time.Duration `mapstructure:"HSTS_MAX_AGE"`
+	MaxBodyBytes             int64         `mapstructure:"MAX_BODY_BYTES"`
+	MaxAuthBodyBytes         int64         `mapstructure:"MAX_AUTH_BODY_BYTES"`
+	IdempotencyStore         string        `mapstructure:"IDEMPOTENCY_STORE"`
+	IdempotencyTTL           time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+	MediaStore               string        `mapstructure:"MEDIA_STORE"`
+	MediaDir                 string        `mapstructure:"MEDIA_DIR"`
+	MediaPublicURL           string        `mapstructure:"MEDIA_PUBLIC_URL"`
+	MediaMaxImageBytes       int64         `mapstructure:"MEDIA_MAX_IMAGE_BYTES"`
+	MediaMaxDocumentBytes    int64         `mapstructure:"MEDIA_MAX_DOCUMENT_BYTES"`
+	MediaThumbnailSizes      []int         `mapstructure:"MEDIA_THUMBNAIL_SIZES"`
+	S3Endpoint               string        `mapstructure:"S3_ENDPOINT"`
+	S3Region                 string        `mapstructure:"S3_REGION"`
+	S3Bucket                 string        `mapstructure:"S3_BUCKET"`
+	S3AccessKeyID            string        `mapstructure:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey        string        `mapstructure:"S3_SECRET_ACCESS_KEY"`
+	S3PathStyle              bool          `mapstructure:"S3_PATH_STYLE"`
+	TokenAuth                *auth.JWTAuth
+	PasswordHasher           passwordhash.Hasher
 }
 
 func LoadConfig(path string) *conf {
@@ -33,6 +119,149 @@ func LoadConfig(path string) *conf {
 	if err != nil {
 		panic(err)
 	}
-	cfg.TokenAuth = jwtauth.New("HS256", []byte(cfg.JWTSecret), nil)
+	if cfg.SchedulerInterval <= 0 {
+		cfg.SchedulerInterval = time.Minute
+	}
+	if cfg.CatalogCacheMaxAge <= 0 {
+		cfg.CatalogCacheMaxAge = time.Minute
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+	if cfg.MaxAuthBodyBytes <= 0 {
+		cfg.MaxAuthBodyBytes = 16 << 10
+	}
+	if cfg.RateLimitDefault == "" {
+		cfg.RateLimitDefault = "300/1m"
+	}
+	if cfg.RateLimitLogin == "" {
+		cfg.RateLimitLogin = "10/1m"
+	}
+	if cfg.RateLimitSignup == "" {
+		cfg.RateLimitSignup = "5/1h"
+	}
+	if cfg.RateLimitAccount == "" {
+		cfg.RateLimitAccount = "20/1h"
+	}
+	if cfg.RateLimitOAuth == "" {
+		cfg.RateLimitOAuth = "60/1m"
+	}
+	if cfg.RateLimitCatalog == "" {
+		cfg.RateLimitCatalog = "600/1m"
+	}
+	if cfg.LoginLockoutThreshold <= 0 {
+		cfg.LoginLockoutThreshold = 5
+	}
+	if cfg.LoginLockoutWindow <= 0 {
+		cfg.LoginLockoutWindow = 15 * time.Minute
+	}
+	if cfg.LoginLockoutBaseDelay <= 0 {
+		cfg.LoginLockoutBaseDelay = time.Minute
+	}
+	if cfg.LoginLockoutMaxDelay <= 0 {
+		cfg.LoginLockoutMaxDelay = time.Hour
+	}
+	if cfg.LoginLockoutTrustFor <= 0 {
+		cfg.LoginLockoutTrustFor = 30 * 24 * time.Hour
+	}
+	if cfg.EmailVerificationTTL <= 0 {
+		cfg.EmailVerificationTTL = 48 * time.Hour
+	}
+	if cfg.PasswordResetTTL <= 0 {
+		cfg.PasswordResetTTL = time.Hour
+	}
+	if cfg.MFAChallengeTTL <= 0 {
+		cfg.MFAChallengeTTL = 5 * time.Minute
+	}
+	if cfg.OAuthCodeTTL <= 0 {
+		cfg.OAuthCodeTTL = time.Minute
+	}
+	if cfg.OAuthRefreshTokenTTL <= 0 {
+		cfg.OAuthRefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		cfg.IdempotencyTTL = 24 * time.Hour
+	}
+	if cfg.MediaDir == "" {
+		cfg.MediaDir = "media"
+	}
+	if cfg.MediaPublicURL == "" {
+		cfg.MediaPublicURL = "/media"
+	}
+	if cfg.MediaMaxImageBytes <= 0 {
+		cfg.MediaMaxImageBytes = 10 << 20
+	}
+	if cfg.MediaMaxDocumentBytes <= 0 {
+		cfg.MediaMaxDocumentBytes = 20 << 20
+	}
+	if cfg.MediaThumbnailSizes == nil {
+		cfg.MediaThumbnailSizes = []int{150, 400, 800}
+	}
+	if len(cfg.UserTokenSecret) < minUserTokenSecretLength {
+		panic(fmt.Sprintf("USER_TOKEN_SECRET must have at least %d characters", minUserTokenSecretLength))
+	}
+	cfg.TokenAuth = loadTokenAuth(cfg)
+	cfg.PasswordHasher = loadPasswordHasher(cfg)
 	return cfg
 }
+
+// loadPasswordHasher hashes new passwords with PASSWORD_HASH_ALGORITHM,
+// bcrypt unless set to argon2id. Existing hashes keep working and are
+// upgraded when their owner logs in. BCRYPT_COST and each ARGON2_* setting
+// left unset take their recommended default.
+func loadPasswordHasher(cfg *conf) passwordhash.Hasher {
+	algorithm := cfg.PasswordHashAlgorithm
+	if algorithm == "" {
+		algorithm = passwordhash.AlgorithmBcrypt
+	}
+	if cfg.BcryptCost == 0 {
+		cfg.BcryptCost = bcrypt.DefaultCost
+	}
+	if cfg.Argon2Memory == 0 {
+		cfg.Argon2Memory = passwordhash.DefaultArgon2idParams.Memory
+	}
+	if cfg.Argon2Iterations == 0 {
+		cfg.Argon2Iterations = passwordhash.DefaultArgon2idParams.Iterations
+	}
+	if cfg.Argon2Parallelism == 0 {
+		cfg.Argon2Parallelism = passwordhash.DefaultArgon2idParams.Parallelism
+	}
+	hasher, err := passwordhash.New(algorithm, cfg.BcryptCost, passwordhash.Argon2idParams{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return hasher
+}
+
+// loadTokenAuth signs tokens with the PEM key in JWT_SIGNING_KEY_FILE and
+// also accepts the keys in JWT_VERIFICATION_KEY_FILES, which should list the
+// previous signing keys while tokens signed by them are still valid. Without
+// a signing key it falls back to HS256 with JWT_SECRET.
+func loadTokenAuth(cfg *conf) *auth.JWTAuth {
+	if cfg.JWTSigningKeyFile == "" {
+		return auth.NewHMAC([]byte(cfg.JWTSecret), cfg.JWTIssuer, cfg.JWTAudience)
+	}
+	signing, err := auth.LoadKeyFile(cfg.JWTSigningKeyFile)
+	if err != nil {
+		panic(err)
+	}
+	if signing.Private == nil {
+		panic("JWT_SIGNING_KEY_FILE must contain a private key")
+	}
+	var verification []*auth.Key
+	for _, path := range cfg.JWTVerificationKeyFiles {
+		if path == "" {
+			continue
+		}
+		key, err := auth.LoadKeyFile(path)
+		if err != nil {
+			panic(err)
+		}
+		verification = append(verification, key)
+	}
+	return auth.New(signing, verification, cfg.JWTIssuer, cfg.JWTAudience)
+}
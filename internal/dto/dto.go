@@ -1,27 +1,422 @@
 package dto
 
-import "github.com/ivandersr/products-api-go/internal/entity"
+import (
+	"time"
 
+	"github.com/ivandersr/products-api-go/internal/entity"
+)
+
+// CreateProductInput creates a draft unless Status is published.
 type CreateProductInput struct {
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	Name        string                 `json:"name" validate:"required,max=255"`
+	Price       float64                `json:"price" validate:"required,gt=0"`
+	Status      string                 `json:"status,omitempty" validate:"omitempty,oneof=draft published"`
+	SKU         string                 `json:"sku,omitempty" validate:"omitempty,max=64"`
+	Barcode     string                 `json:"barcode,omitempty" validate:"omitempty,numeric,min=8,max=14"`
+	Options     []entity.ProductOption `json:"options,omitempty" validate:"omitempty,max=3"`
+	CategoryID  string                 `json:"category_id,omitempty" validate:"omitempty,uuid"`
+	Attributes  map[string]any         `json:"attributes,omitempty" validate:"omitempty,max=50"`
+	Tags        []string               `json:"tags,omitempty" validate:"omitempty,max=20"`
+	PublishAt   *time.Time             `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time             `json:"unpublish_at,omitempty"`
 }
 
 type CreateUserInput struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,max=128"`
 }
 
 type GetJWTInput struct {
-	Email    string `json:"email"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+type EmailInput struct {
+	Email string `json:"email"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token"`
 	Password string `json:"password"`
 }
 
+type UpdateUserInput struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangeRoleInput struct {
+	Role string `json:"role"`
+}
+
+type CreateAPIKeyInput struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyOutput struct {
+	entity.APIKey
+	Key string `json:"key"`
+}
+
+type MFAChallengeInput struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MFACodeInput struct {
+	Code string `json:"code"`
+}
+
+type DisableMFAInput struct {
+	Password string `json:"password"`
+}
+
+type SetMFAPolicyInput struct {
+	Required bool `json:"required"`
+}
+
+type CreateTenantInput struct {
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	OwnerID string `json:"owner_id"`
+}
+
+type AddMemberInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type SwitchTenantInput struct {
+	TenantID string `json:"tenant_id"`
+}
+
+type TenantMembershipOutput struct {
+	entity.Tenant
+	Role string `json:"role"`
+}
+
+type MemberOutput struct {
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateOAuthClientInput struct {
+	Name         string   `json:"name"`
+	Confidential bool     `json:"confidential"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	OwnerID      *string  `json:"owner_id,omitempty"`
+}
+
+type CreateOAuthClientOutput struct {
+	entity.OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AuthorizeInput carries the parameters of an OAuth2 authorization request
+// (RFC 6749 section 4.1.1, RFC 7636).
+type AuthorizeInput struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri,omitempty"`
+	Scope               string `json:"scope,omitempty"`
+	State               string `json:"state,omitempty"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Nonce               string `json:"nonce,omitempty"`
+	Approve             bool   `json:"approve"`
+}
+
+type OAuthClientInfo struct {
+	ID   string `json:"client_id"`
+	Name string `json:"name"`
+}
+
+type AuthorizeOutput struct {
+	RedirectTo      string           `json:"redirect_to,omitempty"`
+	ConsentRequired bool             `json:"consent_required,omitempty"`
+	Client          *OAuthClientInfo `json:"client,omitempty"`
+	Scopes          []string         `json:"scopes,omitempty"`
+}
+
+type OAuthTokenOutput struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type IntrospectionOutput struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+}
+
+type UserInfoOutput struct {
+	Sub           string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+type OpenIDConfigurationOutput struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
 type GetJWTOutput struct {
-	AccessToken string `json:"access_token"`
+	AccessToken string `json:"access_token,omitempty"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+type TOTPEnrollmentOutput struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type ConfirmTOTPOutput struct {
+	AccessToken   string   `json:"access_token"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAStatusOutput struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// VariantInput sets a variant. Options name one value of every option of the
+// product; Price overrides the product price when set.
+type VariantInput struct {
+	Options map[string]string `json:"options" validate:"required"`
+	SKU     string            `json:"sku,omitempty" validate:"omitempty,max=64"`
+	Price   *float64          `json:"price,omitempty" validate:"omitempty,gt=0"`
+	Stock   int               `json:"stock" validate:"min=0" minimum:"0"`
+}
+
+type VariantsOutput struct {
+	Data []entity.Variant `json:"data"`
+}
+
+// SchedulePriceInput sets the price of a product from StartsAt, in the
+// future, until EndsAt when set.
+type SchedulePriceInput struct {
+	Amount   float64    `json:"amount" validate:"required,gt=0"`
+	StartsAt time.Time  `json:"starts_at" validate:"required"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Note     string     `json:"note,omitempty" validate:"max=255"`
+}
+
+// PriceHistoryOutput lists the prices of a product and the periods each is
+// in effect.
+type PriceHistoryOutput struct {
+	Data     []entity.ProductPrice `json:"data"`
+	Timeline []entity.PricePeriod  `json:"timeline"`
+}
+
+// PriceAtOutput is the price of a product, or of its variant when VariantID
+// is set, at a time. PriceID is the product price in effect then.
+type PriceAtOutput struct {
+	ProductID string    `json:"product_id"`
+	VariantID string    `json:"variant_id,omitempty"`
+	At        time.Time `json:"at"`
+	PriceID   string    `json:"price_id"`
+	Amount    float64   `json:"amount"`
+}
+
+// PromotionInput sets a promotion. Percent applies to percentage_off,
+// Amount to fixed_off, BuyQuantity, GetQuantity and GetPercent to
+// buy_x_get_y and Tiers to tiered. It targets the products listed, of the
+// categories listed or with the tags listed.
+type PromotionInput struct {
+	Name        string                 `json:"name" validate:"required,max=100"`
+	Type        string                 `json:"type" validate:"required,oneof=percentage_off fixed_off buy_x_get_y tiered"`
+	Percent     float64                `json:"percent,omitempty" validate:"min=0,max=100" minimum:"0" maximum:"100"`
+	Amount      float64                `json:"amount,omitempty" validate:"min=0" minimum:"0"`
+	BuyQuantity int                    `json:"buy_quantity,omitempty" validate:"min=0" minimum:"0"`
+	GetQuantity int                    `json:"get_quantity,omitempty" validate:"min=0" minimum:"0"`
+	GetPercent  float64                `json:"get_percent,omitempty" validate:"min=0,max=100" minimum:"0" maximum:"100"`
+	Tiers       []entity.PromotionTier `json:"tiers,omitempty" validate:"max=10"`
+	ProductIDs  []string               `json:"product_ids,omitempty" validate:"max=100,dive,uuid"`
+	CategoryIDs []string               `json:"category_ids,omitempty" validate:"max=100,dive,uuid"`
+	Tags        []string               `json:"tags,omitempty" validate:"max=20"`
+	StartsAt    *time.Time             `json:"starts_at,omitempty"`
+	EndsAt      *time.Time             `json:"ends_at,omitempty"`
+}
+
+type PromotionsOutput struct {
+	Data []entity.Promotion `json:"data"`
+}
+
+// QuoteInput prices lines at At, now unless set.
+type QuoteInput struct {
+	At    *time.Time       `json:"at,omitempty"`
+	Lines []QuoteLineInput `json:"lines" validate:"required,min=1,max=100,dive"`
+}
+
+type QuoteLineInput struct {
+	ProductID string `json:"product_id" validate:"required,uuid"`
+	VariantID string `json:"variant_id,omitempty" validate:"omitempty,uuid"`
+	Quantity  int    `json:"quantity" validate:"required,min=1,max=10000" minimum:"1"`
+}
+
+type QuoteLineOutput struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	entity.LineQuote
+}
+
+type QuoteOutput struct {
+	At       time.Time         `json:"at"`
+	Lines    []QuoteLineOutput `json:"lines"`
+	Subtotal float64           `json:"subtotal"`
+	Discount float64           `json:"discount"`
+	Total    float64           `json:"total"`
+}
+
+// CouponRulesInput sets the rule and limits of a coupon. Percent applies to
+// percentage_off and Amount to fixed_off; a zero limit leaves it unlimited,
+// so a max_redemptions of 1 makes a single use coupon.
+type CouponRulesInput struct {
+	Type           string     `json:"type" validate:"required,oneof=percentage_off fixed_off"`
+	Percent        float64    `json:"percent,omitempty" validate:"min=0,max=100" minimum:"0" maximum:"100"`
+	Amount         float64    `json:"amount,omitempty" validate:"min=0" minimum:"0"`
+	MinBasket      float64    `json:"min_basket,omitempty" validate:"min=0" minimum:"0"`
+	MaxRedemptions int        `json:"max_redemptions,omitempty" validate:"min=0" minimum:"0"`
+	MaxPerUser     int        `json:"max_per_user,omitempty" validate:"min=0" minimum:"0"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// CouponInput creates a coupon with Code, or with a generated code when
+// empty.
+type CouponInput struct {
+	Code string `json:"code,omitempty" validate:"max=32"`
+	CouponRulesInput
+}
+
+// CouponBatchInput generates Count coupons with unique codes starting with
+// Prefix.
+type CouponBatchInput struct {
+	Prefix string `json:"prefix,omitempty" validate:"max=16"`
+	Count  int    `json:"count" validate:"required,min=1,max=1000" minimum:"1" maximum:"1000"`
+	CouponRulesInput
+}
+
+type CouponsOutput struct {
+	Data []entity.Coupon `json:"data"`
+}
+
+// RedeemCouponInput redeems Code on a basket of Lines, which the server
+// prices itself.
+type RedeemCouponInput struct {
+	Code  string           `json:"code" validate:"required,max=32"`
+	Lines []QuoteLineInput `json:"lines" validate:"required,min=1,max=100,dive"`
+}
+
+// RedeemCouponOutput has the Basket the coupon was redeemed on, the total of
+// its lines after promotions, and the Total left to pay.
+type RedeemCouponOutput struct {
+	Code       string                   `json:"code"`
+	Basket     float64                  `json:"basket"`
+	Discount   float64                  `json:"discount"`
+	Total      float64                  `json:"total"`
+	Redemption *entity.CouponRedemption `json:"redemption"`
+}
+
+type CouponRedemptionsOutput struct {
+	Data []entity.CouponRedemption `json:"data"`
+}
+
+// CategoryInput names a category and defines the attributes of its products.
+type CategoryInput struct {
+	Name       string                       `json:"name" validate:"required,max=100"`
+	Attributes []entity.AttributeDefinition `json:"attributes" validate:"max=50"`
+}
+
+type CategoriesOutput struct {
+	Data []entity.Category `json:"data"`
+}
+
+// ReorderMediaInput lists every media of a product in its new order.
+type ReorderMediaInput struct {
+	IDs []string `json:"ids" validate:"required,dive,uuid"`
+}
+
+type MediaListOutput struct {
+	Data []entity.Media `json:"data"`
 }
 
 type GetProductOutput struct {
 	Data entity.Product `json:"data"`
 }
+
+// CatalogProductOutput is the public projection of a published product.
+type CatalogProductOutput struct {
+	ID         string               `json:"id"`
+	Name       string               `json:"name"`
+	Price      float64              `json:"price"`
+	SKU        string               `json:"sku,omitempty"`
+	Barcode    string               `json:"barcode,omitempty"`
+	Attributes map[string]any       `json:"attributes,omitempty"`
+	Tags       []string             `json:"tags,omitempty"`
+	Media      []CatalogMediaOutput `json:"media,omitempty"`
+}
+
+// CatalogMediaOutput is the public projection of the media of a published
+// product, whose URLs point to the published files.
+type CatalogMediaOutput struct {
+	Kind        string                  `json:"kind"`
+	Filename    string                  `json:"filename"`
+	ContentType string                  `json:"content_type"`
+	Width       int                     `json:"width,omitempty"`
+	Height      int                     `json:"height,omitempty"`
+	URL         string                  `json:"url"`
+	Thumbnails  []entity.MediaThumbnail `json:"thumbnails,omitempty"`
+}
+
+type CatalogProductsOutput struct {
+	Data  []CatalogProductOutput `json:"data"`
+	Page  int                    `json:"page"`
+	Limit int                    `json:"limit"`
+}
@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+
+	apiKeyPrefix = "pak"
+)
+
+var (
+	ErrInvalidScope      = errors.New("invalid scope")
+	ErrInvalidAPIKey     = errors.New("invalid api key")
+	ErrInvalidExpiration = errors.New("expiration must be in the future")
+)
+
+// Scopes lists the scopes an API key may be restricted to.
+var Scopes = []string{ScopeProductsRead, ScopeProductsWrite}
+
+// APIKey grants machine access on behalf of a user. Keys are shown once as
+// pak_<prefix>_<secret>; only the prefix, used for lookups, and a hash of the
+// secret are stored. An API key without scopes has every scope. Keys are
+// bound to the tenant of the session that created them, if any.
+type APIKey struct {
+	ID         entity.ID  `json:"id"`
+	UserID     entity.ID  `json:"user_id" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"uniqueIndex"`
+	SecretHash string     `json:"-"`
+	TenantID   *entity.ID `json:"tenant_id,omitempty"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKey creates a key and returns it along with the plain text value to
+// hand to the user.
+func NewAPIKey(userID entity.ID, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrNameIsRequired
+	}
+	for _, scope := range scopes {
+		if !isValidScope(scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidExpiration
+	}
+	prefix := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := &APIKey{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    hex.EncodeToString(prefix),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	plain := base64.RawURLEncoding.EncodeToString(secret)
+	key.SecretHash = hashAPIKeySecret(plain)
+	return key, apiKeyPrefix + "_" + key.Prefix + "_" + plain, nil
+}
+
+// ParseAPIKey splits a plain text key into its prefix and secret.
+func ParseAPIKey(value string) (prefix, secret string, err error) {
+	parts := strings.SplitN(value, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", ErrInvalidAPIKey
+	}
+	return parts[1], parts[2], nil
+}
+
+// ValidateSecret compares secret with the stored hash in constant time.
+func (k *APIKey) ValidateSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.SecretHash), []byte(hashAPIKeySecret(secret))) == 1
+}
+
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now())
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return len(k.Scopes) == 0 || slices.Contains(k.Scopes, scope)
+}
+
+func isValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// hashAPIKeySecret uses a fast hash on purpose: secrets are 256 random bits,
+// so unlike passwords they cannot be brute forced.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, plain, err := NewAPIKey(entity.NewID(), "batch job", []string{ScopeProductsRead}, nil)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, key.Prefix)
+	assert.NotContains(t, key.SecretHash, plain)
+
+	prefix, secret, err := ParseAPIKey(plain)
+	assert.Nil(t, err)
+	assert.Equal(t, key.Prefix, prefix)
+	assert.True(t, key.ValidateSecret(secret))
+	assert.False(t, key.ValidateSecret(secret+"x"))
+	assert.True(t, key.HasScope(ScopeProductsRead))
+	assert.False(t, key.HasScope(ScopeProductsWrite))
+	assert.False(t, key.IsExpired())
+}
+
+func TestNewAPIKeyValidation(t *testing.T) {
+	_, _, err := NewAPIKey(entity.NewID(), " ", nil, nil)
+	assert.Equal(t, ErrNameIsRequired, err)
+	_, _, err = NewAPIKey(entity.NewID(), "job", []string{"admin"}, nil)
+	assert.Equal(t, ErrInvalidScope, err)
+	past := time.Now().Add(-time.Hour)
+	_, _, err = NewAPIKey(entity.NewID(), "job", nil, &past)
+	assert.Equal(t, ErrInvalidExpiration, err)
+}
+
+func TestAPIKeyWithoutScopesHasEveryScope(t *testing.T) {
+	key, _, err := NewAPIKey(entity.NewID(), "job", nil, nil)
+	assert.Nil(t, err)
+	assert.True(t, key.HasScope(ScopeProductsWrite))
+}
+
+func TestParseAPIKey(t *testing.T) {
+	for _, invalid := range []string{"", "pak_abc", "xyz_abc_def", "pak__def", "pak_abc_"} {
+		_, _, err := ParseAPIKey(invalid)
+		assert.Equal(t, ErrInvalidAPIKey, err, invalid)
+	}
+}
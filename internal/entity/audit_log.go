@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+const (
+	AuditUserDisabled       = "user.disabled"
+	AuditUserEnabled        = "user.enabled"
+	AuditUserRoleChanged    = "user.role_changed"
+	AuditUserPasswordReset  = "user.password_reset_forced"
+	AuditUserImpersonated   = "user.impersonated"
+	AuditImpersonatedCall   = "user.impersonated_request"
+	AuditUserMFAReset       = "user.mfa_reset"
+	AuditMFAPolicyChanged   = "mfa_policy.changed"
+	AuditOAuthClientCreated = "oauth_client.created"
+	AuditOAuthClientDeleted = "oauth_client.deleted"
+	AuditTenantCreated      = "tenant.created"
+)
+
+// AuditLog records a privileged action, who performed it and on what.
+type AuditLog struct {
+	ID        entity.ID `json:"id"`
+	ActorID   entity.ID `json:"actor_id" gorm:"index"`
+	Action    string    `json:"action" gorm:"index"`
+	TargetID  string    `json:"target_id" gorm:"index"`
+	Details   string    `json:"details,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewAuditLog(actorID entity.ID, action, targetID, details, requestID string) *AuditLog {
+	return &AuditLog{
+		ID:        entity.NewID(),
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		Details:   details,
+		RequestID: requestID,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,243 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+// Attribute types, named after the JSON Schema types they validate as.
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeInteger = "integer"
+	AttributeTypeBoolean = "boolean"
+)
+
+// MaxCategoryAttributes bounds the attributes of a category, and so the rows
+// indexing the attributes of each product.
+const MaxCategoryAttributes = 50
+
+var (
+	ErrInvalidAttributes      = errors.New("attributes need unique names of up to 64 lowercase letters, digits or underscores and a string, number, integer or boolean type")
+	ErrTooManyAttributes      = errors.New("a category has at most 50 attributes")
+	ErrInvalidEnum            = errors.New("enum needs unique values and only applies to string attributes")
+	ErrInvalidRange           = errors.New("minimum and maximum only apply to number and integer attributes, and minimum cannot be above maximum")
+	ErrAttributesNeedCategory = errors.New("attributes require a category")
+)
+
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// AttributeDefinition describes an attribute of the products of a category.
+// Enum restricts a string to a list of values, Minimum and Maximum bound a
+// number or integer, and Unit documents what a number measures.
+type AttributeDefinition struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Unit     string   `json:"unit,omitempty"`
+	Minimum  *float64 `json:"minimum,omitempty"`
+	Maximum  *float64 `json:"maximum,omitempty"`
+}
+
+// Category groups products sharing attributes, such as the voltage of
+// electronics or the material of clothing. Its attribute definitions are the
+// schema the attributes of its products are validated against.
+type Category struct {
+	ID         entity.ID             `json:"id"`
+	TenantID   entity.ID             `json:"tenant_id" gorm:"uniqueIndex:idx_categories_tenant_name,priority:1"`
+	Name       string                `json:"name" gorm:"uniqueIndex:idx_categories_tenant_name,priority:2"`
+	Attributes []AttributeDefinition `json:"attributes" gorm:"serializer:json"`
+	CreatedAt  time.Time             `json:"created_at"`
+}
+
+// AttributeError is an attribute value breaking the schema of a category.
+type AttributeError struct {
+	Attribute string
+	Message   string
+}
+
+// AttributeErrors lists every attribute value breaking the schema of a
+// category.
+type AttributeErrors []AttributeError
+
+func (e AttributeErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, attrErr := range e {
+		messages[i] = attrErr.Attribute + ": " + attrErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func NewCategory(name string, attributes []AttributeDefinition) (*Category, error) {
+	category := &Category{
+		ID:         entity.NewID(),
+		Name:       strings.TrimSpace(name),
+		Attributes: attributes,
+		CreatedAt:  time.Now(),
+	}
+	if err := category.Validate(); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (c *Category) Validate() error {
+	if c.Name == "" {
+		return ErrNameIsRequired
+	}
+	if len(c.Attributes) > MaxCategoryAttributes {
+		return ErrTooManyAttributes
+	}
+	names := make(map[string]bool, len(c.Attributes))
+	for _, attribute := range c.Attributes {
+		if !attributeNamePattern.MatchString(attribute.Name) || names[attribute.Name] {
+			return ErrInvalidAttributes
+		}
+		names[attribute.Name] = true
+		switch attribute.Type {
+		case AttributeTypeString, AttributeTypeNumber, AttributeTypeInteger, AttributeTypeBoolean:
+		default:
+			return ErrInvalidAttributes
+		}
+		if len(attribute.Enum) > 0 && (attribute.Type != AttributeTypeString || !uniqueValues(attribute.Enum)) {
+			return ErrInvalidEnum
+		}
+		numeric := attribute.Type == AttributeTypeNumber || attribute.Type == AttributeTypeInteger
+		if (attribute.Minimum != nil || attribute.Maximum != nil) && !numeric {
+			return ErrInvalidRange
+		}
+		if attribute.Minimum != nil && attribute.Maximum != nil && *attribute.Minimum > *attribute.Maximum {
+			return ErrInvalidRange
+		}
+	}
+	return nil
+}
+
+// Attribute returns the definition of the attribute called name.
+func (c *Category) Attribute(name string) (AttributeDefinition, bool) {
+	for _, attribute := range c.Attributes {
+		if attribute.Name == name {
+			return attribute, true
+		}
+	}
+	return AttributeDefinition{}, false
+}
+
+// ValidateAttributes checks values, as decoded from JSON, against the
+// attributes of the category. It returns AttributeErrors listing every
+// unknown, missing or invalid attribute.
+func (c *Category) ValidateAttributes(values map[string]any) error {
+	var errs AttributeErrors
+	for _, attribute := range c.Attributes {
+		value, ok := values[attribute.Name]
+		if !ok || value == nil {
+			if attribute.Required {
+				errs = append(errs, AttributeError{attribute.Name, "is required"})
+			}
+			continue
+		}
+		if message := attribute.check(value); message != "" {
+			errs = append(errs, AttributeError{attribute.Name, message})
+		}
+	}
+	unknown := make([]string, 0)
+	for name := range values {
+		if _, ok := c.Attribute(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, AttributeError{name, "is not an attribute of the category"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (a AttributeDefinition) check(value any) string {
+	switch a.Type {
+	case AttributeTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if len(a.Enum) > 0 && !slices.Contains(a.Enum, s) {
+			return "must be one of " + strings.Join(a.Enum, ", ")
+		}
+	case AttributeTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case AttributeTypeNumber, AttributeTypeInteger:
+		n, ok := value.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		if a.Type == AttributeTypeInteger && n != math.Trunc(n) {
+			return "must be an integer"
+		}
+		if a.Minimum != nil && n < *a.Minimum {
+			return fmt.Sprintf("must be at least %g", *a.Minimum)
+		}
+		if a.Maximum != nil && n > *a.Maximum {
+			return fmt.Sprintf("must be at most %g", *a.Maximum)
+		}
+	}
+	return ""
+}
+
+// JSONSchema describes the attributes of the category as a JSON Schema
+// (draft 2020-12) object, with units under the x-unit keyword.
+func (c *Category) JSONSchema() map[string]any {
+	properties := make(map[string]any, len(c.Attributes))
+	required := make([]string, 0)
+	for _, attribute := range c.Attributes {
+		property := map[string]any{"type": attribute.Type}
+		if len(attribute.Enum) > 0 {
+			property["enum"] = attribute.Enum
+		}
+		if attribute.Minimum != nil {
+			property["minimum"] = *attribute.Minimum
+		}
+		if attribute.Maximum != nil {
+			property["maximum"] = *attribute.Maximum
+		}
+		if attribute.Unit != "" {
+			property["x-unit"] = attribute.Unit
+		}
+		properties[attribute.Name] = property
+		if attribute.Required {
+			required = append(required, attribute.Name)
+		}
+	}
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                c.Name,
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func uniqueValues(values []string) bool {
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if value == "" || seen[value] {
+			return false
+		}
+		seen[value] = true
+	}
+	return true
+}
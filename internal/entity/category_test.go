@@ -0,0 +1,96 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func newElectronics(t *testing.T) *Category {
+	category, err := NewCategory(" Electronics ", []AttributeDefinition{
+		{Name: "voltage", Type: AttributeTypeInteger, Required: true, Unit: "V", Minimum: float(0), Maximum: float(500)},
+		{Name: "weight", Type: AttributeTypeNumber, Unit: "kg"},
+		{Name: "plug", Type: AttributeTypeString, Enum: []string{"A", "C", "G"}},
+		{Name: "wireless", Type: AttributeTypeBoolean},
+	})
+	assert.Nil(t, err)
+	return category
+}
+
+func TestNewCategory(t *testing.T) {
+	category := newElectronics(t)
+	assert.Equal(t, "Electronics", category.Name)
+	assert.NotEmpty(t, category.ID)
+
+	_, err := NewCategory("", nil)
+	assert.Equal(t, ErrNameIsRequired, err)
+	_, err = NewCategory("A", []AttributeDefinition{{Name: "Voltage", Type: AttributeTypeString}})
+	assert.Equal(t, ErrInvalidAttributes, err)
+	_, err = NewCategory("A", []AttributeDefinition{{Name: "a", Type: AttributeTypeString}, {Name: "a", Type: AttributeTypeNumber}})
+	assert.Equal(t, ErrInvalidAttributes, err)
+	_, err = NewCategory("A", []AttributeDefinition{{Name: "a", Type: "date"}})
+	assert.Equal(t, ErrInvalidAttributes, err)
+	_, err = NewCategory("A", []AttributeDefinition{{Name: "a", Type: AttributeTypeNumber, Enum: []string{"1"}}})
+	assert.Equal(t, ErrInvalidEnum, err)
+	_, err = NewCategory("A", []AttributeDefinition{{Name: "a", Type: AttributeTypeString, Enum: []string{"x", "x"}}})
+	assert.Equal(t, ErrInvalidEnum, err)
+	_, err = NewCategory("A", []AttributeDefinition{{Name: "a", Type: AttributeTypeString, Minimum: float(1)}})
+	assert.Equal(t, ErrInvalidRange, err)
+	_, err = NewCategory("A", []AttributeDefinition{{Name: "a", Type: AttributeTypeNumber, Minimum: float(2), Maximum: float(1)}})
+	assert.Equal(t, ErrInvalidRange, err)
+}
+
+func TestCategoryValidateAttributes(t *testing.T) {
+	category := newElectronics(t)
+
+	assert.Nil(t, category.ValidateAttributes(map[string]any{"voltage": 220.0, "weight": 1.5, "plug": "C", "wireless": true}))
+	err := category.ValidateAttributes(map[string]any{"voltage": 220.5, "weight": "heavy", "plug": "B", "wireless": "yes", "color": "red"})
+	assert.Equal(t, AttributeErrors{
+		{"voltage", "must be an integer"},
+		{"weight", "must be a number"},
+		{"plug", "must be one of A, C, G"},
+		{"wireless", "must be a boolean"},
+		{"color", "is not an attribute of the category"},
+	}, err)
+	assert.Equal(t, AttributeErrors{{"voltage", "is required"}}, category.ValidateAttributes(nil))
+	assert.Equal(t, AttributeErrors{{"voltage", "must be at most 500"}}, category.ValidateAttributes(map[string]any{"voltage": 600.0}))
+}
+
+func TestCategoryJSONSchema(t *testing.T) {
+	schema := newElectronics(t).JSONSchema()
+	assert.Equal(t, "object", schema["type"])
+	assert.Equal(t, []string{"voltage"}, schema["required"])
+	assert.Equal(t, false, schema["additionalProperties"])
+	properties := schema["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "integer", "minimum": 0.0, "maximum": 500.0, "x-unit": "V"}, properties["voltage"])
+	assert.Equal(t, map[string]any{"type": "string", "enum": []string{"A", "C", "G"}}, properties["plug"])
+}
+
+func TestProductAttributeIndex(t *testing.T) {
+	product, _ := NewProduct("Kettle", 30)
+	product.Attributes = map[string]any{"voltage": 220.0}
+	assert.Equal(t, ErrAttributesNeedCategory, product.Validate())
+	category := newElectronics(t)
+	product.CategoryID = &category.ID
+	assert.Nil(t, product.Validate())
+
+	product.Attributes = map[string]any{"voltage": 220.0, "plug": "C", "wireless": false}
+	rows := product.AttributeIndex()
+	assert.Len(t, rows, 3)
+	for _, row := range rows {
+		assert.Equal(t, product.ID, row.ProductID)
+		switch row.Name {
+		case "voltage":
+			assert.Equal(t, 220.0, *row.Number)
+			assert.Nil(t, row.Text)
+		case "plug":
+			assert.Equal(t, "C", *row.Text)
+		case "wireless":
+			assert.Equal(t, "false", *row.Text)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package entity
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+// MaxCouponBatch bounds the coupons generated at once.
+const MaxCouponBatch = 1000
+
+const (
+	couponCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	couponCodeLength   = 10
+)
+
+var (
+	ErrInvalidCouponType   = errors.New("type must be percentage_off or fixed_off")
+	ErrInvalidCouponCode   = errors.New("code must have 4 to 32 letters, digits or dashes")
+	ErrInvalidCouponPrefix = errors.New("prefix must have up to 16 letters, digits or dashes, starting with a letter or digit")
+	ErrInvalidCouponLimits = errors.New("max_redemptions, max_per_user and min_basket cannot be negative")
+	ErrInvalidCouponCount  = errors.New("count must be between 1 and 1000")
+	ErrCouponExpired       = errors.New("coupon has expired")
+	ErrCouponExhausted     = errors.New("coupon has no redemptions left")
+	ErrCouponUserLimit     = errors.New("coupon was already redeemed the most times allowed per user")
+	ErrBasketBelowMinimum  = errors.New("basket is below the minimum of the coupon")
+)
+
+var (
+	couponCodePattern   = regexp.MustCompile(`^[A-Z0-9][A-Z0-9-]{3,31}$`)
+	couponPrefixPattern = regexp.MustCompile(`^([A-Z0-9][A-Z0-9-]{0,15})?$`)
+)
+
+// Coupon is a code taking Percent (percentage_off) or Amount (fixed_off) off
+// a basket of at least MinBasket until ExpiresAt, when set. Codes are unique
+// within the tenant and matched regardless of case. MaxRedemptions bounds
+// its redemptions by anyone, so 1 makes it single use, and MaxPerUser those
+// by each user; 0 leaves either unlimited. Coupons generated together share
+// their BatchID.
+type Coupon struct {
+	ID             entity.ID  `json:"id"`
+	TenantID       entity.ID  `json:"tenant_id" gorm:"uniqueIndex:idx_coupons_tenant_code,priority:1"`
+	Code           string     `json:"code" gorm:"uniqueIndex:idx_coupons_tenant_code,priority:2"`
+	BatchID        *entity.ID `json:"batch_id,omitempty" gorm:"index"`
+	Type           string     `json:"type"`
+	Percent        float64    `json:"percent,omitempty"`
+	Amount         float64    `json:"amount,omitempty"`
+	MinBasket      float64    `json:"min_basket,omitempty"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	MaxPerUser     int        `json:"max_per_user"`
+	Redemptions    int        `json:"redemptions"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CouponRedemption records a user redeeming a coupon on a basket.
+type CouponRedemption struct {
+	ID        entity.ID `json:"id"`
+	TenantID  entity.ID `json:"tenant_id" gorm:"index"`
+	CouponID  entity.ID `json:"coupon_id" gorm:"index:idx_coupon_redemptions_coupon_user,priority:1"`
+	UserID    entity.ID `json:"user_id" gorm:"index:idx_coupon_redemptions_coupon_user,priority:2"`
+	Basket    float64   `json:"basket"`
+	Discount  float64   `json:"discount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewCoupon creates a coupon with code, or with a generated code when code
+// is empty.
+func NewCoupon(code, couponType string) (*Coupon, error) {
+	code = NormalizeCouponCode(code)
+	if code == "" {
+		generated, err := GenerateCouponCode("")
+		if err != nil {
+			return nil, err
+		}
+		code = generated
+	}
+	return &Coupon{
+		ID:        entity.NewID(),
+		Code:      code,
+		Type:      couponType,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// NewCouponBatch creates count coupons with unique codes starting with
+// prefix, all copying the rule and limits of template.
+func NewCouponBatch(template *Coupon, prefix string, count int) ([]Coupon, error) {
+	if count < 1 || count > MaxCouponBatch {
+		return nil, ErrInvalidCouponCount
+	}
+	prefix = NormalizeCouponCode(prefix)
+	if !couponPrefixPattern.MatchString(prefix) {
+		return nil, ErrInvalidCouponPrefix
+	}
+	batchID := entity.NewID()
+	coupons := make([]Coupon, 0, count)
+	codes := make(map[string]bool, count)
+	for len(coupons) < count {
+		code, err := GenerateCouponCode(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if codes[code] {
+			continue
+		}
+		codes[code] = true
+		coupon := *template
+		coupon.ID, coupon.Code, coupon.BatchID = entity.NewID(), code, &batchID
+		coupon.Redemptions, coupon.CreatedAt = 0, time.Now()
+		coupons = append(coupons, coupon)
+	}
+	return coupons, nil
+}
+
+// GenerateCouponCode returns prefix followed by random characters that are
+// hard to mistake for one another, so codes read out or typed by hand work.
+func GenerateCouponCode(prefix string) (string, error) {
+	raw := make([]byte, couponCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := make([]byte, couponCodeLength)
+	for i, b := range raw {
+		// The alphabet has 32 characters, so every byte maps without bias.
+		code[i] = couponCodeAlphabet[int(b)%len(couponCodeAlphabet)]
+	}
+	return prefix + string(code), nil
+}
+
+// NormalizeCouponCode uppercases code as codes are matched regardless of
+// case.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (c *Coupon) Validate() error {
+	if !couponCodePattern.MatchString(c.Code) {
+		return ErrInvalidCouponCode
+	}
+	return c.ValidateRules()
+}
+
+// ValidateRules checks the rule and limits of the coupon, but not its code,
+// so they can be checked before codes are generated.
+func (c *Coupon) ValidateRules() error {
+	switch c.Type {
+	case PromotionPercentageOff:
+		if !validPercent(c.Percent) {
+			return ErrInvalidPercent
+		}
+	case PromotionFixedOff:
+		if c.Amount <= 0 {
+			return ErrInvalidAmountOff
+		}
+	default:
+		return ErrInvalidCouponType
+	}
+	if c.MaxRedemptions < 0 || c.MaxPerUser < 0 || c.MinBasket < 0 {
+		return ErrInvalidCouponLimits
+	}
+	return nil
+}
+
+// SetExpiration sets when the coupon expires, in UTC; nil never expires it.
+func (c *Coupon) SetExpiration(expiresAt *time.Time) {
+	c.ExpiresAt = utc(expiresAt)
+}
+
+// Discount is what the coupon takes off basket at t, rounded to cents and
+// at most the basket. It does not check the redemption limits, which only
+// the repository can enforce.
+func (c *Coupon) Discount(basket float64, t time.Time) (float64, error) {
+	if c.ExpiresAt != nil && !t.Before(*c.ExpiresAt) {
+		return 0, ErrCouponExpired
+	}
+	if basket < c.MinBasket {
+		return 0, ErrBasketBelowMinimum
+	}
+	var discount float64
+	switch c.Type {
+	case PromotionPercentageOff:
+		discount = basket * c.Percent / 100
+	case PromotionFixedOff:
+		discount = c.Amount
+	}
+	return roundCents(math.Min(discount, basket)), nil
+}
+
+func NewCouponRedemption(coupon *Coupon, userID entity.ID, basket, discount float64) *CouponRedemption {
+	return &CouponRedemption{
+		ID:        entity.NewID(),
+		TenantID:  coupon.TenantID,
+		CouponID:  coupon.ID,
+		UserID:    userID,
+		Basket:    basket,
+		Discount:  discount,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Total is what remains of the basket after the discount.
+func (r *CouponRedemption) Total() float64 {
+	return roundCents(r.Basket - r.Discount)
+}
@@ -0,0 +1,87 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCoupon(t *testing.T) {
+	coupon, err := NewCoupon(" summer-10 ", PromotionPercentageOff)
+	assert.Nil(t, err)
+	assert.Equal(t, "SUMMER-10", coupon.Code)
+	coupon.Percent = 10
+	assert.Nil(t, coupon.Validate())
+
+	coupon, err = NewCoupon("", PromotionFixedOff)
+	assert.Nil(t, err)
+	assert.Len(t, coupon.Code, 10)
+	coupon.Amount = 5
+	assert.Nil(t, coupon.Validate())
+}
+
+func TestCouponValidate(t *testing.T) {
+	coupon := &Coupon{Code: "SAVE", Type: PromotionPercentageOff, Percent: 10}
+	assert.Nil(t, coupon.Validate())
+	coupon.Code = "AB"
+	assert.Equal(t, ErrInvalidCouponCode, coupon.Validate())
+	coupon.Code = "SAVE_10"
+	assert.Equal(t, ErrInvalidCouponCode, coupon.Validate())
+	coupon.Code = "SAVE"
+	coupon.Type = PromotionTiered
+	assert.Equal(t, ErrInvalidCouponType, coupon.Validate())
+	coupon.Type, coupon.Percent = PromotionPercentageOff, 0
+	assert.Equal(t, ErrInvalidPercent, coupon.Validate())
+	coupon.Type = PromotionFixedOff
+	assert.Equal(t, ErrInvalidAmountOff, coupon.Validate())
+	coupon.Amount, coupon.MaxPerUser = 5, -1
+	assert.Equal(t, ErrInvalidCouponLimits, coupon.Validate())
+}
+
+func TestNewCouponBatch(t *testing.T) {
+	template := &Coupon{Type: PromotionFixedOff, Amount: 5, MaxRedemptions: 1, Redemptions: 3}
+	coupons, err := NewCouponBatch(template, "xmas-", 200)
+	assert.Nil(t, err)
+	assert.Len(t, coupons, 200)
+	codes := make(map[string]bool)
+	for _, coupon := range coupons {
+		assert.True(t, strings.HasPrefix(coupon.Code, "XMAS-"))
+		assert.Nil(t, coupon.Validate())
+		assert.Equal(t, *coupons[0].BatchID, *coupon.BatchID)
+		assert.Equal(t, 1, coupon.MaxRedemptions)
+		assert.Zero(t, coupon.Redemptions)
+		codes[coupon.Code] = true
+	}
+	assert.Len(t, codes, 200)
+
+	_, err = NewCouponBatch(template, "", 0)
+	assert.Equal(t, ErrInvalidCouponCount, err)
+	_, err = NewCouponBatch(template, "", MaxCouponBatch+1)
+	assert.Equal(t, ErrInvalidCouponCount, err)
+	_, err = NewCouponBatch(template, "-XMAS", 1)
+	assert.Equal(t, ErrInvalidCouponPrefix, err)
+}
+
+func TestCouponDiscount(t *testing.T) {
+	now := time.Now()
+	coupon := &Coupon{Code: "SAVE", Type: PromotionPercentageOff, Percent: 15, MinBasket: 20}
+	discount, err := coupon.Discount(33.33, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 5.0, discount)
+	_, err = coupon.Discount(19.99, now)
+	assert.Equal(t, ErrBasketBelowMinimum, err)
+
+	coupon = &Coupon{Code: "SAVE", Type: PromotionFixedOff, Amount: 30}
+	discount, err = coupon.Discount(25, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 25.0, discount)
+
+	coupon.SetExpiration(&now)
+	_, err = coupon.Discount(25, now)
+	assert.Equal(t, ErrCouponExpired, err)
+	discount, err = coupon.Discount(25, now.Add(-time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, 25.0, discount)
+}
@@ -0,0 +1,170 @@
+package entity
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+const (
+	MediaKindImage    = "image"
+	MediaKindDocument = "document"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("only JPEG, PNG and GIF images and PDF, plain text and CSV documents are supported")
+	ErrInvalidMediaOrder    = errors.New("order must list every media of the product once")
+)
+
+// mediaTypes maps the accepted content types to their kind and the
+// extension of the stored files.
+var mediaTypes = map[string]struct{ kind, ext string }{
+	"image/jpeg":      {MediaKindImage, ".jpg"},
+	"image/png":       {MediaKindImage, ".png"},
+	"image/gif":       {MediaKindImage, ".gif"},
+	"application/pdf": {MediaKindDocument, ".pdf"},
+	"text/plain":      {MediaKindDocument, ".txt"},
+	"text/csv":        {MediaKindDocument, ".csv"},
+}
+
+// Media is an image or document attached to a product, listed by Position.
+// The file lives in blob storage under Key, and the thumbnails of images
+// next to it; URLs are set when media is served.
+type Media struct {
+	ID          entity.ID        `json:"id"`
+	TenantID    entity.ID        `json:"tenant_id" gorm:"index"`
+	ProductID   entity.ID        `json:"product_id" gorm:"index"`
+	Kind        string           `json:"kind"`
+	Filename    string           `json:"filename"`
+	ContentType string           `json:"content_type"`
+	Size        int64            `json:"size"`
+	Width       int              `json:"width,omitempty"`
+	Height      int              `json:"height,omitempty"`
+	Key         string           `json:"-"`
+	Thumbnails  []MediaThumbnail `json:"thumbnails,omitempty" gorm:"serializer:json"`
+	Position    int              `json:"position"`
+	URL         string           `json:"url" gorm:"-"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// MediaThumbnail is a downscaled copy of an image fitting in a Size square.
+type MediaThumbnail struct {
+	Size   int    `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url,omitempty"`
+}
+
+// MediaKind returns the kind of media of a content type, or an empty string
+// when it is not accepted.
+func MediaKind(contentType string) string {
+	return mediaTypes[contentType].kind
+}
+
+func NewMedia(product *Product, filename, contentType string, size int64) (*Media, error) {
+	mediaType, ok := mediaTypes[contentType]
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+	id := entity.NewID()
+	return &Media{
+		ID:          id,
+		TenantID:    product.TenantID,
+		ProductID:   product.ID,
+		Kind:        mediaType.kind,
+		Filename:    cleanFilename(filename, mediaType.ext),
+		ContentType: contentType,
+		Size:        size,
+		Key:         "tenants/" + product.TenantID.String() + "/products/" + product.ID.String() + "/media/" + id.String() + "/original" + mediaType.ext,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// MediaKeyOwner returns the tenant and product whose media a blob key names,
+// which keys of NewMedia and ThumbnailKey start with.
+func MediaKeyOwner(key string) (tenantID, productID entity.ID, ok bool) {
+	parts := strings.SplitN(key, "/", 5)
+	if len(parts) < 5 || parts[0] != "tenants" || parts[2] != "products" {
+		return entity.ID{}, entity.ID{}, false
+	}
+	tenantID, err := entity.ParseID(parts[1])
+	if err != nil {
+		return entity.ID{}, entity.ID{}, false
+	}
+	productID, err = entity.ParseID(parts[3])
+	if err != nil {
+		return entity.ID{}, entity.ID{}, false
+	}
+	return tenantID, productID, true
+}
+
+func (m *Media) IsImage() bool {
+	return m.Kind == MediaKindImage
+}
+
+// ThumbnailKey is where the thumbnail of a size is stored. JPEG images have
+// JPEG thumbnails and the others PNG ones.
+func (m *Media) ThumbnailKey(size int) string {
+	ext := ".png"
+	if m.ContentType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	return path.Dir(m.Key) + "/" + strconv.Itoa(size) + ext
+}
+
+// Keys lists the blobs of the media.
+func (m *Media) Keys() []string {
+	keys := []string{m.Key}
+	for _, thumbnail := range m.Thumbnails {
+		keys = append(keys, m.ThumbnailKey(thumbnail.Size))
+	}
+	return keys
+}
+
+// ReorderMedia sets the positions of media to the order of ids, which must
+// list each of them once.
+func ReorderMedia(media []Media, ids []string) error {
+	if len(ids) != len(media) {
+		return ErrInvalidMediaOrder
+	}
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		if _, ok := positions[id]; ok {
+			return ErrInvalidMediaOrder
+		}
+		positions[id] = i
+	}
+	for i := range media {
+		position, ok := positions[media[i].ID.String()]
+		if !ok {
+			return ErrInvalidMediaOrder
+		}
+		media[i].Position = position
+	}
+	return nil
+}
+
+// cleanFilename keeps the base name of an uploaded file without control
+// characters, falling back to one made of ext.
+func cleanFilename(filename, ext string) string {
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename)
+	filename = strings.TrimSpace(filename)
+	if len(filename) > 255 {
+		filename = filename[len(filename)-255:]
+	}
+	if filename == "" || filename == "." || filename == "/" {
+		return "file" + ext
+	}
+	return filename
+}
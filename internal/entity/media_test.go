@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMedia(t *testing.T) {
+	product, _ := NewProduct("Shirt", 20)
+
+	media, err := NewMedia(product, `C:\photos\front.jpeg`, "image/jpeg", 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, MediaKindImage, media.Kind)
+	assert.True(t, media.IsImage())
+	assert.Equal(t, "front.jpeg", media.Filename)
+	assert.Equal(t, "tenants/"+product.TenantID.String()+"/products/"+product.ID.String()+"/media/"+media.ID.String()+"/original.jpg", media.Key)
+	assert.Equal(t, "tenants/"+product.TenantID.String()+"/products/"+product.ID.String()+"/media/"+media.ID.String()+"/200.jpg", media.ThumbnailKey(200))
+	media.Thumbnails = []MediaThumbnail{{Size: 200}}
+	assert.Len(t, media.Keys(), 2)
+	for _, key := range media.Keys() {
+		tenantID, productID, ok := MediaKeyOwner(key)
+		assert.True(t, ok)
+		assert.Equal(t, product.TenantID, tenantID)
+		assert.Equal(t, product.ID, productID)
+	}
+	_, _, ok := MediaKeyOwner("tenants/x/products/" + product.ID.String() + "/media/a")
+	assert.False(t, ok)
+	_, _, ok = MediaKeyOwner("other/" + product.TenantID.String())
+	assert.False(t, ok)
+
+	media, err = NewMedia(product, "../../\x00", "application/pdf", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, MediaKindDocument, media.Kind)
+	assert.Equal(t, "file.pdf", media.Filename)
+
+	_, err = NewMedia(product, "run.sh", "text/x-shellscript", 10)
+	assert.Equal(t, ErrUnsupportedMediaType, err)
+}
+
+func TestReorderMedia(t *testing.T) {
+	product, _ := NewProduct("Shirt", 20)
+	first, _ := NewMedia(product, "a.png", "image/png", 1)
+	second, _ := NewMedia(product, "b.png", "image/png", 1)
+	second.Position = 1
+	media := []Media{*first, *second}
+
+	assert.Nil(t, ReorderMedia(media, []string{second.ID.String(), first.ID.String()}))
+	assert.Equal(t, 1, media[0].Position)
+	assert.Equal(t, 0, media[1].Position)
+
+	assert.Equal(t, ErrInvalidMediaOrder, ReorderMedia(media, []string{first.ID.String()}))
+	assert.Equal(t, ErrInvalidMediaOrder, ReorderMedia(media, []string{first.ID.String(), first.ID.String()}))
+	assert.Equal(t, ErrInvalidMediaOrder, ReorderMedia(media, []string{first.ID.String(), product.ID.String()}))
+}
@@ -0,0 +1,144 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/ivandersr/products-api-go/pkg/totp"
+)
+
+const (
+	// RecoveryCodeCount is how many recovery codes are issued at once.
+	RecoveryCodeCount = 10
+
+	// totpSkew accepts codes from the previous and next period to absorb
+	// clock drift between the server and the authenticator.
+	totpSkew = 1
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication enrollment was not started")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code")
+)
+
+func (u *User) IsMFAEnabled() bool {
+	return u.MFAEnabledAt != nil
+}
+
+// StartTOTPEnrollment generates a new TOTP secret, which only protects the
+// account once confirmed with ConfirmTOTP.
+func (u *User) StartTOTPEnrollment() (string, error) {
+	if u.IsMFAEnabled() {
+		return "", ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	u.TOTPSecret = secret
+	u.TOTPLastStep = 0
+	return secret, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves the
+// authenticator was set up, and invalidates the sessions opened without it.
+func (u *User) ConfirmTOTP(code string, now time.Time) error {
+	if u.IsMFAEnabled() {
+		return ErrMFAAlreadyEnabled
+	}
+	if u.TOTPSecret == "" {
+		return ErrMFANotEnrolled
+	}
+	step, ok := totp.Validate(u.TOTPSecret, code, now, totpSkew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	u.TOTPLastStep = step
+	u.MFAEnabledAt = &now
+	u.InvalidateSessions()
+	return nil
+}
+
+// VerifyTOTP checks a code of an enabled authenticator. Each code is only
+// accepted once, so an intercepted code cannot be replayed.
+func (u *User) VerifyTOTP(code string, now time.Time) bool {
+	if !u.IsMFAEnabled() {
+		return false
+	}
+	step, ok := totp.Validate(u.TOTPSecret, code, now, totpSkew)
+	if !ok || step <= u.TOTPLastStep {
+		return false
+	}
+	u.TOTPLastStep = step
+	return true
+}
+
+func (u *User) DisableMFA() {
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	u.MFAEnabledAt = nil
+	u.InvalidateSessions()
+}
+
+// RecoveryCode is a single-use code replacing a TOTP code when the
+// authenticator is lost. Only its hash is stored.
+type RecoveryCode struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewRecoveryCodes generates RecoveryCodeCount codes for userID and returns
+// them along with their plain text values to hand to the user.
+func NewRecoveryCodes(userID entity.ID) ([]RecoveryCode, []string, error) {
+	codes := make([]RecoveryCode, RecoveryCodeCount)
+	plain := make([]string, RecoveryCodeCount)
+	now := time.Now()
+	for i := range codes {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		value := hex.EncodeToString(raw)
+		plain[i] = value[:8] + "-" + value[8:]
+		codes[i] = RecoveryCode{
+			ID:        entity.NewID(),
+			UserID:    userID,
+			CodeHash:  HashRecoveryCode(plain[i]),
+			CreatedAt: now,
+		}
+	}
+	return codes, plain, nil
+}
+
+// HashRecoveryCode normalizes code as users tend to type it and hashes it.
+// Codes carry 64 random bits, so a fast hash is enough.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// MFAPolicy records whether users of a role must use two-factor
+// authentication.
+type MFAPolicy struct {
+	Role      string    `json:"role" gorm:"primaryKey"`
+	Required  bool      `json:"required"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewMFAPolicy(role string, required bool) (*MFAPolicy, error) {
+	if !IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	return &MFAPolicy{Role: role, Required: required, UpdatedAt: time.Now()}, nil
+}
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPEnrollment(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "123456")
+	now := time.Now()
+	assert.Equal(t, ErrMFANotEnrolled, user.ConfirmTOTP("000000", now))
+
+	secret, err := user.StartTOTPEnrollment()
+	assert.Nil(t, err)
+	assert.False(t, user.IsMFAEnabled())
+	assert.Equal(t, ErrInvalidMFACode, user.ConfirmTOTP("abcdef", now))
+
+	version := user.TokenVersion
+	code, _ := totp.Code(secret, now)
+	assert.Nil(t, user.ConfirmTOTP(code, now))
+	assert.True(t, user.IsMFAEnabled())
+	assert.Equal(t, version+1, user.TokenVersion)
+
+	_, err = user.StartTOTPEnrollment()
+	assert.Equal(t, ErrMFAAlreadyEnabled, err)
+}
+
+func TestVerifyTOTPRejectsReplays(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "123456")
+	secret, _ := user.StartTOTPEnrollment()
+	now := time.Now()
+	code, _ := totp.Code(secret, now)
+	assert.Nil(t, user.ConfirmTOTP(code, now))
+	assert.False(t, user.VerifyTOTP(code, now))
+
+	later := now.Add(totp.Period)
+	code, _ = totp.Code(secret, later)
+	assert.True(t, user.VerifyTOTP(code, later))
+	assert.False(t, user.VerifyTOTP(code, later))
+
+	user.DisableMFA()
+	assert.False(t, user.IsMFAEnabled())
+	assert.Empty(t, user.TOTPSecret)
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "123456")
+	codes, plain, err := NewRecoveryCodes(user.ID)
+	assert.Nil(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	assert.Len(t, plain, RecoveryCodeCount)
+	assert.Equal(t, codes[0].CodeHash, HashRecoveryCode(plain[0]))
+	assert.Equal(t, codes[0].CodeHash, HashRecoveryCode(" "+plain[0][:8]+plain[0][9:]))
+	assert.NotEqual(t, codes[0].CodeHash, codes[1].CodeHash)
+}
+
+func TestNewMFAPolicy(t *testing.T) {
+	policy, err := NewMFAPolicy(RoleAdmin, true)
+	assert.Nil(t, err)
+	assert.True(t, policy.Required)
+	_, err = NewMFAPolicy("root", true)
+	assert.Equal(t, ErrInvalidRole, err)
+}
@@ -0,0 +1,304 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+
+	PKCEMethodS256 = "S256"
+
+	TokenPurposeOAuthCode         = "oauth_code"
+	TokenPurposeOAuthRefreshToken = "oauth_refresh_token"
+)
+
+var (
+	ErrInvalidRedirectURI   = errors.New("invalid redirect uri")
+	ErrInvalidGrantType     = errors.New("invalid grant type")
+	ErrRedirectURIRequired  = errors.New("authorization code clients need a redirect uri")
+	ErrOwnerRequired        = errors.New("client credentials clients need an owner")
+	ErrPublicClientGrant    = errors.New("public clients cannot use the client credentials grant")
+	ErrInvalidCodeChallenge = errors.New("invalid code challenge")
+)
+
+// OAuthScopes lists the scopes third-party clients may request.
+var OAuthScopes = append([]string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}, Scopes...)
+
+var grantTypes = []string{GrantAuthorizationCode, GrantClientCredentials, GrantRefreshToken}
+
+// OAuthClient is an application registered to obtain tokens through OAuth2.
+// Confidential clients authenticate with a secret, of which only a hash is
+// stored; public clients, such as mobile apps, rely on PKCE alone. Tokens of
+// the client credentials grant act on behalf of the client owner.
+type OAuthClient struct {
+	ID           entity.ID  `json:"client_id"`
+	Name         string     `json:"name"`
+	SecretHash   string     `json:"-"`
+	Confidential bool       `json:"confidential"`
+	RedirectURIs []string   `json:"redirect_uris" gorm:"serializer:json"`
+	GrantTypes   []string   `json:"grant_types" gorm:"serializer:json"`
+	Scopes       []string   `json:"scopes" gorm:"serializer:json"`
+	OwnerID      *entity.ID `json:"owner_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// NewOAuthClient registers a client and returns it along with its plain text
+// secret, empty for public clients.
+func NewOAuthClient(name string, confidential bool, redirectURIs, grants, scopes []string, ownerID *entity.ID) (*OAuthClient, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrNameIsRequired
+	}
+	for _, grant := range grants {
+		if !slices.Contains(grantTypes, grant) {
+			return nil, "", ErrInvalidGrantType
+		}
+	}
+	if len(grants) == 0 {
+		return nil, "", ErrInvalidGrantType
+	}
+	for _, uri := range redirectURIs {
+		if !isValidRedirectURI(uri) {
+			return nil, "", ErrInvalidRedirectURI
+		}
+	}
+	if slices.Contains(grants, GrantAuthorizationCode) && len(redirectURIs) == 0 {
+		return nil, "", ErrRedirectURIRequired
+	}
+	if slices.Contains(grants, GrantClientCredentials) {
+		if !confidential {
+			return nil, "", ErrPublicClientGrant
+		}
+		if ownerID == nil {
+			return nil, "", ErrOwnerRequired
+		}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(OAuthScopes, scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+	client := &OAuthClient{
+		ID:           entity.NewID(),
+		Name:         name,
+		Confidential: confidential,
+		RedirectURIs: redirectURIs,
+		GrantTypes:   grants,
+		Scopes:       scopes,
+		OwnerID:      ownerID,
+		CreatedAt:    time.Now(),
+	}
+	if !confidential {
+		return client, "", nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	plain := base64.RawURLEncoding.EncodeToString(secret)
+	client.SecretHash = hashOAuthSecret(plain)
+	return client, plain, nil
+}
+
+// ValidateSecret compares secret with the stored hash in constant time.
+// Public clients have no secret and never validate.
+func (c *OAuthClient) ValidateSecret(secret string) bool {
+	if !c.Confidential || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.SecretHash), []byte(hashOAuthSecret(secret))) == 1
+}
+
+func (c *OAuthClient) AllowsGrant(grant string) bool {
+	return slices.Contains(c.GrantTypes, grant)
+}
+
+// AllowsRedirectURI compares uri with the registered ones exactly, as
+// partial matching enables open redirects.
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+// AllowsScopes reports whether every requested scope was granted to the
+// client at registration.
+func (c *OAuthClient) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuthConsent records the scopes a user granted to a client, so the user is
+// only asked again when the client requests more.
+type OAuthConsent struct {
+	ID        entity.ID `json:"id"`
+	UserID    entity.ID `json:"user_id" gorm:"uniqueIndex:idx_oauth_consent"`
+	ClientID  entity.ID `json:"client_id" gorm:"uniqueIndex:idx_oauth_consent"`
+	Scopes    []string  `json:"scopes" gorm:"serializer:json"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewOAuthConsent(userID, clientID entity.ID, scopes []string) *OAuthConsent {
+	now := time.Now()
+	return &OAuthConsent{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		ClientID:  clientID,
+		Scopes:    scopes,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Grant adds scopes to the consent.
+func (c *OAuthConsent) Grant(scopes []string) {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			c.Scopes = append(c.Scopes, scope)
+		}
+	}
+	c.UpdatedAt = time.Now()
+}
+
+// OAuthAuthorizationCode is the single-use code of the authorization code
+// grant. It remembers the PKCE challenge and how the user authenticated, so
+// the tokens it is exchanged for carry the same amr.
+type OAuthAuthorizationCode struct {
+	ID            entity.ID  `json:"id"`
+	ClientID      entity.ID  `json:"client_id" gorm:"index"`
+	UserID        entity.ID  `json:"user_id" gorm:"index"`
+	RedirectURI   string     `json:"redirect_uri"`
+	Scopes        []string   `json:"scopes" gorm:"serializer:json"`
+	CodeChallenge string     `json:"-"`
+	Nonce         string     `json:"-"`
+	AMR           []string   `json:"amr" gorm:"serializer:json"`
+	AuthTime      time.Time  `json:"auth_time"`
+	TenantID      *entity.ID `json:"tenant_id,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NewOAuthAuthorizationCode requires an S256 PKCE challenge: PKCE protects
+// public and confidential clients alike against code interception.
+func NewOAuthAuthorizationCode(clientID, userID entity.ID, redirectURI string, scopes []string, codeChallenge, method, nonce string, amr []string, ttl time.Duration) (*OAuthAuthorizationCode, error) {
+	if method != PKCEMethodS256 || len(codeChallenge) != 43 {
+		return nil, ErrInvalidCodeChallenge
+	}
+	if _, err := base64.RawURLEncoding.DecodeString(codeChallenge); err != nil {
+		return nil, ErrInvalidCodeChallenge
+	}
+	now := time.Now()
+	return &OAuthAuthorizationCode{
+		ID:            entity.NewID(),
+		ClientID:      clientID,
+		UserID:        userID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		CodeChallenge: codeChallenge,
+		Nonce:         nonce,
+		AMR:           amr,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+	}, nil
+}
+
+// VerifyCodeVerifier checks the PKCE verifier against the stored challenge.
+func (c *OAuthAuthorizationCode) VerifyCodeVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) == 1
+}
+
+// OAuthRefreshToken lets a client obtain new access tokens without the user.
+// Refresh tokens rotate: each use revokes the token and issues a new one.
+// They die with the user's sessions, tracked through TokenVersion.
+type OAuthRefreshToken struct {
+	ID           entity.ID  `json:"id"`
+	ClientID     entity.ID  `json:"client_id" gorm:"index"`
+	UserID       entity.ID  `json:"user_id" gorm:"index"`
+	Scopes       []string   `json:"scopes" gorm:"serializer:json"`
+	AMR          []string   `json:"amr" gorm:"serializer:json"`
+	TenantID     *entity.ID `json:"tenant_id,omitempty"`
+	TokenVersion int        `json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func NewOAuthRefreshToken(clientID entity.ID, user *User, scopes, amr []string, ttl time.Duration) *OAuthRefreshToken {
+	now := time.Now()
+	return &OAuthRefreshToken{
+		ID:           entity.NewID(),
+		ClientID:     clientID,
+		UserID:       user.ID,
+		Scopes:       scopes,
+		AMR:          amr,
+		TokenVersion: user.TokenVersion,
+		ExpiresAt:    now.Add(ttl),
+		CreatedAt:    now,
+	}
+}
+
+// IsActive reports whether the token can still be used by user.
+func (t *OAuthRefreshToken) IsActive(user *User) bool {
+	return t.RevokedAt == nil && t.ExpiresAt.After(time.Now()) &&
+		!user.IsDisabled() && user.TokenVersion == t.TokenVersion
+}
+
+// ParseScope splits a space-delimited OAuth scope parameter.
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+func isValidRedirectURI(uri string) bool {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme == "" || parsed.Fragment != "" {
+		return false
+	}
+	if parsed.Scheme == "http" || parsed.Scheme == "https" {
+		return parsed.Host != ""
+	}
+	// Custom schemes of native apps.
+	return true
+}
+
+func hashOAuthSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
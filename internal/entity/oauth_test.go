@@ -0,0 +1,85 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOAuthClient(t *testing.T) {
+	client, secret, err := NewOAuthClient("Partner", true, []string{"https://partner.example/callback"},
+		[]string{GrantAuthorizationCode, GrantRefreshToken}, []string{ScopeOpenID, ScopeProductsRead}, nil)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, secret)
+	assert.True(t, client.ValidateSecret(secret))
+	assert.False(t, client.ValidateSecret(secret+"x"))
+	assert.True(t, client.AllowsGrant(GrantRefreshToken))
+	assert.False(t, client.AllowsGrant(GrantClientCredentials))
+	assert.True(t, client.AllowsRedirectURI("https://partner.example/callback"))
+	assert.False(t, client.AllowsRedirectURI("https://partner.example/callback/../evil"))
+	assert.True(t, client.AllowsScopes([]string{ScopeProductsRead}))
+	assert.False(t, client.AllowsScopes([]string{ScopeProductsWrite}))
+}
+
+func TestNewPublicOAuthClient(t *testing.T) {
+	client, secret, err := NewOAuthClient("Mobile", false, []string{"com.partner.app:/callback"},
+		[]string{GrantAuthorizationCode}, []string{ScopeOpenID}, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, secret)
+	assert.False(t, client.ValidateSecret(""))
+}
+
+func TestNewOAuthClientValidation(t *testing.T) {
+	owner := entity.NewID()
+	_, _, err := NewOAuthClient("", true, nil, []string{GrantClientCredentials}, nil, &owner)
+	assert.Equal(t, ErrNameIsRequired, err)
+	_, _, err = NewOAuthClient("App", true, nil, []string{"password"}, nil, &owner)
+	assert.Equal(t, ErrInvalidGrantType, err)
+	_, _, err = NewOAuthClient("App", true, nil, []string{GrantAuthorizationCode}, nil, nil)
+	assert.Equal(t, ErrRedirectURIRequired, err)
+	_, _, err = NewOAuthClient("App", true, []string{"https://app.example/cb#frag"}, []string{GrantAuthorizationCode}, nil, nil)
+	assert.Equal(t, ErrInvalidRedirectURI, err)
+	_, _, err = NewOAuthClient("App", false, nil, []string{GrantClientCredentials}, nil, &owner)
+	assert.Equal(t, ErrPublicClientGrant, err)
+	_, _, err = NewOAuthClient("App", true, nil, []string{GrantClientCredentials}, nil, nil)
+	assert.Equal(t, ErrOwnerRequired, err)
+	_, _, err = NewOAuthClient("App", true, nil, []string{GrantClientCredentials}, []string{"admin"}, &owner)
+	assert.Equal(t, ErrInvalidScope, err)
+}
+
+func TestOAuthAuthorizationCodePKCE(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	_, err := NewOAuthAuthorizationCode(entity.NewID(), entity.NewID(), "https://app.example/cb", nil, challenge, "plain", "", nil, time.Minute)
+	assert.Equal(t, ErrInvalidCodeChallenge, err)
+	_, err = NewOAuthAuthorizationCode(entity.NewID(), entity.NewID(), "https://app.example/cb", nil, "short", PKCEMethodS256, "", nil, time.Minute)
+	assert.Equal(t, ErrInvalidCodeChallenge, err)
+
+	code, err := NewOAuthAuthorizationCode(entity.NewID(), entity.NewID(), "https://app.example/cb", nil, challenge, PKCEMethodS256, "", nil, time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, code.VerifyCodeVerifier(verifier))
+	assert.False(t, code.VerifyCodeVerifier(strings.Repeat("b", 43)))
+}
+
+func TestOAuthConsent(t *testing.T) {
+	consent := NewOAuthConsent(entity.NewID(), entity.NewID(), []string{ScopeOpenID})
+	assert.True(t, consent.Covers([]string{ScopeOpenID}))
+	assert.False(t, consent.Covers([]string{ScopeOpenID, ScopeEmail}))
+	consent.Grant([]string{ScopeEmail, ScopeOpenID})
+	assert.Equal(t, []string{ScopeOpenID, ScopeEmail}, consent.Scopes)
+}
+
+func TestOAuthRefreshTokenIsActive(t *testing.T) {
+	user, _ := NewUser("John", "j@j.com", "123456")
+	token := NewOAuthRefreshToken(entity.NewID(), user, nil, nil, time.Hour)
+	assert.True(t, token.IsActive(user))
+	user.InvalidateSessions()
+	assert.False(t, token.IsActive(user))
+}
@@ -0,0 +1,103 @@
+package entity
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrPasswordTooShort      = errors.New("password is too short")
+	ErrPasswordTooLong       = errors.New("password is too long")
+	ErrPasswordMissingUpper  = errors.New("password must contain an uppercase letter")
+	ErrPasswordMissingLower  = errors.New("password must contain a lowercase letter")
+	ErrPasswordMissingDigit  = errors.New("password must contain a digit")
+	ErrPasswordMissingSymbol = errors.New("password must contain a symbol")
+	ErrPasswordTooCommon     = errors.New("password is too common")
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	denylist      map[string]struct{}
+}
+
+// NewPasswordPolicy returns a policy denying the built-in list of common
+// passwords.
+func NewPasswordPolicy(minLength int, requireUpper, requireLower, requireDigit, requireSymbol bool) *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength:     minLength,
+		RequireUpper:  requireUpper,
+		RequireLower:  requireLower,
+		RequireDigit:  requireDigit,
+		RequireSymbol: requireSymbol,
+		denylist:      map[string]struct{}{},
+	}
+	p.LoadDenylist(strings.NewReader(commonPasswords))
+	return p
+}
+
+// LoadDenylist adds every non empty line of r to the denied passwords.
+func (p *PasswordPolicy) LoadDenylist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.denylist[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("loading password denylist: %w", err)
+	}
+	return nil
+}
+
+func (p *PasswordPolicy) Validate(password string) error {
+	if password == "" {
+		return ErrPasswordIsRequired
+	}
+	if len([]rune(password)) < p.MinLength {
+		return ErrPasswordTooShort
+	}
+	if limit := passwordHasher.MaxPasswordLength(); limit > 0 && len(password) > limit {
+		return ErrPasswordTooLong
+	}
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		return ErrPasswordMissingUpper
+	}
+	if p.RequireLower && !hasLower {
+		return ErrPasswordMissingLower
+	}
+	if p.RequireDigit && !hasDigit {
+		return ErrPasswordMissingDigit
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return ErrPasswordMissingSymbol
+	}
+	if _, denied := p.denylist[strings.ToLower(password)]; denied {
+		return ErrPasswordTooCommon
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ivandersr/products-api-go/pkg/passwordhash"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := NewPasswordPolicy(8, true, true, true, true)
+	assert.Nil(t, policy.Validate("Str0ng!Pass"))
+	assert.Equal(t, ErrPasswordIsRequired, policy.Validate(""))
+	assert.Equal(t, ErrPasswordTooShort, policy.Validate("S0!a"))
+	assert.Equal(t, ErrPasswordTooLong, policy.Validate("S0!a"+strings.Repeat("a", 80)))
+	assert.Equal(t, ErrPasswordMissingUpper, policy.Validate("str0ng!pass"))
+	assert.Equal(t, ErrPasswordMissingLower, policy.Validate("STR0NG!PASS"))
+	assert.Equal(t, ErrPasswordMissingDigit, policy.Validate("Strong!Pass"))
+	assert.Equal(t, ErrPasswordMissingSymbol, policy.Validate("Str0ngPass"))
+}
+
+func TestPasswordPolicyDenylist(t *testing.T) {
+	policy := NewPasswordPolicy(6, false, false, false, false)
+	assert.Equal(t, ErrPasswordTooCommon, policy.Validate("Password1"))
+	assert.Nil(t, policy.Validate("correct horse battery"))
+
+	assert.Nil(t, policy.LoadDenylist(strings.NewReader("correct horse battery\n\n")))
+	assert.Equal(t, ErrPasswordTooCommon, policy.Validate("Correct Horse Battery"))
+}
+
+func TestPasswordPolicyMaxLengthFollowsHasher(t *testing.T) {
+	policy := NewPasswordPolicy(8, false, false, false, false)
+	long := "correct horse battery staple " + strings.Repeat("x", 60)
+	assert.Equal(t, ErrPasswordTooLong, policy.Validate(long))
+
+	hasher, err := passwordhash.NewArgon2id(passwordhash.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1})
+	assert.Nil(t, err)
+	SetPasswordHasher(hasher)
+	t.Cleanup(func() { SetPasswordHasher(&passwordhash.Bcrypt{Cost: bcrypt.DefaultCost}) })
+	assert.Nil(t, policy.Validate(long))
+}
@@ -0,0 +1,185 @@
+package entity
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+var (
+	ErrInvalidPriceWindow = errors.New("ends_at must be after starts_at")
+	ErrPriceNotScheduled  = errors.New("starts_at must be in the future")
+)
+
+// ProductPrice is a price of a product from StartsAt until EndsAt, or until
+// a later price takes over when EndsAt is nil. Prices overlap: the latest
+// started price in effect wins, so a sale with an end falls back to the price
+// it covered. Started and Ended track which transitions were applied to the
+// price of the product.
+type ProductPrice struct {
+	ID        entity.ID  `json:"id"`
+	TenantID  entity.ID  `json:"tenant_id" gorm:"index"`
+	ProductID entity.ID  `json:"product_id" gorm:"index"`
+	Amount    float64    `json:"amount"`
+	StartsAt  time.Time  `json:"starts_at" gorm:"index:idx_product_prices_start,priority:2"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" gorm:"index:idx_product_prices_end,priority:2"`
+	Note      string     `json:"note,omitempty"`
+	Started   bool       `json:"-" gorm:"index:idx_product_prices_start,priority:1"`
+	Ended     bool       `json:"-" gorm:"index:idx_product_prices_end,priority:1"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PricePeriod is a window in which a price was, is or will be in effect. To
+// is nil while no other price is known to take over.
+type PricePeriod struct {
+	PriceID entity.ID  `json:"price_id"`
+	Amount  float64    `json:"amount"`
+	From    time.Time  `json:"from"`
+	To      *time.Time `json:"to,omitempty"`
+}
+
+func NewProductPrice(product *Product, amount float64, startsAt time.Time, endsAt *time.Time, note string) (*ProductPrice, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidPrice
+	}
+	if endsAt != nil && !endsAt.After(startsAt) {
+		return nil, ErrInvalidPriceWindow
+	}
+	now := time.Now()
+	return &ProductPrice{
+		ID:        entity.NewID(),
+		TenantID:  product.TenantID,
+		ProductID: product.ID,
+		Amount:    amount,
+		StartsAt:  startsAt.UTC(),
+		EndsAt:    utc(endsAt),
+		Note:      note,
+		Started:   !startsAt.After(now),
+		CreatedAt: now,
+	}, nil
+}
+
+// ScheduleProductPrice creates a price starting in the future, for the
+// scheduler to apply.
+func ScheduleProductPrice(product *Product, amount float64, startsAt time.Time, endsAt *time.Time, note string) (*ProductPrice, error) {
+	if !startsAt.After(time.Now()) {
+		return nil, ErrPriceNotScheduled
+	}
+	return NewProductPrice(product, amount, startsAt, endsAt, note)
+}
+
+// InEffect reports whether the price covers t, ignoring other prices.
+func (p *ProductPrice) InEffect(t time.Time) bool {
+	return !p.StartsAt.After(t) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+// PriceAt returns the price in effect at t among prices, or nil when none
+// covers t. Of the prices covering t, the latest started wins, then the
+// latest created.
+func PriceAt(prices []ProductPrice, t time.Time) *ProductPrice {
+	var current *ProductPrice
+	for i := range prices {
+		price := &prices[i]
+		if !price.InEffect(t) {
+			continue
+		}
+		if current == nil || price.StartsAt.After(current.StartsAt) ||
+			(price.StartsAt.Equal(current.StartsAt) && price.CreatedAt.After(current.CreatedAt)) {
+			current = price
+		}
+	}
+	return current
+}
+
+// PriceTimeline returns the periods in which each of prices is in effect,
+// in order. A price overridden for a while gets a period on each side.
+func PriceTimeline(prices []ProductPrice) []PricePeriod {
+	boundaries := make([]time.Time, 0, 2*len(prices))
+	for _, price := range prices {
+		boundaries = append(boundaries, price.StartsAt)
+		if price.EndsAt != nil {
+			boundaries = append(boundaries, *price.EndsAt)
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+	periods := []PricePeriod{}
+	for i, from := range boundaries {
+		if i > 0 && from.Equal(boundaries[i-1]) {
+			continue
+		}
+		price := PriceAt(prices, from)
+		if n := len(periods); n > 0 && periods[n-1].To == nil {
+			// The previous period lasts until the next boundary.
+			to := from
+			periods[n-1].To = &to
+		}
+		if price == nil {
+			continue
+		}
+		if n := len(periods); n > 0 && periods[n-1].PriceID == price.ID && periods[n-1].To.Equal(from) {
+			periods[n-1].To = nil
+			continue
+		}
+		periods = append(periods, PricePeriod{PriceID: price.ID, Amount: price.Amount, From: from})
+	}
+	return periods
+}
+
+// VariantPrice records the price override of a variant from StartsAt until
+// the next one of the variant, so quotes at past times use the override of
+// the time. A nil Amount means the variant sold at the price of its product.
+type VariantPrice struct {
+	ID        entity.ID `json:"id"`
+	TenantID  entity.ID `json:"tenant_id" gorm:"index"`
+	ProductID entity.ID `json:"product_id" gorm:"index"`
+	VariantID entity.ID `json:"variant_id" gorm:"index"`
+	Amount    *float64  `json:"amount,omitempty"`
+	StartsAt  time.Time `json:"starts_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewVariantPrice records the current price override of variant as in
+// effect from startsAt.
+func NewVariantPrice(variant *Variant, startsAt time.Time) *VariantPrice {
+	var amount *float64
+	if variant.Price != nil {
+		price := *variant.Price
+		amount = &price
+	}
+	return &VariantPrice{
+		ID:        entity.NewID(),
+		TenantID:  variant.TenantID,
+		ProductID: variant.ProductID,
+		VariantID: variant.ID,
+		Amount:    amount,
+		StartsAt:  startsAt.UTC(),
+		CreatedAt: time.Now(),
+	}
+}
+
+// VariantPriceAt returns the price of a variant at t from its price history,
+// given productPrice, the price of its product at t. It returns nil when the
+// variant did not exist yet at t. Of the prices started by t, the latest
+// started wins, then the latest created.
+func VariantPriceAt(prices []VariantPrice, productPrice float64, t time.Time) *float64 {
+	var current *VariantPrice
+	for i := range prices {
+		price := &prices[i]
+		if price.StartsAt.After(t) {
+			continue
+		}
+		if current == nil || price.StartsAt.After(current.StartsAt) ||
+			(price.StartsAt.Equal(current.StartsAt) && price.CreatedAt.After(current.CreatedAt)) {
+			current = price
+		}
+	}
+	if current == nil {
+		return nil
+	}
+	if current.Amount != nil {
+		return current.Amount
+	}
+	return &productPrice
+}
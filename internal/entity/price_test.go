@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProductPrice(t *testing.T) {
+	product, _ := NewProduct("Kettle", 30)
+	now := time.Now()
+	price, err := NewProductPrice(product, 25, now, nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, product.ID, price.ProductID)
+	assert.True(t, price.Started)
+
+	_, err = NewProductPrice(product, 0, now, nil, "")
+	assert.Equal(t, ErrInvalidPrice, err)
+	_, err = NewProductPrice(product, 25, now, &now, "")
+	assert.Equal(t, ErrInvalidPriceWindow, err)
+	_, err = ScheduleProductPrice(product, 25, now.Add(-time.Minute), nil, "")
+	assert.Equal(t, ErrPriceNotScheduled, err)
+	later := now.Add(time.Hour)
+	scheduled, err := ScheduleProductPrice(product, 20, later, nil, "Black Friday")
+	assert.Nil(t, err)
+	assert.False(t, scheduled.Started)
+}
+
+func TestPriceAtAndTimeline(t *testing.T) {
+	product, _ := NewProduct("Kettle", 30)
+	day := func(d int) time.Time { return time.Date(2026, 11, d, 0, 0, 0, 0, time.UTC) }
+	end := day(30)
+	base, _ := NewProductPrice(product, 30, day(1), nil, "")
+	sale, _ := NewProductPrice(product, 20, day(27), &end, "Black Friday")
+	raise, _ := NewProductPrice(product, 35, day(28), nil, "")
+	prices := []ProductPrice{*base, *sale}
+
+	assert.Nil(t, PriceAt(prices, day(1).Add(-time.Second)))
+	assert.Equal(t, 30.0, PriceAt(prices, day(10)).Amount)
+	assert.Equal(t, 20.0, PriceAt(prices, day(27)).Amount)
+	assert.Equal(t, 30.0, PriceAt(prices, end).Amount)
+
+	// The base price resumes after the sale.
+	assert.Equal(t, []PricePeriod{
+		{PriceID: base.ID, Amount: 30, From: day(1), To: ptr(day(27))},
+		{PriceID: sale.ID, Amount: 20, From: day(27), To: ptr(end)},
+		{PriceID: base.ID, Amount: 30, From: end},
+	}, PriceTimeline(prices))
+
+	// A later price takes over from the sale and outlasts it.
+	prices = append(prices, *raise)
+	assert.Equal(t, 35.0, PriceAt(prices, day(29)).Amount)
+	assert.Equal(t, []PricePeriod{
+		{PriceID: base.ID, Amount: 30, From: day(1), To: ptr(day(27))},
+		{PriceID: sale.ID, Amount: 20, From: day(27), To: ptr(day(28))},
+		{PriceID: raise.ID, Amount: 35, From: day(28)},
+	}, PriceTimeline(prices))
+	assert.Empty(t, PriceTimeline(nil))
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestVariantPriceAt(t *testing.T) {
+	product, _ := NewProduct("Shirt", 20)
+	product.Options = []ProductOption{{Name: "Size", Values: []string{"S"}}}
+	variant, _ := NewVariant(product, map[string]string{"Size": "S"})
+	day := func(d int) time.Time { return time.Date(2026, 11, d, 0, 0, 0, 0, time.UTC) }
+	created := NewVariantPrice(variant, day(1))
+	override := 25.0
+	variant.Price = &override
+	overridden := NewVariantPrice(variant, day(10))
+	variant.Price = nil
+	cleared := NewVariantPrice(variant, day(20))
+	prices := []VariantPrice{*created, *overridden, *cleared}
+
+	assert.Nil(t, VariantPriceAt(prices, 20, day(1).Add(-time.Second)))
+	assert.Equal(t, 20.0, *VariantPriceAt(prices, 20, day(5)))
+	assert.Equal(t, 25.0, *VariantPriceAt(prices, 18, day(10)))
+	assert.Equal(t, 18.0, *VariantPriceAt(prices, 18, day(25)))
+	// The history keeps the override it had, whatever the variant has now.
+	override = 30
+	assert.Equal(t, 25.0, *VariantPriceAt(prices, 20, day(15)))
+}
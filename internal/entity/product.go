@@ -2,24 +2,64 @@ package entity
 
 import (
 	"errors"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/ivandersr/products-api-go/pkg/entity"
 )
 
+// Product statuses. Products start as drafts, are published, then archived;
+// an archived product goes back to draft when unarchived.
+const (
+	ProductStatusDraft     = "draft"
+	ProductStatusPublished = "published"
+	ProductStatusArchived  = "archived"
+)
+
 var (
-	ErrIDIsRequired    = errors.New("id is required")
-	ErrInvalidID       = errors.New("invalid id")
-	ErrNameIsRequired  = errors.New("name is required")
-	ErrPriceIsRequired = errors.New("price is required")
-	ErrInvalidPrice    = errors.New("invalid price")
+	ErrIDIsRequired            = errors.New("id is required")
+	ErrInvalidID               = errors.New("invalid id")
+	ErrNameIsRequired          = errors.New("name is required")
+	ErrPriceIsRequired         = errors.New("price is required")
+	ErrInvalidPrice            = errors.New("invalid price")
+	ErrInvalidStatus           = errors.New("invalid status")
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+	ErrInvalidSchedule         = errors.New("unpublish_at must be after publish_at")
+	ErrInvalidSKU              = errors.New("sku must have up to 64 letters, digits, dots, dashes or underscores")
+	ErrInvalidBarcode          = errors.New("barcode must be a GTIN-8, GTIN-12, GTIN-13 or GTIN-14 with a valid check digit")
 )
 
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Product belongs to the tenant it was created in. OwnerID is the user who
+// created it. A draft is published at PublishAt and a published product is
+// archived at UnpublishAt; products created before statuses existed were
+// already live, so they migrate as published. SKU and Barcode are optional
+// but unique within the tenant, so warehouse and point-of-sale systems can
+// key on them. Products with options are sold as the Variants combining
+// their values, which are only loaded when asked for. Media lists its images
+// and documents. Attributes hold the values of the attributes its Category
+// defines, and are also indexed as ProductAttribute rows to filter on. Tags
+// group products across categories, such as for promotions.
 type Product struct {
-	ID        entity.ID `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          entity.ID       `json:"id"`
+	TenantID    entity.ID       `json:"tenant_id" gorm:"index;uniqueIndex:idx_products_tenant_sku,priority:1;uniqueIndex:idx_products_tenant_barcode,priority:1"`
+	OwnerID     entity.ID       `json:"owner_id"`
+	Name        string          `json:"name"`
+	Price       float64         `json:"price"`
+	SKU         string          `json:"sku,omitempty" gorm:"uniqueIndex:idx_products_tenant_sku,priority:2,where:sku <> ''"`
+	Barcode     string          `json:"barcode,omitempty" gorm:"uniqueIndex:idx_products_tenant_barcode,priority:2,where:barcode <> ''"`
+	Options     []ProductOption `json:"options,omitempty" gorm:"serializer:json"`
+	CategoryID  *entity.ID      `json:"category_id,omitempty" gorm:"index"`
+	Attributes  map[string]any  `json:"attributes,omitempty" gorm:"serializer:json"`
+	Tags        []string        `json:"tags,omitempty" gorm:"serializer:json"`
+	Variants    []Variant       `json:"variants,omitempty" gorm:"-"`
+	Media       []Media         `json:"media,omitempty" gorm:"-"`
+	Status      string          `json:"status" gorm:"index;not null;default:published"`
+	PublishAt   *time.Time      `json:"publish_at,omitempty" gorm:"index"`
+	UnpublishAt *time.Time      `json:"unpublish_at,omitempty" gorm:"index"`
+	CreatedAt   time.Time       `json:"created_at"`
 }
 
 func NewProduct(name string, price float64) (*Product, error) {
@@ -27,6 +67,7 @@ func NewProduct(name string, price float64) (*Product, error) {
 		ID:        entity.NewID(),
 		Name:      name,
 		Price:     price,
+		Status:    ProductStatusDraft,
 		CreatedAt: time.Now(),
 	}
 
@@ -53,5 +94,136 @@ func (p *Product) Validate() error {
 	if p.Price < 0 {
 		return ErrInvalidPrice
 	}
+	if p.SKU != "" && !skuPattern.MatchString(p.SKU) {
+		return ErrInvalidSKU
+	}
+	if p.Barcode != "" && !IsValidGTIN(p.Barcode) {
+		return ErrInvalidBarcode
+	}
+	if err := p.validateOptions(); err != nil {
+		return err
+	}
+	if len(p.Attributes) > 0 && p.CategoryID == nil {
+		return ErrAttributesNeedCategory
+	}
+	if !ValidTags(p.Tags) {
+		return ErrInvalidTags
+	}
+	if !IsValidProductStatus(p.Status) {
+		return ErrInvalidStatus
+	}
+	if p.PublishAt != nil && p.UnpublishAt != nil && !p.UnpublishAt.After(*p.PublishAt) {
+		return ErrInvalidSchedule
+	}
+	return nil
+}
+
+func IsValidProductStatus(status string) bool {
+	return status == ProductStatusDraft || status == ProductStatusPublished || status == ProductStatusArchived
+}
+
+// IsValidGTIN reports whether code is a GTIN-8, GTIN-12 (UPC-A), GTIN-13
+// (EAN-13) or GTIN-14 whose last digit is the GS1 mod 10 check digit.
+func IsValidGTIN(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+		digit := int(code[i] - '0')
+		// Weights alternate 3, 1, 3... from the digit next to the check digit.
+		if (len(code)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	check := code[len(code)-1]
+	return check >= '0' && check <= '9' && int(check-'0') == (10-sum%10)%10
+}
+
+func (p *Product) IsPublished() bool {
+	return p.Status == ProductStatusPublished
+}
+
+// Schedule sets when the product is published and archived; nil leaves it to
+// be done by hand. Times are kept in UTC so they compare in the database.
+func (p *Product) Schedule(publishAt, unpublishAt *time.Time) error {
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return ErrInvalidSchedule
+	}
+	p.PublishAt, p.UnpublishAt = utc(publishAt), utc(unpublishAt)
+	return nil
+}
+
+// Publish makes a draft visible and drops its pending publication.
+func (p *Product) Publish() error {
+	if p.Status != ProductStatusDraft {
+		return ErrInvalidStatusTransition
+	}
+	p.Status = ProductStatusPublished
+	p.PublishAt = nil
 	return nil
 }
+
+// Archive hides a published product and drops its pending archival.
+func (p *Product) Archive() error {
+	if p.Status != ProductStatusPublished {
+		return ErrInvalidStatusTransition
+	}
+	p.Status = ProductStatusArchived
+	p.UnpublishAt = nil
+	return nil
+}
+
+// Unarchive turns an archived product back into a draft.
+func (p *Product) Unarchive() error {
+	if p.Status != ProductStatusArchived {
+		return ErrInvalidStatusTransition
+	}
+	p.Status = ProductStatusDraft
+	return nil
+}
+
+// ProductAttribute indexes an attribute value of a product, so listings can
+// filter on it. Strings and booleans are kept as Text and numbers as Number.
+type ProductAttribute struct {
+	ProductID entity.ID `gorm:"primaryKey"`
+	Name      string    `gorm:"primaryKey;index:idx_product_attributes_text,priority:2;index:idx_product_attributes_number,priority:2"`
+	TenantID  entity.ID `gorm:"index:idx_product_attributes_text,priority:1;index:idx_product_attributes_number,priority:1"`
+	Text      *string   `gorm:"index:idx_product_attributes_text,priority:3"`
+	Number    *float64  `gorm:"index:idx_product_attributes_number,priority:3"`
+}
+
+// AttributeIndex returns the rows indexing the attributes of the product.
+func (p *Product) AttributeIndex() []ProductAttribute {
+	rows := make([]ProductAttribute, 0, len(p.Attributes))
+	for name, value := range p.Attributes {
+		row := ProductAttribute{ProductID: p.ID, Name: name, TenantID: p.TenantID}
+		switch v := value.(type) {
+		case string:
+			row.Text = &v
+		case bool:
+			text := strconv.FormatBool(v)
+			row.Text = &text
+		case float64:
+			row.Number = &v
+		default:
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
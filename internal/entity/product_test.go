@@ -1,7 +1,9 @@
 package entity
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,7 +14,7 @@ func TestNewProduct(t *testing.T) {
 	assert.NotNil(t, p)
 	assert.NotEmpty(t, p.ID)
 	assert.Equal(t, "Product 1", p.Name)
-	assert.Equal(t, 10, p.Price)
+	assert.Equal(t, 10.0, p.Price)
 }
 
 func TestProductWhenNameIsRequired(t *testing.T) {
@@ -39,3 +41,72 @@ func TestProductValidate(t *testing.T) {
 	assert.NotNil(t, p)
 	assert.Nil(t, p.Validate())
 }
+
+func TestProductStartsAsDraft(t *testing.T) {
+	p, err := NewProduct("Product 1", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, ProductStatusDraft, p.Status)
+	assert.False(t, p.IsPublished())
+}
+
+func TestProductStatusTransitions(t *testing.T) {
+	p, _ := NewProduct("Product 1", 10)
+	assert.Equal(t, ErrInvalidStatusTransition, p.Archive())
+	assert.Equal(t, ErrInvalidStatusTransition, p.Unarchive())
+
+	assert.Nil(t, p.Publish())
+	assert.True(t, p.IsPublished())
+	assert.Equal(t, ErrInvalidStatusTransition, p.Publish())
+	assert.Equal(t, ErrInvalidStatusTransition, p.Unarchive())
+
+	assert.Nil(t, p.Archive())
+	assert.Equal(t, ProductStatusArchived, p.Status)
+	assert.Equal(t, ErrInvalidStatusTransition, p.Publish())
+
+	assert.Nil(t, p.Unarchive())
+	assert.Equal(t, ProductStatusDraft, p.Status)
+
+	p.Status = "deleted"
+	assert.Equal(t, ErrInvalidStatus, p.Validate())
+}
+
+func TestProductSchedule(t *testing.T) {
+	p, _ := NewProduct("Product 1", 10)
+	publishAt := time.Now().Add(time.Hour)
+	unpublishAt := publishAt.Add(time.Hour)
+	assert.Nil(t, p.Schedule(&publishAt, &unpublishAt))
+	assert.Equal(t, time.UTC, p.PublishAt.Location())
+	assert.Nil(t, p.Validate())
+
+	assert.Equal(t, ErrInvalidSchedule, p.Schedule(&unpublishAt, &publishAt))
+	assert.Equal(t, ErrInvalidSchedule, p.Schedule(&publishAt, &publishAt))
+
+	assert.Nil(t, p.Publish())
+	assert.Nil(t, p.PublishAt)
+	assert.Nil(t, p.Archive())
+	assert.Nil(t, p.UnpublishAt)
+}
+
+func TestIsValidGTIN(t *testing.T) {
+	for _, code := range []string{"96385074", "036000291452", "4006381333931", "10012345678902"} {
+		assert.True(t, IsValidGTIN(code), code)
+	}
+	for _, code := range []string{"", "9638507", "96385075", "036000291453", "400638133393A", "123456789012345"} {
+		assert.False(t, IsValidGTIN(code), code)
+	}
+}
+
+func TestProductIdentifiers(t *testing.T) {
+	p, _ := NewProduct("Product 1", 10)
+	p.SKU = "TSHIRT-RED_L.01"
+	p.Barcode = "4006381333931"
+	assert.Nil(t, p.Validate())
+
+	p.SKU = "has space"
+	assert.Equal(t, ErrInvalidSKU, p.Validate())
+	p.SKU = strings.Repeat("A", 65)
+	assert.Equal(t, ErrInvalidSKU, p.Validate())
+	p.SKU = ""
+	p.Barcode = "4006381333932"
+	assert.Equal(t, ErrInvalidBarcode, p.Validate())
+}
@@ -0,0 +1,267 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+// Promotion types.
+const (
+	PromotionPercentageOff = "percentage_off"
+	PromotionFixedOff      = "fixed_off"
+	PromotionBuyXGetY      = "buy_x_get_y"
+	PromotionTiered        = "tiered"
+)
+
+// MaxProductTags bounds the tags of a product.
+const MaxProductTags = 20
+
+var (
+	ErrInvalidPromotionType   = errors.New("type must be percentage_off, fixed_off, buy_x_get_y or tiered")
+	ErrInvalidPercent         = errors.New("percent must be above 0 and at most 100")
+	ErrInvalidAmountOff       = errors.New("amount must be above 0")
+	ErrInvalidBuyXGetY        = errors.New("buy_quantity and get_quantity must be at least 1")
+	ErrInvalidTiers           = errors.New("tiers need increasing min_quantity of at least 1 and a percent above 0 and at most 100 each")
+	ErrPromotionNeedsTarget   = errors.New("promotion needs product_ids, category_ids or tags to target")
+	ErrInvalidPromotionWindow = errors.New("ends_at must be after starts_at")
+	ErrInvalidTags            = errors.New("tags need up to 20 unique names of up to 32 lowercase letters, digits or dashes")
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// PromotionTier discounts lines of at least MinQuantity units by Percent.
+type PromotionTier struct {
+	MinQuantity int     `json:"min_quantity"`
+	Percent     float64 `json:"percent"`
+}
+
+// Promotion discounts the products it targets, by ID, category or tag,
+// between StartsAt and EndsAt when set. Percentage-off takes Percent off,
+// fixed-off takes Amount off each unit, buy-X-get-Y takes GetPercent (all
+// of it unless set) off GetQuantity units of every BuyQuantity plus
+// GetQuantity, and tiered takes the Percent of the highest tier the quantity
+// reaches off the line.
+type Promotion struct {
+	ID          entity.ID       `json:"id"`
+	TenantID    entity.ID       `json:"tenant_id" gorm:"index"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Percent     float64         `json:"percent,omitempty"`
+	Amount      float64         `json:"amount,omitempty"`
+	BuyQuantity int             `json:"buy_quantity,omitempty"`
+	GetQuantity int             `json:"get_quantity,omitempty"`
+	GetPercent  float64         `json:"get_percent,omitempty"`
+	Tiers       []PromotionTier `json:"tiers,omitempty" gorm:"serializer:json"`
+	ProductIDs  []string        `json:"product_ids,omitempty" gorm:"serializer:json"`
+	CategoryIDs []string        `json:"category_ids,omitempty" gorm:"serializer:json"`
+	Tags        []string        `json:"tags,omitempty" gorm:"serializer:json"`
+	StartsAt    *time.Time      `json:"starts_at,omitempty" gorm:"index"`
+	EndsAt      *time.Time      `json:"ends_at,omitempty" gorm:"index"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AppliedPromotion explains the discount a promotion gave a line.
+type AppliedPromotion struct {
+	PromotionID entity.ID `json:"promotion_id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Discount    float64   `json:"discount"`
+	Explanation string    `json:"explanation"`
+}
+
+// LineQuote prices Quantity units of a product.
+type LineQuote struct {
+	UnitPrice float64            `json:"unit_price"`
+	Quantity  int                `json:"quantity"`
+	Subtotal  float64            `json:"subtotal"`
+	Discount  float64            `json:"discount"`
+	Total     float64            `json:"total"`
+	Applied   []AppliedPromotion `json:"applied_promotions"`
+}
+
+func NewPromotion(name, promotionType string) *Promotion {
+	return &Promotion{
+		ID:        entity.NewID(),
+		Name:      strings.TrimSpace(name),
+		Type:      promotionType,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (p *Promotion) Validate() error {
+	if p.Name == "" {
+		return ErrNameIsRequired
+	}
+	switch p.Type {
+	case PromotionPercentageOff:
+		if !validPercent(p.Percent) {
+			return ErrInvalidPercent
+		}
+	case PromotionFixedOff:
+		if p.Amount <= 0 {
+			return ErrInvalidAmountOff
+		}
+	case PromotionBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return ErrInvalidBuyXGetY
+		}
+		if p.GetPercent != 0 && !validPercent(p.GetPercent) {
+			return ErrInvalidPercent
+		}
+	case PromotionTiered:
+		if len(p.Tiers) == 0 {
+			return ErrInvalidTiers
+		}
+		for i, tier := range p.Tiers {
+			if tier.MinQuantity < 1 || !validPercent(tier.Percent) || (i > 0 && tier.MinQuantity <= p.Tiers[i-1].MinQuantity) {
+				return ErrInvalidTiers
+			}
+		}
+	default:
+		return ErrInvalidPromotionType
+	}
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 && len(p.Tags) == 0 {
+		return ErrPromotionNeedsTarget
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return ErrInvalidPromotionWindow
+	}
+	return nil
+}
+
+// Schedule sets the window of the promotion, in UTC; nil leaves it open on
+// that side.
+func (p *Promotion) Schedule(startsAt, endsAt *time.Time) error {
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return ErrInvalidPromotionWindow
+	}
+	p.StartsAt, p.EndsAt = utc(startsAt), utc(endsAt)
+	return nil
+}
+
+// ActiveAt reports whether t falls in the window of the promotion.
+func (p *Promotion) ActiveAt(t time.Time) bool {
+	return (p.StartsAt == nil || !p.StartsAt.After(t)) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+// Targets reports whether the promotion applies to product.
+func (p *Promotion) Targets(product *Product) bool {
+	if slices.Contains(p.ProductIDs, product.ID.String()) {
+		return true
+	}
+	if product.CategoryID != nil && slices.Contains(p.CategoryIDs, product.CategoryID.String()) {
+		return true
+	}
+	for _, tag := range product.Tags {
+		if slices.Contains(p.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Discount is what the promotion takes off quantity units at unitPrice,
+// rounded to cents, with how it was worked out. It is zero when the
+// quantity does not qualify.
+func (p *Promotion) Discount(unitPrice float64, quantity int) (float64, string) {
+	subtotal := unitPrice * float64(quantity)
+	switch p.Type {
+	case PromotionPercentageOff:
+		return roundCents(subtotal * p.Percent / 100), fmt.Sprintf("%g%% off", p.Percent)
+	case PromotionFixedOff:
+		off := math.Min(p.Amount, unitPrice)
+		return roundCents(off * float64(quantity)), fmt.Sprintf("%.2f off each of %d units", off, quantity)
+	case PromotionBuyXGetY:
+		discounted := quantity / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+		if discounted == 0 {
+			return 0, ""
+		}
+		percent := p.GetPercent
+		if percent == 0 {
+			percent = 100
+		}
+		explanation := fmt.Sprintf("buy %d get %d free: %d of %d units free", p.BuyQuantity, p.GetQuantity, discounted, quantity)
+		if percent < 100 {
+			explanation = fmt.Sprintf("buy %d get %d at %g%% off: %d of %d units discounted", p.BuyQuantity, p.GetQuantity, percent, discounted, quantity)
+		}
+		return roundCents(unitPrice * float64(discounted) * percent / 100), explanation
+	case PromotionTiered:
+		for i := len(p.Tiers) - 1; i >= 0; i-- {
+			if quantity >= p.Tiers[i].MinQuantity {
+				tier := p.Tiers[i]
+				return roundCents(subtotal * tier.Percent / 100), fmt.Sprintf("%g%% off for %d or more units", tier.Percent, tier.MinQuantity)
+			}
+		}
+	}
+	return 0, ""
+}
+
+// QuoteLine prices quantity units of product at unitPrice with the best of
+// the promotions active at t that target it. Promotions do not stack, so the
+// line gets the largest single discount.
+func QuoteLine(product *Product, unitPrice float64, quantity int, promotions []Promotion, t time.Time) LineQuote {
+	subtotal := roundCents(unitPrice * float64(quantity))
+	quote := LineQuote{
+		UnitPrice: unitPrice,
+		Quantity:  quantity,
+		Subtotal:  subtotal,
+		Total:     subtotal,
+		Applied:   []AppliedPromotion{},
+	}
+	var best *AppliedPromotion
+	for i := range promotions {
+		promotion := &promotions[i]
+		if !promotion.ActiveAt(t) || !promotion.Targets(product) {
+			continue
+		}
+		discount, explanation := promotion.Discount(unitPrice, quantity)
+		discount = math.Min(discount, subtotal)
+		if discount <= 0 || (best != nil && discount <= best.Discount) {
+			continue
+		}
+		best = &AppliedPromotion{
+			PromotionID: promotion.ID,
+			Name:        promotion.Name,
+			Type:        promotion.Type,
+			Discount:    discount,
+			Explanation: explanation,
+		}
+	}
+	if best != nil {
+		quote.Discount = best.Discount
+		quote.Total = roundCents(subtotal - best.Discount)
+		quote.Applied = append(quote.Applied, *best)
+	}
+	return quote
+}
+
+// ValidTags reports whether tags are few, unique and well formed.
+func ValidTags(tags []string) bool {
+	if len(tags) > MaxProductTags {
+		return false
+	}
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	for i, tag := range sorted {
+		if !tagPattern.MatchString(tag) || (i > 0 && tag == sorted[i-1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func validPercent(percent float64) bool {
+	return percent > 0 && percent <= 100
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPromotion(promotionType string, set func(*Promotion)) *Promotion {
+	promotion := NewPromotion("Sale", promotionType)
+	promotion.Tags = []string{"sale"}
+	set(promotion)
+	return promotion
+}
+
+func TestPromotionValidate(t *testing.T) {
+	valid := []*Promotion{
+		newTestPromotion(PromotionPercentageOff, func(p *Promotion) { p.Percent = 10 }),
+		newTestPromotion(PromotionFixedOff, func(p *Promotion) { p.Amount = 5 }),
+		newTestPromotion(PromotionBuyXGetY, func(p *Promotion) { p.BuyQuantity, p.GetQuantity = 2, 1 }),
+		newTestPromotion(PromotionTiered, func(p *Promotion) { p.Tiers = []PromotionTier{{5, 10}, {10, 20}} }),
+	}
+	for _, promotion := range valid {
+		assert.Nil(t, promotion.Validate(), promotion.Type)
+	}
+
+	assert.Equal(t, ErrInvalidPromotionType, newTestPromotion("bogo", func(p *Promotion) {}).Validate())
+	assert.Equal(t, ErrInvalidPercent, newTestPromotion(PromotionPercentageOff, func(p *Promotion) { p.Percent = 120 }).Validate())
+	assert.Equal(t, ErrInvalidAmountOff, newTestPromotion(PromotionFixedOff, func(p *Promotion) {}).Validate())
+	assert.Equal(t, ErrInvalidBuyXGetY, newTestPromotion(PromotionBuyXGetY, func(p *Promotion) { p.BuyQuantity = 2 }).Validate())
+	assert.Equal(t, ErrInvalidTiers, newTestPromotion(PromotionTiered, func(p *Promotion) { p.Tiers = []PromotionTier{{10, 20}, {5, 10}} }).Validate())
+	assert.Equal(t, ErrPromotionNeedsTarget, newTestPromotion(PromotionPercentageOff, func(p *Promotion) { p.Percent, p.Tags = 10, nil }).Validate())
+	now := time.Now()
+	assert.Equal(t, ErrInvalidPromotionWindow, valid[0].Schedule(&now, &now))
+}
+
+func TestPromotionDiscount(t *testing.T) {
+	cases := []struct {
+		promotion   *Promotion
+		quantity    int
+		discount    float64
+		explanation string
+	}{
+		{newTestPromotion(PromotionPercentageOff, func(p *Promotion) { p.Percent = 15 }), 3, 4.5, "15% off"},
+		{newTestPromotion(PromotionFixedOff, func(p *Promotion) { p.Amount = 2.5 }), 3, 7.5, "2.50 off each of 3 units"},
+		{newTestPromotion(PromotionFixedOff, func(p *Promotion) { p.Amount = 50 }), 2, 20, "10.00 off each of 2 units"},
+		{newTestPromotion(PromotionBuyXGetY, func(p *Promotion) { p.BuyQuantity, p.GetQuantity = 2, 1 }), 7, 20, "buy 2 get 1 free: 2 of 7 units free"},
+		{newTestPromotion(PromotionBuyXGetY, func(p *Promotion) { p.BuyQuantity, p.GetQuantity = 2, 1 }), 2, 0, ""},
+		{newTestPromotion(PromotionBuyXGetY, func(p *Promotion) { p.BuyQuantity, p.GetQuantity, p.GetPercent = 1, 1, 50 }), 2, 5, "buy 1 get 1 at 50% off: 1 of 2 units discounted"},
+		{newTestPromotion(PromotionTiered, func(p *Promotion) { p.Tiers = []PromotionTier{{5, 10}, {10, 20}} }), 12, 24, "20% off for 10 or more units"},
+		{newTestPromotion(PromotionTiered, func(p *Promotion) { p.Tiers = []PromotionTier{{5, 10}, {10, 20}} }), 4, 0, ""},
+	}
+	for _, c := range cases {
+		discount, explanation := c.promotion.Discount(10, c.quantity)
+		assert.Equal(t, c.discount, discount, c.promotion.Type)
+		assert.Equal(t, c.explanation, explanation, c.promotion.Type)
+	}
+}
+
+func TestQuoteLine(t *testing.T) {
+	product, _ := NewProduct("Kettle", 10)
+	product.Tags = []string{"kitchen"}
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	tagged := newTestPromotion(PromotionPercentageOff, func(p *Promotion) { p.Percent, p.Tags = 10, []string{"kitchen"} })
+	byID := newTestPromotion(PromotionBuyXGetY, func(p *Promotion) {
+		p.BuyQuantity, p.GetQuantity, p.ProductIDs, p.Tags = 2, 1, []string{product.ID.String()}, nil
+	})
+	ended := newTestPromotion(PromotionPercentageOff, func(p *Promotion) { p.Percent, p.Tags, p.EndsAt = 90, []string{"kitchen"}, &past })
+	other := newTestPromotion(PromotionPercentageOff, func(p *Promotion) { p.Percent = 50 })
+	promotions := []Promotion{*tagged, *byID, *ended, *other}
+
+	quote := QuoteLine(product, 10, 2, promotions, now)
+	assert.Equal(t, 20.0, quote.Subtotal)
+	assert.Equal(t, 2.0, quote.Discount)
+	assert.Equal(t, 18.0, quote.Total)
+	assert.Equal(t, []AppliedPromotion{{PromotionID: tagged.ID, Name: "Sale", Type: PromotionPercentageOff, Discount: 2, Explanation: "10% off"}}, quote.Applied)
+
+	// The best single promotion wins.
+	quote = QuoteLine(product, 10, 3, promotions, now)
+	assert.Equal(t, 10.0, quote.Discount)
+	assert.Equal(t, byID.ID, quote.Applied[0].PromotionID)
+
+	quote = QuoteLine(product, 10, 3, nil, now)
+	assert.Zero(t, quote.Discount)
+	assert.Equal(t, 30.0, quote.Total)
+	assert.Empty(t, quote.Applied)
+}
+
+func TestValidTags(t *testing.T) {
+	assert.True(t, ValidTags(nil))
+	assert.True(t, ValidTags([]string{"summer-2026", "kitchen"}))
+	assert.False(t, ValidTags([]string{"Kitchen"}))
+	assert.False(t, ValidTags([]string{"a", "a"}))
+	assert.False(t, ValidTags([]string{"-a"}))
+}
@@ -0,0 +1,103 @@
+package entity
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+const (
+	TenantRoleOwner  = "owner"
+	TenantRoleEditor = "editor"
+	TenantRoleMember = "member"
+)
+
+var (
+	ErrSlugIsRequired    = errors.New("slug is required")
+	ErrInvalidSlug       = errors.New("invalid slug")
+	ErrInvalidTenantRole = errors.New("invalid tenant role")
+
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// slugMaxLength keeps slugs usable as a DNS label.
+const slugMaxLength = 63
+
+// Tenant is an organization, such as a store brand, owning its own products.
+type Tenant struct {
+	ID        entity.ID `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewTenant(name, slug string) (*Tenant, error) {
+	tenant := &Tenant{
+		ID:        entity.NewID(),
+		Name:      strings.TrimSpace(name),
+		Slug:      strings.TrimSpace(slug),
+		CreatedAt: time.Now(),
+	}
+	if err := tenant.Validate(); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (t *Tenant) Validate() error {
+	if t.Name == "" {
+		return ErrNameIsRequired
+	}
+	if t.Slug == "" {
+		return ErrSlugIsRequired
+	}
+	if len(t.Slug) > slugMaxLength || !slugPattern.MatchString(t.Slug) {
+		return ErrInvalidSlug
+	}
+	return nil
+}
+
+// Membership grants a user access to the products of a tenant. Editors also
+// see the products that are not published, and owners also manage the
+// members.
+type Membership struct {
+	TenantID  entity.ID `json:"tenant_id" gorm:"primaryKey"`
+	UserID    entity.ID `json:"user_id" gorm:"primaryKey;index"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewMembership(tenantID, userID entity.ID, role string) (*Membership, error) {
+	if !IsValidTenantRole(role) {
+		return nil, ErrInvalidTenantRole
+	}
+	return &Membership{
+		TenantID:  tenantID,
+		UserID:    userID,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func IsValidTenantRole(role string) bool {
+	return role == TenantRoleOwner || role == TenantRoleEditor || role == TenantRoleMember
+}
+
+func (m *Membership) IsOwner() bool {
+	return m.Role == TenantRoleOwner
+}
+
+// CanSeeDrafts reports whether the member sees the draft and archived
+// products of the tenant, which owners and editors do.
+func (m *Membership) CanSeeDrafts() bool {
+	return m.Role == TenantRoleOwner || m.Role == TenantRoleEditor
+}
+
+// CanEditProducts reports whether the member creates, changes and deletes
+// the products of the tenant, which owners and editors do.
+func (m *Membership) CanEditProducts() bool {
+	return m.Role == TenantRoleOwner || m.Role == TenantRoleEditor
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTenant(t *testing.T) {
+	tenant, err := NewTenant(" Acme Store ", "acme-store")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, tenant.ID)
+	assert.Equal(t, "Acme Store", tenant.Name)
+	assert.Equal(t, "acme-store", tenant.Slug)
+}
+
+func TestNewTenantValidation(t *testing.T) {
+	_, err := NewTenant(" ", "acme")
+	assert.Equal(t, ErrNameIsRequired, err)
+	_, err = NewTenant("Acme", "")
+	assert.Equal(t, ErrSlugIsRequired, err)
+	for _, slug := range []string{"Acme", "acme store", "-acme", "acme-", "acme--store", "acme_store", strings.Repeat("a", 64)} {
+		_, err = NewTenant("Acme", slug)
+		assert.Equal(t, ErrInvalidSlug, err, slug)
+	}
+}
+
+func TestNewMembership(t *testing.T) {
+	membership, err := NewMembership(entity.NewID(), entity.NewID(), TenantRoleOwner)
+	assert.Nil(t, err)
+	assert.True(t, membership.IsOwner())
+	assert.True(t, membership.CanSeeDrafts())
+	assert.True(t, membership.CanEditProducts())
+
+	membership, err = NewMembership(entity.NewID(), entity.NewID(), TenantRoleEditor)
+	assert.Nil(t, err)
+	assert.False(t, membership.IsOwner())
+	assert.True(t, membership.CanSeeDrafts())
+	assert.True(t, membership.CanEditProducts())
+
+	membership, err = NewMembership(entity.NewID(), entity.NewID(), TenantRoleMember)
+	assert.Nil(t, err)
+	assert.False(t, membership.IsOwner())
+	assert.False(t, membership.CanSeeDrafts())
+	assert.False(t, membership.CanEditProducts())
+
+	_, err = NewMembership(entity.NewID(), entity.NewID(), RoleAdmin)
+	assert.Equal(t, ErrInvalidTenantRole, err)
+}
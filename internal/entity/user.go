@@ -1,31 +1,207 @@
 package entity
 
 import (
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
 	"github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/ivandersr/products-api-go/pkg/passwordhash"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var (
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrEmailIsRequired    = errors.New("email is required")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrPasswordIsRequired = errors.New("password is required")
+)
+
+// passwordHasher hashes new passwords. Hashes made by any other hasher are
+// still accepted and replaced on the next successful login.
+var passwordHasher passwordhash.Hasher = &passwordhash.Bcrypt{Cost: bcrypt.DefaultCost}
+
+// SetPasswordHasher changes the hasher of the passwords set from now on.
+func SetPasswordHasher(hasher passwordhash.Hasher) {
+	passwordHasher = hasher
+}
+
+// PasswordNeedsRehash reports whether hash was not made by the current hasher.
+func PasswordNeedsRehash(hash string) bool {
+	return passwordHasher.NeedsRehash(hash)
+}
+
 type User struct {
-	ID       entity.ID `json:"id"`
-	Name     string    `json:"name"`
-	Email    string    `json:"email"`
-	Password string    `json:"-"`
+	ID              entity.ID  `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email" gorm:"uniqueIndex"`
+	Password        string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	Role            string     `json:"role" gorm:"default:user"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
+	TokenVersion    int        `json:"-"`
+	TOTPSecret      string     `json:"-"`
+	TOTPLastStep    int64      `json:"-"`
+	MFAEnabledAt    *time.Time `json:"mfa_enabled_at,omitempty"`
+	rehashed        bool
 }
 
 func NewUser(name, email, password string) (*User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-	return &User{
+	user := &User{
 		ID:       entity.NewID(),
-		Name:     name,
+		Name:     strings.TrimSpace(name),
 		Email:    email,
-		Password: string(hash),
-	}, nil
+		Password: password,
+		Role:     RoleUser,
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+	if err := user.ChangePassword(password); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Validate checks the user fields and normalizes its email in place.
+func (u *User) Validate() error {
+	if u.ID.String() == "" {
+		return ErrIDIsRequired
+	}
+	if _, err := entity.ParseID(u.ID.String()); err != nil {
+		return ErrInvalidID
+	}
+	if u.Name == "" {
+		return ErrNameIsRequired
+	}
+	email, err := NormalizeEmail(u.Email)
+	if err != nil {
+		return err
+	}
+	u.Email = email
+	if u.Password == "" {
+		return ErrPasswordIsRequired
+	}
+	if u.Role != "" && !IsValidRole(u.Role) {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u *User) ChangeRole(role string) error {
+	if !IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	u.Role = role
+	return nil
+}
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
 }
 
+// Disable blocks the user from logging in and invalidates its sessions.
+func (u *User) Disable() {
+	if u.DisabledAt == nil {
+		now := time.Now()
+		u.DisabledAt = &now
+		u.InvalidateSessions()
+	}
+}
+
+func (u *User) Enable() {
+	u.DisabledAt = nil
+}
+
+// ValidatePassword checks password against the stored hash. When the hash was
+// made by another hasher it is replaced, see PasswordRehashed.
 func (u *User) ValidatePassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	if !passwordhash.Verify(u.Password, password) {
+		return false
+	}
+	if passwordHasher.NeedsRehash(u.Password) {
+		if hash, err := passwordHasher.Hash(password); err == nil {
+			u.Password = hash
+			u.rehashed = true
+		}
+	}
+	return true
+}
+
+// PasswordRehashed reports whether ValidatePassword replaced the stored hash,
+// which then has to be saved.
+func (u *User) PasswordRehashed() bool {
+	return u.rehashed
+}
+
+// ChangePassword replaces the stored hash with one of the given password and
+// invalidates the tokens issued so far.
+func (u *User) ChangePassword(password string) error {
+	if password == "" {
+		return ErrPasswordIsRequired
+	}
+	hash, err := passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
+	u.InvalidateSessions()
+	return nil
+}
+
+// InvalidateSessions makes every token issued before the call unusable.
+func (u *User) InvalidateSessions() {
+	u.TokenVersion++
+}
+
+// ChangeEmail sets a new email, which has to be verified again.
+func (u *User) ChangeEmail(email string) error {
+	normalized, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if normalized != u.Email {
+		u.Email = normalized
+		u.EmailVerifiedAt = nil
+	}
+	return nil
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) VerifyEmail() {
+	if u.EmailVerifiedAt == nil {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+}
+
+// NormalizeEmail parses a bare RFC 5322 address, without display name, and
+// case-folds it so the same mailbox always maps to the same account.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", ErrEmailIsRequired
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(addr.Address), nil
 }
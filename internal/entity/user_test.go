@@ -3,7 +3,9 @@ package entity
 import (
 	"testing"
 
+	"github.com/ivandersr/products-api-go/pkg/passwordhash"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestNewUser(t *testing.T) {
@@ -23,3 +25,120 @@ func TestUserValidatePassword(t *testing.T) {
 	assert.False(t, user.ValidatePassword("1234567"))
 	assert.NotEqual(t, "123456", user.Password)
 }
+
+func TestUserValidatePasswordRehashes(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, err)
+	legacy := user.Password
+	assert.True(t, user.ValidatePassword("123456"))
+	assert.False(t, user.PasswordRehashed())
+
+	hasher, err := passwordhash.NewArgon2id(passwordhash.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1})
+	assert.Nil(t, err)
+	SetPasswordHasher(hasher)
+	t.Cleanup(func() { SetPasswordHasher(&passwordhash.Bcrypt{Cost: bcrypt.DefaultCost}) })
+	assert.True(t, PasswordNeedsRehash(legacy))
+
+	version := user.TokenVersion
+	assert.False(t, user.ValidatePassword("1234567"))
+	assert.False(t, user.PasswordRehashed())
+	assert.True(t, user.ValidatePassword("123456"))
+	assert.True(t, user.PasswordRehashed())
+	assert.Equal(t, passwordhash.AlgorithmArgon2id, passwordhash.Identify(user.Password))
+	assert.Equal(t, version, user.TokenVersion)
+	assert.True(t, user.ValidatePassword("123456"))
+	assert.False(t, PasswordNeedsRehash(user.Password))
+}
+
+func TestNewUserNormalizesEmail(t *testing.T) {
+	user, err := NewUser(" John Doe ", " John.Doe@Example.COM ", "123456")
+	assert.Nil(t, err)
+	assert.Equal(t, "John Doe", user.Name)
+	assert.Equal(t, "john.doe@example.com", user.Email)
+}
+
+func TestNewUserWhenPasswordIsRequired(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "")
+	assert.Nil(t, user)
+	assert.Equal(t, ErrPasswordIsRequired, err)
+}
+
+func TestNewUserWhenNameIsRequired(t *testing.T) {
+	user, err := NewUser("  ", "j@j.com", "123456")
+	assert.Nil(t, user)
+	assert.Equal(t, ErrNameIsRequired, err)
+}
+
+func TestNewUserWhenEmailIsInvalid(t *testing.T) {
+	for _, email := range []string{"j.com", "John <j@j.com>", "j@", "j@j.com, k@k.com"} {
+		user, err := NewUser("John Doe", email, "123456")
+		assert.Nil(t, user)
+		assert.Equal(t, ErrInvalidEmail, err, email)
+	}
+	user, err := NewUser("John Doe", "", "123456")
+	assert.Nil(t, user)
+	assert.Equal(t, ErrEmailIsRequired, err)
+}
+
+func TestUserChangePassword(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, err)
+	assert.Nil(t, user.ChangePassword("654321"))
+	assert.True(t, user.ValidatePassword("654321"))
+	assert.False(t, user.ValidatePassword("123456"))
+	assert.Equal(t, ErrPasswordIsRequired, user.ChangePassword(""))
+}
+
+func TestUserVerifyEmail(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, err)
+	assert.False(t, user.IsEmailVerified())
+	user.VerifyEmail()
+	assert.True(t, user.IsEmailVerified())
+}
+
+func TestUserChangePasswordInvalidatesSessions(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, err)
+	version := user.TokenVersion
+	assert.Nil(t, user.ChangePassword("654321"))
+	assert.Equal(t, version+1, user.TokenVersion)
+}
+
+func TestUserChangeEmail(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, err)
+	user.VerifyEmail()
+
+	assert.Nil(t, user.ChangeEmail("J@J.com"))
+	assert.True(t, user.IsEmailVerified())
+
+	assert.Nil(t, user.ChangeEmail("John@Example.com"))
+	assert.Equal(t, "john@example.com", user.Email)
+	assert.False(t, user.IsEmailVerified())
+
+	assert.Equal(t, ErrInvalidEmail, user.ChangeEmail("john"))
+	assert.Equal(t, "john@example.com", user.Email)
+}
+
+func TestUserChangeRole(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleUser, user.Role)
+	assert.False(t, user.IsAdmin())
+	assert.Nil(t, user.ChangeRole(RoleAdmin))
+	assert.True(t, user.IsAdmin())
+	assert.Equal(t, ErrInvalidRole, user.ChangeRole("root"))
+	assert.Equal(t, RoleAdmin, user.Role)
+}
+
+func TestUserDisable(t *testing.T) {
+	user, err := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, err)
+	version := user.TokenVersion
+	user.Disable()
+	assert.True(t, user.IsDisabled())
+	assert.Equal(t, version+1, user.TokenVersion)
+	user.Enable()
+	assert.False(t, user.IsDisabled())
+}
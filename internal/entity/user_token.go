@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeMFAChallenge      = "mfa_challenge"
+)
+
+// UserToken backs a single-use, expiring token sent to a user by email or
+// handed out as the second step of a login. Email is the address a token
+// was sent to, which it only works for.
+type UserToken struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose"`
+	Email     string     `json:"email,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func NewUserToken(userID entity.ID, purpose string, ttl time.Duration) *UserToken {
+	now := time.Now()
+	return &UserToken{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
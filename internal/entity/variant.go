@@ -0,0 +1,245 @@
+package entity
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+// Limits on option axes, so the variants of a product stay manageable.
+const (
+	MaxProductOptions  = 3
+	MaxProductVariants = 100
+)
+
+var (
+	ErrInvalidOptions        = errors.New("options need unique names and at least one unique value each")
+	ErrTooManyOptions        = errors.New("a product has at most 3 options")
+	ErrTooManyVariants       = errors.New("options cannot make more than 100 variants")
+	ErrProductHasNoOptions   = errors.New("product has no options to vary")
+	ErrInvalidVariantOptions = errors.New("variant needs one of the values of every product option")
+	ErrInvalidStock          = errors.New("stock cannot be negative")
+	ErrOptionsInUse          = errors.New("options no longer match the variants of the product")
+)
+
+// ProductOption is an axis a product varies along, such as size or color.
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Variant is a combination of one value of each option of its product. It
+// has its own SKU and stock, and its price overrides the product's when set.
+type Variant struct {
+	ID         entity.ID         `json:"id"`
+	TenantID   entity.ID         `json:"tenant_id" gorm:"index;uniqueIndex:idx_variants_tenant_sku,priority:1"`
+	ProductID  entity.ID         `json:"product_id" gorm:"uniqueIndex:idx_variants_product_options,priority:1"`
+	Options    map[string]string `json:"options" gorm:"serializer:json"`
+	OptionsKey string            `json:"-" gorm:"uniqueIndex:idx_variants_product_options,priority:2"`
+	SKU        string            `json:"sku,omitempty" gorm:"uniqueIndex:idx_variants_tenant_sku,priority:2,where:sku <> ''"`
+	Price      *float64          `json:"price,omitempty"`
+	Stock      int               `json:"stock"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+func NewVariant(product *Product, options map[string]string) (*Variant, error) {
+	variant := &Variant{
+		ID:        entity.NewID(),
+		TenantID:  product.TenantID,
+		ProductID: product.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := variant.SetOptions(product, options); err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+// SetOptions sets the option values of the variant, which must name one
+// value of every option of product.
+func (v *Variant) SetOptions(product *Product, options map[string]string) error {
+	if len(product.Options) == 0 {
+		return ErrProductHasNoOptions
+	}
+	if !product.hasCombination(options) {
+		return ErrInvalidVariantOptions
+	}
+	v.Options = options
+	v.OptionsKey = optionsKey(options)
+	return nil
+}
+
+// Validate checks the variant against product, whose options may have
+// changed since the variant was made.
+func (v *Variant) Validate(product *Product) error {
+	if len(product.Options) == 0 {
+		return ErrProductHasNoOptions
+	}
+	if v.ProductID != product.ID || !product.hasCombination(v.Options) || v.OptionsKey != optionsKey(v.Options) {
+		return ErrInvalidVariantOptions
+	}
+	if v.SKU != "" && !skuPattern.MatchString(v.SKU) {
+		return ErrInvalidSKU
+	}
+	if v.Price != nil && *v.Price <= 0 {
+		return ErrInvalidPrice
+	}
+	if v.Stock < 0 {
+		return ErrInvalidStock
+	}
+	return nil
+}
+
+// EffectivePrice is the price the variant sells for.
+func (v *Variant) EffectivePrice(product *Product) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
+
+// CheckVariants tells whether the options of the product still cover the
+// combinations of its variants, so options in use are not dropped.
+func (p *Product) CheckVariants(variants []Variant) error {
+	for _, variant := range variants {
+		if !p.hasCombination(variant.Options) {
+			return ErrOptionsInUse
+		}
+	}
+	return nil
+}
+
+// GenerateVariants makes a variant for every combination of option values
+// that existing lacks. Variants of a product with a SKU get the product SKU
+// suffixed with their values.
+func (p *Product) GenerateVariants(existing []Variant) []*Variant {
+	taken := map[string]bool{}
+	for _, variant := range existing {
+		taken[variant.OptionsKey] = true
+	}
+	var variants []*Variant
+	for _, options := range p.combinations() {
+		if taken[optionsKey(options)] {
+			continue
+		}
+		variant, err := NewVariant(p, options)
+		if err != nil {
+			continue
+		}
+		variant.SKU = p.variantSKU(options)
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// validateOptions checks the option axes of the product.
+func (p *Product) validateOptions() error {
+	if len(p.Options) > MaxProductOptions {
+		return ErrTooManyOptions
+	}
+	names := map[string]bool{}
+	combinations := 1
+	for _, option := range p.Options {
+		name := strings.TrimSpace(option.Name)
+		if name == "" || name != option.Name || names[name] || len(option.Values) == 0 {
+			return ErrInvalidOptions
+		}
+		names[name] = true
+		values := map[string]bool{}
+		for _, value := range option.Values {
+			if strings.TrimSpace(value) == "" || values[value] {
+				return ErrInvalidOptions
+			}
+			values[value] = true
+		}
+		combinations *= len(option.Values)
+		if combinations > MaxProductVariants {
+			return ErrTooManyVariants
+		}
+	}
+	return nil
+}
+
+func (p *Product) hasCombination(options map[string]string) bool {
+	if len(options) != len(p.Options) {
+		return false
+	}
+	for _, option := range p.Options {
+		value, ok := options[option.Name]
+		if !ok || !slices.Contains(option.Values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// combinations returns the cartesian product of the option values, in the
+// order the options and their values are listed.
+func (p *Product) combinations() []map[string]string {
+	if len(p.Options) == 0 {
+		return nil
+	}
+	combinations := []map[string]string{{}}
+	for _, option := range p.Options {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range option.Values {
+				options := make(map[string]string, len(combination)+1)
+				for name, v := range combination {
+					options[name] = v
+				}
+				options[option.Name] = value
+				next = append(next, options)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+func (p *Product) variantSKU(options map[string]string) string {
+	if p.SKU == "" {
+		return ""
+	}
+	parts := []string{p.SKU}
+	for _, option := range p.Options {
+		parts = append(parts, skuPart(options[option.Name]))
+	}
+	sku := strings.Join(parts, "-")
+	if !skuPattern.MatchString(sku) {
+		return ""
+	}
+	return sku
+}
+
+// skuPart upper cases value and turns what a SKU cannot hold into dashes.
+func skuPart(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(value))
+}
+
+// optionsKey identifies a combination whatever the order of the options.
+func optionsKey(options map[string]string) string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + options[name]
+	}
+	return strings.Join(pairs, "\x1f")
+}
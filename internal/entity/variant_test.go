@@ -0,0 +1,85 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newShirt(t *testing.T) *Product {
+	product, err := NewProduct("Shirt", 20)
+	assert.Nil(t, err)
+	product.SKU = "SHIRT"
+	product.Options = []ProductOption{
+		{Name: "Size", Values: []string{"S", "M", "L"}},
+		{Name: "Color", Values: []string{"Red", "Navy Blue"}},
+	}
+	assert.Nil(t, product.Validate())
+	return product
+}
+
+func TestProductOptionsValidation(t *testing.T) {
+	product := newShirt(t)
+
+	product.Options = []ProductOption{{Name: "Size", Values: []string{"S"}}, {Name: "Size", Values: []string{"M"}}}
+	assert.Equal(t, ErrInvalidOptions, product.Validate())
+	product.Options = []ProductOption{{Name: "Size"}}
+	assert.Equal(t, ErrInvalidOptions, product.Validate())
+	product.Options = []ProductOption{{Name: "Size", Values: []string{"S", "S"}}}
+	assert.Equal(t, ErrInvalidOptions, product.Validate())
+	product.Options = []ProductOption{{Name: "A", Values: []string{"1"}}, {Name: "B", Values: []string{"1"}}, {Name: "C", Values: []string{"1"}}, {Name: "D", Values: []string{"1"}}}
+	assert.Equal(t, ErrTooManyOptions, product.Validate())
+	values := make([]string, 11)
+	for i := range values {
+		values[i] = string(rune('a' + i))
+	}
+	product.Options = []ProductOption{{Name: "A", Values: values}, {Name: "B", Values: values}}
+	assert.Equal(t, ErrTooManyVariants, product.Validate())
+}
+
+func TestNewVariant(t *testing.T) {
+	product := newShirt(t)
+
+	variant, err := NewVariant(product, map[string]string{"Size": "M", "Color": "Red"})
+	assert.Nil(t, err)
+	assert.Equal(t, product.ID, variant.ProductID)
+	assert.Nil(t, variant.Validate(product))
+	assert.Equal(t, 20.0, variant.EffectivePrice(product))
+	price := 25.0
+	variant.Price = &price
+	assert.Equal(t, 25.0, variant.EffectivePrice(product))
+	variant.Stock = -1
+	assert.Equal(t, ErrInvalidStock, variant.Validate(product))
+
+	_, err = NewVariant(product, map[string]string{"Size": "XL", "Color": "Red"})
+	assert.Equal(t, ErrInvalidVariantOptions, err)
+	_, err = NewVariant(product, map[string]string{"Size": "M"})
+	assert.Equal(t, ErrInvalidVariantOptions, err)
+	plain, _ := NewProduct("Mug", 10)
+	_, err = NewVariant(plain, map[string]string{})
+	assert.Equal(t, ErrProductHasNoOptions, err)
+
+	variant.Stock = 0
+	assert.Nil(t, product.CheckVariants([]Variant{*variant}))
+	product.Options[1].Values = []string{"Navy Blue"}
+	assert.Equal(t, ErrInvalidVariantOptions, variant.Validate(product))
+	assert.Equal(t, ErrOptionsInUse, product.CheckVariants([]Variant{*variant}))
+}
+
+func TestGenerateVariants(t *testing.T) {
+	product := newShirt(t)
+	existing, _ := NewVariant(product, map[string]string{"Size": "S", "Color": "Red"})
+
+	variants := product.GenerateVariants([]Variant{*existing})
+	assert.Len(t, variants, 5)
+	assert.Equal(t, map[string]string{"Size": "S", "Color": "Navy Blue"}, variants[0].Options)
+	assert.Equal(t, "SHIRT-S-NAVY-BLUE", variants[0].SKU)
+	assert.Equal(t, "SHIRT-L-NAVY-BLUE", variants[4].SKU)
+	for _, variant := range variants {
+		assert.Nil(t, variant.Validate(product))
+		assert.NotEqual(t, existing.OptionsKey, variant.OptionsKey)
+	}
+
+	product.SKU = ""
+	assert.Empty(t, product.GenerateVariants(nil)[0].SKU)
+}
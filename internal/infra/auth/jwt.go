@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// hmacKeyID identifies tokens signed with the shared secret.
+const hmacKeyID = "hs256"
+
+// Token types carried in the typ header.
+const (
+	TypeAccessToken = "at+jwt"
+	TypeIDToken     = "JWT"
+)
+
+var (
+	ErrUnknownKey     = errors.New("token signed with an unknown key")
+	ErrNotAccessToken = errors.New("token is not an access token")
+)
+
+// JWTAuth signs tokens with a single active key and verifies them against
+// every configured key, picked by the kid header. Keeping the previous keys
+// as verification keys for a token lifetime allows rotating the signing key
+// without downtime. Tokens carry and are validated against iss and aud.
+type JWTAuth struct {
+	signing  *Key
+	secret   []byte
+	keys     map[string]*Key
+	Issuer   string
+	Audience string
+}
+
+// New returns a JWTAuth signing with signing and also accepting tokens signed
+// by the verification keys.
+func New(signing *Key, verification []*Key, issuer, audience string) *JWTAuth {
+	a := &JWTAuth{
+		signing:  signing,
+		keys:     map[string]*Key{signing.ID: signing},
+		Issuer:   issuer,
+		Audience: audience,
+	}
+	for _, key := range verification {
+		a.keys[key.ID] = key
+	}
+	return a
+}
+
+// NewHMAC returns a JWTAuth signing with a shared HS256 secret. Such tokens
+// cannot be verified by other services without sharing the secret, prefer New.
+func NewHMAC(secret []byte, issuer, audience string) *JWTAuth {
+	return &JWTAuth{secret: secret, Issuer: issuer, Audience: audience}
+}
+
+// Encode signs claims as an access token (RFC 9068), adding the iss and aud
+// claims when they are missing.
+func (a *JWTAuth) Encode(claims map[string]interface{}) (jwt.Token, string, error) {
+	return a.encode(TypeAccessToken, claims)
+}
+
+// EncodeIDToken signs claims as an OpenID Connect ID token. Its typ header
+// differs from the one of access tokens, so Decode rejects it and a client
+// cannot use it as a bearer token even though it is signed with the same key.
+func (a *JWTAuth) EncodeIDToken(claims map[string]interface{}) (jwt.Token, string, error) {
+	return a.encode(TypeIDToken, claims)
+}
+
+func (a *JWTAuth) encode(typ string, claims map[string]interface{}) (jwt.Token, string, error) {
+	token := jwt.New()
+	if a.Issuer != "" {
+		token.Set(jwt.IssuerKey, a.Issuer)
+	}
+	if a.Audience != "" {
+		token.Set(jwt.AudienceKey, []string{a.Audience})
+	}
+	for k, v := range claims {
+		if err := token.Set(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return nil, "", err
+	}
+	// jwt.Sign would overwrite typ with JWT, so the token is signed as a
+	// plain JWS payload instead.
+	headers := jws.NewHeaders()
+	headers.Set(jws.TypeKey, typ)
+	var signed []byte
+	if a.signing != nil {
+		key, keyErr := a.signing.signingKey()
+		if keyErr != nil {
+			return nil, "", keyErr
+		}
+		signed, err = jws.Sign(payload, a.signing.Algorithm, key, jws.WithHeaders(headers))
+	} else {
+		headers.Set(jws.KeyIDKey, hmacKeyID)
+		signed, err = jws.Sign(payload, jwa.HS256, a.secret, jws.WithHeaders(headers))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return token, string(signed), nil
+}
+
+// Decode verifies the signature of tokenString with the key named by its kid
+// header, using the algorithm of that key rather than the one the token
+// claims, and validates its exp, nbf, iat, iss and aud claims. Only access
+// tokens are accepted.
+func (a *JWTAuth) Decode(tokenString string) (jwt.Token, error) {
+	msg, err := jws.ParseString(tokenString)
+	if err != nil || len(msg.Signatures()) != 1 {
+		return nil, jwtauth.ErrUnauthorized
+	}
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	if headers.Type() != TypeAccessToken {
+		return nil, ErrNotAccessToken
+	}
+	kid := headers.KeyID()
+
+	var verify jwt.ParseOption
+	if a.signing == nil {
+		if kid != "" && kid != hmacKeyID {
+			return nil, ErrUnknownKey
+		}
+		verify = jwt.WithVerify(jwa.HS256, a.secret)
+	} else {
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		verify = jwt.WithVerify(key.Algorithm, key.Public)
+	}
+	token, err := jwt.ParseString(tokenString, verify)
+	if err != nil {
+		return nil, jwtauth.ErrUnauthorized
+	}
+	options := []jwt.ValidateOption{jwt.WithAcceptableSkew(30 * time.Second)}
+	if a.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.Issuer))
+	}
+	if a.Audience != "" {
+		options = append(options, jwt.WithAudience(a.Audience))
+	}
+	if err := jwt.Validate(token, options...); err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+	return token, nil
+}
+
+// Algorithm returns the algorithm tokens are signed with.
+func (a *JWTAuth) Algorithm() jwa.SignatureAlgorithm {
+	if a.signing == nil {
+		return jwa.HS256
+	}
+	return a.signing.Algorithm
+}
+
+// JWKS returns the public verification keys as a JWK set.
+func (a *JWTAuth) JWKS() (jwk.Set, error) {
+	set := jwk.NewSet()
+	for _, key := range a.keys {
+		jwkKey, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+		set.Add(jwkKey)
+	}
+	return set, nil
+}
+
+// Verifier works like jwtauth.Verifier: it stores the token found in the
+// Authorization header or jwt cookie, and the verification error, in the
+// request context for jwtauth.Authenticator and jwtauth.FromContext.
+func Verifier(a *JWTAuth) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := a.VerifyRequest(r)
+			ctx := jwtauth.NewContext(r.Context(), token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (a *JWTAuth) VerifyRequest(r *http.Request) (jwt.Token, error) {
+	tokenString := jwtauth.TokenFromHeader(r)
+	if tokenString == "" {
+		tokenString = jwtauth.TokenFromCookie(r)
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+	return a.Decode(tokenString)
+}
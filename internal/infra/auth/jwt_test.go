@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func pemKey(t *testing.T, raw interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(raw)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func newTestKey(t *testing.T, alg jwa.SignatureAlgorithm) *Key {
+	var raw interface{}
+	switch alg {
+	case jwa.RS256:
+		raw, _ = rsa.GenerateKey(rand.Reader, 2048)
+	case jwa.ES256:
+		raw, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwa.EdDSA:
+		_, raw, _ = ed25519.GenerateKey(rand.Reader)
+	}
+	key, err := ParseKey(pemKey(t, raw))
+	assert.Nil(t, err)
+	assert.Equal(t, alg, key.Algorithm)
+	return key
+}
+
+func claims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.EdDSA} {
+		key := newTestKey(t, alg)
+		tokenAuth := New(key, nil, "products-api", "products")
+		_, tokenString, err := tokenAuth.Encode(claims())
+		assert.Nil(t, err, alg)
+
+		msg, err := jws.ParseString(tokenString)
+		assert.Nil(t, err)
+		assert.Equal(t, key.ID, msg.Signatures()[0].ProtectedHeaders().KeyID())
+
+		token, err := tokenAuth.Decode(tokenString)
+		assert.Nil(t, err, alg)
+		assert.Equal(t, "user-1", token.Subject())
+		assert.Equal(t, "products-api", token.Issuer())
+		assert.Equal(t, []string{"products"}, token.Audience())
+	}
+}
+
+func TestDecodeWithRotatedKeys(t *testing.T) {
+	oldKey := newTestKey(t, jwa.RS256)
+	newKey := newTestKey(t, jwa.EdDSA)
+	_, oldToken, _ := New(oldKey, nil, "", "").Encode(claims())
+
+	rotated := New(newKey, []*Key{oldKey}, "", "")
+	_, err := rotated.Decode(oldToken)
+	assert.Nil(t, err)
+	_, newToken, _ := rotated.Encode(claims())
+	_, err = rotated.Decode(newToken)
+	assert.Nil(t, err)
+
+	retired := New(newKey, nil, "", "")
+	_, err = retired.Decode(oldToken)
+	assert.Equal(t, ErrUnknownKey, err)
+}
+
+func TestDecodeValidatesClaims(t *testing.T) {
+	key := newTestKey(t, jwa.ES256)
+	_, tokenString, _ := New(key, nil, "other-issuer", "products").Encode(claims())
+	_, err := New(key, nil, "products-api", "products").Decode(tokenString)
+	assert.Equal(t, jwtauth.ErrUnauthorized, err)
+
+	_, tokenString, _ = New(key, nil, "products-api", "other").Encode(claims())
+	_, err = New(key, nil, "products-api", "products").Decode(tokenString)
+	assert.Equal(t, jwtauth.ErrUnauthorized, err)
+
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, tokenString, _ = New(key, nil, "", "").Encode(expired)
+	_, err = New(key, nil, "", "").Decode(tokenString)
+	assert.Equal(t, jwtauth.ErrExpired, err)
+}
+
+func TestDecodeRejectsAlgorithmConfusion(t *testing.T) {
+	key := newTestKey(t, jwa.RS256)
+	publicDER, _ := x509.MarshalPKIXPublicKey(key.Public)
+	headers := jws.NewHeaders()
+	headers.Set(jws.KeyIDKey, key.ID)
+	headers.Set(jws.TypeKey, TypeAccessToken)
+	token := jwt.New()
+	token.Set("sub", "attacker")
+	payload, _ := json.Marshal(token)
+	forged, err := jws.Sign(payload, jwa.HS256, publicDER, jws.WithHeaders(headers))
+	assert.Nil(t, err)
+
+	_, err = New(key, nil, "", "").Decode(string(forged))
+	assert.Equal(t, jwtauth.ErrUnauthorized, err)
+}
+
+func TestDecodeRejectsIDTokens(t *testing.T) {
+	for _, tokenAuth := range []*JWTAuth{New(newTestKey(t, jwa.ES256), nil, "", ""), NewHMAC([]byte("secret"), "", "")} {
+		_, tokenString, err := tokenAuth.EncodeIDToken(claims())
+		assert.Nil(t, err)
+		_, err = tokenAuth.Decode(tokenString)
+		assert.Equal(t, ErrNotAccessToken, err)
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	tokenAuth := NewHMAC([]byte("secret"), "products-api", "")
+	_, tokenString, err := tokenAuth.Encode(claims())
+	assert.Nil(t, err)
+	token, err := tokenAuth.Decode(tokenString)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", token.Subject())
+
+	_, err = NewHMAC([]byte("other"), "products-api", "").Decode(tokenString)
+	assert.Equal(t, jwtauth.ErrUnauthorized, err)
+}
+
+func TestJWKSAndVerifier(t *testing.T) {
+	signing := newTestKey(t, jwa.EdDSA)
+	previous := newTestKey(t, jwa.RS256)
+	tokenAuth := New(signing, []*Key{previous}, "", "")
+
+	set, err := tokenAuth.JWKS()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, set.Len())
+	raw, _ := json.Marshal(set)
+	assert.NotContains(t, string(raw), `"d":`)
+	_, ok := set.LookupKeyID(previous.ID)
+	assert.True(t, ok)
+
+	_, tokenString, _ := tokenAuth.Encode(claims())
+	handler := Verifier(tokenAuth)(jwtauth.Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, _ := jwtauth.FromContext(r.Context())
+		w.Write([]byte(token.Subject()))
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user-1", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+var (
+	ErrNoPEMBlock         = errors.New("no PEM block found")
+	ErrUnsupportedKeyType = errors.New("unsupported key type, expected RSA, ECDSA (P-256, P-384, P-521) or Ed25519")
+)
+
+// Key is an asymmetric JWT key identified by its RFC 7638 thumbprint. Private
+// is nil for keys that may only verify tokens.
+type Key struct {
+	ID        string
+	Algorithm jwa.SignatureAlgorithm
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+// LoadKeyFile reads a PEM encoded private or public key.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseKey parses a PEM encoded PKCS#8, PKCS#1 or SEC 1 private key, or a
+// PKIX or PKCS#1 public key.
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEMBlock
+	}
+	var raw interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		raw, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		raw, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		raw, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		raw, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		raw, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(raw)
+}
+
+// NewKey wraps a raw private or public key, inferring the signing algorithm
+// from its type.
+func NewKey(raw interface{}) (*Key, error) {
+	key := &Key{}
+	if signer, ok := raw.(crypto.Signer); ok {
+		key.Private = signer
+		key.Public = signer.Public()
+	} else {
+		key.Public = raw
+	}
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm = jwa.RS256
+	case *ecdsa.PublicKey:
+		switch public.Curve {
+		case elliptic.P256():
+			key.Algorithm = jwa.ES256
+		case elliptic.P384():
+			key.Algorithm = jwa.ES384
+		case elliptic.P521():
+			key.Algorithm = jwa.ES512
+		default:
+			return nil, ErrUnsupportedKeyType
+		}
+	case ed25519.PublicKey:
+		key.Algorithm = jwa.EdDSA
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+	jwkKey, err := jwk.New(key.Public)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := jwkKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	return key, nil
+}
+
+// JWK returns the public half of the key as a JWK.
+func (k *Key) JWK() (jwk.Key, error) {
+	jwkKey, err := jwk.New(k.Public)
+	if err != nil {
+		return nil, err
+	}
+	jwkKey.Set(jwk.KeyIDKey, k.ID)
+	jwkKey.Set(jwk.AlgorithmKey, k.Algorithm.String())
+	jwkKey.Set(jwk.KeyUsageKey, "sig")
+	return jwkKey, nil
+}
+
+// signingKey returns the key handed to jws so the kid header is set.
+func (k *Key) signingKey() (interface{}, error) {
+	jwkKey, err := jwk.New(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	jwkKey.Set(jwk.KeyIDKey, k.ID)
+	return jwkKey, nil
+}
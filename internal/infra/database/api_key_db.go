@@ -0,0 +1,51 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+type APIKey struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyDB(db *gorm.DB) *APIKey {
+	return &APIKey{DB: db}
+}
+
+func (k *APIKey) Create(key *entity.APIKey) error {
+	return k.DB.Create(key).Error
+}
+
+func (k *APIKey) FindByPrefix(prefix string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	if err := k.DB.First(&key, "prefix = ?", prefix).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (k *APIKey) FindAllByUser(userID string) ([]entity.APIKey, error) {
+	var keys []entity.APIKey
+	err := k.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+// Delete removes a key only if it belongs to userID, so users cannot revoke
+// each other's keys by guessing IDs.
+func (k *APIKey) Delete(id, userID string) error {
+	result := k.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&entity.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (k *APIKey) Touch(id string, usedAt time.Time) error {
+	return k.DB.Model(&entity.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.APIKey{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	other, _ := entity.NewUser("Jane", "jane@j.com", "123456")
+	key, _, err := entity.NewAPIKey(user.ID, "batch job", []string{entity.ScopeProductsRead}, nil)
+	assert.Nil(t, err)
+	keyDB := NewAPIKeyDB(db)
+	assert.Nil(t, keyDB.Create(key))
+
+	found, err := keyDB.FindByPrefix(key.Prefix)
+	assert.Nil(t, err)
+	assert.Equal(t, key.ID, found.ID)
+	assert.Equal(t, []string{entity.ScopeProductsRead}, found.Scopes)
+	assert.Equal(t, key.SecretHash, found.SecretHash)
+
+	usedAt := time.Now()
+	assert.Nil(t, keyDB.Touch(key.ID.String(), usedAt))
+	keys, err := keyDB.FindAllByUser(user.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	assert.Equal(t, gorm.ErrRecordNotFound, keyDB.Delete(key.ID.String(), other.ID.String()))
+	assert.Nil(t, keyDB.Delete(key.ID.String(), user.ID.String()))
+	_, err = keyDB.FindByPrefix(key.Prefix)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+type AuditLog struct {
+	DB *gorm.DB
+}
+
+func NewAuditLogDB(db *gorm.DB) *AuditLog {
+	return &AuditLog{DB: db}
+}
+
+func (a *AuditLog) Create(log *entity.AuditLog) error {
+	return a.DB.Create(log).Error
+}
+
+func (a *AuditLog) FindAll(filter AuditLogFilter, page, limit int) (*PaginatedAuditLogsResponse, error) {
+	query := a.DB.Model(&entity.AuditLog{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if page != 0 && limit != 0 {
+		query = query.Limit(limit).Offset((page - 1) * limit)
+	}
+	var logs []entity.AuditLog
+	if err := query.Order("created_at desc").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return &PaginatedAuditLogsResponse{
+		Data:  logs,
+		Page:  page,
+		Limit: limit,
+	}, nil
+}
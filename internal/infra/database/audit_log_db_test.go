@@ -0,0 +1,31 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateAndFindAuditLogs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.AuditLog{})
+	auditLogDB := NewAuditLogDB(db)
+	actorID := entityPkg.NewID()
+	assert.Nil(t, auditLogDB.Create(entity.NewAuditLog(actorID, entity.AuditUserDisabled, "user-1", "", "")))
+	assert.Nil(t, auditLogDB.Create(entity.NewAuditLog(actorID, entity.AuditUserEnabled, "user-2", "", "")))
+
+	response, err := auditLogDB.FindAll(AuditLogFilter{TargetID: "user-2"}, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, entity.AuditUserEnabled, response.Data[0].Action)
+
+	response, _ = auditLogDB.FindAll(AuditLogFilter{ActorID: actorID.String()}, 0, 0)
+	assert.Len(t, response.Data, 2)
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryAlreadyExists = errors.New("a category with this name already exists")
+	ErrCategoryInUse         = errors.New("category has products")
+)
+
+// Category is scoped to the tenant carried by the context of each call.
+type Category struct {
+	DB *gorm.DB
+}
+
+func NewCategoryDB(db *gorm.DB) *Category {
+	return &Category{DB: db}
+}
+
+func (c *Category) scoped(ctx context.Context) *gorm.DB {
+	return c.DB.WithContext(ctx).Scopes(TenantScope(ctx))
+}
+
+// Create adds category to the tenant of ctx, whatever its TenantID.
+func (c *Category) Create(ctx context.Context, category *entity.Category) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	category.TenantID = tenantID
+	if err := c.checkName(ctx, category); err != nil {
+		return err
+	}
+	err := c.DB.WithContext(ctx).Create(category).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrCategoryAlreadyExists
+	}
+	return err
+}
+
+func (c *Category) FindByID(ctx context.Context, id string) (*entity.Category, error) {
+	var category entity.Category
+	if err := c.scoped(ctx).First(&category, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// FindAll returns the categories of the tenant of ctx by name.
+func (c *Category) FindAll(ctx context.Context) ([]entity.Category, error) {
+	categories := []entity.Category{}
+	if err := c.scoped(ctx).Order("name asc").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// Update saves category if it belongs to the tenant of ctx. It cannot be
+// moved to another tenant.
+func (c *Category) Update(ctx context.Context, category *entity.Category) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	category.TenantID = tenantID
+	if err := c.checkName(ctx, category); err != nil {
+		return err
+	}
+	result := c.scoped(ctx).Model(category).Select("*").Updates(category)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return ErrCategoryAlreadyExists
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes a category no product of the tenant of ctx belongs to.
+func (c *Category) Delete(ctx context.Context, id string) error {
+	var count int64
+	err := c.scoped(ctx).Model(&entity.Product{}).Where("category_id = ?", id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategoryInUse
+	}
+	result := c.scoped(ctx).Delete(&entity.Category{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (c *Category) checkName(ctx context.Context, category *entity.Category) error {
+	var count int64
+	err := c.scoped(ctx).Model(&entity.Category{}).
+		Where("name = ? AND id <> ?", category.Name, category.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategoryAlreadyExists
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCategoryDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Category{}, &entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{})
+	categoryDB := NewCategoryDB(db)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	otherCtx := WithTenant(context.Background(), entityPkg.NewID())
+
+	clothing, _ := entity.NewCategory("Clothing", []entity.AttributeDefinition{{Name: "material", Type: entity.AttributeTypeString}})
+	assert.Nil(t, categoryDB.Create(ctx, clothing))
+	duplicate, _ := entity.NewCategory("Clothing", nil)
+	assert.Equal(t, ErrCategoryAlreadyExists, categoryDB.Create(ctx, duplicate))
+	assert.Nil(t, categoryDB.Create(otherCtx, duplicate))
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	assert.Nil(t, categoryDB.Create(ctx, electronics))
+	assert.Equal(t, ErrTenantRequired, categoryDB.Create(context.Background(), electronics))
+
+	categories, err := categoryDB.FindAll(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, categories, 2)
+	assert.Equal(t, "Clothing", categories[0].Name)
+	assert.Equal(t, "material", categories[0].Attributes[0].Name)
+	_, err = categoryDB.FindByID(otherCtx, clothing.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	electronics.Name = "Clothing"
+	assert.Equal(t, ErrCategoryAlreadyExists, categoryDB.Update(ctx, electronics))
+	electronics.Name = "Appliances"
+	assert.Nil(t, categoryDB.Update(ctx, electronics))
+	assert.ErrorIs(t, categoryDB.Update(otherCtx, electronics), gorm.ErrRecordNotFound)
+
+	product, _ := entity.NewProduct("Shirt", 10)
+	product.CategoryID = &clothing.ID
+	assert.Nil(t, NewProductDB(db).Create(ctx, product))
+	assert.Equal(t, ErrCategoryInUse, categoryDB.Delete(ctx, clothing.ID.String()))
+	assert.Nil(t, categoryDB.Delete(ctx, electronics.ID.String()))
+	assert.ErrorIs(t, categoryDB.Delete(ctx, electronics.ID.String()), gorm.ErrRecordNotFound)
+}
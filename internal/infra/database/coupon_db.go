@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+var ErrCouponAlreadyExists = errors.New("a coupon with this code already exists")
+
+// Coupon is scoped to the tenant carried by the context of each call.
+type Coupon struct {
+	DB *gorm.DB
+}
+
+func NewCouponDB(db *gorm.DB) *Coupon {
+	return &Coupon{DB: db}
+}
+
+func (c *Coupon) scoped(ctx context.Context) *gorm.DB {
+	return c.DB.WithContext(ctx).Scopes(TenantScope(ctx))
+}
+
+// Create adds coupon to the tenant of ctx, whatever its TenantID.
+func (c *Coupon) Create(ctx context.Context, coupon *entity.Coupon) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	coupon.TenantID = tenantID
+	var count int64
+	if err := c.scoped(ctx).Model(&entity.Coupon{}).Where("code = ?", coupon.Code).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCouponAlreadyExists
+	}
+	err := c.DB.WithContext(ctx).Create(coupon).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrCouponAlreadyExists
+	}
+	return err
+}
+
+// CreateBatch adds coupons to the tenant of ctx at once. None is added when
+// the code of any already exists.
+func (c *Coupon) CreateBatch(ctx context.Context, coupons []entity.Coupon) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	for i := range coupons {
+		coupons[i].TenantID = tenantID
+	}
+	err := c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(coupons, 100).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrCouponAlreadyExists
+	}
+	return err
+}
+
+func (c *Coupon) FindByID(ctx context.Context, id string) (*entity.Coupon, error) {
+	var coupon entity.Coupon
+	if err := c.scoped(ctx).First(&coupon, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// FindAll returns the coupons of the tenant of ctx, newest first, only those
+// of batchID when set.
+func (c *Coupon) FindAll(ctx context.Context, batchID string, page, limit int) ([]entity.Coupon, error) {
+	query := c.scoped(ctx).Order("created_at desc, code asc")
+	if batchID != "" {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if page > 0 && limit > 0 {
+		query = query.Limit(limit).Offset((page - 1) * limit)
+	}
+	coupons := []entity.Coupon{}
+	if err := query.Find(&coupons).Error; err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// Update saves the rule, limits and expiration of coupon if it belongs to
+// the tenant of ctx. Its code and redemptions are left as they are.
+func (c *Coupon) Update(ctx context.Context, coupon *entity.Coupon) error {
+	if _, ok := TenantFromContext(ctx); !ok {
+		return ErrTenantRequired
+	}
+	result := c.scoped(ctx).Model(&entity.Coupon{}).Where("id = ?", coupon.ID).
+		Select("type", "percent", "amount", "min_basket", "max_redemptions", "max_per_user", "expires_at").
+		Updates(coupon)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes a coupon of the tenant of ctx along with its redemptions.
+func (c *Coupon) Delete(ctx context.Context, id string) error {
+	return c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(TenantScope(ctx)).Delete(&entity.Coupon{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("coupon_id = ?", id).Delete(&entity.CouponRedemption{}).Error
+	})
+}
+
+// Redeem redeems the coupon of the tenant of ctx with code for userID on
+// basket at now, and returns the coupon and the redemption recorded. It
+// returns gorm.ErrRecordNotFound for an unknown code, the error of
+// Coupon.Discount when the coupon does not apply, ErrCouponExhausted when
+// it has no redemptions left and ErrCouponUserLimit when userID used up its
+// own. Concurrent redemptions cannot exceed either limit.
+func (c *Coupon) Redeem(ctx context.Context, code string, userID entityPkg.ID, basket float64, now time.Time) (*entity.Coupon, *entity.CouponRedemption, error) {
+	if _, ok := TenantFromContext(ctx); !ok {
+		return nil, nil, ErrTenantRequired
+	}
+	var coupon entity.Coupon
+	var redemption *entity.CouponRedemption
+	err := c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(TenantScope(ctx)).First(&coupon, "code = ?", entity.NormalizeCouponCode(code)).Error; err != nil {
+			return err
+		}
+		discount, err := coupon.Discount(basket, now)
+		if err != nil {
+			return err
+		}
+		// Counting in the update itself keeps concurrent redemptions from
+		// all reading the last one left, and locks the coupon until the
+		// transaction ends, so the per user count below cannot race either.
+		result := tx.Model(&entity.Coupon{}).
+			Where("id = ? AND (max_redemptions = 0 OR redemptions < max_redemptions)", coupon.ID).
+			UpdateColumn("redemptions", gorm.Expr("redemptions + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrCouponExhausted
+		}
+		coupon.Redemptions++
+		if coupon.MaxPerUser > 0 {
+			var used int64
+			err := tx.Model(&entity.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", coupon.ID, userID).Count(&used).Error
+			if err != nil {
+				return err
+			}
+			if used >= int64(coupon.MaxPerUser) {
+				return entity.ErrCouponUserLimit
+			}
+		}
+		redemption = entity.NewCouponRedemption(&coupon, userID, basket, discount)
+		return tx.Create(redemption).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &coupon, redemption, nil
+}
+
+// FindRedemptions returns the redemptions of a coupon of the tenant of ctx,
+// newest first.
+func (c *Coupon) FindRedemptions(ctx context.Context, couponID string, page, limit int) ([]entity.CouponRedemption, error) {
+	query := c.scoped(ctx).Where("coupon_id = ?", couponID).Order("created_at desc")
+	if page > 0 && limit > 0 {
+		query = query.Limit(limit).Offset((page - 1) * limit)
+	}
+	redemptions := []entity.CouponRedemption{}
+	if err := query.Find(&redemptions).Error; err != nil {
+		return nil, err
+	}
+	return redemptions, nil
+}
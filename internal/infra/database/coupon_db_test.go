@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newCouponTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to file::memory: opens a new database.
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	db.AutoMigrate(&entity.Coupon{}, &entity.CouponRedemption{})
+	return db
+}
+
+func newTestCoupon(t *testing.T, code string, maxRedemptions, maxPerUser int) *entity.Coupon {
+	coupon, err := entity.NewCoupon(code, entity.PromotionPercentageOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coupon.Percent, coupon.MaxRedemptions, coupon.MaxPerUser = 10, maxRedemptions, maxPerUser
+	return coupon
+}
+
+func TestCouponDB(t *testing.T) {
+	couponDB := NewCouponDB(newCouponTestDB(t))
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	otherCtx := WithTenant(context.Background(), entityPkg.NewID())
+
+	coupon := newTestCoupon(t, "SAVE10", 0, 0)
+	assert.Nil(t, couponDB.Create(ctx, coupon))
+	assert.Equal(t, ErrCouponAlreadyExists, couponDB.Create(ctx, newTestCoupon(t, "SAVE10", 0, 0)))
+	assert.Nil(t, couponDB.Create(otherCtx, newTestCoupon(t, "SAVE10", 0, 0)))
+	assert.Equal(t, ErrTenantRequired, couponDB.Create(context.Background(), newTestCoupon(t, "OTHER", 0, 0)))
+
+	batch, err := entity.NewCouponBatch(newTestCoupon(t, "", 1, 0), "B-", 5)
+	assert.Nil(t, err)
+	assert.Nil(t, couponDB.CreateBatch(ctx, batch))
+	coupons, err := couponDB.FindAll(ctx, "", 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, coupons, 6)
+	coupons, err = couponDB.FindAll(ctx, batch[0].BatchID.String(), 1, 2)
+	assert.Nil(t, err)
+	assert.Len(t, coupons, 2)
+
+	coupon.MaxRedemptions = 5
+	assert.Nil(t, couponDB.Update(ctx, coupon))
+	assert.ErrorIs(t, couponDB.Update(otherCtx, coupon), gorm.ErrRecordNotFound)
+	found, err := couponDB.FindByID(ctx, coupon.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 5, found.MaxRedemptions)
+
+	_, _, err = couponDB.Redeem(ctx, "save10", entityPkg.NewID(), 50, time.Now())
+	assert.Nil(t, err)
+	assert.ErrorIs(t, couponDB.Delete(otherCtx, coupon.ID.String()), gorm.ErrRecordNotFound)
+	assert.Nil(t, couponDB.Delete(ctx, coupon.ID.String()))
+	redemptions, err := couponDB.FindRedemptions(ctx, coupon.ID.String(), 0, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, redemptions)
+}
+
+func TestRedeemCoupon(t *testing.T) {
+	couponDB := NewCouponDB(newCouponTestDB(t))
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	otherCtx := WithTenant(context.Background(), entityPkg.NewID())
+	coupon := newTestCoupon(t, "TWICE", 3, 2)
+	coupon.MinBasket = 20
+	assert.Nil(t, couponDB.Create(ctx, coupon))
+	alice, bob := entityPkg.NewID(), entityPkg.NewID()
+	now := time.Now()
+
+	redeemed, redemption, err := couponDB.Redeem(ctx, "twice", alice, 45, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, redeemed.Redemptions)
+	assert.Equal(t, 4.5, redemption.Discount)
+	assert.Equal(t, alice, redemption.UserID)
+	assert.Equal(t, 40.5, redemption.Total())
+
+	_, _, err = couponDB.Redeem(ctx, "TWICE", alice, 10, now)
+	assert.Equal(t, entity.ErrBasketBelowMinimum, err)
+	_, _, err = couponDB.Redeem(otherCtx, "TWICE", alice, 45, now)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, _, err = couponDB.Redeem(ctx, "TWICE", alice, 45, now)
+	assert.Nil(t, err)
+	_, _, err = couponDB.Redeem(ctx, "TWICE", alice, 45, now)
+	assert.Equal(t, entity.ErrCouponUserLimit, err)
+	_, _, err = couponDB.Redeem(ctx, "TWICE", bob, 45, now)
+	assert.Nil(t, err)
+	_, _, err = couponDB.Redeem(ctx, "TWICE", bob, 45, now)
+	assert.Equal(t, entity.ErrCouponExhausted, err)
+
+	found, err := couponDB.FindByID(ctx, coupon.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, found.Redemptions)
+	redemptions, err := couponDB.FindRedemptions(ctx, coupon.ID.String(), 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, redemptions, 3)
+
+	expiring := newTestCoupon(t, "EXPIRED", 0, 0)
+	expiring.SetExpiration(&now)
+	assert.Nil(t, couponDB.Create(ctx, expiring))
+	_, _, err = couponDB.Redeem(ctx, "EXPIRED", alice, 45, now)
+	assert.Equal(t, entity.ErrCouponExpired, err)
+}
+
+func TestRedeemCouponConcurrently(t *testing.T) {
+	couponDB := NewCouponDB(newCouponTestDB(t))
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	coupon := newTestCoupon(t, "FIRST5", 5, 1)
+	assert.Nil(t, couponDB.Create(ctx, coupon))
+	user := entityPkg.NewID()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := map[error]int{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			userID := entityPkg.NewID()
+			if i%4 == 0 {
+				userID = user
+			}
+			_, _, err := couponDB.Redeem(ctx, "FIRST5", userID, 30, time.Now())
+			mu.Lock()
+			errs[err]++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, errs[nil])
+	found, err := couponDB.FindByID(ctx, coupon.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 5, found.Redemptions)
+	redemptions, err := couponDB.FindRedemptions(ctx, coupon.ID.String(), 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, redemptions, 5)
+	perUser := 0
+	for _, redemption := range redemptions {
+		if redemption.UserID == user {
+			perUser++
+		}
+	}
+	assert.LessOrEqual(t, perUser, 1)
+}
@@ -1,6 +1,12 @@
 package database
 
-import "github.com/ivandersr/products-api-go/internal/entity"
+import (
+	"context"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+)
 
 type PaginatedResponse struct {
 	Data  []entity.Product `json:"data"`
@@ -8,15 +14,210 @@ type PaginatedResponse struct {
 	Limit int              `json:"limit"`
 }
 
+// ProductFilter narrows a product listing. An empty Status or CategoryID
+// matches any, and products have to match every attribute filter.
+type ProductFilter struct {
+	Status     string
+	CategoryID string
+	Attributes []AttributeFilter
+}
+
+// Attribute filter operators. Eq compares texts, and also numbers when Value
+// is one; the others only compare numbers.
+const (
+	AttributeOpEq  = "eq"
+	AttributeOpGt  = "gt"
+	AttributeOpGte = "gte"
+	AttributeOpLt  = "lt"
+	AttributeOpLte = "lte"
+)
+
+// AttributeFilter matches products whose attribute Name compares to Value
+// with Op.
+type AttributeFilter struct {
+	Name  string
+	Op    string
+	Value string
+}
+
+type UserFilter struct {
+	Query    string
+	Role     string
+	Disabled *bool
+}
+
+type PaginatedUsersResponse struct {
+	Data  []entity.User `json:"data"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Total int64         `json:"total"`
+}
+
+type AuditLogFilter struct {
+	ActorID  string
+	TargetID string
+	Action   string
+}
+
+type PaginatedAuditLogsResponse struct {
+	Data  []entity.AuditLog `json:"data"`
+	Page  int               `json:"page"`
+	Limit int               `json:"limit"`
+}
+
 type UserInterface interface {
 	Create(user *entity.User) error
 	FindByEmail(email string) (*entity.User, error)
+	FindByID(id string) (*entity.User, error)
+	Update(user *entity.User) error
+	ClaimTOTPStep(id string, step int64) error
+	Delete(id string) error
+	FindAll(filter UserFilter, page, limit int) (*PaginatedUsersResponse, error)
+}
+
+type AuditLogInterface interface {
+	Create(log *entity.AuditLog) error
+	FindAll(filter AuditLogFilter, page, limit int) (*PaginatedAuditLogsResponse, error)
+}
+
+type UserTokenInterface interface {
+	Create(token *entity.UserToken) error
+	Find(id, purpose string) (*entity.UserToken, error)
+	Consume(id, purpose string) (*entity.UserToken, error)
+	Revoke(userID, purpose string) error
 }
 
+// ProductInterface is scoped to the tenant set with WithTenant on ctx.
 type ProductInterface interface {
-	Create(product *entity.Product) error
-	FindAll(page, limit int, sort string) (*PaginatedResponse, error)
-	FindByID(id string) (*entity.Product, error)
-	Update(product *entity.Product) error
+	Create(ctx context.Context, product *entity.Product) error
+	FindAll(ctx context.Context, filter ProductFilter, page, limit int, sort string) (*PaginatedResponse, error)
+	FindByID(ctx context.Context, id string) (*entity.Product, error)
+	FindBySKU(ctx context.Context, sku string) (*entity.Product, error)
+	FindByBarcode(ctx context.Context, code string) (*entity.Product, error)
+	Update(ctx context.Context, product *entity.Product) error
+	Delete(ctx context.Context, id string) error
+}
+
+// VariantInterface is scoped to the tenant set with WithTenant on ctx.
+type VariantInterface interface {
+	Create(ctx context.Context, variant *entity.Variant) error
+	CreateMany(ctx context.Context, variants []*entity.Variant) error
+	FindByID(ctx context.Context, productID, id string) (*entity.Variant, error)
+	FindBySKU(ctx context.Context, sku string) (*entity.Variant, error)
+	FindPrices(ctx context.Context, variantID string) ([]entity.VariantPrice, error)
+	FindAllByProduct(ctx context.Context, productID string) ([]entity.Variant, error)
+	FindAllByProducts(ctx context.Context, productIDs []string) ([]entity.Variant, error)
+	Update(ctx context.Context, variant *entity.Variant) error
+	Delete(ctx context.Context, productID, id string) error
+}
+
+// CategoryInterface is scoped to the tenant set with WithTenant on ctx.
+type CategoryInterface interface {
+	Create(ctx context.Context, category *entity.Category) error
+	FindByID(ctx context.Context, id string) (*entity.Category, error)
+	FindAll(ctx context.Context) ([]entity.Category, error)
+	Update(ctx context.Context, category *entity.Category) error
+	Delete(ctx context.Context, id string) error
+}
+
+// PriceInterface is scoped to the tenant set with WithTenant on ctx.
+type PriceInterface interface {
+	Create(ctx context.Context, price *entity.ProductPrice) error
+	FindByID(ctx context.Context, productID, id string) (*entity.ProductPrice, error)
+	FindAllByProduct(ctx context.Context, productID string) ([]entity.ProductPrice, error)
+	Delete(ctx context.Context, productID, id string) error
+}
+
+// PromotionInterface is scoped to the tenant set with WithTenant on ctx.
+type PromotionInterface interface {
+	Create(ctx context.Context, promotion *entity.Promotion) error
+	FindByID(ctx context.Context, id string) (*entity.Promotion, error)
+	FindAll(ctx context.Context) ([]entity.Promotion, error)
+	FindActive(ctx context.Context, at time.Time) ([]entity.Promotion, error)
+	Update(ctx context.Context, promotion *entity.Promotion) error
+	Delete(ctx context.Context, id string) error
+}
+
+// CouponInterface is scoped to the tenant set with WithTenant on ctx.
+type CouponInterface interface {
+	Create(ctx context.Context, coupon *entity.Coupon) error
+	CreateBatch(ctx context.Context, coupons []entity.Coupon) error
+	FindByID(ctx context.Context, id string) (*entity.Coupon, error)
+	FindAll(ctx context.Context, batchID string, page, limit int) ([]entity.Coupon, error)
+	Update(ctx context.Context, coupon *entity.Coupon) error
+	Delete(ctx context.Context, id string) error
+	Redeem(ctx context.Context, code string, userID entityPkg.ID, basket float64, now time.Time) (*entity.Coupon, *entity.CouponRedemption, error)
+	FindRedemptions(ctx context.Context, couponID string, page, limit int) ([]entity.CouponRedemption, error)
+}
+
+// MediaInterface is scoped to the tenant set with WithTenant on ctx.
+type MediaInterface interface {
+	Create(ctx context.Context, media *entity.Media) error
+	FindByID(ctx context.Context, productID, id string) (*entity.Media, error)
+	FindAllByProduct(ctx context.Context, productID string) ([]entity.Media, error)
+	FindAllByProducts(ctx context.Context, productIDs []string) ([]entity.Media, error)
+	UpdatePositions(ctx context.Context, media []entity.Media) error
+	Delete(ctx context.Context, productID, id string) error
+}
+
+type APIKeyInterface interface {
+	Create(key *entity.APIKey) error
+	FindByPrefix(prefix string) (*entity.APIKey, error)
+	FindAllByUser(userID string) ([]entity.APIKey, error)
+	Delete(id, userID string) error
+	Touch(id string, usedAt time.Time) error
+}
+
+type RecoveryCodeInterface interface {
+	Replace(userID string, codes []entity.RecoveryCode) error
+	Consume(userID, code string) error
+	CountUnused(userID string) (int64, error)
+}
+
+type MFAPolicyInterface interface {
+	FindAll() ([]entity.MFAPolicy, error)
+	Save(policy *entity.MFAPolicy) error
+	IsRequired(role string) (bool, error)
+}
+
+type OAuthClientInterface interface {
+	Create(client *entity.OAuthClient) error
+	FindByID(id string) (*entity.OAuthClient, error)
+	FindAll() ([]entity.OAuthClient, error)
 	Delete(id string) error
 }
+
+type OAuthConsentInterface interface {
+	Find(userID, clientID string) (*entity.OAuthConsent, error)
+	Save(consent *entity.OAuthConsent) error
+	FindAllByUser(userID string) ([]entity.OAuthConsent, error)
+	Delete(userID, clientID string) error
+}
+
+type OAuthAuthorizationCodeInterface interface {
+	Create(code *entity.OAuthAuthorizationCode) error
+	FindByID(id string) (*entity.OAuthAuthorizationCode, error)
+	Consume(id string, clientID entityPkg.ID, redirectURI string) error
+}
+
+type OAuthRefreshTokenInterface interface {
+	Create(token *entity.OAuthRefreshToken) error
+	FindByID(id string) (*entity.OAuthRefreshToken, error)
+	Rotate(id string, next *entity.OAuthRefreshToken) error
+	Revoke(id string) error
+}
+
+type TenantInterface interface {
+	Create(tenant *entity.Tenant, owner *entity.Membership) error
+	FindByID(id string) (*entity.Tenant, error)
+	FindBySlug(slug string) (*entity.Tenant, error)
+	FindAll() ([]entity.Tenant, error)
+}
+
+type MembershipInterface interface {
+	Create(membership *entity.Membership) error
+	Find(tenantID, userID string) (*entity.Membership, error)
+	FindAllByUser(userID string) ([]entity.Membership, error)
+	FindAllByTenant(tenantID string) ([]entity.Membership, error)
+	Delete(tenantID, userID string) error
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+// Media is scoped to the tenant carried by the context of each call.
+type Media struct {
+	DB *gorm.DB
+}
+
+func NewMediaDB(db *gorm.DB) *Media {
+	return &Media{DB: db}
+}
+
+func (m *Media) scoped(ctx context.Context) *gorm.DB {
+	return m.DB.WithContext(ctx).Scopes(TenantScope(ctx))
+}
+
+// Create adds media to the tenant of ctx after the last media of its
+// product.
+func (m *Media) Create(ctx context.Context, media *entity.Media) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	media.TenantID = tenantID
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last struct{ Position *int }
+		err := tx.Model(&entity.Media{}).Scopes(TenantScope(ctx)).
+			Select("MAX(position) AS position").Where("product_id = ?", media.ProductID).
+			Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		media.Position = 0
+		if last.Position != nil {
+			media.Position = *last.Position + 1
+		}
+		return tx.Create(media).Error
+	})
+}
+
+func (m *Media) FindByID(ctx context.Context, productID, id string) (*entity.Media, error) {
+	var media entity.Media
+	err := m.scoped(ctx).First(&media, "product_id = ? AND id = ?", productID, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+func (m *Media) FindAllByProduct(ctx context.Context, productID string) ([]entity.Media, error) {
+	return m.FindAllByProducts(ctx, []string{productID})
+}
+
+// FindAllByProducts returns the media of every product in productIDs by
+// position.
+func (m *Media) FindAllByProducts(ctx context.Context, productIDs []string) ([]entity.Media, error) {
+	media := []entity.Media{}
+	if len(productIDs) == 0 {
+		return media, nil
+	}
+	err := m.scoped(ctx).Where("product_id IN ?", productIDs).Order("position asc, created_at asc").Find(&media).Error
+	if err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// UpdatePositions saves the positions of media at once.
+func (m *Media) UpdatePositions(ctx context.Context, media []entity.Media) error {
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range media {
+			err := tx.Model(&entity.Media{}).Scopes(TenantScope(ctx)).
+				Where("id = ?", item.ID).Update("position", item.Position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Media) Delete(ctx context.Context, productID, id string) error {
+	result := m.scoped(ctx).Delete(&entity.Media{}, "product_id = ? AND id = ?", productID, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
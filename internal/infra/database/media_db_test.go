@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMediaDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	mediaDB := NewMediaDB(db)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	product, _ := entity.NewProduct("Shirt", 20)
+	assert.Nil(t, NewProductDB(db).Create(ctx, product))
+
+	first, _ := entity.NewMedia(product, "front.png", "image/png", 10)
+	first.Thumbnails = []entity.MediaThumbnail{{Size: 100, Width: 100, Height: 50}}
+	second, _ := entity.NewMedia(product, "manual.pdf", "application/pdf", 10)
+	assert.Nil(t, mediaDB.Create(ctx, first))
+	assert.Nil(t, mediaDB.Create(ctx, second))
+	assert.Equal(t, 0, first.Position)
+	assert.Equal(t, 1, second.Position)
+	assert.Equal(t, ErrTenantRequired, mediaDB.Create(context.Background(), second))
+
+	media, err := mediaDB.FindAllByProduct(ctx, product.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, media, 2)
+	assert.Equal(t, first.ID, media[0].ID)
+	assert.Equal(t, first.Thumbnails, media[0].Thumbnails)
+	assert.Equal(t, first.Key, media[0].Key)
+
+	assert.Nil(t, entity.ReorderMedia(media, []string{second.ID.String(), first.ID.String()}))
+	assert.Nil(t, mediaDB.UpdatePositions(ctx, media))
+	media, _ = mediaDB.FindAllByProduct(ctx, product.ID.String())
+	assert.Equal(t, second.ID, media[0].ID)
+
+	other := WithTenant(context.Background(), entityPkg.NewID())
+	_, err = mediaDB.FindByID(other, product.ID.String(), first.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, mediaDB.Delete(other, product.ID.String(), first.ID.String()), gorm.ErrRecordNotFound)
+
+	assert.Nil(t, mediaDB.Delete(ctx, product.ID.String(), first.ID.String()))
+	_, err = mediaDB.FindByID(ctx, product.ID.String(), first.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	// Deleting the product deletes the records of its media.
+	assert.Nil(t, NewProductDB(db).Delete(ctx, product.ID.String()))
+	media, _ = mediaDB.FindAllByProducts(ctx, []string{product.ID.String()})
+	assert.Empty(t, media)
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+var ErrAlreadyMember = errors.New("user is already a member of the tenant")
+
+type Membership struct {
+	DB *gorm.DB
+}
+
+func NewMembershipDB(db *gorm.DB) *Membership {
+	return &Membership{DB: db}
+}
+
+func (m *Membership) Create(membership *entity.Membership) error {
+	err := m.DB.Create(membership).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrAlreadyMember
+	}
+	return err
+}
+
+func (m *Membership) Find(tenantID, userID string) (*entity.Membership, error) {
+	var membership entity.Membership
+	if err := m.DB.First(&membership, "tenant_id = ? AND user_id = ?", tenantID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (m *Membership) FindAllByUser(userID string) ([]entity.Membership, error) {
+	var memberships []entity.Membership
+	err := m.DB.Where("user_id = ?", userID).Order("created_at asc").Find(&memberships).Error
+	return memberships, err
+}
+
+func (m *Membership) FindAllByTenant(tenantID string) ([]entity.Membership, error) {
+	var memberships []entity.Membership
+	err := m.DB.Where("tenant_id = ?", tenantID).Order("created_at asc").Find(&memberships).Error
+	return memberships, err
+}
+
+func (m *Membership) Delete(tenantID, userID string) error {
+	result := m.DB.Where("tenant_id = ? AND user_id = ?", tenantID, userID).Delete(&entity.Membership{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+type MFAPolicy struct {
+	DB *gorm.DB
+}
+
+func NewMFAPolicyDB(db *gorm.DB) *MFAPolicy {
+	return &MFAPolicy{DB: db}
+}
+
+func (p *MFAPolicy) FindAll() ([]entity.MFAPolicy, error) {
+	var policies []entity.MFAPolicy
+	err := p.DB.Order("role asc").Find(&policies).Error
+	return policies, err
+}
+
+// Save creates or replaces the policy of its role.
+func (p *MFAPolicy) Save(policy *entity.MFAPolicy) error {
+	return p.DB.Save(policy).Error
+}
+
+// IsRequired reports whether users of role must use two-factor
+// authentication. Roles without a policy do not.
+func (p *MFAPolicy) IsRequired(role string) (bool, error) {
+	var policy entity.MFAPolicy
+	err := p.DB.First(&policy, "role = ?", role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return policy.Required, nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMFAPolicy(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.MFAPolicy{})
+	policyDB := NewMFAPolicyDB(db)
+
+	required, err := policyDB.IsRequired(entity.RoleAdmin)
+	assert.Nil(t, err)
+	assert.False(t, required)
+
+	policy, _ := entity.NewMFAPolicy(entity.RoleAdmin, true)
+	assert.Nil(t, policyDB.Save(policy))
+	required, _ = policyDB.IsRequired(entity.RoleAdmin)
+	assert.True(t, required)
+
+	policy, _ = entity.NewMFAPolicy(entity.RoleAdmin, false)
+	assert.Nil(t, policyDB.Save(policy))
+	required, _ = policyDB.IsRequired(entity.RoleAdmin)
+	assert.False(t, required)
+
+	policies, err := policyDB.FindAll()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+type OAuthClient struct {
+	DB *gorm.DB
+}
+
+func NewOAuthClientDB(db *gorm.DB) *OAuthClient {
+	return &OAuthClient{DB: db}
+}
+
+func (c *OAuthClient) Create(client *entity.OAuthClient) error {
+	return c.DB.Create(client).Error
+}
+
+func (c *OAuthClient) FindByID(id string) (*entity.OAuthClient, error) {
+	var client entity.OAuthClient
+	if err := c.DB.First(&client, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (c *OAuthClient) FindAll() ([]entity.OAuthClient, error) {
+	var clients []entity.OAuthClient
+	err := c.DB.Order("created_at desc").Find(&clients).Error
+	return clients, err
+}
+
+// Delete removes a client along with its consents, codes and refresh tokens.
+func (c *OAuthClient) Delete(id string) error {
+	client, err := c.FindByID(id)
+	if err != nil {
+		return err
+	}
+	return c.DB.Transaction(func(tx *gorm.DB) error {
+		for _, owned := range []interface{}{&entity.OAuthConsent{}, &entity.OAuthAuthorizationCode{}, &entity.OAuthRefreshToken{}} {
+			if err := tx.Where("client_id = ?", id).Delete(owned).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(client).Error
+	})
+}
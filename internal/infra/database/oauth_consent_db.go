@@ -0,0 +1,51 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+type OAuthConsent struct {
+	DB *gorm.DB
+}
+
+func NewOAuthConsentDB(db *gorm.DB) *OAuthConsent {
+	return &OAuthConsent{DB: db}
+}
+
+func (c *OAuthConsent) Find(userID, clientID string) (*entity.OAuthConsent, error) {
+	var consent entity.OAuthConsent
+	if err := c.DB.First(&consent, "user_id = ? AND client_id = ?", userID, clientID).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (c *OAuthConsent) Save(consent *entity.OAuthConsent) error {
+	return c.DB.Save(consent).Error
+}
+
+func (c *OAuthConsent) FindAllByUser(userID string) ([]entity.OAuthConsent, error) {
+	var consents []entity.OAuthConsent
+	err := c.DB.Where("user_id = ?", userID).Order("updated_at desc").Find(&consents).Error
+	return consents, err
+}
+
+// Delete withdraws the consent of a user to a client and revokes the refresh
+// tokens the client obtained with it.
+func (c *OAuthConsent) Delete(userID, clientID string) error {
+	return c.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&entity.OAuthConsent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&entity.OAuthRefreshToken{}).
+			Where("user_id = ? AND client_id = ? AND revoked_at IS NULL", userID, clientID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newOAuthTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.OAuthClient{}, &entity.OAuthConsent{}, &entity.OAuthAuthorizationCode{}, &entity.OAuthRefreshToken{})
+	return db
+}
+
+func newTestOAuthClient() *entity.OAuthClient {
+	client, _, _ := entity.NewOAuthClient("Partner", true, []string{"https://partner.example/cb"},
+		[]string{entity.GrantAuthorizationCode, entity.GrantRefreshToken}, []string{entity.ScopeOpenID}, nil)
+	return client
+}
+
+func TestOAuthClientLifecycle(t *testing.T) {
+	db := newOAuthTestDB(t)
+	clientDB := NewOAuthClientDB(db)
+	client := newTestOAuthClient()
+	assert.Nil(t, clientDB.Create(client))
+
+	found, err := clientDB.FindByID(client.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, client.RedirectURIs, found.RedirectURIs)
+	assert.Equal(t, client.SecretHash, found.SecretHash)
+
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	NewOAuthConsentDB(db).Save(entity.NewOAuthConsent(user.ID, client.ID, []string{entity.ScopeOpenID}))
+	NewOAuthRefreshTokenDB(db).Create(entity.NewOAuthRefreshToken(client.ID, user, nil, nil, time.Hour))
+
+	assert.Nil(t, clientDB.Delete(client.ID.String()))
+	_, err = clientDB.FindByID(client.ID.String())
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	var remaining int64
+	db.Model(&entity.OAuthRefreshToken{}).Count(&remaining)
+	assert.Zero(t, remaining)
+}
+
+func TestOAuthConsent(t *testing.T) {
+	db := newOAuthTestDB(t)
+	consentDB := NewOAuthConsentDB(db)
+	tokenDB := NewOAuthRefreshTokenDB(db)
+	client := newTestOAuthClient()
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+
+	_, err := consentDB.Find(user.ID.String(), client.ID.String())
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	consent := entity.NewOAuthConsent(user.ID, client.ID, []string{entity.ScopeOpenID})
+	assert.Nil(t, consentDB.Save(consent))
+	consent.Grant([]string{entity.ScopeEmail})
+	assert.Nil(t, consentDB.Save(consent))
+	found, err := consentDB.Find(user.ID.String(), client.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{entity.ScopeOpenID, entity.ScopeEmail}, found.Scopes)
+
+	refresh := entity.NewOAuthRefreshToken(client.ID, user, nil, nil, time.Hour)
+	tokenDB.Create(refresh)
+	assert.Nil(t, consentDB.Delete(user.ID.String(), client.ID.String()))
+	assert.Equal(t, gorm.ErrRecordNotFound, consentDB.Delete(user.ID.String(), client.ID.String()))
+	revoked, _ := tokenDB.FindByID(refresh.ID.String())
+	assert.NotNil(t, revoked.RevokedAt)
+}
+
+func TestConsumeOAuthAuthorizationCode(t *testing.T) {
+	db := newOAuthTestDB(t)
+	codeDB := NewOAuthAuthorizationCodeDB(db)
+	sum := sha256.Sum256([]byte(strings.Repeat("a", 43)))
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	code, _ := entity.NewOAuthAuthorizationCode(newTestOAuthClient().ID, user.ID, "https://partner.example/cb",
+		[]string{entity.ScopeOpenID}, base64.RawURLEncoding.EncodeToString(sum[:]), entity.PKCEMethodS256, "n", []string{"pwd"}, time.Minute)
+	assert.Nil(t, codeDB.Create(code))
+
+	found, err := codeDB.FindByID(code.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pwd"}, found.AMR)
+	assert.Equal(t, ErrInvalidToken, codeDB.Consume(code.ID.String(), entityPkg.NewID(), code.RedirectURI))
+	assert.Equal(t, ErrInvalidToken, codeDB.Consume(code.ID.String(), code.ClientID, "https://attacker.example/cb"))
+	assert.Nil(t, codeDB.Consume(code.ID.String(), code.ClientID, code.RedirectURI))
+	assert.Equal(t, ErrInvalidToken, codeDB.Consume(code.ID.String(), code.ClientID, code.RedirectURI))
+}
+
+func TestRotateOAuthRefreshToken(t *testing.T) {
+	db := newOAuthTestDB(t)
+	tokenDB := NewOAuthRefreshTokenDB(db)
+	client := newTestOAuthClient()
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	first := entity.NewOAuthRefreshToken(client.ID, user, nil, nil, time.Hour)
+	assert.Nil(t, tokenDB.Create(first))
+
+	second := entity.NewOAuthRefreshToken(client.ID, user, nil, nil, time.Hour)
+	assert.Nil(t, tokenDB.Rotate(first.ID.String(), second))
+	third := entity.NewOAuthRefreshToken(client.ID, user, nil, nil, time.Hour)
+	assert.Equal(t, ErrInvalidToken, tokenDB.Rotate(first.ID.String(), third))
+	_, err := tokenDB.FindByID(third.ID.String())
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	assert.Nil(t, tokenDB.Revoke(second.ID.String()))
+	revoked, _ := tokenDB.FindByID(second.ID.String())
+	assert.NotNil(t, revoked.RevokedAt)
+}
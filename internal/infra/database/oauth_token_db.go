@@ -0,0 +1,89 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+type OAuthAuthorizationCode struct {
+	DB *gorm.DB
+}
+
+func NewOAuthAuthorizationCodeDB(db *gorm.DB) *OAuthAuthorizationCode {
+	return &OAuthAuthorizationCode{DB: db}
+}
+
+func (c *OAuthAuthorizationCode) Create(code *entity.OAuthAuthorizationCode) error {
+	return c.DB.Create(code).Error
+}
+
+func (c *OAuthAuthorizationCode) FindByID(id string) (*entity.OAuthAuthorizationCode, error) {
+	var code entity.OAuthAuthorizationCode
+	if err := c.DB.First(&code, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// Consume marks a code as used if it is unused, unexpired and was issued to
+// clientID for redirectURI. The conditional update makes sure a code is only
+// ever exchanged once, and only by the client it was issued to.
+func (c *OAuthAuthorizationCode) Consume(id string, clientID entityPkg.ID, redirectURI string) error {
+	now := time.Now()
+	result := c.DB.Model(&entity.OAuthAuthorizationCode{}).
+		Where("id = ? AND client_id = ? AND redirect_uri = ? AND used_at IS NULL AND expires_at > ?", id, clientID, redirectURI, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+type OAuthRefreshToken struct {
+	DB *gorm.DB
+}
+
+func NewOAuthRefreshTokenDB(db *gorm.DB) *OAuthRefreshToken {
+	return &OAuthRefreshToken{DB: db}
+}
+
+func (t *OAuthRefreshToken) Create(token *entity.OAuthRefreshToken) error {
+	return t.DB.Create(token).Error
+}
+
+func (t *OAuthRefreshToken) FindByID(id string) (*entity.OAuthRefreshToken, error) {
+	var token entity.OAuthRefreshToken
+	if err := t.DB.First(&token, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rotate revokes the refresh token id and stores next in its place. The
+// conditional update makes sure concurrent refreshes cannot both succeed.
+func (t *OAuthRefreshToken) Rotate(id string, next *entity.OAuthRefreshToken) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.OAuthRefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (t *OAuthRefreshToken) Revoke(id string) error {
+	return t.DB.Model(&entity.OAuthRefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+var ErrPriceStarted = errors.New("price already took effect")
+
+// Price is scoped to the tenant carried by the context of each call, except
+// for ApplySchedules and Backfill which cover every tenant.
+type Price struct {
+	DB *gorm.DB
+}
+
+func NewPriceDB(db *gorm.DB) *Price {
+	return &Price{DB: db}
+}
+
+func (p *Price) scoped(ctx context.Context) *gorm.DB {
+	return p.DB.WithContext(ctx).Scopes(TenantScope(ctx))
+}
+
+// Create adds price to the tenant of ctx, whatever its TenantID.
+func (p *Price) Create(ctx context.Context, price *entity.ProductPrice) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	price.TenantID = tenantID
+	return p.DB.WithContext(ctx).Create(price).Error
+}
+
+func (p *Price) FindByID(ctx context.Context, productID, id string) (*entity.ProductPrice, error) {
+	var price entity.ProductPrice
+	err := p.scoped(ctx).First(&price, "product_id = ? AND id = ?", productID, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// FindAllByProduct returns the prices of a product by start, then creation.
+func (p *Price) FindAllByProduct(ctx context.Context, productID string) ([]entity.ProductPrice, error) {
+	prices := []entity.ProductPrice{}
+	err := p.scoped(ctx).Where("product_id = ?", productID).Order("starts_at asc, created_at asc").Find(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// Delete cancels a price that has not taken effect yet.
+func (p *Price) Delete(ctx context.Context, productID, id string) error {
+	result := p.scoped(ctx).Delete(&entity.ProductPrice{}, "product_id = ? AND id = ? AND started = ?", productID, id, false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := p.FindByID(ctx, productID, id); err != nil {
+			return err
+		}
+		return ErrPriceStarted
+	}
+	return nil
+}
+
+// ApplySchedules sets the price of the products, in every tenant, whose
+// prices started or ended by now to the price in effect at now. It is
+// idempotent, so several instances can run it concurrently.
+func (p *Price) ApplySchedules(ctx context.Context, now time.Time) (int64, error) {
+	now = now.UTC()
+	var productIDs []string
+	err := p.DB.WithContext(ctx).Model(&entity.ProductPrice{}).
+		Where("(started = ? AND starts_at <= ?) OR (ended = ? AND ends_at <= ?)", false, now, false, now).
+		Distinct().Pluck("product_id", &productIDs).Error
+	if err != nil {
+		return 0, err
+	}
+	var updated int64
+	for _, productID := range productIDs {
+		changed, err := p.applyProduct(ctx, productID, now)
+		if err != nil {
+			return updated, err
+		}
+		if changed {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (p *Price) applyProduct(ctx context.Context, productID string, now time.Time) (bool, error) {
+	changed := false
+	err := p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var prices []entity.ProductPrice
+		if err := tx.Where("product_id = ?", productID).Find(&prices).Error; err != nil {
+			return err
+		}
+		if current := entity.PriceAt(prices, now); current != nil {
+			result := tx.Model(&entity.Product{}).Where("id = ? AND price <> ?", productID, current.Amount).Update("price", current.Amount)
+			if result.Error != nil {
+				return result.Error
+			}
+			changed = result.RowsAffected > 0
+		}
+		if err := tx.Model(&entity.ProductPrice{}).Where("product_id = ? AND started = ? AND starts_at <= ?", productID, false, now).Update("started", true).Error; err != nil {
+			return err
+		}
+		return tx.Model(&entity.ProductPrice{}).Where("product_id = ? AND ended = ? AND ends_at <= ?", productID, false, now).Update("ended", true).Error
+	})
+	return changed, err
+}
+
+// Backfill records the price of the products, in every tenant, that have no
+// price history yet as in effect since their creation.
+func (p *Price) Backfill(ctx context.Context) (int64, error) {
+	var products []entity.Product
+	err := p.DB.WithContext(ctx).
+		Where("id NOT IN (?)", p.DB.Model(&entity.ProductPrice{}).Select("product_id")).
+		Find(&products).Error
+	if err != nil || len(products) == 0 {
+		return 0, err
+	}
+	prices := make([]entity.ProductPrice, len(products))
+	for i := range products {
+		prices[i] = initialPrice(&products[i])
+	}
+	if err := p.DB.WithContext(ctx).CreateInBatches(prices, 100).Error; err != nil {
+		return 0, err
+	}
+	return int64(len(prices)), nil
+}
+
+// initialPrice is the price of product since its creation.
+func initialPrice(product *entity.Product) entity.ProductPrice {
+	return entity.ProductPrice{
+		ID:        entityPkg.NewID(),
+		TenantID:  product.TenantID,
+		ProductID: product.ID,
+		Amount:    product.Price,
+		StartsAt:  product.CreatedAt.UTC(),
+		Started:   true,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newPriceDB(t *testing.T) (*gorm.DB, *Product, *Price) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{})
+	return db, NewProductDB(db), NewPriceDB(db)
+}
+
+func TestProductPriceHistory(t *testing.T) {
+	_, productDB, priceDB := newPriceDB(t)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	product, _ := entity.NewProduct("Kettle", 30)
+	assert.Nil(t, productDB.Create(ctx, product))
+
+	product.Name = "Electric kettle"
+	assert.Nil(t, productDB.Update(ctx, product))
+	prices, err := priceDB.FindAllByProduct(ctx, product.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, prices, 1)
+	assert.Equal(t, 30.0, prices[0].Amount)
+
+	product.Price = 35
+	assert.Nil(t, productDB.Update(ctx, product))
+	prices, err = priceDB.FindAllByProduct(ctx, product.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, prices, 2)
+	assert.Equal(t, 35.0, entity.PriceAt(prices, time.Now()).Amount)
+	assert.Equal(t, 30.0, entity.PriceAt(prices, product.CreatedAt).Amount)
+
+	other := WithTenant(context.Background(), entityPkg.NewID())
+	prices, err = priceDB.FindAllByProduct(other, product.ID.String())
+	assert.Nil(t, err)
+	assert.Empty(t, prices)
+}
+
+func TestApplyPriceSchedules(t *testing.T) {
+	_, productDB, priceDB := newPriceDB(t)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	product, _ := entity.NewProduct("Kettle", 30)
+	assert.Nil(t, productDB.Create(ctx, product))
+	now := time.Now()
+	ends := now.Add(2 * time.Hour)
+	sale, err := entity.ScheduleProductPrice(product, 20, now.Add(time.Hour), &ends, "Black Friday")
+	assert.Nil(t, err)
+	assert.Nil(t, priceDB.Create(ctx, sale))
+
+	updated, err := priceDB.ApplySchedules(context.Background(), now)
+	assert.Nil(t, err)
+	assert.Zero(t, updated)
+
+	updated, err = priceDB.ApplySchedules(context.Background(), now.Add(90*time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), updated)
+	found, _ := productDB.FindByID(ctx, product.ID.String())
+	assert.Equal(t, 20.0, found.Price)
+	assert.Equal(t, ErrPriceStarted, priceDB.Delete(ctx, product.ID.String(), sale.ID.String()))
+
+	// Applying again changes nothing, and the end of the sale restores the
+	// price it covered.
+	updated, err = priceDB.ApplySchedules(context.Background(), now.Add(90*time.Minute))
+	assert.Nil(t, err)
+	assert.Zero(t, updated)
+	updated, err = priceDB.ApplySchedules(context.Background(), now.Add(3*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), updated)
+	found, _ = productDB.FindByID(ctx, product.ID.String())
+	assert.Equal(t, 30.0, found.Price)
+}
+
+func TestDeleteScheduledPrice(t *testing.T) {
+	_, productDB, priceDB := newPriceDB(t)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	product, _ := entity.NewProduct("Kettle", 30)
+	assert.Nil(t, productDB.Create(ctx, product))
+	price, _ := entity.ScheduleProductPrice(product, 20, time.Now().Add(time.Hour), nil, "")
+	assert.Nil(t, priceDB.Create(ctx, price))
+
+	other := WithTenant(context.Background(), entityPkg.NewID())
+	assert.ErrorIs(t, priceDB.Delete(other, product.ID.String(), price.ID.String()), gorm.ErrRecordNotFound)
+	assert.Nil(t, priceDB.Delete(ctx, product.ID.String(), price.ID.String()))
+	assert.ErrorIs(t, priceDB.Delete(ctx, product.ID.String(), price.ID.String()), gorm.ErrRecordNotFound)
+}
+
+func TestBackfillPrices(t *testing.T) {
+	db, productDB, priceDB := newPriceDB(t)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	legacy, _ := entity.NewProduct("Legacy", 10)
+	legacy.TenantID, _ = TenantFromContext(ctx)
+	assert.Nil(t, db.Create(legacy).Error)
+	product, _ := entity.NewProduct("Kettle", 30)
+	assert.Nil(t, productDB.Create(ctx, product))
+
+	backfilled, err := priceDB.Backfill(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), backfilled)
+	prices, _ := priceDB.FindAllByProduct(ctx, legacy.ID.String())
+	assert.Len(t, prices, 1)
+	assert.Equal(t, 10.0, prices[0].Amount)
+	assert.True(t, prices[0].StartsAt.Equal(legacy.CreatedAt))
+	backfilled, err = priceDB.Backfill(context.Background())
+	assert.Nil(t, err)
+	assert.Zero(t, backfilled)
+}
@@ -1,10 +1,26 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrSKUAlreadyExists     = errors.New("sku already exists")
+	ErrBarcodeAlreadyExists = errors.New("barcode already exists")
+	ErrInvalidFilter        = errors.New("invalid attribute filter")
+)
+
+// Product is scoped to the tenant carried by the context of each call, except
+// for ApplySchedules, CountWithoutTenant and AssignTenant which cover every
+// tenant.
 type Product struct {
 	DB *gorm.DB
 }
@@ -13,30 +29,95 @@ func NewProductDB(db *gorm.DB) *Product {
 	return &Product{DB: db}
 }
 
-func (p *Product) Create(product *entity.Product) error {
-	return p.DB.Create(product).Error
+func (p *Product) scoped(ctx context.Context) *gorm.DB {
+	return p.DB.WithContext(ctx).Scopes(TenantScope(ctx))
 }
 
-func (p *Product) FindByID(id string) (*entity.Product, error) {
+// Create adds product to the tenant of ctx, whatever its TenantID.
+func (p *Product) Create(ctx context.Context, product *entity.Product) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	product.TenantID = tenantID
+	if err := p.checkIdentifiers(ctx, product); err != nil {
+		return err
+	}
+	err := p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		price := initialPrice(product)
+		if err := tx.Create(&price).Error; err != nil {
+			return err
+		}
+		if rows := product.AttributeIndex(); len(rows) > 0 {
+			return tx.Create(&rows).Error
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return p.identifierConflict(ctx, product, err)
+	}
+	return err
+}
+
+func (p *Product) FindByID(ctx context.Context, id string) (*entity.Product, error) {
 	var product entity.Product
-	err := p.DB.First(&product, "id = ?", id).Error
+	err := p.scoped(ctx).First(&product, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-func (p *Product) FindAll(page, limit int, sort string) (*PaginatedResponse, error) {
+// FindBySKU finds the product of the tenant of ctx with sku.
+func (p *Product) FindBySKU(ctx context.Context, sku string) (*entity.Product, error) {
+	return p.findBy(ctx, "sku", sku)
+}
+
+// FindByBarcode finds the product of the tenant of ctx with the GTIN code.
+func (p *Product) FindByBarcode(ctx context.Context, code string) (*entity.Product, error) {
+	return p.findBy(ctx, "barcode", code)
+}
+
+func (p *Product) findBy(ctx context.Context, column, value string) (*entity.Product, error) {
+	if value == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var product entity.Product
+	if err := p.scoped(ctx).First(&product, column+" = ?", value).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (p *Product) FindAll(ctx context.Context, filter ProductFilter, page, limit int, sort string) (*PaginatedResponse, error) {
 	var products []entity.Product
 	var err error
 	if sort != "" && sort != "asc" && sort != "desc" {
 		sort = "asc"
 	}
+	query := p.scoped(ctx)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CategoryID != "" {
+		query = query.Where("category_id = ?", filter.CategoryID)
+	}
+	for _, attribute := range filter.Attributes {
+		condition, args, err := attributeCondition(attribute)
+		if err != nil {
+			return nil, err
+		}
+		args = append([]interface{}{attribute.Name}, args...)
+		query = query.Where("id IN (SELECT product_id FROM product_attributes WHERE tenant_id = products.tenant_id AND name = ? AND "+condition+")", args...)
+	}
 	if page != 0 && limit != 0 {
 		offset := (page - 1) * limit
-		err = p.DB.Limit(limit).Offset(offset).Order("created_at " + sort).Find(&products).Error
+		err = query.Limit(limit).Offset(offset).Order("created_at " + sort).Find(&products).Error
 	} else {
-		err = p.DB.Order("created_at " + sort).Find(&products).Error
+		err = query.Order("created_at " + sort).Find(&products).Error
 	}
 
 	if err != nil {
@@ -52,18 +133,198 @@ func (p *Product) FindAll(page, limit int, sort string) (*PaginatedResponse, err
 	return response, nil
 }
 
-func (p *Product) Update(product *entity.Product) error {
-	_, err := p.FindByID(product.ID.String())
-	if err != nil {
+// Update saves product if it belongs to the tenant of ctx. It cannot be moved
+// to another tenant.
+func (p *Product) Update(ctx context.Context, product *entity.Product) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	product.TenantID = tenantID
+	if err := p.checkIdentifiers(ctx, product); err != nil {
+		return err
+	}
+	err := p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous entity.Product
+		if err := tx.Scopes(TenantScope(ctx)).Select("price").First(&previous, "id = ?", product.ID).Error; err != nil {
+			return err
+		}
+		result := tx.Scopes(TenantScope(ctx)).Model(product).Select("*").Updates(product)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if product.Price != previous.Price {
+			// The new price takes over from now, scheduled prices included.
+			price, err := entity.NewProductPrice(product, product.Price, time.Now(), nil, "")
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(price).Error; err != nil {
+				return err
+			}
+		}
+		return indexAttributes(tx, product)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return p.identifierConflict(ctx, product, err)
+	}
+	return err
+}
+
+// indexAttributes replaces the rows indexing the attributes of product.
+func indexAttributes(tx *gorm.DB, product *entity.Product) error {
+	if err := tx.Delete(&entity.ProductAttribute{}, "product_id = ?", product.ID).Error; err != nil {
 		return err
 	}
-	return p.DB.Save(product).Error
+	rows := product.AttributeIndex()
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
 }
 
-func (p *Product) Delete(id string) error {
-	product, err := p.FindByID(id)
-	if err != nil {
+// attributeCondition compares the indexed value of an attribute as filter
+// asks. Numbers compare as numbers, anything else as text.
+func attributeCondition(filter AttributeFilter) (string, []interface{}, error) {
+	number, err := strconv.ParseFloat(filter.Value, 64)
+	isNumber := err == nil
+	switch filter.Op {
+	case "", AttributeOpEq:
+		if isNumber {
+			return "(number = ? OR text = ?)", []interface{}{number, filter.Value}, nil
+		}
+		return "text = ?", []interface{}{filter.Value}, nil
+	case AttributeOpGt, AttributeOpGte, AttributeOpLt, AttributeOpLte:
+		if !isNumber {
+			return "", nil, fmt.Errorf("%w: %s.%s needs a number", ErrInvalidFilter, filter.Name, filter.Op)
+		}
+		operators := map[string]string{AttributeOpGt: ">", AttributeOpGte: ">=", AttributeOpLt: "<", AttributeOpLte: "<="}
+		return "number " + operators[filter.Op] + " ?", []interface{}{number}, nil
+	}
+	return "", nil, fmt.Errorf("%w: unknown operator %s", ErrInvalidFilter, filter.Op)
+}
+
+// checkIdentifiers tells which of the SKU and barcode of product another
+// product of its tenant already has. SKUs are also unique across the
+// variants of the tenant, so a SKU finds a single product or variant.
+func (p *Product) checkIdentifiers(ctx context.Context, product *entity.Product) error {
+	for _, identifier := range []struct {
+		column, value string
+		err           error
+	}{
+		{"sku", product.SKU, ErrSKUAlreadyExists},
+		{"barcode", product.Barcode, ErrBarcodeAlreadyExists},
+	} {
+		if identifier.value == "" {
+			continue
+		}
+		var count int64
+		err := p.scoped(ctx).Model(&entity.Product{}).
+			Where(identifier.column+" = ? AND id <> ?", identifier.value, product.ID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return identifier.err
+		}
+	}
+	if product.SKU == "" {
+		return nil
+	}
+	var count int64
+	if err := p.scoped(ctx).Model(&entity.Variant{}).Where("sku = ?", product.SKU).Count(&count).Error; err != nil {
 		return err
 	}
-	return p.DB.Delete(product).Error
+	if count > 0 {
+		return ErrSKUAlreadyExists
+	}
+	return nil
+}
+
+// identifierConflict names the identifier a concurrent write took between
+// checkIdentifiers and the unique index rejecting product.
+func (p *Product) identifierConflict(ctx context.Context, product *entity.Product, err error) error {
+	if conflict := p.checkIdentifiers(ctx, product); conflict != nil {
+		return conflict
+	}
+	return err
+}
+
+func (p *Product) Delete(ctx context.Context, id string) error {
+	return p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(TenantScope(ctx)).Delete(&entity.Product{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Delete(&entity.ProductAttribute{}, "product_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.Variant{}, "product_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.VariantPrice{}, "product_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.Media{}, "product_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.ProductPrice{}, "product_id = ?", id).Error
+	})
+}
+
+// withoutTenant matches the rows created before tenants existed, whose
+// tenant_id was left unset.
+const withoutTenant = "tenant_id IS NULL OR tenant_id = '' OR tenant_id = ?"
+
+// CountWithoutTenant counts the products created before tenants existed,
+// which no tenant sees until AssignTenant assigns them.
+func (p *Product) CountWithoutTenant(ctx context.Context) (int64, error) {
+	var count int64
+	err := p.DB.WithContext(ctx).Model(&entity.Product{}).Where(withoutTenant, entityPkg.ID{}).Count(&count).Error
+	return count, err
+}
+
+// AssignTenant assigns the products created before tenants existed, and the
+// prices Price.Backfill recorded for them, to tenantID.
+func (p *Product) AssignTenant(ctx context.Context, tenantID entityPkg.ID) (int64, error) {
+	var assigned int64
+	err := p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		legacy := tx.Session(&gorm.Session{NewDB: true}).Model(&entity.Product{}).Select("id").Where(withoutTenant, entityPkg.ID{})
+		err := tx.Model(&entity.ProductPrice{}).Where("product_id IN (?)", legacy).UpdateColumn("tenant_id", tenantID).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Model(&entity.Product{}).Where(withoutTenant, entityPkg.ID{}).UpdateColumn("tenant_id", tenantID)
+		assigned = result.RowsAffected
+		return result.Error
+	})
+	return assigned, err
+}
+
+// ApplySchedules publishes the drafts whose publish_at and archives the
+// published products whose unpublish_at is not after now, in every tenant.
+// It is idempotent, so several instances can run it concurrently.
+func (p *Product) ApplySchedules(ctx context.Context, now time.Time) (published, archived int64, err error) {
+	now = now.UTC()
+	result := p.DB.WithContext(ctx).Model(&entity.Product{}).
+		Where("status = ? AND publish_at <= ?", entity.ProductStatusDraft, now).
+		Updates(map[string]interface{}{"status": entity.ProductStatusPublished, "publish_at": nil})
+	if result.Error != nil {
+		return 0, 0, result.Error
+	}
+	published = result.RowsAffected
+	result = p.DB.WithContext(ctx).Model(&entity.Product{}).
+		Where("status = ? AND unpublish_at <= ?", entity.ProductStatusPublished, now).
+		Updates(map[string]interface{}{"status": entity.ProductStatusArchived, "unpublish_at": nil})
+	if result.Error != nil {
+		return published, 0, result.Error
+	}
+	return published, result.RowsAffected, nil
 }
@@ -1,10 +1,13 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -15,11 +18,14 @@ func TestCreateProduct(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	db.AutoMigrate(&entity.Product{})
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	tenantID := entityPkg.NewID()
+	ctx := WithTenant(context.Background(), tenantID)
 	product, _ := entity.NewProduct("Product 01", 80)
+	product.TenantID = tenantID
 	productDB := NewProductDB(db)
 
-	err = productDB.Create(product)
+	err = productDB.Create(ctx, product)
 	assert.Nil(t, err)
 
 	var productFound entity.Product
@@ -36,13 +42,16 @@ func TestFindProductByID(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	db.AutoMigrate(&entity.Product{})
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	tenantID := entityPkg.NewID()
+	ctx := WithTenant(context.Background(), tenantID)
 	product, _ := entity.NewProduct("Product 01", 80)
+	product.TenantID = tenantID
 	productDB := NewProductDB(db)
 
 	db.Create(product)
 
-	productFound, err := productDB.FindByID(product.ID.String())
+	productFound, err := productDB.FindByID(ctx, product.ID.String())
 	assert.Nil(t, err)
 	assert.Equal(t, product.ID, productFound.ID)
 	assert.Equal(t, product.Price, productFound.Price)
@@ -53,15 +62,18 @@ func TestFindAllProducts(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	db.AutoMigrate(&entity.Product{})
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	tenantID := entityPkg.NewID()
+	ctx := WithTenant(context.Background(), tenantID)
 	var products []entity.Product
 	for i := 1; i <= 10; i++ {
 		product, _ := entity.NewProduct(fmt.Sprintf("Product %d", i), float64(i*10.0))
+		product.TenantID = tenantID
 		products = append(products, *product)
 	}
 	db.Create(products)
 	productDB := NewProductDB(db)
-	response, err := productDB.FindAll(0, 0, "")
+	response, err := productDB.FindAll(ctx, ProductFilter{}, 0, 0, "")
 	assert.Nil(t, err)
 	assert.Len(t, response.Data, len(products))
 }
@@ -71,14 +83,17 @@ func TestFindAllProductsWithPagination(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	db.AutoMigrate(&entity.Product{})
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	tenantID := entityPkg.NewID()
+	ctx := WithTenant(context.Background(), tenantID)
 	for i := 1; i <= 10; i++ {
 		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), float64(i*10.0))
 		assert.NoError(t, err)
+		product.TenantID = tenantID
 		db.Create(product)
 	}
 	productDB := NewProductDB(db)
-	response, err := productDB.FindAll(2, 4, "")
+	response, err := productDB.FindAll(ctx, ProductFilter{}, 2, 4, "")
 	assert.Nil(t, err)
 	assert.Len(t, response.Data, 4)
 	assert.Equal(t, "Product 5", response.Data[0].Name)
@@ -92,15 +107,18 @@ func TestUpdateProdcut(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	db.AutoMigrate(&entity.Product{})
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	tenantID := entityPkg.NewID()
+	ctx := WithTenant(context.Background(), tenantID)
 	product, _ := entity.NewProduct("Product 01", 80)
+	product.TenantID = tenantID
 	productDB := NewProductDB(db)
 
 	db.Create(product)
 	product.Name = "Updated Product 01"
 	product.Price = 100.0
 	var foundProduct entity.Product
-	err = productDB.Update(product)
+	err = productDB.Update(ctx, product)
 	assert.Nil(t, err)
 	err = db.First(&foundProduct, "id = ?", product.ID).Error
 	assert.Nil(t, err)
@@ -113,16 +131,289 @@ func TestDeleteProduct(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	db.AutoMigrate(&entity.Product{})
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	tenantID := entityPkg.NewID()
+	ctx := WithTenant(context.Background(), tenantID)
 	product, _ := entity.NewProduct("Product 01", 80)
+	product.TenantID = tenantID
+	product.Options = []entity.ProductOption{{Name: "Size", Values: []string{"S"}}}
 	productDB := NewProductDB(db)
 
 	db.Create(product)
+	variant, _ := entity.NewVariant(product, map[string]string{"Size": "S"})
+	db.Create(variant)
 
-	err = productDB.Delete(product.ID.String())
+	err = productDB.Delete(ctx, product.ID.String())
 	assert.NoError(t, err)
 
-	_, err = productDB.FindByID(product.ID.String())
+	_, err = productDB.FindByID(ctx, product.ID.String())
 	assert.Error(t, err)
 	assert.Equal(t, "record not found", err.Error())
+	var variants int64
+	db.Model(&entity.Variant{}).Where("product_id = ?", product.ID).Count(&variants)
+	assert.Zero(t, variants)
+}
+
+func TestCreateProductRequiresTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	product, _ := entity.NewProduct("Product 01", 80)
+	productDB := NewProductDB(db)
+
+	assert.Equal(t, ErrTenantRequired, productDB.Create(context.Background(), product))
+	assert.Equal(t, ErrTenantRequired, productDB.Update(context.Background(), product))
+
+	tenantID := entityPkg.NewID()
+	product.TenantID = entityPkg.NewID()
+	assert.Nil(t, productDB.Create(WithTenant(context.Background(), tenantID), product))
+	assert.Equal(t, tenantID, product.TenantID)
+}
+
+func TestProductsAreIsolatedByTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	productDB := NewProductDB(db)
+	acme := WithTenant(context.Background(), entityPkg.NewID())
+	globex := WithTenant(context.Background(), entityPkg.NewID())
+	product, _ := entity.NewProduct("Product 01", 80)
+	assert.Nil(t, productDB.Create(acme, product))
+
+	_, err = productDB.FindByID(globex, product.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = productDB.FindByID(context.Background(), product.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	response, err := productDB.FindAll(globex, ProductFilter{}, 0, 0, "")
+	assert.Nil(t, err)
+	assert.Empty(t, response.Data)
+	response, err = productDB.FindAll(context.Background(), ProductFilter{}, 0, 0, "")
+	assert.Nil(t, err)
+	assert.Empty(t, response.Data)
+
+	stolen := *product
+	stolen.Name = "Stolen"
+	assert.ErrorIs(t, productDB.Update(globex, &stolen), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, productDB.Delete(globex, product.ID.String()), gorm.ErrRecordNotFound)
+
+	found, err := productDB.FindByID(acme, product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "Product 01", found.Name)
+	tenantID, _ := TenantFromContext(acme)
+	assert.Equal(t, tenantID, found.TenantID)
+}
+
+func TestFindAllProductsByStatus(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	productDB := NewProductDB(db)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	draft, _ := entity.NewProduct("Draft", 10)
+	published, _ := entity.NewProduct("Published", 10)
+	published.Publish()
+	assert.Nil(t, productDB.Create(ctx, draft))
+	assert.Nil(t, productDB.Create(ctx, published))
+
+	response, err := productDB.FindAll(ctx, ProductFilter{Status: entity.ProductStatusPublished}, 0, 0, "")
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, "Published", response.Data[0].Name)
+	response, err = productDB.FindAll(ctx, ProductFilter{}, 0, 0, "")
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 2)
+}
+
+func TestApplyProductSchedules(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	productDB := NewProductDB(db)
+	acme := WithTenant(context.Background(), entityPkg.NewID())
+	globex := WithTenant(context.Background(), entityPkg.NewID())
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	due, _ := entity.NewProduct("Due", 10)
+	due.Schedule(&past, &future)
+	later, _ := entity.NewProduct("Later", 10)
+	later.Schedule(&future, nil)
+	expired, _ := entity.NewProduct("Expired", 10)
+	expired.Publish()
+	expired.Schedule(nil, &past)
+	assert.Nil(t, productDB.Create(acme, due))
+	assert.Nil(t, productDB.Create(acme, later))
+	assert.Nil(t, productDB.Create(globex, expired))
+
+	published, archived, err := productDB.ApplySchedules(context.Background(), now)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), published)
+	assert.Equal(t, int64(1), archived)
+
+	found, _ := productDB.FindByID(acme, due.ID.String())
+	assert.Equal(t, entity.ProductStatusPublished, found.Status)
+	assert.Nil(t, found.PublishAt)
+	assert.NotNil(t, found.UnpublishAt)
+	found, _ = productDB.FindByID(acme, later.ID.String())
+	assert.Equal(t, entity.ProductStatusDraft, found.Status)
+	found, _ = productDB.FindByID(globex, expired.ID.String())
+	assert.Equal(t, entity.ProductStatusArchived, found.Status)
+	assert.Nil(t, found.UnpublishAt)
+
+	published, archived, err = productDB.ApplySchedules(context.Background(), now)
+	assert.Nil(t, err)
+	assert.Zero(t, published)
+	assert.Zero(t, archived)
+}
+
+func TestProductIdentifiersAreUniquePerTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	productDB := NewProductDB(db)
+	acme := WithTenant(context.Background(), entityPkg.NewID())
+	globex := WithTenant(context.Background(), entityPkg.NewID())
+	product, _ := entity.NewProduct("Product 01", 80)
+	product.SKU = "SKU-1"
+	product.Barcode = "4006381333931"
+	assert.Nil(t, productDB.Create(acme, product))
+	other, _ := entity.NewProduct("Product 02", 80)
+	assert.Nil(t, productDB.Create(acme, other))
+	unlabeled, _ := entity.NewProduct("Product 03", 80)
+	assert.Nil(t, productDB.Create(acme, unlabeled))
+
+	found, err := productDB.FindBySKU(acme, "SKU-1")
+	assert.Nil(t, err)
+	assert.Equal(t, product.ID, found.ID)
+	found, err = productDB.FindByBarcode(acme, "4006381333931")
+	assert.Nil(t, err)
+	assert.Equal(t, product.ID, found.ID)
+	_, err = productDB.FindBySKU(globex, "SKU-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = productDB.FindBySKU(acme, "")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	duplicate, _ := entity.NewProduct("Product 04", 80)
+	duplicate.SKU = "SKU-1"
+	assert.Equal(t, ErrSKUAlreadyExists, productDB.Create(acme, duplicate))
+	duplicate.SKU = ""
+	duplicate.Barcode = "4006381333931"
+	assert.Equal(t, ErrBarcodeAlreadyExists, productDB.Create(acme, duplicate))
+	other.SKU = "SKU-1"
+	assert.Equal(t, ErrSKUAlreadyExists, productDB.Update(acme, other))
+	assert.Nil(t, productDB.Update(acme, product))
+
+	duplicate.SKU = "SKU-1"
+	assert.Nil(t, productDB.Create(globex, duplicate))
+}
+
+func TestFindAllProductsByAttributes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	productDB := NewProductDB(db)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	categoryID := entityPkg.NewID()
+	newProduct := func(name string, attributes map[string]any) *entity.Product {
+		product, _ := entity.NewProduct(name, 10)
+		product.CategoryID, product.Attributes = &categoryID, attributes
+		assert.Nil(t, productDB.Create(ctx, product))
+		return product
+	}
+	newProduct("Kettle", map[string]any{"voltage": 220.0, "plug": "C"})
+	toaster := newProduct("Toaster", map[string]any{"voltage": 110.0, "plug": "A"})
+	plain, _ := entity.NewProduct("Plain", 10)
+	assert.Nil(t, productDB.Create(ctx, plain))
+	// Products of other tenants never match.
+	other, _ := entity.NewProduct("Other", 10)
+	other.CategoryID, other.Attributes = &categoryID, map[string]any{"voltage": 220.0}
+	assert.Nil(t, productDB.Create(WithTenant(context.Background(), entityPkg.NewID()), other))
+
+	names := func(filter ProductFilter) []string {
+		response, err := productDB.FindAll(ctx, filter, 0, 0, "asc")
+		assert.Nil(t, err)
+		names := []string{}
+		for _, product := range response.Data {
+			names = append(names, product.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"Kettle", "Toaster"}, names(ProductFilter{CategoryID: categoryID.String()}))
+	assert.Equal(t, []string{"Kettle"}, names(ProductFilter{Attributes: []AttributeFilter{{Name: "voltage", Value: "220"}}}))
+	assert.Equal(t, []string{"Toaster"}, names(ProductFilter{Attributes: []AttributeFilter{{Name: "plug", Op: AttributeOpEq, Value: "A"}}}))
+	assert.Equal(t, []string{"Kettle", "Toaster"}, names(ProductFilter{Attributes: []AttributeFilter{{Name: "voltage", Op: AttributeOpGte, Value: "110"}}}))
+	assert.Equal(t, []string{}, names(ProductFilter{Attributes: []AttributeFilter{
+		{Name: "voltage", Op: AttributeOpLt, Value: "200"},
+		{Name: "plug", Value: "C"},
+	}}))
+	_, err = productDB.FindAll(ctx, ProductFilter{Attributes: []AttributeFilter{{Name: "plug", Op: AttributeOpGt, Value: "C"}}}, 0, 0, "")
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+
+	// Updates and deletes keep the index in step with the product.
+	toaster.Attributes = map[string]any{"voltage": 230.0}
+	assert.Nil(t, productDB.Update(ctx, toaster))
+	assert.Equal(t, []string{"Toaster"}, names(ProductFilter{Attributes: []AttributeFilter{{Name: "voltage", Op: AttributeOpGt, Value: "225"}}}))
+	assert.Equal(t, []string{}, names(ProductFilter{Attributes: []AttributeFilter{{Name: "plug", Value: "A"}}}))
+	assert.Nil(t, productDB.Delete(ctx, toaster.ID.String()))
+	var count int64
+	db.Model(&entity.ProductAttribute{}).Where("product_id = ?", toaster.ID).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestAssignProductsWithoutTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	productDB := NewProductDB(db)
+	priceDB := NewPriceDB(db)
+	tenantID := entityPkg.NewID()
+	ctx := WithTenant(context.Background(), tenantID)
+
+	unset, _ := entity.NewProduct("Unset", 10)
+	null, _ := entity.NewProduct("Null", 20)
+	db.Create(unset)
+	db.Create(null)
+	db.Model(null).UpdateColumn("tenant_id", nil)
+	other, _ := entity.NewProduct("Other", 30)
+	assert.Nil(t, productDB.Create(WithTenant(context.Background(), entityPkg.NewID()), other))
+	backfilled, err := priceDB.Backfill(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), backfilled)
+
+	count, err := productDB.CountWithoutTenant(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	_, err = productDB.FindByID(ctx, unset.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	assigned, err := productDB.AssignTenant(context.Background(), tenantID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), assigned)
+	for _, product := range []*entity.Product{unset, null} {
+		found, err := productDB.FindByID(ctx, product.ID.String())
+		assert.Nil(t, err)
+		assert.Equal(t, tenantID, found.TenantID)
+		var price entity.ProductPrice
+		assert.Nil(t, db.First(&price, "product_id = ?", product.ID).Error)
+		assert.Equal(t, tenantID, price.TenantID)
+	}
+	_, err = productDB.FindByID(ctx, other.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	count, _ = productDB.CountWithoutTenant(context.Background())
+	assert.Zero(t, count)
 }
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+// Promotion is scoped to the tenant carried by the context of each call.
+type Promotion struct {
+	DB *gorm.DB
+}
+
+func NewPromotionDB(db *gorm.DB) *Promotion {
+	return &Promotion{DB: db}
+}
+
+func (p *Promotion) scoped(ctx context.Context) *gorm.DB {
+	return p.DB.WithContext(ctx).Scopes(TenantScope(ctx))
+}
+
+// Create adds promotion to the tenant of ctx, whatever its TenantID.
+func (p *Promotion) Create(ctx context.Context, promotion *entity.Promotion) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	promotion.TenantID = tenantID
+	return p.DB.WithContext(ctx).Create(promotion).Error
+}
+
+func (p *Promotion) FindByID(ctx context.Context, id string) (*entity.Promotion, error) {
+	var promotion entity.Promotion
+	if err := p.scoped(ctx).First(&promotion, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// FindAll returns the promotions of the tenant of ctx, newest first.
+func (p *Promotion) FindAll(ctx context.Context) ([]entity.Promotion, error) {
+	promotions := []entity.Promotion{}
+	if err := p.scoped(ctx).Order("created_at desc").Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+// FindActive returns the promotions of the tenant of ctx whose window
+// includes at.
+func (p *Promotion) FindActive(ctx context.Context, at time.Time) ([]entity.Promotion, error) {
+	at = at.UTC()
+	promotions := []entity.Promotion{}
+	err := p.scoped(ctx).
+		Where("(starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", at, at).
+		Order("created_at asc").Find(&promotions).Error
+	if err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+// Update saves promotion if it belongs to the tenant of ctx. It cannot be
+// moved to another tenant.
+func (p *Promotion) Update(ctx context.Context, promotion *entity.Promotion) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	promotion.TenantID = tenantID
+	result := p.scoped(ctx).Model(promotion).Select("*").Updates(promotion)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (p *Promotion) Delete(ctx context.Context, id string) error {
+	result := p.scoped(ctx).Delete(&entity.Promotion{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPromotionDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Promotion{})
+	promotionDB := NewPromotionDB(db)
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	otherCtx := WithTenant(context.Background(), entityPkg.NewID())
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	current := entity.NewPromotion("Current", entity.PromotionPercentageOff)
+	current.Percent, current.Tags = 10, []string{"sale"}
+	assert.Nil(t, promotionDB.Create(ctx, current))
+	upcoming := entity.NewPromotion("Upcoming", entity.PromotionTiered)
+	upcoming.Tiers, upcoming.Tags = []entity.PromotionTier{{MinQuantity: 5, Percent: 10}}, []string{"sale"}
+	upcoming.Schedule(&later, nil)
+	assert.Nil(t, promotionDB.Create(ctx, upcoming))
+	assert.Equal(t, ErrTenantRequired, promotionDB.Create(context.Background(), current))
+
+	promotions, err := promotionDB.FindAll(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, promotions, 2)
+	active, err := promotionDB.FindActive(ctx, now)
+	assert.Nil(t, err)
+	assert.Len(t, active, 1)
+	assert.Equal(t, "Current", active[0].Name)
+	active, err = promotionDB.FindActive(ctx, later)
+	assert.Nil(t, err)
+	assert.Len(t, active, 2)
+	assert.Equal(t, 5, active[1].Tiers[0].MinQuantity)
+	active, err = promotionDB.FindActive(otherCtx, later)
+	assert.Nil(t, err)
+	assert.Empty(t, active)
+
+	current.Percent = 20
+	assert.Nil(t, promotionDB.Update(ctx, current))
+	found, err := promotionDB.FindByID(ctx, current.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 20.0, found.Percent)
+	assert.ErrorIs(t, promotionDB.Update(otherCtx, current), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, promotionDB.Delete(otherCtx, current.ID.String()), gorm.ErrRecordNotFound)
+	assert.Nil(t, promotionDB.Delete(ctx, current.ID.String()))
+}
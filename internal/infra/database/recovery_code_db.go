@@ -0,0 +1,50 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+type RecoveryCode struct {
+	DB *gorm.DB
+}
+
+func NewRecoveryCodeDB(db *gorm.DB) *RecoveryCode {
+	return &RecoveryCode{DB: db}
+}
+
+// Replace swaps every recovery code of a user for codes, which may be empty.
+func (c *RecoveryCode) Replace(userID string, codes []entity.RecoveryCode) error {
+	return c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// Consume marks an unused recovery code of a user as used. The conditional
+// update makes sure a code is only ever accepted once.
+func (c *RecoveryCode) Consume(userID, code string) error {
+	result := c.DB.Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, entity.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (c *RecoveryCode) CountUnused(userID string) (int64, error) {
+	var count int64
+	err := c.DB.Model(&entity.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
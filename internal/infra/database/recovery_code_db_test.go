@@ -0,0 +1,40 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestConsumeRecoveryCode(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RecoveryCode{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	other, _ := entity.NewUser("Jane", "jane@j.com", "123456")
+	codes, plain, _ := entity.NewRecoveryCodes(user.ID)
+	codeDB := NewRecoveryCodeDB(db)
+	assert.Nil(t, codeDB.Replace(user.ID.String(), codes))
+
+	assert.Equal(t, ErrInvalidToken, codeDB.Consume(other.ID.String(), plain[0]))
+	assert.Nil(t, codeDB.Consume(user.ID.String(), plain[0]))
+	assert.Equal(t, ErrInvalidToken, codeDB.Consume(user.ID.String(), plain[0]))
+	unused, err := codeDB.CountUnused(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(entity.RecoveryCodeCount-1), unused)
+
+	fresh, _, _ := entity.NewRecoveryCodes(user.ID)
+	assert.Nil(t, codeDB.Replace(user.ID.String(), fresh))
+	assert.Equal(t, ErrInvalidToken, codeDB.Consume(user.ID.String(), plain[1]))
+	unused, _ = codeDB.CountUnused(user.ID.String())
+	assert.Equal(t, int64(entity.RecoveryCodeCount), unused)
+
+	assert.Nil(t, codeDB.Replace(user.ID.String(), nil))
+	unused, _ = codeDB.CountUnused(user.ID.String())
+	assert.Zero(t, unused)
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+var ErrSlugAlreadyExists = errors.New("slug already exists")
+
+type Tenant struct {
+	DB *gorm.DB
+}
+
+func NewTenantDB(db *gorm.DB) *Tenant {
+	return &Tenant{DB: db}
+}
+
+// Create adds tenant along with the membership of its first owner.
+func (t *Tenant) Create(tenant *entity.Tenant, owner *entity.Membership) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(tenant).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrSlugAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Create(owner).Error
+	})
+}
+
+func (t *Tenant) FindByID(id string) (*entity.Tenant, error) {
+	var tenant entity.Tenant
+	if err := t.DB.First(&tenant, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (t *Tenant) FindBySlug(slug string) (*entity.Tenant, error) {
+	var tenant entity.Tenant
+	if err := t.DB.First(&tenant, "slug = ?", slug).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (t *Tenant) FindAll() ([]entity.Tenant, error) {
+	var tenants []entity.Tenant
+	err := t.DB.Order("slug asc").Find(&tenants).Error
+	return tenants, err
+}
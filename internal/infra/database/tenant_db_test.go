@@ -0,0 +1,74 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Tenant{}, &entity.Membership{})
+	tenantDB := NewTenantDB(db)
+	membershipDB := NewMembershipDB(db)
+	userID := entityPkg.NewID()
+	tenant, _ := entity.NewTenant("Acme", "acme")
+	owner, _ := entity.NewMembership(tenant.ID, userID, entity.TenantRoleOwner)
+
+	assert.Nil(t, tenantDB.Create(tenant, owner))
+	found, err := tenantDB.FindBySlug("acme")
+	assert.Nil(t, err)
+	assert.Equal(t, tenant.ID, found.ID)
+	found, err = tenantDB.FindByID(tenant.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "Acme", found.Name)
+	membership, err := membershipDB.Find(tenant.ID.String(), userID.String())
+	assert.Nil(t, err)
+	assert.True(t, membership.IsOwner())
+
+	duplicate, _ := entity.NewTenant("Acme Again", "acme")
+	other, _ := entity.NewMembership(duplicate.ID, userID, entity.TenantRoleOwner)
+	assert.Equal(t, ErrSlugAlreadyExists, tenantDB.Create(duplicate, other))
+	_, err = membershipDB.Find(duplicate.ID.String(), userID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	tenants, err := tenantDB.FindAll()
+	assert.Nil(t, err)
+	assert.Len(t, tenants, 1)
+}
+
+func TestMemberships(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Membership{})
+	membershipDB := NewMembershipDB(db)
+	acme, globex := entityPkg.NewID(), entityPkg.NewID()
+	john, jane := entityPkg.NewID(), entityPkg.NewID()
+	for _, m := range [][2]entityPkg.ID{{acme, john}, {acme, jane}, {globex, john}} {
+		membership, _ := entity.NewMembership(m[0], m[1], entity.TenantRoleMember)
+		assert.Nil(t, membershipDB.Create(membership))
+	}
+	again, _ := entity.NewMembership(acme, john, entity.TenantRoleOwner)
+	assert.Equal(t, ErrAlreadyMember, membershipDB.Create(again))
+
+	memberships, err := membershipDB.FindAllByUser(john.String())
+	assert.Nil(t, err)
+	assert.Len(t, memberships, 2)
+	memberships, err = membershipDB.FindAllByTenant(acme.String())
+	assert.Nil(t, err)
+	assert.Len(t, memberships, 2)
+
+	assert.Nil(t, membershipDB.Delete(acme.String(), jane.String()))
+	assert.ErrorIs(t, membershipDB.Delete(acme.String(), jane.String()), gorm.ErrRecordNotFound)
+	_, err = membershipDB.Find(acme.String(), jane.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+var ErrTenantRequired = errors.New("tenant required")
+
+type tenantCtxKey struct{}
+
+// WithTenant returns a context restricting the tenant scoped repositories to
+// tenantID.
+func WithTenant(ctx context.Context, tenantID entity.ID) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+func TenantFromContext(ctx context.Context) (entity.ID, bool) {
+	tenantID, ok := ctx.Value(tenantCtxKey{}).(entity.ID)
+	return tenantID, ok
+}
+
+// TenantScope filters a query by the tenant of ctx. Without one it matches
+// nothing, so a missing tenant can never expose every tenant's rows.
+func TenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		tenantID, ok := TenantFromContext(ctx)
+		if !ok {
+			return db.Where("1 = 0")
+		}
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
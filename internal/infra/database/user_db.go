@@ -1,10 +1,15 @@
 package database
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/ivandersr/products-api-go/internal/entity"
 	"gorm.io/gorm"
 )
 
+var ErrEmailAlreadyExists = errors.New("email already exists")
+
 type User struct {
 	DB *gorm.DB
 }
@@ -14,13 +19,208 @@ func NewUserDB(db *gorm.DB) *User {
 }
 
 func (u *User) Create(user *entity.User) error {
-	return u.DB.Create(user).Error
+	err := u.DB.Create(user).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrEmailAlreadyExists
+	}
+	return err
 }
 
 func (u *User) FindByEmail(email string) (*entity.User, error) {
 	var user entity.User
+	email = strings.ToLower(strings.TrimSpace(email))
 	if err := u.DB.Where("email = ?", email).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (u *User) FindByID(id string) (*entity.User, error) {
+	var user entity.User
+	if err := u.DB.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u *User) Update(user *entity.User) error {
+	_, err := u.FindByID(user.ID.String())
+	if err != nil {
+		return err
+	}
+	err = u.DB.Save(user).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrEmailAlreadyExists
+	}
+	return err
+}
+
+// NormalizeEmails lowercases and trims the emails stored before they were
+// normalized on write, as logins look them up normalized, and resolves the
+// duplicates this reveals, which the unique index on email refuses. It has
+// to run before that index is created.
+//
+// Of the accounts sharing an email once normalized, the one that verified
+// it keeps it, or else the one logins found: the first by ID among those
+// stored normalized, or among all. The others get a placeholder address
+// under the reserved .invalid domain and are returned with their previous
+// email, so an admin can give them a new one. Their sessions and API keys
+// keep working in the meantime.
+func (u *User) NormalizeEmails() (int64, []entity.User, error) {
+	normalizedEmail := "LOWER(TRIM(email))"
+	affected := u.DB.Model(&entity.User{}).Select(normalizedEmail).Group(normalizedEmail).
+		Having("COUNT(*) > 1 OR SUM(CASE WHEN email <> " + normalizedEmail + " THEN 1 ELSE 0 END) > 0")
+	columns := []string{"id", "email"}
+	if u.DB.Migrator().HasColumn(&entity.User{}, "EmailVerifiedAt") {
+		columns = append(columns, "email_verified_at")
+	}
+	var users []entity.User
+	err := u.DB.Select(columns).Where(normalizedEmail+" IN (?)", affected).Order("id").Find(&users).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	groups := map[string][]entity.User{}
+	var emails []string
+	for _, user := range users {
+		email := strings.ToLower(strings.TrimSpace(user.Email))
+		if groups[email] == nil {
+			emails = append(emails, email)
+		}
+		groups[email] = append(groups[email], user)
+	}
+	var normalized int64
+	var renamed []entity.User
+	err = u.DB.Transaction(func(tx *gorm.DB) error {
+		for _, email := range emails {
+			group := groups[email]
+			keeper := emailKeeper(group, email)
+			// The others go first, one of them may hold the email already.
+			for _, user := range group {
+				if user.ID == keeper.ID {
+					continue
+				}
+				placeholder := user.ID.String() + "@duplicate.invalid"
+				if err := tx.Model(&entity.User{}).Where("id = ?", user.ID).UpdateColumn("email", placeholder).Error; err != nil {
+					return err
+				}
+				renamed = append(renamed, user)
+			}
+			if keeper.Email != email {
+				if err := tx.Model(&entity.User{}).Where("id = ?", keeper.ID).UpdateColumn("email", email).Error; err != nil {
+					return err
+				}
+				normalized++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return normalized, renamed, nil
+}
+
+// emailKeeper picks the account of group, sorted by ID, that keeps email.
+func emailKeeper(group []entity.User, email string) entity.User {
+	for _, user := range group {
+		if user.IsEmailVerified() {
+			return user
+		}
+	}
+	for _, user := range group {
+		if user.Email == email {
+			return user
+		}
+	}
+	return group[0]
+}
+
+// ClaimTOTPStep records step as the last TOTP time step accepted for the
+// user. It returns entity.ErrInvalidMFACode when that step or a later one
+// was already accepted, so concurrent requests cannot both use a code.
+func (u *User) ClaimTOTPStep(id string, step int64) error {
+	result := u.DB.Model(&entity.User{}).Where("id = ? AND totp_last_step < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (u *User) Delete(id string) error {
+	user, err := u.FindByID(id)
+	if err != nil {
+		return err
+	}
+	return u.DB.Transaction(func(tx *gorm.DB) error {
+		for _, owned := range []interface{}{
+			&entity.UserToken{}, &entity.APIKey{}, &entity.RecoveryCode{},
+			&entity.OAuthConsent{}, &entity.OAuthAuthorizationCode{}, &entity.OAuthRefreshToken{},
+			&entity.Membership{},
+		} {
+			if err := tx.Where("user_id = ?", id).Delete(owned).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, so searches match
+// them literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (u *User) FindAll(filter UserFilter, page, limit int) (*PaginatedUsersResponse, error) {
+	query := u.DB.Model(&entity.User{})
+	if filter.Query != "" {
+		like := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		query = query.Where(`LOWER(name) LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\'`, like, like)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			query = query.Where("disabled_at IS NOT NULL")
+		} else {
+			query = query.Where("disabled_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var users []entity.User
+	query = query.Order("email asc")
+	if page != 0 && limit != 0 {
+		query = query.Limit(limit).Offset((page - 1) * limit)
+	}
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return &PaginatedUsersResponse{
+		Data:  users,
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	}, nil
+}
+
+// passwordHashBatchSize bounds how many users PasswordHashes loads at once.
+const passwordHashBatchSize = 500
+
+// PasswordHashes calls fn with the password hash of every user.
+func (u *User) PasswordHashes(fn func(hash string)) error {
+	var users []entity.User
+	return u.DB.Model(&entity.User{}).Select("id", "password").
+		FindInBatches(&users, passwordHashBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, user := range users {
+				fn(user.Password)
+			}
+			return nil
+		}).Error
+}
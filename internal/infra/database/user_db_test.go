@@ -1,9 +1,12 @@
 package database
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -43,3 +46,268 @@ func TestFindByEmail(t *testing.T) {
 	assert.Equal(t, user.ID, userFound.ID)
 	assert.Equal(t, "j@j.com", userFound.Email)
 }
+
+func TestCreateUserWithDuplicateEmail(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDB := NewUserDB(db)
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	assert.Nil(t, userDB.Create(user))
+
+	duplicate, _ := entity.NewUser("Johnny", "J@J.com", "654321")
+	err = userDB.Create(duplicate)
+	assert.Equal(t, ErrEmailAlreadyExists, err)
+}
+
+func TestFindByEmailIsCaseInsensitive(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	userDB := NewUserDB(db)
+	db.Create(user)
+	userFound, err := userDB.FindByEmail(" J@J.COM ")
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, userFound.ID)
+}
+
+// legacyUser is the users table before emails were unique.
+type legacyUser struct {
+	ID              entityPkg.ID
+	Name            string
+	Email           string
+	Password        string
+	EmailVerifiedAt *time.Time
+}
+
+func (legacyUser) TableName() string {
+	return "users"
+}
+
+func TestNormalizeEmails(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&legacyUser{})
+	userDB := NewUserDB(db)
+	verified := time.Now()
+	users := []legacyUser{
+		{Email: " John@J.com"},
+		{Email: "jane@j.com"},
+		{Email: "Jane@j.com"},
+		{Email: "BOB@j.com"},
+		{Email: "Bob@J.com", EmailVerifiedAt: &verified},
+		{Email: "ann@j.com"},
+		{Email: "ann@j.com"},
+	}
+	for i := range users {
+		users[i].ID = entityPkg.NewID()
+		assert.Nil(t, db.Create(&users[i]).Error)
+	}
+	lowest := func(a, b legacyUser) legacyUser {
+		if a.ID.String() < b.ID.String() {
+			return a
+		}
+		return b
+	}
+
+	normalized, renamed, err := userDB.NormalizeEmails()
+	assert.Nil(t, err)
+	// John and Bob are normalized. Jane stored normalized and Bob verified
+	// keep their emails, so do the first of the two Anns.
+	assert.Equal(t, int64(2), normalized)
+	ann := lowest(users[5], users[6])
+	otherAnn := users[5]
+	if ann.ID == users[5].ID {
+		otherAnn = users[6]
+	}
+	renamedIDs := map[string]string{}
+	for _, user := range renamed {
+		renamedIDs[user.ID.String()] = user.Email
+	}
+	assert.Equal(t, map[string]string{
+		users[2].ID.String(): "Jane@j.com",
+		users[3].ID.String(): "BOB@j.com",
+		otherAnn.ID.String(): "ann@j.com",
+	}, renamedIDs)
+
+	// The unique index can be created now.
+	assert.Nil(t, db.AutoMigrate(&entity.User{}))
+	for email, id := range map[string]entityPkg.ID{"john@j.com": users[0].ID, "jane@j.com": users[1].ID, "bob@j.com": users[4].ID, "ann@j.com": ann.ID} {
+		found, err := userDB.FindByEmail(email)
+		assert.Nil(t, err)
+		assert.Equal(t, id, found.ID, email)
+	}
+	found, _ := userDB.FindByID(users[2].ID.String())
+	assert.Equal(t, users[2].ID.String()+"@duplicate.invalid", found.Email)
+
+	normalized, renamed, err = userDB.NormalizeEmails()
+	assert.Nil(t, err)
+	assert.Zero(t, normalized)
+	assert.Empty(t, renamed)
+}
+
+func TestClaimTOTPStep(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	userDB := NewUserDB(db)
+	userDB.Create(user)
+
+	assert.Nil(t, userDB.ClaimTOTPStep(user.ID.String(), 100))
+	assert.Equal(t, entity.ErrInvalidMFACode, userDB.ClaimTOTPStep(user.ID.String(), 100))
+	assert.Equal(t, entity.ErrInvalidMFACode, userDB.ClaimTOTPStep(user.ID.String(), 99))
+	assert.Nil(t, userDB.ClaimTOTPStep(user.ID.String(), 101))
+
+	found, _ := userDB.FindByID(user.ID.String())
+	assert.Equal(t, int64(101), found.TOTPLastStep)
+}
+
+func TestFindUserByID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	userDB := NewUserDB(db)
+	db.Create(user)
+	userFound, err := userDB.FindByID(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, user.Email, userFound.Email)
+}
+
+func TestUpdateUser(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	userDB := NewUserDB(db)
+	db.Create(user)
+	user.VerifyEmail()
+	assert.Nil(t, userDB.Update(user))
+
+	var userFound entity.User
+	db.First(&userFound, "id = ?", user.ID)
+	assert.True(t, userFound.IsEmailVerified())
+}
+
+func TestDeleteUser(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.UserToken{}, &entity.APIKey{}, &entity.RecoveryCode{},
+		&entity.OAuthConsent{}, &entity.OAuthAuthorizationCode{}, &entity.OAuthRefreshToken{}, &entity.Membership{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	userDB := NewUserDB(db)
+	db.Create(user)
+	db.Create(entity.NewUserToken(user.ID, entity.TokenPurposePasswordReset, time.Hour))
+	key, _, _ := entity.NewAPIKey(user.ID, "job", nil, nil)
+	db.Create(key)
+	codes, _, _ := entity.NewRecoveryCodes(user.ID)
+	db.Create(codes)
+	membership, _ := entity.NewMembership(entityPkg.NewID(), user.ID, entity.TenantRoleOwner)
+	db.Create(membership)
+
+	assert.Nil(t, userDB.Delete(user.ID.String()))
+	_, err = userDB.FindByID(user.ID.String())
+	assert.Error(t, err)
+	var tokens int64
+	db.Model(&entity.UserToken{}).Count(&tokens)
+	assert.Zero(t, tokens)
+	var keys int64
+	db.Model(&entity.APIKey{}).Count(&keys)
+	assert.Zero(t, keys)
+	var recoveryCodes int64
+	db.Model(&entity.RecoveryCode{}).Count(&recoveryCodes)
+	assert.Zero(t, recoveryCodes)
+	var memberships int64
+	db.Model(&entity.Membership{}).Count(&memberships)
+	assert.Zero(t, memberships)
+}
+
+func TestFindAllUsersWithFilters(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDB := NewUserDB(db)
+	for i := 1; i <= 5; i++ {
+		user, _ := entity.NewUser(fmt.Sprintf("User %d", i), fmt.Sprintf("user%d@j.com", i), "123456")
+		if i == 1 {
+			user.ChangeRole(entity.RoleAdmin)
+		}
+		if i == 2 {
+			user.Disable()
+		}
+		db.Create(user)
+	}
+
+	response, err := userDB.FindAll(UserFilter{}, 2, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), response.Total)
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, "user3@j.com", response.Data[0].Email)
+
+	response, _ = userDB.FindAll(UserFilter{Role: entity.RoleAdmin}, 0, 0)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, "user1@j.com", response.Data[0].Email)
+
+	disabled := true
+	response, _ = userDB.FindAll(UserFilter{Disabled: &disabled}, 0, 0)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, "user2@j.com", response.Data[0].Email)
+
+	response, _ = userDB.FindAll(UserFilter{Query: "USER4"}, 0, 0)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, "user4@j.com", response.Data[0].Email)
+
+	// Wildcards in the query are matched literally.
+	wildcard, _ := entity.NewUser("100% Cotton", "cotton_shop@j.com", "123456")
+	db.Create(wildcard)
+	for _, query := range []string{"%", "_", "0% c", "n_s"} {
+		response, _ = userDB.FindAll(UserFilter{Query: query}, 0, 0)
+		assert.Len(t, response.Data, 1, query)
+		assert.Equal(t, "cotton_shop@j.com", response.Data[0].Email, query)
+	}
+	response, _ = userDB.FindAll(UserFilter{Query: `\`}, 0, 0)
+	assert.Empty(t, response.Data)
+}
+
+func TestUserPasswordHashes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDB := NewUserDB(db)
+	hashes := map[string]bool{}
+	for i := 1; i <= 3; i++ {
+		user, _ := entity.NewUser(fmt.Sprintf("User %d", i), fmt.Sprintf("user%d@j.com", i), "123456")
+		db.Create(user)
+		hashes[user.Password] = false
+	}
+
+	err = userDB.PasswordHashes(func(hash string) {
+		_, ok := hashes[hash]
+		assert.True(t, ok)
+		hashes[hash] = true
+	})
+	assert.Nil(t, err)
+	for _, seen := range hashes {
+		assert.True(t, seen)
+	}
+}
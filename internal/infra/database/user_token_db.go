@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+type UserToken struct {
+	DB *gorm.DB
+}
+
+func NewUserTokenDB(db *gorm.DB) *UserToken {
+	return &UserToken{DB: db}
+}
+
+func (t *UserToken) Create(token *entity.UserToken) error {
+	return t.DB.Create(token).Error
+}
+
+// Find returns an unused, unexpired token without consuming it.
+func (t *UserToken) Find(id, purpose string) (*entity.UserToken, error) {
+	var token entity.UserToken
+	err := t.DB.Where("id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", id, purpose, time.Now()).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token as used and returns it. The
+// conditional update makes sure a token is only ever consumed once.
+func (t *UserToken) Consume(id, purpose string) (*entity.UserToken, error) {
+	now := time.Now()
+	result := t.DB.Model(&entity.UserToken{}).
+		Where("id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", id, purpose, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+	var token entity.UserToken
+	if err := t.DB.First(&token, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke consumes every outstanding token of a user for the given purpose.
+func (t *UserToken) Revoke(userID, purpose string) error {
+	return t.DB.Model(&entity.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
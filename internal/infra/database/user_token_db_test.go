@@ -0,0 +1,87 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestConsumeUserToken(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	token := entity.NewUserToken(user.ID, entity.TokenPurposePasswordReset, time.Hour)
+	tokenDB := NewUserTokenDB(db)
+	assert.Nil(t, tokenDB.Create(token))
+
+	_, err = tokenDB.Consume(token.ID.String(), entity.TokenPurposeEmailVerification)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	consumed, err := tokenDB.Consume(token.ID.String(), entity.TokenPurposePasswordReset)
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, consumed.UserID)
+	assert.NotNil(t, consumed.UsedAt)
+
+	_, err = tokenDB.Consume(token.ID.String(), entity.TokenPurposePasswordReset)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestConsumeExpiredUserToken(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	token := entity.NewUserToken(user.ID, entity.TokenPurposePasswordReset, -time.Minute)
+	tokenDB := NewUserTokenDB(db)
+	db.Create(token)
+
+	_, err = tokenDB.Consume(token.ID.String(), entity.TokenPurposePasswordReset)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	token := entity.NewUserToken(user.ID, entity.TokenPurposePasswordReset, time.Hour)
+	tokenDB := NewUserTokenDB(db)
+	db.Create(token)
+
+	assert.Nil(t, tokenDB.Revoke(user.ID.String(), entity.TokenPurposePasswordReset))
+	_, err = tokenDB.Consume(token.ID.String(), entity.TokenPurposePasswordReset)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestFindUserToken(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	token := entity.NewUserToken(user.ID, entity.TokenPurposeMFAChallenge, time.Minute)
+	tokenDB := NewUserTokenDB(db)
+	assert.Nil(t, tokenDB.Create(token))
+
+	found, err := tokenDB.Find(token.ID.String(), entity.TokenPurposeMFAChallenge)
+	assert.Nil(t, err)
+	assert.Nil(t, found.UsedAt)
+	_, err = tokenDB.Find(token.ID.String(), entity.TokenPurposePasswordReset)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	tokenDB.Consume(token.ID.String(), entity.TokenPurposeMFAChallenge)
+	_, err = tokenDB.Find(token.ID.String(), entity.TokenPurposeMFAChallenge)
+	assert.Equal(t, ErrInvalidToken, err)
+}
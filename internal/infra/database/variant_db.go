@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"gorm.io/gorm"
+)
+
+var ErrVariantAlreadyExists = errors.New("a variant with these options already exists")
+
+// Variant is scoped to the tenant carried by the context of each call, except
+// for BackfillPrices which covers every tenant.
+type Variant struct {
+	DB *gorm.DB
+}
+
+func NewVariantDB(db *gorm.DB) *Variant {
+	return &Variant{DB: db}
+}
+
+func (v *Variant) scoped(ctx context.Context) *gorm.DB {
+	return v.DB.WithContext(ctx).Scopes(TenantScope(ctx))
+}
+
+// Create adds variant to the tenant of ctx, whatever its TenantID.
+func (v *Variant) Create(ctx context.Context, variant *entity.Variant) error {
+	return v.CreateMany(ctx, []*entity.Variant{variant})
+}
+
+// CreateMany adds every variant to the tenant of ctx, or none of them.
+func (v *Variant) CreateMany(ctx context.Context, variants []*entity.Variant) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	return v.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, variant := range variants {
+			variant.TenantID = tenantID
+			if err := checkVariant(tx.Scopes(TenantScope(ctx)), variant); err != nil {
+				return err
+			}
+			if err := tx.Create(variant).Error; err != nil {
+				return variantConflict(tx.Scopes(TenantScope(ctx)), variant, err)
+			}
+			if err := tx.Create(entity.NewVariantPrice(variant, variant.CreatedAt)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (v *Variant) FindByID(ctx context.Context, productID, id string) (*entity.Variant, error) {
+	var variant entity.Variant
+	err := v.scoped(ctx).First(&variant, "product_id = ? AND id = ?", productID, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// FindBySKU finds the variant of the tenant of ctx with sku.
+func (v *Variant) FindBySKU(ctx context.Context, sku string) (*entity.Variant, error) {
+	if sku == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var variant entity.Variant
+	if err := v.scoped(ctx).First(&variant, "sku = ?", sku).Error; err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+func (v *Variant) FindAllByProduct(ctx context.Context, productID string) ([]entity.Variant, error) {
+	return v.FindAllByProducts(ctx, []string{productID})
+}
+
+// FindAllByProducts returns the variants of every product in productIDs,
+// oldest first.
+func (v *Variant) FindAllByProducts(ctx context.Context, productIDs []string) ([]entity.Variant, error) {
+	variants := []entity.Variant{}
+	if len(productIDs) == 0 {
+		return variants, nil
+	}
+	err := v.scoped(ctx).Where("product_id IN ?", productIDs).Order("created_at asc").Find(&variants).Error
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// Update saves variant if it belongs to the tenant of ctx. It cannot be moved
+// to another tenant or product. A new price override is recorded in the
+// price history of the variant as in effect from now.
+func (v *Variant) Update(ctx context.Context, variant *entity.Variant) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrTenantRequired
+	}
+	variant.TenantID = tenantID
+	if err := checkVariant(v.scoped(ctx), variant); err != nil {
+		return err
+	}
+	return v.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var previous entity.Variant
+		err := tx.Scopes(TenantScope(ctx)).Select("price").
+			First(&previous, "product_id = ? AND id = ?", variant.ProductID, variant.ID).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Scopes(TenantScope(ctx)).Model(variant).Where("product_id = ?", variant.ProductID).Select("*").Updates(variant)
+		if result.Error != nil {
+			return variantConflict(tx.Scopes(TenantScope(ctx)), variant, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if samePrice(previous.Price, variant.Price) {
+			return nil
+		}
+		return tx.Create(entity.NewVariantPrice(variant, time.Now())).Error
+	})
+}
+
+func samePrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Delete deletes a variant and its price history.
+func (v *Variant) Delete(ctx context.Context, productID, id string) error {
+	return v.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(TenantScope(ctx)).Delete(&entity.Variant{}, "product_id = ? AND id = ?", productID, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&entity.VariantPrice{}, "variant_id = ?", id).Error
+	})
+}
+
+// FindPrices returns the price history of a variant by start, then creation.
+func (v *Variant) FindPrices(ctx context.Context, variantID string) ([]entity.VariantPrice, error) {
+	prices := []entity.VariantPrice{}
+	err := v.scoped(ctx).Where("variant_id = ?", variantID).Order("starts_at asc, created_at asc").Find(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// BackfillPrices records the price override of the variants, in every
+// tenant, that have no price history yet as in effect since their creation.
+func (v *Variant) BackfillPrices(ctx context.Context) (int64, error) {
+	var variants []entity.Variant
+	err := v.DB.WithContext(ctx).
+		Where("id NOT IN (?)", v.DB.Model(&entity.VariantPrice{}).Select("variant_id")).
+		Find(&variants).Error
+	if err != nil || len(variants) == 0 {
+		return 0, err
+	}
+	prices := make([]*entity.VariantPrice, len(variants))
+	for i := range variants {
+		prices[i] = entity.NewVariantPrice(&variants[i], variants[i].CreatedAt)
+	}
+	if err := v.DB.WithContext(ctx).CreateInBatches(prices, 100).Error; err != nil {
+		return 0, err
+	}
+	return int64(len(prices)), nil
+}
+
+// checkVariant tells whether another variant already has the options of
+// variant in its product, or whether another variant or a product has its
+// SKU in the tenant db is scoped to.
+func checkVariant(db *gorm.DB, variant *entity.Variant) error {
+	// A new session keeps the conditions of one query out of the next.
+	db = db.Session(&gorm.Session{})
+	var count int64
+	err := db.Model(&entity.Variant{}).
+		Where("product_id = ? AND options_key = ? AND id <> ?", variant.ProductID, variant.OptionsKey, variant.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrVariantAlreadyExists
+	}
+	if variant.SKU == "" {
+		return nil
+	}
+	err = db.Model(&entity.Variant{}).
+		Where("sku = ? AND id <> ?", variant.SKU, variant.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSKUAlreadyExists
+	}
+	if err := db.Model(&entity.Product{}).Where("sku = ?", variant.SKU).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSKUAlreadyExists
+	}
+	return nil
+}
+
+// variantConflict names what a concurrent write took between checkVariant
+// and the unique indexes rejecting variant.
+func variantConflict(db *gorm.DB, variant *entity.Variant, err error) error {
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return err
+	}
+	if conflict := checkVariant(db, variant); conflict != nil {
+		return conflict
+	}
+	return err
+}
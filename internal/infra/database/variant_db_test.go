@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newVariantTest(t *testing.T) (*Variant, context.Context, *entity.Product) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{})
+	ctx := WithTenant(context.Background(), entityPkg.NewID())
+	product, _ := entity.NewProduct("Shirt", 20)
+	product.SKU = "SHIRT"
+	product.Options = []entity.ProductOption{
+		{Name: "Size", Values: []string{"S", "M"}},
+		{Name: "Color", Values: []string{"Red", "Blue"}},
+	}
+	assert.Nil(t, NewProductDB(db).Create(ctx, product))
+	return NewVariantDB(db), ctx, product
+}
+
+func TestCreateVariants(t *testing.T) {
+	variantDB, ctx, product := newVariantTest(t)
+
+	variants := product.GenerateVariants(nil)
+	assert.Nil(t, variantDB.CreateMany(ctx, variants))
+	found, err := variantDB.FindAllByProduct(ctx, product.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, found, 4)
+	assert.Equal(t, product.TenantID, found[0].TenantID)
+	assert.Equal(t, map[string]string{"Size": "S", "Color": "Red"}, found[0].Options)
+
+	duplicate, _ := entity.NewVariant(product, map[string]string{"Color": "Red", "Size": "S"})
+	assert.Equal(t, ErrVariantAlreadyExists, variantDB.Create(ctx, duplicate))
+	product.Options[0].Values = append(product.Options[0].Values, "L")
+	large, _ := entity.NewVariant(product, map[string]string{"Color": "Red", "Size": "L"})
+	large.SKU = variants[0].SKU
+	assert.Equal(t, ErrSKUAlreadyExists, variantDB.Create(ctx, large))
+	// A failed batch leaves nothing behind.
+	large.SKU = "SHIRT-L-RED"
+	assert.Equal(t, ErrVariantAlreadyExists, variantDB.CreateMany(ctx, []*entity.Variant{large, duplicate}))
+	found, _ = variantDB.FindAllByProduct(ctx, product.ID.String())
+	assert.Len(t, found, 4)
+
+	_, err = variantDB.FindAllByProduct(WithTenant(context.Background(), entityPkg.NewID()), product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, ErrTenantRequired, variantDB.Create(context.Background(), large))
+}
+
+func TestUpdateAndDeleteVariant(t *testing.T) {
+	variantDB, ctx, product := newVariantTest(t)
+	variants := product.GenerateVariants(nil)
+	assert.Nil(t, variantDB.CreateMany(ctx, variants))
+
+	variant, err := variantDB.FindByID(ctx, product.ID.String(), variants[0].ID.String())
+	assert.Nil(t, err)
+	price := 25.0
+	variant.Price = &price
+	variant.Stock = 7
+	assert.Nil(t, variantDB.Update(ctx, variant))
+	variant, _ = variantDB.FindByID(ctx, product.ID.String(), variant.ID.String())
+	assert.Equal(t, 25.0, *variant.Price)
+	assert.Equal(t, 7, variant.Stock)
+
+	variant.SKU = variants[1].SKU
+	assert.Equal(t, ErrSKUAlreadyExists, variantDB.Update(ctx, variant))
+	other := WithTenant(context.Background(), entityPkg.NewID())
+	variant.SKU = ""
+	assert.ErrorIs(t, variantDB.Update(other, variant), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, variantDB.Delete(other, product.ID.String(), variant.ID.String()), gorm.ErrRecordNotFound)
+
+	assert.Nil(t, variantDB.Delete(ctx, product.ID.String(), variant.ID.String()))
+	_, err = variantDB.FindByID(ctx, product.ID.String(), variant.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Nil(t, NewProductDB(variantDB.DB).Delete(ctx, product.ID.String()))
+	found, _ := variantDB.FindAllByProducts(ctx, []string{product.ID.String()})
+	assert.Empty(t, found)
+}
+
+func TestVariantSKUsAreUniqueAcrossProducts(t *testing.T) {
+	variantDB, ctx, product := newVariantTest(t)
+	productDB := NewProductDB(variantDB.DB)
+	variants := product.GenerateVariants(nil)
+	assert.Nil(t, variantDB.CreateMany(ctx, variants))
+
+	found, err := variantDB.FindBySKU(ctx, variants[1].SKU)
+	assert.Nil(t, err)
+	assert.Equal(t, variants[1].ID, found.ID)
+	_, err = variantDB.FindBySKU(WithTenant(context.Background(), entityPkg.NewID()), variants[1].SKU)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	variants[0].SKU = product.SKU
+	assert.Equal(t, ErrSKUAlreadyExists, variantDB.Update(ctx, variants[0]))
+	other, _ := entity.NewProduct("Other shirt", 20)
+	other.SKU = variants[1].SKU
+	assert.Equal(t, ErrSKUAlreadyExists, productDB.Create(ctx, other))
+	other.SKU = "OTHER"
+	assert.Nil(t, productDB.Create(ctx, other))
+	other.SKU = variants[1].SKU
+	assert.Equal(t, ErrSKUAlreadyExists, productDB.Update(ctx, other))
+}
+
+func TestVariantPriceHistory(t *testing.T) {
+	variantDB, ctx, product := newVariantTest(t)
+	variants := product.GenerateVariants(nil)
+	assert.Nil(t, variantDB.CreateMany(ctx, variants))
+	variant := variants[0]
+
+	prices, err := variantDB.FindPrices(ctx, variant.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, prices, 1)
+	assert.Nil(t, prices[0].Amount)
+
+	override := 25.0
+	variant.Price = &override
+	assert.Nil(t, variantDB.Update(ctx, variant))
+	variant.Stock = 3
+	assert.Nil(t, variantDB.Update(ctx, variant))
+	prices, _ = variantDB.FindPrices(ctx, variant.ID.String())
+	assert.Len(t, prices, 2)
+	assert.Equal(t, 25.0, *prices[1].Amount)
+	assert.Equal(t, 25.0, *entity.VariantPriceAt(prices, product.Price, prices[1].StartsAt))
+	assert.Equal(t, product.Price, *entity.VariantPriceAt(prices, product.Price, prices[1].StartsAt.Add(-time.Nanosecond)))
+	other, _ := variantDB.FindPrices(WithTenant(context.Background(), entityPkg.NewID()), variant.ID.String())
+	assert.Empty(t, other)
+
+	// Variants created before price histories get theirs from their creation.
+	variantDB.DB.Where("1 = 1").Delete(&entity.VariantPrice{})
+	backfilled, err := variantDB.BackfillPrices(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(variants)), backfilled)
+	prices, _ = variantDB.FindPrices(ctx, variant.ID.String())
+	assert.Len(t, prices, 1)
+	assert.Equal(t, 25.0, *prices[0].Amount)
+	assert.Equal(t, variant.CreatedAt.UTC(), prices[0].StartsAt.UTC())
+
+	assert.Nil(t, variantDB.Delete(ctx, product.ID.String(), variant.ID.String()))
+	prices, _ = variantDB.FindPrices(ctx, variant.ID.String())
+	assert.Empty(t, prices)
+}
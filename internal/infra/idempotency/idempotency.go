@@ -0,0 +1,33 @@
+// Package idempotency stores the responses to requests carrying an
+// Idempotency-Key so retries replay them instead of repeating side effects.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Record is what is stored under an idempotency key: the fingerprint of the
+// request that reserved it and, once it completed, its response.
+type Record struct {
+	Fingerprint string      `json:"fingerprint"`
+	Completed   bool        `json:"completed"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// Store keeps idempotency records. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Reserve stores an in-progress record for fingerprint under key for
+	// ttl unless key is taken. It returns the record holding key and
+	// whether it was just reserved.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error)
+	// Complete replaces the record under key with a completed one kept for
+	// ttl.
+	Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+	// Release frees key so the request can be tried again.
+	Release(ctx context.Context, key string) error
+}
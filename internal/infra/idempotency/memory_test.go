@@ -0,0 +1,43 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	record, reserved, err := store.Reserve(ctx, "key", "abc", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, reserved)
+	assert.False(t, record.Completed)
+
+	record, reserved, _ = store.Reserve(ctx, "key", "def", time.Minute)
+	assert.False(t, reserved)
+	assert.Equal(t, "abc", record.Fingerprint)
+	assert.False(t, record.Completed)
+
+	completed := &Record{Fingerprint: "abc", Status: http.StatusCreated, Header: http.Header{"Location": {"/products/1"}}, Body: []byte("{}")}
+	assert.Nil(t, store.Complete(ctx, "key", completed, time.Hour))
+	record, reserved, _ = store.Reserve(ctx, "key", "abc", time.Minute)
+	assert.False(t, reserved)
+	assert.True(t, record.Completed)
+	assert.Equal(t, http.StatusCreated, record.Status)
+	assert.Equal(t, "/products/1", record.Header.Get("Location"))
+
+	now = now.Add(time.Hour)
+	_, reserved, _ = store.Reserve(ctx, "key", "def", time.Minute)
+	assert.True(t, reserved)
+
+	assert.Nil(t, store.Release(ctx, "key"))
+	_, reserved, _ = store.Reserve(ctx, "key", "abc", time.Minute)
+	assert.True(t, reserved)
+}
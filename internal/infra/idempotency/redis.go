@@ -0,0 +1,66 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps idempotency records in Redis or any server speaking its
+// protocol, so retries can land on any API instance.
+type RedisStore struct {
+	Client redis.UniversalClient
+	Prefix string
+}
+
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{Client: client, Prefix: prefix}
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	record := &Record{Fingerprint: fingerprint}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+	// The holder may expire between SET NX and GET, hence the second try.
+	for i := 0; i < 2; i++ {
+		reserved, err := s.Client.SetNX(ctx, s.Prefix+key, value, ttl).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if reserved {
+			return record, true, nil
+		}
+		stored, err := s.Client.Get(ctx, s.Prefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		var existing Record
+		if err := json.Unmarshal(stored, &existing); err != nil {
+			return nil, false, err
+		}
+		return &existing, false, nil
+	}
+	return nil, false, errors.New("idempotency key kept expiring while reserving it")
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	completed := *record
+	completed.Completed = true
+	value, err := json.Marshal(completed)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(ctx, s.Prefix+key, value, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.Client.Del(ctx, s.Prefix+key).Err()
+}
@@ -0,0 +1,52 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client, "test:"), server
+}
+
+func TestRedisStore(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+
+	record, reserved, err := store.Reserve(ctx, "key", "abc", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, reserved)
+	assert.False(t, record.Completed)
+	assert.True(t, server.Exists("test:key"))
+
+	record, reserved, err = store.Reserve(ctx, "key", "def", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, "abc", record.Fingerprint)
+	assert.False(t, record.Completed)
+
+	completed := &Record{Fingerprint: "abc", Status: http.StatusCreated, Header: http.Header{"Location": {"/products/1"}}, Body: []byte("{}")}
+	assert.Nil(t, store.Complete(ctx, "key", completed, time.Hour))
+	record, reserved, _ = store.Reserve(ctx, "key", "abc", time.Minute)
+	assert.False(t, reserved)
+	assert.True(t, record.Completed)
+	assert.Equal(t, http.StatusCreated, record.Status)
+	assert.Equal(t, "/products/1", record.Header.Get("Location"))
+	assert.Equal(t, []byte("{}"), record.Body)
+
+	server.FastForward(time.Hour)
+	_, reserved, _ = store.Reserve(ctx, "key", "def", time.Minute)
+	assert.True(t, reserved)
+
+	assert.Nil(t, store.Release(ctx, "key"))
+	assert.False(t, server.Exists("test:key"))
+}
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLoggerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, "info", "json")
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "abc-123")
+	log.InfoContext(ctx, "hello", "key", "value")
+
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "hello", record["msg"])
+	assert.Equal(t, "value", record["key"])
+	assert.Equal(t, "abc-123", record["request_id"])
+}
+
+func TestNewLoggerRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, "warn", "text")
+	log.Info("ignored")
+	assert.Empty(t, buf.String())
+	log.Warn("kept")
+	assert.Contains(t, buf.String(), "msg=kept")
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, ParseLevel("DEBUG"))
+	assert.Equal(t, slog.LevelError, ParseLevel("error"))
+	assert.Equal(t, slog.LevelInfo, ParseLevel(""))
+}
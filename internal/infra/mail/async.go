@@ -0,0 +1,59 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+// sendTimeout bounds each delivery, so a relay that hangs cannot stall the
+// messages queued behind it forever.
+const sendTimeout = 30 * time.Second
+
+// AsyncMailer queues messages and delivers them with Mailer from Run, so
+// requests sending mail do not wait on the relay. Delivery failures are
+// logged, as the request that queued the message is long gone by then.
+type AsyncMailer struct {
+	Mailer Mailer
+	Logger *slog.Logger
+	queue  chan Message
+}
+
+// NewAsyncMailer queues up to size messages; Send fails beyond that.
+func NewAsyncMailer(mailer Mailer, logger *slog.Logger, size int) *AsyncMailer {
+	return &AsyncMailer{Mailer: mailer, Logger: logger, queue: make(chan Message, size)}
+}
+
+// Send queues msg without waiting for its delivery. It returns ErrQueueFull
+// rather than block when the queue is full.
+func (m *AsyncMailer) Send(ctx context.Context, msg Message) error {
+	select {
+	case m.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers queued messages one after the other until ctx is done.
+func (m *AsyncMailer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-m.queue:
+			m.deliver(ctx, msg)
+		}
+	}
+}
+
+func (m *AsyncMailer) deliver(ctx context.Context, msg Message) {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	if err := m.Mailer.Send(ctx, msg); err != nil {
+		m.Logger.ErrorContext(ctx, "failed to send email", "subject", msg.Subject, "error", err)
+	}
+}
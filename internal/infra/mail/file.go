@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+// FileMailer writes every message as an .eml file in Dir, which is handy to
+// inspect outgoing mail locally.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), entity.NewID())
+	return os.WriteFile(filepath.Join(m.Dir, name), msg.Bytes(m.From), 0o644)
+}
+
+// LogMailer logs messages instead of delivering them. Bodies may contain
+// secrets such as reset links, so it is meant for development only.
+type LogMailer struct {
+	Logger *slog.Logger
+	From   string
+}
+
+func NewLogMailer(logger *slog.Logger, from string) *LogMailer {
+	return &LogMailer{Logger: logger, From: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.Logger.InfoContext(ctx, "mail sent", "from", m.From, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
@@ -0,0 +1,59 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	dir := t.TempDir()
+	mailer := NewFileMailer(dir, "no-reply@example.com")
+	err := mailer.Send(context.Background(), Message{
+		To:      "j@j.com",
+		Subject: "Verify your email",
+		Body:    "Click the link",
+	})
+	assert.Nil(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Len(t, files, 1)
+	content, _ := os.ReadFile(files[0])
+	assert.Contains(t, string(content), "From: no-reply@example.com\r\n")
+	assert.Contains(t, string(content), "To: j@j.com\r\n")
+	assert.Contains(t, string(content), "Subject: Verify your email\r\n")
+	assert.Contains(t, string(content), "\r\n\r\nClick the link")
+}
+
+type recordingMailer struct {
+	sent chan Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg Message) error {
+	m.sent <- msg
+	return nil
+}
+
+func TestAsyncMailer(t *testing.T) {
+	recorder := &recordingMailer{sent: make(chan Message, 1)}
+	mailer := NewAsyncMailer(recorder, slog.New(slog.NewTextHandler(io.Discard, nil)), 1)
+
+	assert.Nil(t, mailer.Send(context.Background(), Message{To: "j@j.com", Subject: "First"}))
+	assert.Equal(t, ErrQueueFull, mailer.Send(context.Background(), Message{To: "j@j.com", Subject: "Second"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mailer.Run(ctx)
+	select {
+	case msg := <-recorder.sent:
+		assert.Equal(t, "First", msg.Subject)
+	case <-time.After(time.Second):
+		t.Fatal("queued message was not delivered")
+	}
+}
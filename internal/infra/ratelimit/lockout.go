@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Lockout locks an account out after Threshold consecutive failures within
+// Window. Each further failure doubles the lock duration, starting at
+// BaseDelay and capped at MaxDelay. Clients that succeeded on an account
+// are trusted on it for TrustFor, so callers can let them past its lock.
+type Lockout struct {
+	Store     Store
+	Threshold int
+	Window    time.Duration
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	TrustFor  time.Duration
+	now       func() time.Time
+}
+
+func NewLockout(store Store, threshold int, window, baseDelay, maxDelay, trustFor time.Duration) *Lockout {
+	return &Lockout{
+		Store:     store,
+		Threshold: threshold,
+		Window:    window,
+		BaseDelay: baseDelay,
+		MaxDelay:  maxDelay,
+		TrustFor:  trustFor,
+		now:       time.Now,
+	}
+}
+
+// Locked returns how long the account remains locked, or 0 when it is not.
+func (l *Lockout) Locked(ctx context.Context, account string) (time.Duration, error) {
+	until, err := l.Store.Get(ctx, l.lockKey(account))
+	if err != nil {
+		return 0, err
+	}
+	if until == 0 {
+		return 0, nil
+	}
+	remaining := time.Unix(0, until).Sub(l.clock())
+	if remaining <= 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// Fail records a failed attempt and returns the lock duration it triggered,
+// or 0 when the account is still below the threshold.
+func (l *Lockout) Fail(ctx context.Context, account string) (time.Duration, error) {
+	failures, err := l.Store.Increment(ctx, l.failuresKey(account), l.Window)
+	if err != nil {
+		return 0, err
+	}
+	if l.Threshold <= 0 || failures < int64(l.Threshold) {
+		return 0, nil
+	}
+	delay := l.BaseDelay
+	for i := int64(l.Threshold); i < failures && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+	if l.MaxDelay > 0 && delay > l.MaxDelay {
+		delay = l.MaxDelay
+	}
+	until := l.clock().Add(delay)
+	if err := l.Store.Set(ctx, l.lockKey(account), until.UnixNano(), delay); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Reset clears the failures of an account after a successful attempt.
+func (l *Lockout) Reset(ctx context.Context, account string) error {
+	return l.Store.Delete(ctx, l.failuresKey(account), l.lockKey(account))
+}
+
+// Trust records that client succeeded on account, for TrustFor.
+func (l *Lockout) Trust(ctx context.Context, account, client string) error {
+	if l.TrustFor <= 0 {
+		return nil
+	}
+	return l.Store.Set(ctx, l.trustKey(account, client), 1, l.TrustFor)
+}
+
+// Trusted reports whether client succeeded on account within TrustFor.
+func (l *Lockout) Trusted(ctx context.Context, account, client string) (bool, error) {
+	trusted, err := l.Store.Get(ctx, l.trustKey(account, client))
+	return trusted > 0, err
+}
+
+func (l *Lockout) clock() time.Time {
+	if l.now == nil {
+		return time.Now()
+	}
+	return l.now()
+}
+
+func (l *Lockout) failuresKey(account string) string {
+	return "lockout:failures:" + strings.ToLower(account)
+}
+
+func (l *Lockout) lockKey(account string) string {
+	return "lockout:until:" + strings.ToLower(account)
+}
+
+func (l *Lockout) trustKey(account, client string) string {
+	return "lockout:trusted:" + strings.ToLower(account) + ":" + client
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("10/1m")
+	assert.Nil(t, err)
+	assert.Equal(t, 10, limit.Burst)
+	assert.InDelta(t, 10.0/60.0, limit.Rate, 0.0001)
+
+	for _, invalid := range []string{"", "10", "a/1m", "10/x", "0/1m", "10/0s"} {
+		_, err := ParseLimit(invalid)
+		assert.Equal(t, ErrInvalidLimit, err, invalid)
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	res, err := store.Take(ctx, "client", limit)
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	res, _ = store.Take(ctx, "client", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	res, _ = store.Take(ctx, "client", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 2*time.Second, res.ResetAfter)
+
+	res, _ = store.Take(ctx, "other", limit)
+	assert.True(t, res.Allowed)
+
+	now = now.Add(time.Second)
+	res, _ = store.Take(ctx, "client", limit)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStoreCounters(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	value, _ := store.Increment(ctx, "counter", time.Minute)
+	assert.Equal(t, int64(1), value)
+	value, _ = store.Increment(ctx, "counter", time.Minute)
+	assert.Equal(t, int64(2), value)
+	value, _ = store.Get(ctx, "counter")
+	assert.Equal(t, int64(2), value)
+
+	now = now.Add(time.Minute)
+	value, _ = store.Get(ctx, "counter")
+	assert.Equal(t, int64(0), value)
+
+	assert.Nil(t, store.Set(ctx, "counter", 42, time.Minute))
+	assert.Nil(t, store.Delete(ctx, "counter"))
+	value, _ = store.Get(ctx, "counter")
+	assert.Equal(t, int64(0), value)
+}
+
+func TestLockout(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	lockout := NewLockout(store, 3, time.Hour, time.Minute, 4*time.Minute, 24*time.Hour)
+	lockout.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		delay, err := lockout.Fail(ctx, "John@Example.com")
+		assert.Nil(t, err)
+		assert.Zero(t, delay)
+	}
+	delay, _ := lockout.Fail(ctx, "john@example.com")
+	assert.Equal(t, time.Minute, delay)
+	remaining, _ := lockout.Locked(ctx, "JOHN@example.com")
+	assert.Equal(t, time.Minute, remaining)
+
+	delay, _ = lockout.Fail(ctx, "john@example.com")
+	assert.Equal(t, 2*time.Minute, delay)
+	delay, _ = lockout.Fail(ctx, "john@example.com")
+	assert.Equal(t, 4*time.Minute, delay)
+	delay, _ = lockout.Fail(ctx, "john@example.com")
+	assert.Equal(t, 4*time.Minute, delay)
+
+	now = now.Add(5 * time.Minute)
+	remaining, _ = lockout.Locked(ctx, "john@example.com")
+	assert.Zero(t, remaining)
+
+	assert.Nil(t, lockout.Reset(ctx, "john@example.com"))
+	delay, _ = lockout.Fail(ctx, "john@example.com")
+	assert.Zero(t, delay)
+}
+
+func TestLockoutTrust(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	lockout := NewLockout(store, 3, time.Hour, time.Minute, 4*time.Minute, 24*time.Hour)
+	ctx := context.Background()
+
+	trusted, err := lockout.Trusted(ctx, "john@example.com", "ip:10.0.0.1")
+	assert.Nil(t, err)
+	assert.False(t, trusted)
+	assert.Nil(t, lockout.Trust(ctx, "John@Example.com", "ip:10.0.0.1"))
+	trusted, _ = lockout.Trusted(ctx, "john@example.com", "ip:10.0.0.1")
+	assert.True(t, trusted)
+	trusted, _ = lockout.Trusted(ctx, "john@example.com", "ip:10.0.0.2")
+	assert.False(t, trusted)
+
+	now = now.Add(25 * time.Hour)
+	trusted, _ = lockout.Trusted(ctx, "john@example.com", "ip:10.0.0.1")
+	assert.False(t, trusted)
+
+	lockout.TrustFor = 0
+	assert.Nil(t, lockout.Trust(ctx, "john@example.com", "ip:10.0.0.1"))
+	trusted, _ = lockout.Trusted(ctx, "john@example.com", "ip:10.0.0.1")
+	assert.False(t, trusted)
+}
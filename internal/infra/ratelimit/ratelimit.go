@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit, expected <requests>/<period> such as 10/1m")
+
+// Limit describes a token bucket: Burst tokens at most, refilled at Rate
+// tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Store keeps rate limiting state. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Take removes one token from the bucket identified by key.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Increment adds one to the counter identified by key and returns the new
+	// value. The ttl is applied when the counter is created.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Get returns the counter identified by key, or 0 when it does not exist.
+	Get(ctx context.Context, key string) (int64, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value int64, ttl time.Duration) error
+	// Delete removes the given keys.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// ParseLimit parses "<requests>/<period>", for instance "10/1m" or "1000/1h".
+// The bucket holds <requests> tokens and refills them evenly over <period>.
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return Limit{}, ErrInvalidLimit
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	return Limit{Rate: float64(requests) / period.Seconds(), Burst: requests}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, time.Duration(float64(l.Burst)/l.Rate*float64(time.Second)))
+}
+
+// result builds a Result from the bucket level left after a take.
+func (l Limit) result(allowed bool, tokens float64) Result {
+	res := Result{
+		Allowed:    allowed,
+		Limit:      l.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: l.refill(float64(l.Burst) - tokens),
+	}
+	if !allowed {
+		res.RetryAfter = l.refill(1 - tokens)
+	}
+	return res
+}
+
+// refill returns how long it takes to refill the given amount of tokens.
+func (l Limit) refill(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
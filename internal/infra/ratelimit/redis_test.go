@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client, "test:"), server
+}
+
+func TestRedisStoreTake(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	res, err := store.Take(ctx, "client", limit)
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	res, _ = store.Take(ctx, "client", limit)
+	assert.True(t, res.Allowed)
+	res, err = store.Take(ctx, "client", limit)
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.True(t, server.Exists("test:client"))
+
+	now = now.Add(time.Second)
+	res, _ = store.Take(ctx, "client", limit)
+	assert.True(t, res.Allowed)
+}
+
+func TestRedisStoreCounters(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+
+	value, err := store.Increment(ctx, "counter", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), value)
+	value, _ = store.Increment(ctx, "counter", time.Minute)
+	assert.Equal(t, int64(2), value)
+	value, _ = store.Get(ctx, "counter")
+	assert.Equal(t, int64(2), value)
+
+	server.FastForward(time.Minute)
+	value, err = store.Get(ctx, "counter")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), value)
+
+	assert.Nil(t, store.Set(ctx, "counter", 42, time.Minute))
+	value, _ = store.Get(ctx, "counter")
+	assert.Equal(t, int64(42), value)
+	assert.Nil(t, store.Delete(ctx, "counter"))
+	assert.False(t, server.Exists("test:counter"))
+}
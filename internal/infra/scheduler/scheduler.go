@@ -0,0 +1,57 @@
+// Package scheduler runs background jobs at a fixed interval.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job does the work due at now. Jobs should be idempotent: a tick may be
+// missed or run by several instances of the API at once.
+type Job func(ctx context.Context, now time.Time) error
+
+type namedJob struct {
+	name string
+	run  Job
+}
+
+type Scheduler struct {
+	Interval time.Duration
+	Logger   *slog.Logger
+	jobs     []namedJob
+}
+
+func New(interval time.Duration, logger *slog.Logger) *Scheduler {
+	return &Scheduler{Interval: interval, Logger: logger}
+}
+
+// Add registers job under name, which is used in logs. It must be called
+// before Run.
+func (s *Scheduler) Add(name string, job Job) {
+	s.jobs = append(s.jobs, namedJob{name: name, run: job})
+}
+
+// Run runs the jobs right away and then every Interval, one after the other,
+// until ctx is done. A failing job is logged and retried on the next tick.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	s.tick(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.tick(ctx, now)
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	for _, job := range s.jobs {
+		if err := job.run(ctx, now); err != nil {
+			s.Logger.ErrorContext(ctx, "scheduled job failed", "job", job.name, "error", err)
+		}
+	}
+}
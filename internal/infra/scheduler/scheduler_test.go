@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRunsJobsUntilCanceled(t *testing.T) {
+	s := New(10*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	var runs, failures atomic.Int32
+	s.Add("fail", func(ctx context.Context, now time.Time) error {
+		failures.Add(1)
+		return errors.New("boom")
+	})
+	s.Add("count", func(ctx context.Context, now time.Time) error {
+		runs.Add(1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	// A failing job does not stop the ones after it or the next ticks.
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 && failures.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	stopped := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	root := t.TempDir()
+	store := NewLocalStore(root)
+	ctx := context.Background()
+
+	assert.Nil(t, store.Put(ctx, "products/1/image.png", []byte("png"), "image/png"))
+	object, err := store.Get(ctx, "products/1/image.png")
+	assert.Nil(t, err)
+	data, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	assert.Equal(t, "png", string(data))
+	assert.Equal(t, "image/png", object.ContentType)
+	assert.Equal(t, int64(3), object.Size)
+
+	_, err = store.Get(ctx, "products/1")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.Get(ctx, "products/2/image.png")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Nil(t, store.Delete(ctx, "products/1/image.png"))
+	assert.Nil(t, store.Delete(ctx, "products/1/image.png"))
+	_, err = os.Stat(filepath.Join(root, "products", "1", "image.png"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestValidKey(t *testing.T) {
+	for _, key := range []string{"a", "a/b.png", "tenants/1/products/2/original.jpg"} {
+		assert.True(t, ValidKey(key), key)
+	}
+	for _, key := range []string{"", "/a", "a/", "a//b", "../a", "a/../b", "a/./b", `a\b`} {
+		assert.False(t, ValidKey(key), key)
+	}
+	store := NewLocalStore(t.TempDir())
+	assert.Equal(t, ErrInvalidKey, store.Put(context.Background(), "../escape", []byte("x"), "text/plain"))
+}
@@ -0,0 +1,46 @@
+// Package storage keeps blobs, such as product media, behind a driver
+// independent interface.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Object is a stored blob being read. Callers close Body.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// Store keeps blobs under slash separated keys. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns ErrNotFound when nothing is stored under key.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete succeeds when nothing is stored under key.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key is made of non empty path segments without
+// dot segments, so it cannot escape the root of a store.
+func ValidKey(key string) bool {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+// Package thumbnail decodes uploaded images and makes downscaled copies of
+// them using only the standard library codecs.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxPixels bounds the images Decode accepts, so a small compressed upload
+// cannot make the server allocate gigabytes.
+const MaxPixels = 40_000_000
+
+var ErrImageTooLarge = errors.New("image has too many pixels")
+
+// Thumbnail is an encoded copy of an image fitting in a Size square.
+type Thumbnail struct {
+	Size        int
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// Decode decodes a JPEG, PNG or GIF image, returning its format name.
+func Decode(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, "", ErrImageTooLarge
+	}
+	switch format {
+	case "jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		return img, format, err
+	case "png":
+		img, err := png.Decode(bytes.NewReader(data))
+		return img, format, err
+	case "gif":
+		img, err := gif.Decode(bytes.NewReader(data))
+		return img, format, err
+	}
+	return nil, "", image.ErrFormat
+}
+
+// Generate makes a thumbnail of img for each size, never upscaling it.
+// JPEG sources make JPEG thumbnails; others make PNG ones to keep
+// transparency.
+func Generate(img image.Image, format string, sizes []int) ([]Thumbnail, error) {
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	thumbnails := make([]Thumbnail, 0, len(sizes))
+	for _, size := range sizes {
+		scaled := Fit(src, size)
+		var buf bytes.Buffer
+		thumbnail := Thumbnail{Size: size, Width: scaled.Bounds().Dx(), Height: scaled.Bounds().Dy()}
+		var err error
+		if format == "jpeg" {
+			thumbnail.ContentType = "image/jpeg"
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+		} else {
+			thumbnail.ContentType = "image/png"
+			err = png.Encode(&buf, scaled)
+		}
+		if err != nil {
+			return nil, err
+		}
+		thumbnail.Data = buf.Bytes()
+		thumbnails = append(thumbnails, thumbnail)
+	}
+	return thumbnails, nil
+}
+
+// Fit scales src down so its longest side is at most size, averaging the
+// source pixels each destination pixel covers. Smaller images are returned
+// as they are.
+func Fit(src *image.RGBA, size int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if size <= 0 || (sw <= size && sh <= size) {
+		return src
+	}
+	dw, dh := size, sh*size/sw
+	if sh > sw {
+		dw, dh = sw*size/sh, size
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[(sy-src.Rect.Min.Y)*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					i := (sx - src.Rect.Min.X) * 4
+					r += int(row[i])
+					g += int(row[i+1])
+					b += int(row[i+2])
+					a += int(row[i+3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,72 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestFit(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				src.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	dst := Fit(src, 100)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), dst.Bounds())
+	assert.Equal(t, color.RGBA{R: 127, B: 127, A: 255}, dst.RGBAAt(10, 10))
+	assert.Equal(t, image.Rect(0, 0, 50, 100), Fit(image.NewRGBA(image.Rect(0, 0, 200, 400)), 100).Bounds())
+	assert.Same(t, src, Fit(src, 800))
+}
+
+func TestDecodeAndGenerate(t *testing.T) {
+	data := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 300, 150)))
+	img, format, err := Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "png", format)
+
+	thumbnails, err := Generate(img, format, []int{100, 200, 400})
+	assert.Nil(t, err)
+	assert.Len(t, thumbnails, 3)
+	assert.Equal(t, 100, thumbnails[0].Width)
+	assert.Equal(t, 50, thumbnails[0].Height)
+	assert.Equal(t, "image/png", thumbnails[0].ContentType)
+	assert.Equal(t, 300, thumbnails[2].Width)
+	decoded, err := png.Decode(bytes.NewReader(thumbnails[1].Data))
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 200, 100), decoded.Bounds())
+
+	var buf bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 64)), nil))
+	img, format, _ = Decode(buf.Bytes())
+	thumbnails, err = Generate(img, format, []int{32})
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", thumbnails[0].ContentType)
+
+	_, _, err = Decode([]byte("not an image"))
+	assert.Error(t, err)
+}
+
+func TestDecodeRejectsHugeImages(t *testing.T) {
+	// A tiny PNG can declare huge dimensions; only the header is read.
+	data := encodePNG(t, image.NewGray(image.Rect(0, 0, 8000, 8000)))
+	_, _, err := Decode(data)
+	assert.Equal(t, ErrImageTooLarge, err)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/mail"
+)
+
+var (
+	ErrEmailNotVerified = errors.New("email not verified")
+	ErrAccountDisabled  = errors.New("account disabled")
+)
+
+// UserSettings holds the tunables of the account flows.
+type UserSettings struct {
+	AppBaseURL               string
+	EmailVerificationTTL     time.Duration
+	PasswordResetTTL         time.Duration
+	RequireEmailVerification bool
+	MFAIssuer                string
+	MFAChallengeTTL          time.Duration
+}
+
+// VerifyEmail godoc
+// @Summary 		 Verify email
+// @Description 	 Marks the email of a user as verified using the token sent by email
+// @Tags 			 users
+// @Accept 		 	 json
+// @Param 			 request  body 	   dto.VerifyEmailInput  true  "verification request"
+// @Success		 	 204
+// @Failure		 	 400      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/verify [post]
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var input dto.VerifyEmailInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	user, err := h.consumeToken(r.Context(), entity.TokenPurposeEmailVerification, input.Token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, database.ErrInvalidToken)
+		return
+	}
+	user.VerifyEmail()
+	if err := h.UserDB.Update(user); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to verify email", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification godoc
+// @Summary 		 Resend verification email
+// @Description 	 Sends a new verification email when the account exists and is not verified yet
+// @Tags 			 users
+// @Accept 		 	 json
+// @Param 			 request  body 	   dto.EmailInput  true  "resend request"
+// @Success		 	 202
+// @Failure		 	 400      {object} Error
+// @Router 		 	 /users/verify/resend [post]
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var input dto.EmailInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if user, err := h.UserDB.FindByEmail(input.Email); err == nil && !user.IsEmailVerified() {
+		h.sendVerificationEmail(r.Context(), user)
+	}
+	// Always accepted so the endpoint cannot be used to enumerate accounts.
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ForgotPassword godoc
+// @Summary 		 Request password reset
+// @Description 	 Sends a password reset link when the account exists
+// @Tags 			 users
+// @Accept 		 	 json
+// @Param 			 request  body 	   dto.EmailInput  true  "forgot password request"
+// @Success		 	 202
+// @Failure		 	 400      {object} Error
+// @Router 		 	 /users/password/forgot [post]
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.EmailInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if user, err := h.UserDB.FindByEmail(input.Email); err == nil {
+		h.sendPasswordResetEmail(r.Context(), user)
+	}
+	// Always accepted so the endpoint cannot be used to enumerate accounts.
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword godoc
+// @Summary 		 Reset password
+// @Description 	 Sets a new password using the token sent by email
+// @Tags 			 users
+// @Accept 		 	 json
+// @Param 			 request  body 	   dto.ResetPasswordInput  true  "reset password request"
+// @Success		 	 204
+// @Failure		 	 400      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/password/reset [post]
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.ResetPasswordInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if err := h.PasswordPolicy.Validate(input.Password); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	user, err := h.consumeToken(r.Context(), entity.TokenPurposePasswordReset, input.Token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, database.ErrInvalidToken)
+		return
+	}
+	if err := user.ChangePassword(input.Password); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// Receiving the reset link proves ownership of the mailbox.
+	user.VerifyEmail()
+	if err := h.UserDB.Update(user); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to reset password", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.UserTokenDB.Revoke(user.ID.String(), entity.TokenPurposePasswordReset); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to revoke password reset tokens", "user_id", user.ID.String(), "error", err)
+	}
+	if err := h.Lockout.Reset(r.Context(), loginAccount(user.Email)); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to reset login lockout", "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// consumeToken verifies the signature of a token, marks it as used and
+// returns the user it was issued to.
+func (h *UserHandler) consumeToken(ctx context.Context, purpose, signed string) (*entity.User, error) {
+	id, err := h.Signer.Verify(purpose, signed)
+	if err != nil {
+		return nil, err
+	}
+	token, err := h.UserTokenDB.Consume(id, purpose)
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.UserDB.FindByID(token.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+	// A link sent to a previous address proves nothing about the current one.
+	if token.Email != "" && token.Email != user.Email {
+		return nil, database.ErrInvalidToken
+	}
+	return user, nil
+}
+
+func (h *UserHandler) sendVerificationEmail(ctx context.Context, user *entity.User) {
+	link, err := h.issueToken(user, entity.TokenPurposeEmailVerification, h.Settings.EmailVerificationTTL, "/verify-email")
+	if err != nil {
+		h.Logger.ErrorContext(ctx, "failed to issue email verification token", "user_id", user.ID.String(), "error", err)
+		return
+	}
+	h.send(ctx, user, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, link, h.Settings.EmailVerificationTTL),
+	})
+}
+
+func (h *UserHandler) sendPasswordResetEmail(ctx context.Context, user *entity.User) {
+	link, err := h.issueToken(user, entity.TokenPurposePasswordReset, h.Settings.PasswordResetTTL, "/reset-password")
+	if err != nil {
+		h.Logger.ErrorContext(ctx, "failed to issue password reset token", "user_id", user.ID.String(), "error", err)
+		return
+	}
+	h.send(ctx, user, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nReset your password by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not ask for it, ignore this email.\n",
+			user.Name, link, h.Settings.PasswordResetTTL),
+	})
+}
+
+// issueToken stores a new token for the current email of user and returns
+// the link to path carrying it.
+func (h *UserHandler) issueToken(user *entity.User, purpose string, ttl time.Duration, path string) (string, error) {
+	token := entity.NewUserToken(user.ID, purpose, ttl)
+	token.Email = user.Email
+	if err := h.UserTokenDB.Create(token); err != nil {
+		return "", err
+	}
+	signed := h.Signer.Sign(purpose, token.ID.String())
+	return h.Settings.AppBaseURL + path + "?token=" + url.QueryEscape(signed), nil
+}
+
+func (h *UserHandler) send(ctx context.Context, user *entity.User, msg mail.Message) {
+	if err := h.Mailer.Send(ctx, msg); err != nil {
+		h.Logger.ErrorContext(ctx, "failed to send email", "user_id", user.ID.String(), "subject", msg.Subject, "error", err)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/mail"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	"github.com/ivandersr/products-api-go/pkg/signedtoken"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// outbox records the messages sent instead of delivering them.
+type outbox struct {
+	sent []mail.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mail.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// token returns the token of the link in the last message sent to address.
+func (o *outbox) token(t *testing.T, address string) string {
+	for i := len(o.sent) - 1; i >= 0; i-- {
+		if o.sent[i].To != address {
+			continue
+		}
+		for _, field := range strings.Fields(o.sent[i].Body) {
+			if link, err := url.Parse(field); err == nil && link.Query().Has("token") {
+				return link.Query().Get("token")
+			}
+		}
+	}
+	t.Fatalf("no link sent to %s", address)
+	return ""
+}
+
+func newAccountHandlerTest(t *testing.T) (*UserHandler, *outbox, *entity.User) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.UserToken{})
+	mailer := &outbox{}
+	handler := NewUserHandler(database.NewUserDB(db), database.NewUserTokenDB(db), nil, nil, nil,
+		signedtoken.NewSigner("0123456789abcdef0123456789abcdef"), mailer, nil, nil,
+		UserSettings{AppBaseURL: "https://app.example.com", EmailVerificationTTL: time.Hour, PasswordResetTTL: time.Hour},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	user, _ := entity.NewUser("John", "old@example.com", "Sup3r-secret!pass")
+	assert.Nil(t, handler.UserDB.Create(user))
+	return handler, mailer, user
+}
+
+func verifyEmail(handler *UserHandler, token string) int {
+	req := httptest.NewRequest(http.MethodPost, "/users/verify", strings.NewReader(`{"token":"`+token+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.VerifyEmail(rec, req)
+	return rec.Code
+}
+
+func TestChangingEmailRevokesVerificationLinks(t *testing.T) {
+	handler, mailer, user := newAccountHandlerTest(t)
+	handler.sendVerificationEmail(context.Background(), user)
+	oldLink := mailer.token(t, "old@example.com")
+
+	req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"email":"new@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(middlewares.WithUser(req.Context(), user))
+	rec := httptest.NewRecorder()
+	handler.UpdateMe(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, http.StatusBadRequest, verifyEmail(handler, oldLink))
+	found, _ := handler.UserDB.FindByID(user.ID.String())
+	assert.False(t, found.IsEmailVerified())
+
+	assert.Equal(t, http.StatusNoContent, verifyEmail(handler, mailer.token(t, "new@example.com")))
+	found, _ = handler.UserDB.FindByID(user.ID.String())
+	assert.True(t, found.IsEmailVerified())
+	assert.Equal(t, "new@example.com", found.Email)
+}
+
+func TestVerificationLinksOnlyVerifyTheirAddress(t *testing.T) {
+	handler, mailer, user := newAccountHandlerTest(t)
+	handler.sendVerificationEmail(context.Background(), user)
+
+	// Even if the link outlived the change of address, it is refused.
+	assert.Nil(t, user.ChangeEmail("new@example.com"))
+	assert.Nil(t, handler.UserDB.Update(user))
+	assert.Equal(t, http.StatusBadRequest, verifyEmail(handler, mailer.token(t, "old@example.com")))
+	found, _ := handler.UserDB.FindByID(user.ID.String())
+	assert.False(t, found.IsEmailVerified())
+}
@@ -0,0 +1,628 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+// impersonationExpiresIn bounds how long an impersonation token is valid.
+const impersonationExpiresIn = 15 * time.Minute
+
+var (
+	ErrCannotTargetSelf        = errors.New("admins cannot perform this action on themselves")
+	ErrCannotImpersonateAdmin  = errors.New("admins cannot be impersonated")
+	ErrCannotImpersonateBanned = errors.New("disabled users cannot be impersonated")
+	ErrOwnerNotFound           = errors.New("owner not found")
+)
+
+type AdminHandler struct {
+	Users         *UserHandler
+	AuditLogDB    database.AuditLogInterface
+	OAuthClientDB database.OAuthClientInterface
+	TenantDB      database.TenantInterface
+	Logger        *slog.Logger
+}
+
+func NewAdminHandler(
+	users *UserHandler,
+	auditLogDB database.AuditLogInterface,
+	oauthClientDB database.OAuthClientInterface,
+	tenantDB database.TenantInterface,
+	logger *slog.Logger,
+) *AdminHandler {
+	return &AdminHandler{
+		Users:         users,
+		AuditLogDB:    auditLogDB,
+		OAuthClientDB: oauthClientDB,
+		TenantDB:      tenantDB,
+		Logger:        logger,
+	}
+}
+
+// ListUsers godoc
+// @Summary 		 List users
+// @Description 	 Returns users matching the filters with optional pagination
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 q	  	   query   	string   	   false      "name or email search"
+// @Param			 role	   query   	string   	   false      "role"
+// @Param			 disabled  query   	bool   	       false      "disabled users only (true) or enabled users only (false)"
+// @Param			 page	   query   	string   	   false      "page number"
+// @Param			 limit	   query   	string   	   false      "items per page"
+// @Success		 	 200 	   {object} database.PaginatedUsersResponse
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500       {object} Error
+// @Router 		 	 /admin/users [get]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	filter := database.UserFilter{
+		Query: r.URL.Query().Get("q"),
+		Role:  r.URL.Query().Get("role"),
+	}
+	if disabled, err := strconv.ParseBool(r.URL.Query().Get("disabled")); err == nil {
+		filter.Disabled = &disabled
+	}
+	users, err := h.Users.UserDB.FindAll(filter, queryInt(r, "page"), queryInt(r, "limit"))
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list users", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// GetUser godoc
+// @Summary 		 Find a user
+// @Description 	 Returns a user by its ID
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"user ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.User
+// @Failure			 400
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Router 		 	 /admin/users/{id} [get]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// DisableUser godoc
+// @Summary 		 Disable a user
+// @Description 	 Blocks a user from logging in and revokes its tokens
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"user ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.User
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/users/{id}/disable [post]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	user.Disable()
+	h.save(w, r, user, entity.AuditUserDisabled, "")
+}
+
+// EnableUser godoc
+// @Summary 		 Enable a user
+// @Description 	 Allows a disabled user to log in again
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"user ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.User
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/users/{id}/enable [post]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	user.Enable()
+	h.save(w, r, user, entity.AuditUserEnabled, "")
+}
+
+// ChangeUserRole godoc
+// @Summary 		 Change the role of a user
+// @Description 	 Changes the role of a user and revokes its tokens
+// @Tags 			 admin
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	        true		"user ID"   Format(uuid)
+// @Param 			 request  body 	    dto.ChangeRoleInput	true 		"role request"
+// @Success		 	 200 	  {object}  entity.User
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/users/{id}/role [put]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ChangeUserRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	var input dto.ChangeRoleInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	previous := user.Role
+	if err := user.ChangeRole(input.Role); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	user.InvalidateSessions()
+	h.save(w, r, user, entity.AuditUserRoleChanged, fmt.Sprintf("%s -> %s", previous, user.Role))
+}
+
+// ForcePasswordReset godoc
+// @Summary 		 Force a password reset
+// @Description 	 Discards the password of a user, revokes its tokens and emails a reset link
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"user ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.User
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/users/{id}/password-reset [post]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	// Replace the password with a random one nobody knows, the user has to
+	// go through the reset link to log in again.
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := user.ChangePassword(hex.EncodeToString(secret)); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.save(w, r, user, entity.AuditUserPasswordReset, "") {
+		return
+	}
+	h.Users.sendPasswordResetEmail(r.Context(), user)
+}
+
+// ImpersonateUser godoc
+// @Summary 		 Impersonate a user
+// @Description 	 Issues a short lived token acting as the user. Every change made with it is audited
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"user ID"   Format(uuid)
+// @Success		 	 200 	  {object}  dto.GetJWTOutput
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/users/{id}/impersonate [post]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	if user.IsAdmin() {
+		writeError(w, http.StatusBadRequest, ErrCannotImpersonateAdmin)
+		return
+	}
+	if user.IsDisabled() {
+		writeError(w, http.StatusBadRequest, ErrCannotImpersonateBanned)
+		return
+	}
+	admin := middlewares.UserFromContext(r.Context())
+	if !h.audit(w, r, entity.AuditUserImpersonated, user.ID.String(), "") {
+		return
+	}
+	tokenString, err := h.Users.encodeToken(r, user, map[string]interface{}{
+		"act": map[string]interface{}{"sub": admin.ID.String(), "ver": admin.TokenVersion},
+	}, impersonationExpiresIn)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.GetJWTOutput{AccessToken: tokenString})
+}
+
+// ResetUserMFA godoc
+// @Summary 		 Reset two-factor authentication
+// @Description 	 Removes the authenticator and recovery codes of a user who lost them, and revokes its tokens
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"user ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.User
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/users/{id}/mfa/reset [post]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ResetUserMFA(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findOtherUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.IsMFAEnabled() {
+		writeError(w, http.StatusBadRequest, entity.ErrMFANotEnabled)
+		return
+	}
+	user.DisableMFA()
+	if err := h.Users.RecoveryCodeDB.Replace(user.ID.String(), nil); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete recovery codes", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.save(w, r, user, entity.AuditUserMFAReset, "")
+}
+
+// ListMFAPolicies godoc
+// @Summary 		 List two-factor policies
+// @Description 	 Returns the roles with a two-factor authentication policy
+// @Tags 			 admin
+// @Produce		 	 json
+// @Success		 	 200 	  {array}   entity.MFAPolicy
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/mfa-policies [get]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ListMFAPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.Users.MFAPolicyDB.FindAll()
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list mfa policies", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if policies == nil {
+		policies = []entity.MFAPolicy{}
+	}
+	writeJSON(w, http.StatusOK, policies)
+}
+
+// SetMFAPolicy godoc
+// @Summary 		 Set the two-factor policy of a role
+// @Description 	 Requires, or stops requiring, two-factor authentication for every user of a role. Users without it are then limited to enrolling, and their API keys are refused until they enroll
+// @Tags 			 admin
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 role	  path   	string  	             true		"role"
+// @Param 			 request  body 	    dto.SetMFAPolicyInput	 true 		"policy request"
+// @Success		 	 200 	  {object}  entity.MFAPolicy
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/mfa-policies/{role} [put]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) SetMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	var input dto.SetMFAPolicyInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	policy, err := entity.NewMFAPolicy(chi.URLParam(r, "role"), input.Required)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.Users.MFAPolicyDB.Save(policy); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to save mfa policy", "role", policy.Role, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.audit(w, r, entity.AuditMFAPolicyChanged, policy.Role, fmt.Sprintf("required=%t", policy.Required)) {
+		return
+	}
+	writeJSON(w, http.StatusOK, policy)
+}
+
+// ListOAuthClients godoc
+// @Summary 		 List OAuth clients
+// @Description 	 Returns the applications registered with the OAuth2 authorization server
+// @Tags 			 admin
+// @Produce		 	 json
+// @Success		 	 200 	  {array}   entity.OAuthClient
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/oauth-clients [get]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ListOAuthClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.OAuthClientDB.FindAll()
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list oauth clients", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if clients == nil {
+		clients = []entity.OAuthClient{}
+	}
+	writeJSON(w, http.StatusOK, clients)
+}
+
+// CreateOAuthClient godoc
+// @Summary 		 Register an OAuth client
+// @Description 	 Registers an application with the OAuth2 authorization server. The secret of confidential clients is only returned once. Clients using the client_credentials grant act as their owner
+// @Tags 			 admin
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.CreateOAuthClientInput	 true 		"client request"
+// @Success		 	 201 	  {object}  dto.CreateOAuthClientOutput
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/oauth-clients [post]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateOAuthClientInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	var ownerID *entityPkg.ID
+	if input.OwnerID != nil {
+		id, err := entityPkg.ParseID(*input.OwnerID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, entity.ErrInvalidID)
+			return
+		}
+		if _, err := h.Users.UserDB.FindByID(id.String()); err != nil {
+			writeError(w, http.StatusBadRequest, ErrOwnerNotFound)
+			return
+		}
+		ownerID = &id
+	}
+	client, secret, err := entity.NewOAuthClient(input.Name, input.Confidential, input.RedirectURIs, input.GrantTypes, input.Scopes, ownerID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.OAuthClientDB.Create(client); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create oauth client", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.audit(w, r, entity.AuditOAuthClientCreated, client.ID.String(), client.Name) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, dto.CreateOAuthClientOutput{OAuthClient: *client, ClientSecret: secret})
+}
+
+// DeleteOAuthClient godoc
+// @Summary 		 Delete an OAuth client
+// @Description 	 Removes an application along with the consents given to it and its outstanding codes and refresh tokens
+// @Tags 			 admin
+// @Param			 id	      path   	string  	true		"client ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 400
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/oauth-clients/{id} [delete]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := entityPkg.ParseID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := h.OAuthClientDB.FindByID(id); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err := h.OAuthClientDB.Delete(id); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete oauth client", "client_id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.audit(w, r, entity.AuditOAuthClientDeleted, id, "") {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTenants godoc
+// @Summary 		 List tenants
+// @Description 	 Returns every tenant of the deployment
+// @Tags 			 admin
+// @Produce		 	 json
+// @Success		 	 200 	  {array}   entity.Tenant
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/tenants [get]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.TenantDB.FindAll()
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list tenants", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if tenants == nil {
+		tenants = []entity.Tenant{}
+	}
+	writeJSON(w, http.StatusOK, tenants)
+}
+
+// CreateTenant godoc
+// @Summary 		 Create a tenant
+// @Description 	 Creates a tenant, such as a store brand, with a first owner who then manages its members
+// @Tags 			 admin
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.CreateTenantInput	 true 		"tenant request"
+// @Success		 	 201 	  {object}  entity.Tenant
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 409      {object}  Error
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /admin/tenants [post]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateTenantInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if _, err := entityPkg.ParseID(input.OwnerID); err != nil {
+		writeError(w, http.StatusBadRequest, entity.ErrInvalidID)
+		return
+	}
+	owner, err := h.Users.UserDB.FindByID(input.OwnerID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrOwnerNotFound)
+		return
+	}
+	tenant, err := entity.NewTenant(input.Name, input.Slug)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	membership, err := entity.NewMembership(tenant.ID, owner.ID, entity.TenantRoleOwner)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.TenantDB.Create(tenant, membership)
+	if errors.Is(err, database.ErrSlugAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create tenant", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !h.audit(w, r, entity.AuditTenantCreated, tenant.ID.String(), fmt.Sprintf("slug=%s owner=%s", tenant.Slug, owner.ID.String())) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, tenant)
+}
+
+// ListAuditLogs godoc
+// @Summary 		 List audit logs
+// @Description 	 Returns the audit trail of admin actions, newest first
+// @Tags 			 admin
+// @Produce		 	 json
+// @Param			 actor_id   query   string   	   false      "admin ID"
+// @Param			 target_id  query   string   	   false      "target ID"
+// @Param			 action	    query   string   	   false      "action"
+// @Param			 page	    query   string   	   false      "page number"
+// @Param			 limit	    query   string   	   false      "items per page"
+// @Success		 	 200 	    {object} database.PaginatedAuditLogsResponse
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500        {object} Error
+// @Router 		 	 /admin/audit-logs [get]
+// @Security 		 ApiKeyAuth
+func (h *AdminHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	filter := database.AuditLogFilter{
+		ActorID:  r.URL.Query().Get("actor_id"),
+		TargetID: r.URL.Query().Get("target_id"),
+		Action:   r.URL.Query().Get("action"),
+	}
+	logs, err := h.AuditLogDB.FindAll(filter, queryInt(r, "page"), queryInt(r, "limit"))
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list audit logs", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, logs)
+}
+
+func (h *AdminHandler) findUser(w http.ResponseWriter, r *http.Request) (*entity.User, bool) {
+	id := chi.URLParam(r, "id")
+	if _, err := entityPkg.ParseID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	user, err := h.Users.UserDB.FindByID(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// findOtherUser is findUser refusing to target the admin performing the
+// request, so admins cannot lock themselves out.
+func (h *AdminHandler) findOtherUser(w http.ResponseWriter, r *http.Request) (*entity.User, bool) {
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if admin := middlewares.UserFromContext(r.Context()); admin != nil && admin.ID == user.ID {
+		writeError(w, http.StatusBadRequest, ErrCannotTargetSelf)
+		return nil, false
+	}
+	return user, true
+}
+
+// save persists user, records action in the audit log and writes the user.
+func (h *AdminHandler) save(w http.ResponseWriter, r *http.Request, user *entity.User, action, details string) bool {
+	if err := h.Users.UserDB.Update(user); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to update user", "user_id", user.ID.String(), "action", action, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if !h.audit(w, r, action, user.ID.String(), details) {
+		return false
+	}
+	writeJSON(w, http.StatusOK, user)
+	return true
+}
+
+func (h *AdminHandler) audit(w http.ResponseWriter, r *http.Request, action, targetID, details string) bool {
+	admin := middlewares.UserFromContext(r.Context())
+	log := entity.NewAuditLog(admin.ID, action, targetID, details, middleware.GetReqID(r.Context()))
+	if err := h.AuditLogDB.Create(log); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to write audit log", "action", action, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	h.Logger.InfoContext(r.Context(), "admin action", "action", action, "actor_id", admin.ID.String(), "target_id", targetID)
+	return true
+}
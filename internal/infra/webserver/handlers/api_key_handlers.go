@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	APIKeyDB database.APIKeyInterface
+	Logger   *slog.Logger
+}
+
+func NewAPIKeyHandler(db database.APIKeyInterface, logger *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		APIKeyDB: db,
+		Logger:   logger,
+	}
+}
+
+// ListAPIKeys godoc
+// @Summary 		 List API keys
+// @Description 	 Lists the API keys of the authenticated user. Secrets are never returned
+// @Tags 			 api-keys
+// @Produce		 	 json
+// @Success		 	 200      {array}  entity.APIKey
+// @Failure			 401
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/api-keys [get]
+// @Security 		 ApiKeyAuth
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	keys, err := h.APIKeyDB.FindAllByUser(user.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list api keys", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if keys == nil {
+		keys = []entity.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// CreateAPIKey godoc
+// @Summary 		 Create API key
+// @Description 	 Creates an API key for the authenticated user, bound to the tenant of the token. The key is only shown in this response; send it in the X-API-Key header
+// @Tags 			 api-keys
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.CreateAPIKeyInput  true  "api key request"
+// @Success		 	 201      {object} dto.CreateAPIKeyOutput
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/api-keys [post]
+// @Security 		 ApiKeyAuth
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	var input dto.CreateAPIKeyInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	key, plain, err := entity.NewAPIKey(user.ID, input.Name, input.Scopes, input.ExpiresAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if tenantID, err := entityPkg.ParseID(middlewares.TenantClaimFromRequest(r)); err == nil {
+		key.TenantID = &tenantID
+	}
+	if err := h.APIKeyDB.Create(key); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create api key", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "api key created", "user_id", user.ID.String(), "api_key_id", key.ID.String())
+	writeJSON(w, http.StatusCreated, dto.CreateAPIKeyOutput{APIKey: *key, Key: plain})
+}
+
+// DeleteAPIKey godoc
+// @Summary 		 Revoke API key
+// @Description 	 Deletes an API key of the authenticated user
+// @Tags 			 api-keys
+// @Param			 id	  	  path     string  true  "api key ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 400
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/api-keys/{id} [delete]
+// @Security 		 ApiKeyAuth
+func (h *APIKeyHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+	if _, err := entityPkg.ParseID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err := h.APIKeyDB.Delete(id, user.ID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete api key", "api_key_id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "api key revoked", "user_id", user.ID.String(), "api_key_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"gorm.io/gorm"
+)
+
+// Page sizes of the catalog, which always paginates since it is public.
+const (
+	catalogDefaultLimit = 20
+	catalogMaxLimit     = 100
+)
+
+// CatalogSettings holds the cache lifetime of catalog responses and the
+// PublicURL of published media, as in MediaSettings.
+type CatalogSettings struct {
+	CacheMaxAge time.Duration
+	PublicURL   string
+}
+
+// CatalogHandler serves the published products of a tenant to anyone.
+type CatalogHandler struct {
+	TenantDB  database.TenantInterface
+	ProductDB database.ProductInterface
+	MediaDB   database.MediaInterface
+	Settings  CatalogSettings
+	Logger    *slog.Logger
+}
+
+func NewCatalogHandler(tenantDB database.TenantInterface, productDB database.ProductInterface, mediaDB database.MediaInterface, settings CatalogSettings, logger *slog.Logger) *CatalogHandler {
+	return &CatalogHandler{
+		TenantDB:  tenantDB,
+		ProductDB: productDB,
+		MediaDB:   mediaDB,
+		Settings:  settings,
+		Logger:    logger,
+	}
+}
+
+// ListCatalogProducts godoc
+// @Summary 		 List catalog products
+// @Description 	 Returns the published products of a tenant with the URLs of their media. Public and cacheable: answers 304 when If-None-Match matches the ETag
+// @Tags 			 catalog
+// @Produce		 	 json
+// @Param			 tenant	  path   	string   	   true       "tenant slug"
+// @Param			 page	  query   	int   	       false      "page number, from 1"
+// @Param			 limit	  query   	int   	       false      "items per page, up to 100"
+// @Param			 sort	  query   	string   	   false      "asc or desc by creation date"
+// @Success		 	 200 	  {object}  dto.CatalogProductsOutput
+// @Success		 	 304
+// @Failure			 404
+// @Failure			 429
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /catalog/{tenant}/products [get]
+func (h *CatalogHandler) ListCatalogProducts(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := h.tenantContext(w, r)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = catalogDefaultLimit
+	}
+	if limit > catalogMaxLimit {
+		limit = catalogMaxLimit
+	}
+	filter := database.ProductFilter{Status: entity.ProductStatusPublished}
+	products, err := h.ProductDB.FindAll(ctx, filter, page, limit, r.URL.Query().Get("sort"))
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list catalog products", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	data, ok := h.catalogProducts(ctx, w, r, products.Data)
+	if !ok {
+		return
+	}
+	writeCachedJSON(w, r, dto.CatalogProductsOutput{Data: data, Page: page, Limit: limit}, h.Settings.CacheMaxAge)
+}
+
+// GetCatalogProduct godoc
+// @Summary 		 Find a catalog product
+// @Description 	 Returns a published product of a tenant with the URLs of its media. Public and cacheable: answers 304 when If-None-Match matches the ETag
+// @Tags 			 catalog
+// @Produce		 	 json
+// @Param			 tenant	  path   	string   	   true       "tenant slug"
+// @Param			 id	  	  path   	string  	   true		  "product ID"   Format(uuid)
+// @Success		 	 200 	  {object}  dto.CatalogProductOutput
+// @Success		 	 304
+// @Failure			 404
+// @Failure			 429
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /catalog/{tenant}/products/{id} [get]
+func (h *CatalogHandler) GetCatalogProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := h.tenantContext(w, r)
+	if !ok {
+		return
+	}
+	product, err := h.ProductDB.FindByID(ctx, chi.URLParam(r, "id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !product.IsPublished()) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find catalog product", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	data, ok := h.catalogProducts(ctx, w, r, []entity.Product{*product})
+	if !ok {
+		return
+	}
+	writeCachedJSON(w, r, data[0], h.Settings.CacheMaxAge)
+}
+
+// tenantContext scopes the request to the tenant of the URL, answering 404
+// when there is none.
+func (h *CatalogHandler) tenantContext(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	tenant, err := h.TenantDB.FindBySlug(chi.URLParam(r, "tenant"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find catalog tenant", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return database.WithTenant(r.Context(), tenant.ID), true
+}
+
+// catalogProducts projects published products along with their media.
+func (h *CatalogHandler) catalogProducts(ctx context.Context, w http.ResponseWriter, r *http.Request, products []entity.Product) ([]dto.CatalogProductOutput, bool) {
+	output := []dto.CatalogProductOutput{}
+	if len(products) == 0 {
+		return output, true
+	}
+	ids := make([]string, len(products))
+	for i, product := range products {
+		ids[i] = product.ID.String()
+	}
+	media, err := h.MediaDB.FindAllByProducts(ctx, ids)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to load catalog media", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	byProduct := map[string][]entity.Media{}
+	for _, item := range media {
+		byProduct[item.ProductID.String()] = append(byProduct[item.ProductID.String()], item)
+	}
+	for i := range products {
+		product := &products[i]
+		productMedia := byProduct[product.ID.String()]
+		setMediaURLs(h.Settings.PublicURL, product, productMedia)
+		output = append(output, catalogProduct(product, productMedia))
+	}
+	return output, true
+}
+
+func catalogProduct(product *entity.Product, media []entity.Media) dto.CatalogProductOutput {
+	output := dto.CatalogProductOutput{
+		ID:         product.ID.String(),
+		Name:       product.Name,
+		Price:      product.Price,
+		SKU:        product.SKU,
+		Barcode:    product.Barcode,
+		Attributes: product.Attributes,
+		Tags:       product.Tags,
+	}
+	for _, item := range media {
+		output.Media = append(output.Media, dto.CatalogMediaOutput{
+			Kind:        item.Kind,
+			Filename:    item.Filename,
+			ContentType: item.ContentType,
+			Width:       item.Width,
+			Height:      item.Height,
+			URL:         item.URL,
+			Thumbnails:  item.Thumbnails,
+		})
+	}
+	return output
+}
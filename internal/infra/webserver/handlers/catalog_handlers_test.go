@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCatalogProductsListPublishedMedia(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.Tenant{}, &entity.Membership{}, &entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Media{})
+	tenantDB := database.NewTenantDB(db)
+	handler := NewCatalogHandler(tenantDB, database.NewProductDB(db), database.NewMediaDB(db),
+		CatalogSettings{CacheMaxAge: time.Minute, PublicURL: "https://cdn.example.com/"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	router := chi.NewRouter()
+	router.Get("/catalog/{tenant}/products", handler.ListCatalogProducts)
+	router.Get("/catalog/{tenant}/products/{id}", handler.GetCatalogProduct)
+
+	tenant, _ := entity.NewTenant("Acme", "acme")
+	owner, _ := entity.NewMembership(tenant.ID, entityPkg.NewID(), entity.TenantRoleOwner)
+	assert.Nil(t, tenantDB.Create(tenant, owner))
+	ctx := database.WithTenant(context.Background(), tenant.ID)
+	product, _ := entity.NewProduct("Shirt", 20)
+	assert.Nil(t, product.Publish())
+	assert.Nil(t, handler.ProductDB.Create(ctx, product))
+	image, _ := entity.NewMedia(product, "front.png", "image/png", 1024)
+	image.Width, image.Height = 800, 600
+	image.Thumbnails = []entity.MediaThumbnail{{Size: 150, Width: 150, Height: 113}}
+	assert.Nil(t, handler.MediaDB.Create(ctx, image))
+
+	want := dto.CatalogMediaOutput{
+		Kind:        entity.MediaKindImage,
+		Filename:    "front.png",
+		ContentType: "image/png",
+		Width:       800,
+		Height:      600,
+		URL:         "https://cdn.example.com/" + image.Key,
+		Thumbnails:  []entity.MediaThumbnail{{Size: 150, Width: 150, Height: 113, URL: "https://cdn.example.com/" + image.ThumbnailKey(150)}},
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/catalog/acme/products", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var list dto.CatalogProductsOutput
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	if assert.Len(t, list.Data, 1) {
+		assert.Equal(t, []dto.CatalogMediaOutput{want}, list.Data[0].Media)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/catalog/acme/products/"+product.ID.String(), nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var found dto.CatalogProductOutput
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &found))
+	assert.Equal(t, []dto.CatalogMediaOutput{want}, found.Media)
+	assert.NotContains(t, rec.Body.String(), "/file")
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/request"
+	"gorm.io/gorm"
+)
+
+type CategoryHandler struct {
+	CategoryDB database.CategoryInterface
+	ProductDB  database.ProductInterface
+	Logger     *slog.Logger
+}
+
+func NewCategoryHandler(categoryDB database.CategoryInterface, productDB database.ProductInterface, logger *slog.Logger) *CategoryHandler {
+	return &CategoryHandler{
+		CategoryDB: categoryDB,
+		ProductDB:  productDB,
+		Logger:     logger,
+	}
+}
+
+// ListCategories godoc
+// @Summary 		 List categories
+// @Description 	 Returns the categories of the tenant of the token by name, with the attributes of their products
+// @Tags 			 categories
+// @Produce		 	 json
+// @Success		 	 200 	  {object}  dto.CategoriesOutput
+// @Failure			 401
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /categories [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.CategoryDB.FindAll(r.Context())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list categories", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.CategoriesOutput{Data: categories})
+}
+
+// CreateCategory godoc
+// @Summary 		 Create category
+// @Description 	 Creates a category in the tenant of the token. Its attributes define the name, type (string, number, integer or boolean), allowed values, unit, range and whether the products of the category require them
+// @Tags 			 categories
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.CategoryInput  true  "category request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Param 			 Prefer   header    string  false  "return=minimal to leave the category out of the response"
+// @Success		 	 201      {object}  entity.Category
+// @Header			 201      {string}  Location "path of the created category"
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 409      {object}  Error
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /categories [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var input dto.CategoryInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	category, err := entity.NewCategory(input.Name, input.Attributes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.CategoryDB.Create(r.Context(), category)
+	if errors.Is(err, database.ErrCategoryAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create category", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeCreated(w, r, "/categories/"+category.ID.String(), category)
+}
+
+// GetCategory godoc
+// @Summary 		 Find a category
+// @Description 	 Returns a category of the tenant of the token
+// @Tags 			 categories
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"category ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.Category
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /categories/{id} [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.pathCategory(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, category)
+}
+
+// GetCategorySchema godoc
+// @Summary 		 Get the attribute schema of a category
+// @Description 	 Returns the JSON Schema (draft 2020-12) the attributes of the products of a category are validated against. Units are under the x-unit keyword
+// @Tags 			 categories
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"category ID"   Format(uuid)
+// @Success		 	 200 	  {object}  map[string]interface{}
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /categories/{id}/schema [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CategoryHandler) GetCategorySchema(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.pathCategory(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, category.JSONSchema())
+}
+
+// UpdateCategory godoc
+// @Summary 		 Update category
+// @Description 	 Renames a category or changes its attributes. Attribute changes the products of the category no longer satisfy are rejected
+// @Tags 			 categories
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"category ID"   Format(uuid)
+// @Param 			 request  body 	    dto.CategoryInput  true  "category request"
+// @Param 			 Prefer   header    string  false  "return=minimal to answer 204 without the category"
+// @Success		 	 200      {object}  entity.Category
+// @Success		 	 204
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 404
+// @Failure			 409      {object}  Error
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /categories/{id} [put]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.pathCategory(w, r)
+	if !ok {
+		return
+	}
+	var input dto.CategoryInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	category.Name, category.Attributes = input.Name, input.Attributes
+	if err := category.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	products, err := h.ProductDB.FindAll(r.Context(), database.ProductFilter{CategoryID: category.ID.String()}, 0, 0, "")
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list category products", "category_id", category.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	invalid := 0
+	for _, product := range products.Data {
+		if category.ValidateAttributes(product.Attributes) != nil {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		writeError(w, http.StatusConflict, errors.New(strconv.Itoa(invalid)+" products of the category do not match the new attributes"))
+		return
+	}
+	err = h.CategoryDB.Update(r.Context(), category)
+	if errors.Is(err, database.ErrCategoryAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to update category", "category_id", category.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeUpdated(w, r, category)
+}
+
+// DeleteCategory godoc
+// @Summary 		 Delete category
+// @Description 	 Deletes a category no product belongs to
+// @Tags 			 categories
+// @Param			 id	  	  path   	string  	true		"category ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 401
+// @Failure			 404
+// @Failure			 409      {object}  Error
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /categories/{id} [delete]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	err := h.CategoryDB.Delete(r.Context(), chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, database.ErrCategoryInUse):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		h.Logger.ErrorContext(r.Context(), "failed to delete category", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *CategoryHandler) pathCategory(w http.ResponseWriter, r *http.Request) (*entity.Category, bool) {
+	category, err := h.CategoryDB.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find category", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return category, true
+}
+
+// checkAttributes validates the attributes of product against the schema of
+// its category, answering 422 with every invalid attribute otherwise.
+// Attributes set to null are dropped.
+func (h *ProductHandler) checkAttributes(w http.ResponseWriter, r *http.Request, product *entity.Product) bool {
+	for name, value := range product.Attributes {
+		if value == nil {
+			delete(product.Attributes, name)
+		}
+	}
+	if product.CategoryID == nil {
+		return true
+	}
+	category, err := h.CategoryDB.FindByID(r.Context(), product.CategoryID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeJSON(w, http.StatusUnprocessableEntity, ValidationError{
+			Message: "validation failed",
+			Errors:  []request.FieldError{{Field: "category_id", Message: "category_id does not exist"}},
+		})
+		return false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find category", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	var attrErrs entity.AttributeErrors
+	if err := category.ValidateAttributes(product.Attributes); errors.As(err, &attrErrs) {
+		fieldErrs := make([]request.FieldError, len(attrErrs))
+		for i, attrErr := range attrErrs {
+			field := "attributes." + attrErr.Attribute
+			fieldErrs[i] = request.FieldError{Field: field, Message: field + " " + attrErr.Message}
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, ValidationError{Message: "validation failed", Errors: fieldErrs})
+		return false
+	}
+	return true
+}
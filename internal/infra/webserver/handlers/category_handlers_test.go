@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newCategoryHandlerTest(t *testing.T) (*CategoryHandler, *chi.Mux, context.Context) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.Category{}, &entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{})
+	handler := NewCategoryHandler(database.NewCategoryDB(db), database.NewProductDB(db), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	router := chi.NewRouter()
+	router.Post("/categories", handler.CreateCategory)
+	router.Get("/categories/{id}", handler.GetCategory)
+	router.Put("/categories/{id}", handler.UpdateCategory)
+	return handler, router, database.WithTenant(context.Background(), entityPkg.NewID())
+}
+
+func categoryRequest(ctx context.Context, method, target, body, prefer string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+	return req
+}
+
+func TestCreateCategoryAnswersLocation(t *testing.T) {
+	_, router, ctx := newCategoryHandlerTest(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, categoryRequest(ctx, http.MethodPost, "/categories", `{"name":"Shirts"}`, ""))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var category entity.Category
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &category))
+	assert.Equal(t, "Shirts", category.Name)
+	assert.Equal(t, "/categories/"+category.ID.String(), rec.Header().Get("Location"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, categoryRequest(ctx, http.MethodPost, "/categories", `{"name":"Shoes"}`, "return=minimal"))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, "return=minimal", rec.Header().Get("Preference-Applied"))
+	location := rec.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "/categories/"))
+
+	// The Location of a minimal response finds the created category.
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, categoryRequest(ctx, http.MethodGet, location, "", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &category))
+	assert.Equal(t, "Shoes", category.Name)
+}
+
+func TestUpdateCategoryPrefersMinimal(t *testing.T) {
+	handler, router, ctx := newCategoryHandlerTest(t)
+	category, _ := entity.NewCategory("Shirts", nil)
+	assert.Nil(t, handler.CategoryDB.Create(ctx, category))
+	target := "/categories/" + category.ID.String()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, categoryRequest(ctx, http.MethodPut, target, `{"name":"T-shirts"}`, ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &category))
+	assert.Equal(t, "T-shirts", category.Name)
+	assert.Empty(t, rec.Header().Get("Location"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, categoryRequest(ctx, http.MethodPut, target, `{"name":"Tees"}`, "return=minimal"))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	found, _ := handler.CategoryDB.FindByID(ctx, category.ID.String())
+	assert.Equal(t, "Tees", found.Name)
+}
+
+func TestGetCategoryAnswersServerErrors(t *testing.T) {
+	handler, router, ctx := newCategoryHandlerTest(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, categoryRequest(ctx, http.MethodGet, "/categories/"+entityPkg.NewID().String(), "", ""))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Failures other than a missing category are not reported as 404.
+	handler.CategoryDB.(*database.Category).DB.Migrator().DropTable(&entity.Category{})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, categoryRequest(ctx, http.MethodGet, "/categories/"+entityPkg.NewID().String(), "", ""))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/request"
+	"gorm.io/gorm"
+)
+
+// couponCodeAttempts bounds how many times generated codes are drawn again
+// when one is already taken.
+const couponCodeAttempts = 3
+
+// CouponHandler prices the baskets coupons are redeemed on with Pricing, so
+// min_basket and the discount never rest on a total the client sent.
+type CouponHandler struct {
+	CouponDB database.CouponInterface
+	Pricing  *PricingHandler
+	Logger   *slog.Logger
+}
+
+func NewCouponHandler(couponDB database.CouponInterface, pricing *PricingHandler, logger *slog.Logger) *CouponHandler {
+	return &CouponHandler{
+		CouponDB: couponDB,
+		Pricing:  pricing,
+		Logger:   logger,
+	}
+}
+
+// ListCoupons godoc
+// @Summary 		 List coupons
+// @Description 	 Returns the coupons of the tenant of the token, newest first. Only tenant owners manage coupons
+// @Tags 			 coupons
+// @Produce		 	 json
+// @Param			 batch	  query   	string   	   false      "batch ID of generated coupons"
+// @Param			 page	  query   	string   	   false      "page number"
+// @Param			 limit	  query   	string   	   false      "items per page"
+// @Success		 	 200 	  {object}  dto.CouponsOutput
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.CouponDB.FindAll(r.Context(), r.URL.Query().Get("batch"), queryInt(r, "page"), queryInt(r, "limit"))
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list coupons", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.CouponsOutput{Data: coupons})
+}
+
+// CreateCoupon godoc
+// @Summary 		 Create coupon
+// @Description 	 Creates a percentage_off or fixed_off coupon with the given code, matched regardless of case, or with a generated one. max_redemptions bounds its redemptions by anyone and max_per_user those by each user, unlimited when 0
+// @Tags 			 coupons
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.CouponInput  true  "coupon request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Param 			 Prefer   header    string  false  "return=minimal to leave the coupon out of the response"
+// @Success		 	 201      {object}  entity.Coupon
+// @Header			 201      {string}  Location "path of the created coupon"
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 409      {object}  Error
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	var input dto.CouponInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	var coupon *entity.Coupon
+	var err error
+	for attempt := 0; attempt < couponCodeAttempts; attempt++ {
+		coupon, err = entity.NewCoupon(input.Code, input.Type)
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to generate coupon code", "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		setCouponRules(coupon, &input.CouponRulesInput)
+		if err := coupon.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = h.CouponDB.Create(r.Context(), coupon)
+		if !errors.Is(err, database.ErrCouponAlreadyExists) || input.Code != "" {
+			break
+		}
+	}
+	if errors.Is(err, database.ErrCouponAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create coupon", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeCreated(w, r, "/coupons/"+coupon.ID.String(), coupon)
+}
+
+// GenerateCoupons godoc
+// @Summary 		 Generate coupons
+// @Description 	 Generates count coupons sharing the same rule and limits, with unique codes starting with prefix and a shared batch_id to list them by. Answers 409, like a taken code on create, when the generated codes keep colliding with existing ones
+// @Tags 			 coupons
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.CouponBatchInput  true  "coupon batch request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Success		 	 201      {object}  dto.CouponsOutput
+// @Header			 201      {string}  Location "path listing the generated coupons"
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 409      {object}  Error
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons/generate [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) GenerateCoupons(w http.ResponseWriter, r *http.Request) {
+	var input dto.CouponBatchInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	template := &entity.Coupon{Type: input.Type}
+	setCouponRules(template, &input.CouponRulesInput)
+	if err := template.ValidateRules(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var coupons []entity.Coupon
+	var err error
+	for attempt := 0; attempt < couponCodeAttempts; attempt++ {
+		coupons, err = entity.NewCouponBatch(template, input.Prefix, input.Count)
+		if errors.Is(err, entity.ErrInvalidCouponPrefix) || errors.Is(err, entity.ErrInvalidCouponCount) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err != nil {
+			break
+		}
+		if err = h.CouponDB.CreateBatch(r.Context(), coupons); !errors.Is(err, database.ErrCouponAlreadyExists) {
+			break
+		}
+	}
+	if errors.Is(err, database.ErrCouponAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to generate coupons", "count", input.Count, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", "/coupons?batch="+coupons[0].BatchID.String())
+	writeJSON(w, http.StatusCreated, dto.CouponsOutput{Data: coupons})
+}
+
+// GetCoupon godoc
+// @Summary 		 Find a coupon
+// @Description 	 Returns a coupon of the tenant of the token with how many times it was redeemed
+// @Tags 			 coupons
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"coupon ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.Coupon
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons/{id} [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	coupon, ok := h.pathCoupon(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, coupon)
+}
+
+// UpdateCoupon godoc
+// @Summary 		 Update coupon
+// @Description 	 Replaces the rule, limits and expiration of a coupon. Its code and redemptions stay, so lowering max_redemptions below them exhausts it
+// @Tags 			 coupons
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"coupon ID"   Format(uuid)
+// @Param 			 request  body 	    dto.CouponRulesInput  true  "coupon rules request"
+// @Param 			 Prefer   header    string  false  "return=minimal to answer 204 without the coupon"
+// @Success		 	 200      {object}  entity.Coupon
+// @Success		 	 204
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons/{id} [put]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	coupon, ok := h.pathCoupon(w, r)
+	if !ok {
+		return
+	}
+	var input dto.CouponRulesInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	coupon.Type = input.Type
+	setCouponRules(coupon, &input)
+	if err := coupon.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err := h.CouponDB.Update(r.Context(), coupon)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to update coupon", "coupon_id", coupon.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeUpdated(w, r, coupon)
+}
+
+// DeleteCoupon godoc
+// @Summary 		 Delete coupon
+// @Description 	 Deletes a coupon along with its redemptions, so its code can no longer be redeemed
+// @Tags 			 coupons
+// @Param			 id	  	  path   	string  	true		"coupon ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons/{id} [delete]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	err := h.CouponDB.Delete(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete coupon", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListCouponRedemptions godoc
+// @Summary 		 List coupon redemptions
+// @Description 	 Returns who redeemed a coupon, on which basket and for which discount, newest first
+// @Tags 			 coupons
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"coupon ID"   Format(uuid)
+// @Param			 page	  query   	string   	   false      "page number"
+// @Param			 limit	  query   	string   	   false      "items per page"
+// @Success		 	 200 	  {object}  dto.CouponRedemptionsOutput
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons/{id}/redemptions [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) ListCouponRedemptions(w http.ResponseWriter, r *http.Request) {
+	coupon, ok := h.pathCoupon(w, r)
+	if !ok {
+		return
+	}
+	redemptions, err := h.CouponDB.FindRedemptions(r.Context(), coupon.ID.String(), queryInt(r, "page"), queryInt(r, "limit"))
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list coupon redemptions", "coupon_id", coupon.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.CouponRedemptionsOutput{Data: redemptions})
+}
+
+// RedeemCoupon godoc
+// @Summary 		 Redeem coupon
+// @Description 	 Redeems a coupon code for the user of the token on a basket of lines, priced as by /pricing/quote at the current prices and promotions, and records the redemption. Redemptions are counted atomically, so concurrent requests never exceed max_redemptions or max_per_user. Send an Idempotency-Key so a retried checkout does not redeem twice
+// @Tags 			 coupons
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.RedeemCouponInput  true  "redemption request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Success		 	 200      {object}  dto.RedeemCouponOutput
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 409      {object}  Error
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /coupons/redeem [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *CouponHandler) RedeemCoupon(w http.ResponseWriter, r *http.Request) {
+	var input dto.RedeemCouponInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	basket, ok := h.Pricing.quote(w, r, dto.QuoteInput{Lines: input.Lines})
+	if !ok {
+		return
+	}
+	user := middlewares.UserFromContext(r.Context())
+	coupon, redemption, err := h.CouponDB.Redeem(r.Context(), input.Code, user.ID, basket.Total, time.Now())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case errors.Is(err, entity.ErrBasketBelowMinimum):
+		writeJSON(w, http.StatusUnprocessableEntity, ValidationError{
+			Message: "validation failed",
+			Errors:  []request.FieldError{{Field: "lines", Message: err.Error()}},
+		})
+		return
+	case errors.Is(err, entity.ErrCouponExpired), errors.Is(err, entity.ErrCouponExhausted), errors.Is(err, entity.ErrCouponUserLimit):
+		writeError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		h.Logger.ErrorContext(r.Context(), "failed to redeem coupon", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.RedeemCouponOutput{
+		Code:       coupon.Code,
+		Basket:     basket.Total,
+		Discount:   redemption.Discount,
+		Total:      redemption.Total(),
+		Redemption: redemption,
+	})
+}
+
+func setCouponRules(coupon *entity.Coupon, input *dto.CouponRulesInput) {
+	coupon.Percent, coupon.Amount, coupon.MinBasket = input.Percent, input.Amount, input.MinBasket
+	coupon.MaxRedemptions, coupon.MaxPerUser = input.MaxRedemptions, input.MaxPerUser
+	coupon.SetExpiration(input.ExpiresAt)
+}
+
+// pathCoupon finds the coupon of the URL, answering 404 when the tenant of
+// the token has none with that ID.
+func (h *CouponHandler) pathCoupon(w http.ResponseWriter, r *http.Request) (*entity.Coupon, bool) {
+	coupon, err := h.CouponDB.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find coupon", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return coupon, true
+}
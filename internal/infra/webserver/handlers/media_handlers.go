@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/storage"
+	"github.com/ivandersr/products-api-go/internal/infra/thumbnail"
+	"gorm.io/gorm"
+)
+
+// multipartMemory is how much of an upload is kept in memory before the
+// rest is spooled to a temporary file.
+const multipartMemory = 1 << 20
+
+// MediaSettings configures product media. The blob URLs of published
+// products are PublicURL followed by the blob key; those of other products
+// point to DownloadMedia, so only the members who see them get their files.
+// A PublicURL pointing at the storage itself bypasses this check.
+type MediaSettings struct {
+	PublicURL        string
+	MaxImageBytes    int64
+	MaxDocumentBytes int64
+	ThumbnailSizes   []int
+}
+
+// UploadMedia godoc
+// @Summary 		 Upload product media
+// @Description 	 Attaches an image (JPEG, PNG or GIF) or document (PDF, plain text or CSV) to a product, after the existing media. The type is sniffed from the content, whatever the file name says. Images get a thumbnail per configured size
+// @Tags 			 media
+// @Accept 		 	 mpfd
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param			 file	  formData	file		true		"image or document"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Param 			 Prefer   header    string  false  "return=minimal to leave the media out of the response"
+// @Success		 	 201      {object}  entity.Media
+// @Header			 201      {string}  Location "path of the created media"
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/media [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	data, filename, ok := h.readUpload(w, r)
+	if !ok {
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(mimetype.Detect(data).String())
+	kind := entity.MediaKind(contentType)
+	if kind == "" {
+		writeError(w, http.StatusUnsupportedMediaType, entity.ErrUnsupportedMediaType)
+		return
+	}
+	limit := h.Media.MaxDocumentBytes
+	if kind == entity.MediaKindImage {
+		limit = h.Media.MaxImageBytes
+	}
+	if int64(len(data)) > limit {
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("file is larger than "+strconv.FormatInt(limit, 10)+" bytes"))
+		return
+	}
+	media, err := entity.NewMedia(product, filename, contentType, int64(len(data)))
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	blobs := map[string][]byte{media.Key: data}
+	if media.IsImage() {
+		img, format, err := thumbnail.Decode(data)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("image cannot be decoded"))
+			return
+		}
+		media.Width, media.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		thumbnails, err := thumbnail.Generate(img, format, h.Media.ThumbnailSizes)
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to generate thumbnails", "product_id", product.ID.String(), "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, t := range thumbnails {
+			media.Thumbnails = append(media.Thumbnails, entity.MediaThumbnail{Size: t.Size, Width: t.Width, Height: t.Height})
+			blobs[media.ThumbnailKey(t.Size)] = t.Data
+		}
+	}
+	for key, blob := range blobs {
+		blobType := media.ContentType
+		if key != media.Key {
+			blobType = mime.TypeByExtension(key[strings.LastIndex(key, "."):])
+		}
+		if err := h.Blobs.Put(r.Context(), key, blob, blobType); err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to store media", "product_id", product.ID.String(), "key", key, "error", err)
+			h.deleteBlobs(r, media.Keys())
+			writeError(w, http.StatusInternalServerError, errors.New("failed to store media"))
+			return
+		}
+	}
+	if err := h.MediaDB.Create(r.Context(), media); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create media", "product_id", product.ID.String(), "error", err)
+		h.deleteBlobs(r, media.Keys())
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	withURLs := []entity.Media{*media}
+	setMediaURLs(h.Media.PublicURL, product, withURLs)
+	writeCreated(w, r, "/products/"+product.ID.String()+"/media/"+media.ID.String(), withURLs[0])
+}
+
+// ListMedia godoc
+// @Summary 		 List product media
+// @Description 	 Returns the media of a product in order. Only tenant owners and editors find the media of the products that are not published
+// @Tags 			 media
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Success		 	 200 	  {object}  dto.MediaListOutput
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/media [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) ListMedia(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	media, err := h.MediaDB.FindAllByProduct(r.Context(), product.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list media", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	setMediaURLs(h.Media.PublicURL, product, media)
+	writeJSON(w, http.StatusOK, dto.MediaListOutput{Data: media})
+}
+
+// ReorderMedia godoc
+// @Summary 		 Reorder product media
+// @Description 	 Sets the order of the media of a product, which has to list every media of the product once
+// @Tags 			 media
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param 			 request  body 	    dto.ReorderMediaInput  true  "media IDs in their new order"
+// @Success		 	 200 	  {object}  dto.MediaListOutput
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/media/order [put]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) ReorderMedia(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	var input dto.ReorderMediaInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	media, err := h.MediaDB.FindAllByProduct(r.Context(), product.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list media", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := entity.ReorderMedia(media, input.IDs); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.MediaDB.UpdatePositions(r.Context(), media); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to reorder media", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sortMedia(media)
+	setMediaURLs(h.Media.PublicURL, product, media)
+	writeJSON(w, http.StatusOK, dto.MediaListOutput{Data: media})
+}
+
+// DeleteMedia godoc
+// @Summary 		 Delete product media
+// @Description 	 Deletes a media of a product along with its files
+// @Tags 			 media
+// @Param			 id	  	  	path   	string  	true		"product ID"   Format(uuid)
+// @Param			 media_id	path   	string  	true		"media ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/media/{media_id} [delete]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	media, err := h.MediaDB.FindByID(r.Context(), product.ID.String(), chi.URLParam(r, "media_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = h.MediaDB.Delete(r.Context(), product.ID.String(), media.ID.String())
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete media", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.deleteBlobs(r, media.Keys())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DownloadMedia godoc
+// @Summary 		 Download product media
+// @Description 	 Returns the file of a media of a product, or its thumbnail of a size. Unlike /media, it serves the media of the products that are not published, to tenant owners and editors only
+// @Tags 			 media
+// @Produce		 	 octet-stream
+// @Param			 id	  	  	path   	string  	true		"product ID"   Format(uuid)
+// @Param			 media_id	path   	string  	true		"media ID"   Format(uuid)
+// @Param			 size	  	query   	int  	false		"thumbnail size"
+// @Success		 	 200
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/media/{media_id}/file [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) DownloadMedia(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	media, err := h.MediaDB.FindByID(r.Context(), product.ID.String(), chi.URLParam(r, "media_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find media", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	key := media.Key
+	if size := queryInt(r, "size"); size > 0 {
+		if !slices.ContainsFunc(media.Thumbnails, func(t entity.MediaThumbnail) bool { return t.Size == size }) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		key = media.ThumbnailKey(size)
+	}
+	serveBlob(w, r, h.Blobs, key, "private, no-cache", h.Logger)
+}
+
+// readUpload reads the file field of a multipart request, answering 413
+// when the body is over the limit and 400 or 415 when it has no file.
+func (h *ProductHandler) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	err := r.ParseMultipartForm(multipartMemory)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body is too large"))
+		return nil, "", false
+	case errors.Is(err, http.ErrNotMultipart):
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("content type must be multipart/form-data"))
+		return nil, "", false
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return nil, "", false
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("file is required"))
+		return nil, "", false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, "", false
+	}
+	return data, header.Filename, true
+}
+
+// deleteBlobs removes blobs whose records are gone. Failures leave orphaned
+// files behind, which is logged rather than failing the request.
+func (h *ProductHandler) deleteBlobs(r *http.Request, keys []string) {
+	for _, key := range keys {
+		if err := h.Blobs.Delete(r.Context(), key); err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to delete media blob", "key", key, "error", err)
+		}
+	}
+}
+
+// embedMedia loads the media of products into them.
+func (h *ProductHandler) embedMedia(w http.ResponseWriter, r *http.Request, products []entity.Product) bool {
+	if len(products) == 0 {
+		return true
+	}
+	ids := make([]string, len(products))
+	for i, product := range products {
+		ids[i] = product.ID.String()
+	}
+	media, err := h.MediaDB.FindAllByProducts(r.Context(), ids)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to embed product media", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	byProduct := map[string][]entity.Media{}
+	for _, item := range media {
+		byProduct[item.ProductID.String()] = append(byProduct[item.ProductID.String()], item)
+	}
+	for i := range products {
+		products[i].Media = byProduct[products[i].ID.String()]
+		setMediaURLs(h.Media.PublicURL, &products[i], products[i].Media)
+	}
+	return true
+}
+
+// setMediaURLs sets the URLs of the media of product and their thumbnails,
+// public only while the product is published.
+func setMediaURLs(publicURL string, product *entity.Product, media []entity.Media) {
+	base := strings.TrimSuffix(publicURL, "/") + "/"
+	for i := range media {
+		url := func(size int) string {
+			if !product.IsPublished() {
+				file := "/products/" + product.ID.String() + "/media/" + media[i].ID.String() + "/file"
+				if size > 0 {
+					file += "?size=" + strconv.Itoa(size)
+				}
+				return file
+			}
+			if size > 0 {
+				return base + media[i].ThumbnailKey(size)
+			}
+			return base + media[i].Key
+		}
+		media[i].URL = url(0)
+		thumbnails := make([]entity.MediaThumbnail, len(media[i].Thumbnails))
+		for j, t := range media[i].Thumbnails {
+			t.URL = url(t.Size)
+			thumbnails[j] = t
+		}
+		media[i].Thumbnails = thumbnails
+	}
+}
+
+func sortMedia(media []entity.Media) {
+	sorted := make([]entity.Media, len(media))
+	for _, item := range media {
+		sorted[item.Position] = item
+	}
+	copy(media, sorted)
+}
+
+// MediaHandler serves the blobs of the media of published products when
+// they are not exposed by the storage itself. Responses are cached for
+// CacheMaxAge, so archiving a product hides its media soon after.
+type MediaHandler struct {
+	Blobs       storage.Store
+	ProductDB   database.ProductInterface
+	CacheMaxAge time.Duration
+	Logger      *slog.Logger
+}
+
+func NewMediaHandler(blobs storage.Store, productDB database.ProductInterface, cacheMaxAge time.Duration, logger *slog.Logger) *MediaHandler {
+	return &MediaHandler{Blobs: blobs, ProductDB: productDB, CacheMaxAge: cacheMaxAge, Logger: logger}
+}
+
+// ServeMedia godoc
+// @Summary 		 Download media
+// @Description 	 Returns a stored media file or thumbnail of a published product. Responses are cached for CATALOG_CACHE_MAX_AGE, and the media of other products are only served by /products/{id}/media/{media_id}/file
+// @Tags 			 media
+// @Produce		 	 octet-stream
+// @Param			 key	  path   	string  	true		"blob key"
+// @Success		 	 200
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /media/{key} [get]
+func (h *MediaHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	tenantID, productID, ok := entity.MediaKeyOwner(key)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	product, err := h.ProductDB.FindByID(database.WithTenant(r.Context(), tenantID), productID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !product.IsPublished()) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find media product", "product_id", productID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	maxAge := strconv.Itoa(int(h.CacheMaxAge.Seconds()))
+	serveBlob(w, r, h.Blobs, key, "public, max-age="+maxAge, h.Logger)
+}
+
+// serveBlob answers with the blob under key, or 404 when there is none.
+func serveBlob(w http.ResponseWriter, r *http.Request, blobs storage.Store, key, cacheControl string, logger *slog.Logger) {
+	object, err := blobs.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to read media", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer object.Body.Close()
+	w.Header().Set("Content-Type", object.ContentType)
+	if object.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, object.Body)
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	"github.com/ivandersr/products-api-go/pkg/totp"
+)
+
+var ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+
+// startMFAChallenge answers a correct password of a user with two-factor
+// authentication with a short-lived, single-use token for the second step.
+func (h *UserHandler) startMFAChallenge(w http.ResponseWriter, r *http.Request, user *entity.User) {
+	challenge := entity.NewUserToken(user.ID, entity.TokenPurposeMFAChallenge, h.Settings.MFAChallengeTTL)
+	if err := h.UserTokenDB.Create(challenge); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create mfa challenge", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.GetJWTOutput{
+		MFARequired: true,
+		MFAToken:    h.Signer.Sign(entity.TokenPurposeMFAChallenge, challenge.ID.String()),
+	})
+}
+
+// VerifyMFA godoc
+// @Summary 		 Complete two-factor login
+// @Description 	 Exchanges the mfa_token returned by /users/token and a TOTP or recovery code for an access token
+// @Tags 			 users
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.MFAChallengeInput  true  "mfa request"
+// @Success		 	 200	  {object} dto.GetJWTOutput
+// @Failure		 	 400      {object} Error
+// @Failure 		 401      {object} Error
+// @Failure 		 403      {object} Error
+// @Failure 		 429
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/token/mfa [post]
+func (h *UserHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var input dto.MFAChallengeInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	id, err := h.Signer.Verify(entity.TokenPurposeMFAChallenge, input.MFAToken)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, ErrInvalidMFAToken)
+		return
+	}
+	challenge, err := h.UserTokenDB.Find(id, entity.TokenPurposeMFAChallenge)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, ErrInvalidMFAToken)
+		return
+	}
+	user, err := h.UserDB.FindByID(challenge.UserID.String())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, ErrInvalidMFAToken)
+		return
+	}
+	lockoutKey := "mfa:" + user.ID.String()
+	lockedFor, err := h.Lockout.Locked(r.Context(), lockoutKey)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to check mfa lockout", "error", err)
+	}
+	if lockedFor > 0 {
+		h.Logger.WarnContext(r.Context(), "mfa attempt on locked account", "user_id", user.ID.String())
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	if user.IsDisabled() {
+		writeError(w, http.StatusForbidden, ErrAccountDisabled)
+		return
+	}
+	amr := []string{middlewares.AMRPassword, middlewares.AMRMFA}
+	var valid bool
+	switch {
+	case input.Code != "":
+		amr = append(amr, middlewares.AMROTP)
+		valid, err = h.verifyTOTP(user, input.Code)
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to record totp step", "user_id", user.ID.String(), "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if valid && !h.consumeMFAChallenge(w, id) {
+			return
+		}
+	case input.RecoveryCode != "":
+		// Recovery codes are few, so the challenge is consumed first: a
+		// replayed challenge must not cost the user one.
+		if !h.consumeMFAChallenge(w, id) {
+			return
+		}
+		valid = h.RecoveryCodeDB.Consume(user.ID.String(), input.RecoveryCode) == nil
+		if valid {
+			h.Logger.InfoContext(r.Context(), "recovery code used", "user_id", user.ID.String())
+		}
+	}
+	if !valid {
+		h.Logger.WarnContext(r.Context(), "invalid mfa code", "user_id", user.ID.String())
+		h.loginFailed(r, lockoutKey)
+		writeError(w, http.StatusUnauthorized, entity.ErrInvalidMFACode)
+		return
+	}
+	if err := h.Lockout.Reset(r.Context(), lockoutKey); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to reset mfa lockout", "error", err)
+	}
+	tokenString, err := h.issueAccessToken(r, user, amr)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.GetJWTOutput{AccessToken: tokenString})
+}
+
+// GetMFA godoc
+// @Summary 		 Two-factor status
+// @Description 	 Returns whether two-factor authentication is enabled or required for the authenticated user
+// @Tags 			 mfa
+// @Produce		 	 json
+// @Success		 	 200      {object} dto.MFAStatusOutput
+// @Failure			 401
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/mfa [get]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) GetMFA(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	required, err := h.MFAPolicyDB.IsRequired(user.Role)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to load mfa policy", "role", user.Role, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	remaining, err := h.RecoveryCodeDB.CountUnused(user.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to count recovery codes", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.MFAStatusOutput{
+		Enabled:                user.IsMFAEnabled(),
+		Required:               required,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// EnrollTOTP godoc
+// @Summary 		 Start TOTP enrollment
+// @Description 	 Generates a TOTP secret and its otpauth:// provisioning URI, to be rendered as a QR code. Enrollment completes at /users/me/mfa/totp/confirm
+// @Tags 			 mfa
+// @Produce		 	 json
+// @Success		 	 200      {object} dto.TOTPEnrollmentOutput
+// @Failure			 401
+// @Failure			 409      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/mfa/totp [post]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	secret, err := user.StartTOTPEnrollment()
+	if errors.Is(err, entity.ErrMFAAlreadyEnabled) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to generate totp secret", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.UserDB.Update(user); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to update user", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.TOTPEnrollmentOutput{
+		Secret: secret,
+		URI:    totp.URI(h.Settings.MFAIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP godoc
+// @Summary 		 Confirm TOTP enrollment
+// @Description 	 Enables two-factor authentication with a code of the new authenticator. Returns the recovery codes, shown only once, and a new access token since other sessions are invalidated
+// @Tags 			 mfa
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.MFACodeInput  true  "confirmation request"
+// @Success		 	 200      {object} dto.ConfirmTOTPOutput
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 409      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/mfa/totp/confirm [post]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	var input dto.MFACodeInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	err := user.ConfirmTOTP(input.Code, time.Now())
+	if errors.Is(err, entity.ErrMFAAlreadyEnabled) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	codes, ok := h.replaceRecoveryCodes(w, r, user)
+	if !ok {
+		return
+	}
+	if err := h.UserDB.Update(user); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to enable mfa", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "mfa enabled", "user_id", user.ID.String())
+	tokenString, err := h.issueAccessToken(r, user, []string{middlewares.AMRPassword, middlewares.AMROTP, middlewares.AMRMFA})
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.ConfirmTOTPOutput{AccessToken: tokenString, RecoveryCodes: codes})
+}
+
+// DisableMFA godoc
+// @Summary 		 Disable two-factor authentication
+// @Description 	 Removes the authenticator and recovery codes of the authenticated user. Not allowed when the role requires two-factor authentication
+// @Tags 			 mfa
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.DisableMFAInput  true  "disable request"
+// @Success		 	 200      {object} dto.GetJWTOutput
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 403      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/mfa/totp [delete]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	var input dto.DisableMFAInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if !user.ValidatePassword(input.Password) {
+		h.Logger.WarnContext(r.Context(), "invalid current password", "user_id", user.ID.String())
+		writeError(w, http.StatusForbidden, ErrInvalidCurrentPassword)
+		return
+	}
+	if !user.IsMFAEnabled() {
+		writeError(w, http.StatusBadRequest, entity.ErrMFANotEnabled)
+		return
+	}
+	required, err := h.MFAPolicyDB.IsRequired(user.Role)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to load mfa policy", "role", user.Role, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if required {
+		writeError(w, http.StatusForbidden, middlewares.ErrMFARequired)
+		return
+	}
+	user.DisableMFA()
+	if err := h.RecoveryCodeDB.Replace(user.ID.String(), nil); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete recovery codes", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.UserDB.Update(user); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to disable mfa", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "mfa disabled", "user_id", user.ID.String())
+	tokenString, err := h.issueAccessToken(r, user, []string{middlewares.AMRPassword})
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.GetJWTOutput{AccessToken: tokenString})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary 		 Regenerate recovery codes
+// @Description 	 Replaces the recovery codes of the authenticated user after checking a TOTP code. The new codes are shown only once
+// @Tags 			 mfa
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.MFACodeInput  true  "regeneration request"
+// @Success		 	 200      {object} dto.RecoveryCodesOutput
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 403      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/mfa/recovery-codes [post]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	var input dto.MFACodeInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if !user.IsMFAEnabled() {
+		writeError(w, http.StatusBadRequest, entity.ErrMFANotEnabled)
+		return
+	}
+	valid, err := h.verifyTOTP(user, input.Code)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to record totp step", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !valid {
+		writeError(w, http.StatusForbidden, entity.ErrInvalidMFACode)
+		return
+	}
+	codes, ok := h.replaceRecoveryCodes(w, r, user)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.RecoveryCodesOutput{RecoveryCodes: codes})
+}
+
+// verifyTOTP checks a code of the authenticator of user and records its time
+// step in the same conditional update that checks it was not used yet, so a
+// code accepted by concurrent requests only succeeds once.
+func (h *UserHandler) verifyTOTP(user *entity.User, code string) (bool, error) {
+	if !user.VerifyTOTP(code, time.Now()) {
+		return false, nil
+	}
+	err := h.UserDB.ClaimTOTPStep(user.ID.String(), user.TOTPLastStep)
+	if errors.Is(err, entity.ErrInvalidMFACode) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// consumeMFAChallenge marks the challenge as used, answering 401 when it
+// already was.
+func (h *UserHandler) consumeMFAChallenge(w http.ResponseWriter, id string) bool {
+	if _, err := h.UserTokenDB.Consume(id, entity.TokenPurposeMFAChallenge); err != nil {
+		writeError(w, http.StatusUnauthorized, ErrInvalidMFAToken)
+		return false
+	}
+	return true
+}
+
+// replaceRecoveryCodes issues a fresh set of recovery codes for user and
+// returns their plain text values.
+func (h *UserHandler) replaceRecoveryCodes(w http.ResponseWriter, r *http.Request, user *entity.User) ([]string, bool) {
+	codes, plain, err := entity.NewRecoveryCodes(user.ID)
+	if err == nil {
+		err = h.RecoveryCodeDB.Replace(user.ID.String(), codes)
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to issue recovery codes", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return plain, true
+}
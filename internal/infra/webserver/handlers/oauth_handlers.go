@@ -0,0 +1,758 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/auth"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/request"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// OAuth2 error codes (RFC 6749 section 5.2).
+const (
+	oauthInvalidRequest          = "invalid_request"
+	oauthInvalidClient           = "invalid_client"
+	oauthInvalidGrant            = "invalid_grant"
+	oauthInvalidScope            = "invalid_scope"
+	oauthUnauthorizedClient      = "unauthorized_client"
+	oauthUnsupportedGrantType    = "unsupported_grant_type"
+	oauthUnsupportedResponseType = "unsupported_response_type"
+	oauthAccessDenied            = "access_denied"
+)
+
+var ErrInvalidClient = errors.New("client authentication failed")
+
+// OAuthError is the error body of the OAuth2 endpoints.
+type OAuthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// OAuthSettings holds the lifetimes of the OAuth2 grants.
+type OAuthSettings struct {
+	CodeTTL         time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// OAuthHandler is the OAuth2 authorization server. Access tokens are the JWTs
+// issued by UserHandler, restricted by the scope and client_id claims.
+type OAuthHandler struct {
+	Users          *UserHandler
+	ClientDB       database.OAuthClientInterface
+	ConsentDB      database.OAuthConsentInterface
+	CodeDB         database.OAuthAuthorizationCodeInterface
+	RefreshTokenDB database.OAuthRefreshTokenInterface
+	Settings       OAuthSettings
+	Logger         *slog.Logger
+}
+
+func NewOAuthHandler(
+	users *UserHandler,
+	clientDB database.OAuthClientInterface,
+	consentDB database.OAuthConsentInterface,
+	codeDB database.OAuthAuthorizationCodeInterface,
+	refreshTokenDB database.OAuthRefreshTokenInterface,
+	settings OAuthSettings,
+	logger *slog.Logger,
+) *OAuthHandler {
+	return &OAuthHandler{
+		Users:          users,
+		ClientDB:       clientDB,
+		ConsentDB:      consentDB,
+		CodeDB:         codeDB,
+		RefreshTokenDB: refreshTokenDB,
+		Settings:       settings,
+		Logger:         logger,
+	}
+}
+
+// authorizeRequest is a validated authorization request.
+type authorizeRequest struct {
+	input       dto.AuthorizeInput
+	client      *entity.OAuthClient
+	redirectURI string
+	scopes      []string
+}
+
+// GetAuthorize godoc
+// @Summary 		 Start an OAuth2 authorization
+// @Description 	 Validates an authorization code request (PKCE S256 required) for the logged in user. Returns where to redirect the user agent when consent was already given, or the client and scopes to ask consent for
+// @Tags 			 oauth
+// @Produce		 	 json
+// @Param			 response_type	       query   string  true   "code"
+// @Param			 client_id	           query   string  true   "client ID"
+// @Param			 redirect_uri	       query   string  false  "registered redirect URI"
+// @Param			 scope	               query   string  false  "space separated scopes"
+// @Param			 state	               query   string  false  "opaque client state"
+// @Param			 code_challenge	       query   string  true   "PKCE challenge"
+// @Param			 code_challenge_method query   string  true   "S256"
+// @Param			 nonce	               query   string  false  "OpenID Connect nonce"
+// @Success		 	 200      {object} dto.AuthorizeOutput
+// @Failure			 400      {object} OAuthError
+// @Failure			 401
+// @Router 		 	 /oauth/authorize [get]
+// @Security 		 ApiKeyAuth
+func (h *OAuthHandler) GetAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	input := dto.AuthorizeInput{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		Nonce:               query.Get("nonce"),
+	}
+	req, ok := h.validateAuthorize(w, r, input)
+	if !ok {
+		return
+	}
+	user := middlewares.UserFromContext(r.Context())
+	consent, err := h.ConsentDB.Find(user.ID.String(), req.client.ID.String())
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		h.Logger.ErrorContext(r.Context(), "failed to load oauth consent", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if consent == nil || !consent.Covers(req.scopes) {
+		writeJSON(w, http.StatusOK, dto.AuthorizeOutput{
+			ConsentRequired: true,
+			Client:          &dto.OAuthClientInfo{ID: req.client.ID.String(), Name: req.client.Name},
+			Scopes:          req.scopes,
+		})
+		return
+	}
+	h.issueCode(w, r, req)
+}
+
+// PostAuthorize godoc
+// @Summary 		 Answer an OAuth2 authorization
+// @Description 	 Records the decision of the logged in user on an authorization request and returns where to redirect the user agent, with a code when approved
+// @Tags 			 oauth
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.AuthorizeInput  true  "authorization request"
+// @Success		 	 200      {object} dto.AuthorizeOutput
+// @Failure			 400      {object} OAuthError
+// @Failure			 401
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /oauth/authorize [post]
+// @Security 		 ApiKeyAuth
+func (h *OAuthHandler) PostAuthorize(w http.ResponseWriter, r *http.Request) {
+	var input dto.AuthorizeInput
+	if err := request.DecodeJSON(r, &input); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+	req, ok := h.validateAuthorize(w, r, input)
+	if !ok {
+		return
+	}
+	if !input.Approve {
+		h.redirectError(w, req, oauthAccessDenied, "the user denied the request")
+		return
+	}
+	user := middlewares.UserFromContext(r.Context())
+	consent, err := h.ConsentDB.Find(user.ID.String(), req.client.ID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		consent, err = entity.NewOAuthConsent(user.ID, req.client.ID, nil), nil
+	}
+	if err == nil {
+		consent.Grant(req.scopes)
+		err = h.ConsentDB.Save(consent)
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to save oauth consent", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.issueCode(w, r, req)
+}
+
+// validateAuthorize checks an authorization request. Problems with the client
+// or redirect URI are answered directly, since redirecting to an unverified
+// URI would make an open redirect; others are sent back to the client.
+func (h *OAuthHandler) validateAuthorize(w http.ResponseWriter, r *http.Request, input dto.AuthorizeInput) (*authorizeRequest, bool) {
+	client, err := h.findClient(input.ClientID)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidClient, "unknown client")
+		return nil, false
+	}
+	redirectURI := input.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirectURI(redirectURI) {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, "redirect_uri is not registered for the client")
+		return nil, false
+	}
+	req := &authorizeRequest{input: input, client: client, redirectURI: redirectURI, scopes: entity.ParseScope(input.Scope)}
+	switch {
+	case input.ResponseType != "code":
+		h.redirectError(w, req, oauthUnsupportedResponseType, "only the code response type is supported")
+	case !client.AllowsGrant(entity.GrantAuthorizationCode):
+		h.redirectError(w, req, oauthUnauthorizedClient, "the client cannot use the authorization code grant")
+	case !client.AllowsScopes(req.scopes):
+		h.redirectError(w, req, oauthInvalidScope, "the client cannot request these scopes")
+	case input.CodeChallenge == "" || input.CodeChallengeMethod != entity.PKCEMethodS256:
+		h.redirectError(w, req, oauthInvalidRequest, "a S256 code_challenge is required")
+	default:
+		return req, true
+	}
+	return nil, false
+}
+
+func (h *OAuthHandler) issueCode(w http.ResponseWriter, r *http.Request, req *authorizeRequest) {
+	user := middlewares.UserFromContext(r.Context())
+	code, err := entity.NewOAuthAuthorizationCode(req.client.ID, user.ID, req.redirectURI, req.scopes,
+		req.input.CodeChallenge, req.input.CodeChallengeMethod, req.input.Nonce, middlewares.AMRFromRequest(r), h.Settings.CodeTTL)
+	if err != nil {
+		h.redirectError(w, req, oauthInvalidRequest, err.Error())
+		return
+	}
+	if tenantID, err := entityPkg.ParseID(middlewares.TenantClaimFromRequest(r)); err == nil {
+		code.TenantID = &tenantID
+	}
+	if err := h.CodeDB.Create(code); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create authorization code", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "oauth authorization granted", "user_id", user.ID.String(), "client_id", req.client.ID.String())
+	h.redirect(w, req, url.Values{"code": {h.Users.Signer.Sign(entity.TokenPurposeOAuthCode, code.ID.String())}})
+}
+
+func (h *OAuthHandler) redirectError(w http.ResponseWriter, req *authorizeRequest, code, description string) {
+	h.redirect(w, req, url.Values{"error": {code}, "error_description": {description}})
+}
+
+func (h *OAuthHandler) redirect(w http.ResponseWriter, req *authorizeRequest, params url.Values) {
+	target, _ := url.Parse(req.redirectURI)
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.input.State != "" {
+		query.Set("state", req.input.State)
+	}
+	target.RawQuery = query.Encode()
+	writeJSON(w, http.StatusOK, dto.AuthorizeOutput{RedirectTo: target.String()})
+}
+
+// Token godoc
+// @Summary 		 OAuth2 token endpoint
+// @Description 	 Issues tokens for the authorization_code (with PKCE), client_credentials and refresh_token grants. Confidential clients authenticate with HTTP Basic or client_secret in the form
+// @Tags 			 oauth
+// @Accept 		 	 x-www-form-urlencoded
+// @Produce		 	 json
+// @Param			 grant_type	     formData  string  true   "authorization_code, client_credentials or refresh_token"
+// @Param			 client_id	     formData  string  false  "client ID, unless sent with HTTP Basic"
+// @Param			 client_secret	 formData  string  false  "client secret, unless sent with HTTP Basic"
+// @Param			 code	         formData  string  false  "authorization code"
+// @Param			 redirect_uri	 formData  string  false  "redirect URI of the authorization request"
+// @Param			 code_verifier	 formData  string  false  "PKCE verifier"
+// @Param			 refresh_token	 formData  string  false  "refresh token"
+// @Param			 scope	         formData  string  false  "space separated scopes"
+// @Success		 	 200      {object} dto.OAuthTokenOutput
+// @Failure			 400      {object} OAuthError
+// @Failure			 401      {object} OAuthError
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /oauth/token [post]
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+	client, err := h.authenticateClient(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, err.Error())
+		return
+	}
+	grant := r.PostForm.Get("grant_type")
+	if grant != entity.GrantAuthorizationCode && grant != entity.GrantClientCredentials && grant != entity.GrantRefreshToken {
+		writeOAuthError(w, http.StatusBadRequest, oauthUnsupportedGrantType, "")
+		return
+	}
+	if !client.AllowsGrant(grant) {
+		writeOAuthError(w, http.StatusBadRequest, oauthUnauthorizedClient, "the client cannot use this grant")
+		return
+	}
+	switch grant {
+	case entity.GrantAuthorizationCode:
+		h.exchangeCode(w, r, client)
+	case entity.GrantClientCredentials:
+		h.clientCredentials(w, r, client)
+	case entity.GrantRefreshToken:
+		h.refresh(w, r, client)
+	}
+}
+
+func (h *OAuthHandler) exchangeCode(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient) {
+	id, err := h.Users.Signer.Verify(entity.TokenPurposeOAuthCode, r.PostForm.Get("code"))
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "invalid code")
+		return
+	}
+	code, err := h.CodeDB.FindByID(id)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "invalid or expired code")
+		return
+	}
+	// A mismatching request must not burn the code of the legitimate client,
+	// so it is only consumed once everything checks out.
+	redirectURI := r.PostForm.Get("redirect_uri")
+	if code.ClientID != client.ID || code.RedirectURI != redirectURI ||
+		!code.VerifyCodeVerifier(r.PostForm.Get("code_verifier")) {
+		h.Logger.WarnContext(r.Context(), "authorization code exchange rejected", "client_id", client.ID.String())
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "code, redirect_uri or code_verifier mismatch")
+		return
+	}
+	if err := h.CodeDB.Consume(id, client.ID, redirectURI); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "invalid or expired code")
+		return
+	}
+	user, err := h.Users.UserDB.FindByID(code.UserID.String())
+	if err != nil || user.IsDisabled() {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "the user is no longer active")
+		return
+	}
+	h.issueTokens(w, r, client, grant{
+		user:     user,
+		scopes:   code.Scopes,
+		amr:      code.AMR,
+		tenantID: code.TenantID,
+		nonce:    code.Nonce,
+		authTime: code.AuthTime,
+	}, true)
+}
+
+func (h *OAuthHandler) clientCredentials(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient) {
+	scopes := entity.ParseScope(r.PostForm.Get("scope"))
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !client.AllowsScopes(scopes) {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidScope, "")
+		return
+	}
+	if client.OwnerID == nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthUnauthorizedClient, "the client has no owner to act for")
+		return
+	}
+	owner, err := h.Users.UserDB.FindByID(client.OwnerID.String())
+	if err != nil || owner.IsDisabled() {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "the client owner is no longer active")
+		return
+	}
+	h.issueTokens(w, r, client, grant{user: owner, scopes: scopes}, false)
+}
+
+func (h *OAuthHandler) refresh(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient) {
+	id, err := h.Users.Signer.Verify(entity.TokenPurposeOAuthRefreshToken, r.PostForm.Get("refresh_token"))
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "invalid refresh token")
+		return
+	}
+	token, err := h.RefreshTokenDB.FindByID(id)
+	if err != nil || token.ClientID != client.ID {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "invalid refresh token")
+		return
+	}
+	user, err := h.Users.UserDB.FindByID(token.UserID.String())
+	if err != nil || !token.IsActive(user) {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "invalid refresh token")
+		return
+	}
+	scopes := token.Scopes
+	if requested := entity.ParseScope(r.PostForm.Get("scope")); len(requested) > 0 {
+		consent := entity.OAuthConsent{Scopes: token.Scopes}
+		if !consent.Covers(requested) {
+			writeOAuthError(w, http.StatusBadRequest, oauthInvalidScope, "scopes exceed the original grant")
+			return
+		}
+		scopes = requested
+	}
+	next := entity.NewOAuthRefreshToken(client.ID, user, token.Scopes, token.AMR, h.Settings.RefreshTokenTTL)
+	next.TenantID = token.TenantID
+	if err := h.RefreshTokenDB.Rotate(token.ID.String(), next); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "invalid refresh token")
+		return
+	}
+	h.writeTokens(w, r, client, grant{user: user, scopes: scopes, amr: token.AMR, tenantID: token.TenantID},
+		h.Users.Signer.Sign(entity.TokenPurposeOAuthRefreshToken, next.ID.String()))
+}
+
+// grant is what the tokens of a token response are issued for. A nil
+// tenantID leaves the tenant to the default of the user.
+type grant struct {
+	user     *entity.User
+	scopes   []string
+	amr      []string
+	tenantID *entityPkg.ID
+	nonce    string
+	authTime time.Time
+}
+
+// issueTokens answers a grant with an access token, a refresh token when
+// offline access was granted and the user is involved, and an ID token when
+// openid was requested.
+func (h *OAuthHandler) issueTokens(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient, g grant, withUser bool) {
+	refreshToken := ""
+	if withUser && client.AllowsGrant(entity.GrantRefreshToken) && slices.Contains(g.scopes, entity.ScopeOfflineAccess) {
+		token := entity.NewOAuthRefreshToken(client.ID, g.user, g.scopes, g.amr, h.Settings.RefreshTokenTTL)
+		token.TenantID = g.tenantID
+		if err := h.RefreshTokenDB.Create(token); err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to create refresh token", "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		refreshToken = h.Users.Signer.Sign(entity.TokenPurposeOAuthRefreshToken, token.ID.String())
+	}
+	h.writeTokens(w, r, client, g, refreshToken)
+}
+
+func (h *OAuthHandler) writeTokens(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient, g grant, refreshToken string) {
+	expiresIn := r.Context().Value("jwtExpiresIn").(int)
+	user, scopes := g.user, g.scopes
+	claims := map[string]interface{}{
+		middlewares.ScopeClaim:    strings.Join(scopes, " "),
+		middlewares.ClientIDClaim: client.ID.String(),
+	}
+	if len(g.amr) > 0 {
+		claims["amr"] = g.amr
+	}
+	if g.tenantID != nil {
+		claims[middlewares.TenantClaim] = g.tenantID.String()
+	}
+	accessToken, err := h.Users.encodeToken(r, user, claims, time.Duration(expiresIn)*time.Second)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	output := dto.OAuthTokenOutput{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    expiresIn,
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}
+	if slices.Contains(scopes, entity.ScopeOpenID) {
+		output.IDToken, err = h.encodeIDToken(r, client, g, time.Duration(expiresIn)*time.Second)
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to encode id token", "user_id", user.ID.String(), "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	h.Logger.InfoContext(r.Context(), "oauth tokens issued", "client_id", client.ID.String(), "user_id", user.ID.String())
+	noStore(w)
+	writeJSON(w, http.StatusOK, output)
+}
+
+// encodeIDToken signs an OpenID Connect ID token. Its audience is the client
+// and its typ is not the one of access tokens, so it cannot be used as an
+// access token for this API.
+func (h *OAuthHandler) encodeIDToken(r *http.Request, client *entity.OAuthClient, g grant, expiresIn time.Duration) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"sub": g.user.ID.String(),
+		"aud": client.ID.String(),
+		"iat": now.Unix(),
+		"exp": now.Add(expiresIn).Unix(),
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	if !g.authTime.IsZero() {
+		claims["auth_time"] = g.authTime.Unix()
+	}
+	if len(g.amr) > 0 {
+		claims["amr"] = g.amr
+	}
+	for key, value := range userClaims(g.user, g.scopes) {
+		claims[key] = value
+	}
+	jwt := r.Context().Value("jwt").(*auth.JWTAuth)
+	_, token, err := jwt.EncodeIDToken(claims)
+	return token, err
+}
+
+// Introspect godoc
+// @Summary 		 OAuth2 token introspection
+// @Description 	 Reports whether an access or refresh token is active (RFC 7662). Requires confidential client authentication
+// @Tags 			 oauth
+// @Accept 		 	 x-www-form-urlencoded
+// @Produce		 	 json
+// @Param			 token	           formData  string  true   "token"
+// @Param			 token_type_hint   formData  string  false  "access_token or refresh_token"
+// @Success		 	 200      {object} dto.IntrospectionOutput
+// @Failure			 400      {object} OAuthError
+// @Failure			 401      {object} OAuthError
+// @Router 		 	 /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+	client, err := h.authenticateClient(r)
+	if err != nil || !client.Confidential {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, ErrInvalidClient.Error())
+		return
+	}
+	value := r.PostForm.Get("token")
+	output := dto.IntrospectionOutput{}
+	if id, err := h.Users.Signer.Verify(entity.TokenPurposeOAuthRefreshToken, value); err == nil {
+		output = h.introspectRefreshToken(id)
+	} else {
+		output = h.introspectAccessToken(r, value)
+	}
+	noStore(w)
+	writeJSON(w, http.StatusOK, output)
+}
+
+func (h *OAuthHandler) introspectRefreshToken(id string) dto.IntrospectionOutput {
+	token, err := h.RefreshTokenDB.FindByID(id)
+	if err != nil {
+		return dto.IntrospectionOutput{}
+	}
+	user, err := h.Users.UserDB.FindByID(token.UserID.String())
+	if err != nil || !token.IsActive(user) {
+		return dto.IntrospectionOutput{}
+	}
+	return dto.IntrospectionOutput{
+		Active:    true,
+		Scope:     strings.Join(token.Scopes, " "),
+		ClientID:  token.ClientID.String(),
+		Sub:       token.UserID.String(),
+		TokenType: "refresh_token",
+		Exp:       token.ExpiresAt.Unix(),
+		Iat:       token.CreatedAt.Unix(),
+	}
+}
+
+// introspectAccessToken applies the checks of the Session middleware on top
+// of the signature, so revoked sessions are reported inactive.
+func (h *OAuthHandler) introspectAccessToken(r *http.Request, value string) dto.IntrospectionOutput {
+	jwt := r.Context().Value("jwt").(*auth.JWTAuth)
+	token, err := jwt.Decode(value)
+	if err != nil {
+		return dto.IntrospectionOutput{}
+	}
+	claims, err := token.AsMap(r.Context())
+	if err != nil {
+		return dto.IntrospectionOutput{}
+	}
+	user, err := h.Users.UserDB.FindByID(token.Subject())
+	if err != nil || user.IsDisabled() {
+		return dto.IntrospectionOutput{}
+	}
+	if version, _ := claims["ver"].(float64); int(version) != user.TokenVersion {
+		return dto.IntrospectionOutput{}
+	}
+	scope, _ := claims[middlewares.ScopeClaim].(string)
+	clientID, _ := claims[middlewares.ClientIDClaim].(string)
+	return dto.IntrospectionOutput{
+		Active:    true,
+		Scope:     scope,
+		ClientID:  clientID,
+		Sub:       token.Subject(),
+		TokenType: "access_token",
+		Exp:       token.Expiration().Unix(),
+		Iat:       token.IssuedAt().Unix(),
+	}
+}
+
+// Revoke godoc
+// @Summary 		 OAuth2 token revocation
+// @Description 	 Revokes a refresh token of the authenticated client (RFC 7009). Access tokens are short lived and expire on their own. Unknown tokens are ignored
+// @Tags 			 oauth
+// @Accept 		 	 x-www-form-urlencoded
+// @Param			 token	           formData  string  true   "token"
+// @Param			 token_type_hint   formData  string  false  "access_token or refresh_token"
+// @Success		 	 200
+// @Failure			 400      {object} OAuthError
+// @Failure			 401      {object} OAuthError
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /oauth/revoke [post]
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+	client, err := h.authenticateClient(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidClient, err.Error())
+		return
+	}
+	id, err := h.Users.Signer.Verify(entity.TokenPurposeOAuthRefreshToken, r.PostForm.Get("token"))
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	token, err := h.RefreshTokenDB.FindByID(id)
+	if err != nil || token.ClientID != client.ID {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := h.RefreshTokenDB.Revoke(id); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to revoke refresh token", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// UserInfo godoc
+// @Summary 		 OpenID Connect user info
+// @Description 	 Returns the claims about the user the access token was issued for, according to its scopes
+// @Tags 			 oauth
+// @Produce		 	 json
+// @Success		 	 200      {object} dto.UserInfoOutput
+// @Failure			 401
+// @Failure			 403
+// @Router 		 	 /oauth/userinfo [get]
+// @Security 		 ApiKeyAuth
+func (h *OAuthHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	scopes, restricted := middlewares.ScopesFromRequest(r)
+	if !restricted {
+		scopes = []string{entity.ScopeProfile, entity.ScopeEmail}
+	}
+	claims := userClaims(user, scopes)
+	output := dto.UserInfoOutput{Sub: user.ID.String()}
+	output.Name, _ = claims["name"].(string)
+	output.Email, _ = claims["email"].(string)
+	if verified, ok := claims["email_verified"].(bool); ok {
+		output.EmailVerified = &verified
+	}
+	writeJSON(w, http.StatusOK, output)
+}
+
+// ListConsents godoc
+// @Summary 		 List OAuth consents
+// @Description 	 Lists the applications the authenticated user granted access to
+// @Tags 			 oauth
+// @Produce		 	 json
+// @Success		 	 200      {array}  entity.OAuthConsent
+// @Failure			 401
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/oauth-consents [get]
+// @Security 		 ApiKeyAuth
+func (h *OAuthHandler) ListConsents(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	consents, err := h.ConsentDB.FindAllByUser(user.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list oauth consents", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if consents == nil {
+		consents = []entity.OAuthConsent{}
+	}
+	writeJSON(w, http.StatusOK, consents)
+}
+
+// RevokeConsent godoc
+// @Summary 		 Revoke an OAuth consent
+// @Description 	 Withdraws the access granted to an application and revokes its refresh tokens
+// @Tags 			 oauth
+// @Param			 client_id	path     string  true  "client ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 400
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/oauth-consents/{client_id} [delete]
+// @Security 		 ApiKeyAuth
+func (h *OAuthHandler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	clientID := chi.URLParam(r, "client_id")
+	if _, err := entityPkg.ParseID(clientID); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err := h.ConsentDB.Delete(user.ID.String(), clientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to revoke oauth consent", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticateClient identifies the client with HTTP Basic credentials or the
+// client_id and client_secret form fields. Public clients only send their ID.
+func (h *OAuthHandler) authenticateClient(r *http.Request) (*entity.OAuthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 section 2.3.1 form-encodes Basic credentials.
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+	client, err := h.findClient(clientID)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+	if client.Confidential && !client.ValidateSecret(secret) {
+		return nil, ErrInvalidClient
+	}
+	if !client.Confidential && secret != "" {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+func (h *OAuthHandler) findClient(id string) (*entity.OAuthClient, error) {
+	if _, err := entityPkg.ParseID(id); err != nil {
+		return nil, err
+	}
+	return h.ClientDB.FindByID(id)
+}
+
+// userClaims returns the OpenID Connect claims about user its scopes allow.
+func userClaims(user *entity.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if slices.Contains(scopes, entity.ScopeProfile) {
+		claims["name"] = user.Name
+	}
+	if slices.Contains(scopes, entity.ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsEmailVerified()
+	}
+	return claims
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	noStore(w)
+	writeJSON(w, status, OAuthError{Error: code, Description: description})
+}
+
+// noStore keeps responses carrying tokens out of caches (RFC 6749 5.1).
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"gorm.io/gorm"
+)
+
+// ListPrices godoc
+// @Summary 		 List product prices
+// @Description 	 Returns every price of a product, past, current and scheduled, with the timeline of the periods each is in effect. Only tenant owners and editors find the prices of the products that are not published
+// @Tags 			 prices
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Success		 	 200 	  {object}  dto.PriceHistoryOutput
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/prices [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) ListPrices(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	prices, err := h.PriceDB.FindAllByProduct(r.Context(), product.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list product prices", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.PriceHistoryOutput{Data: prices, Timeline: entity.PriceTimeline(prices)})
+}
+
+// GetPriceAt godoc
+// @Summary 		 Find the price of a product at a time
+// @Description 	 Returns the price a product had, has or will have at a time, such as when an order was placed. With variant_id, returns the price of that variant, from the history of its price overrides
+// @Tags 			 prices
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param			 time	  query   	string  	true		"RFC 3339 time"   Format(date-time)
+// @Param			 variant_id	  query   	string  	false		"variant ID"   Format(uuid)
+// @Success		 	 200 	  {object}  dto.PriceAtOutput
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/prices/at [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) GetPriceAt(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("time"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("time must be an RFC 3339 time"))
+		return
+	}
+	prices, err := h.PriceDB.FindAllByProduct(r.Context(), product.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list product prices", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	price := entity.PriceAt(prices, at)
+	if price == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	output := dto.PriceAtOutput{
+		ProductID: product.ID.String(),
+		At:        at,
+		PriceID:   price.ID.String(),
+		Amount:    price.Amount,
+	}
+	if variantID := r.URL.Query().Get("variant_id"); variantID != "" {
+		amount, ok := h.variantPriceAt(w, r, product, variantID, price.Amount, at)
+		if !ok {
+			return
+		}
+		output.VariantID = variantID
+		output.Amount = amount
+	}
+	writeJSON(w, http.StatusOK, output)
+}
+
+// variantPriceAt returns the price of a variant of product at a time, given
+// productPrice, the price of the product then. It answers 404 when the
+// variant does not exist, or did not exist yet at that time.
+func (h *ProductHandler) variantPriceAt(w http.ResponseWriter, r *http.Request, product *entity.Product, variantID string, productPrice float64, at time.Time) (float64, bool) {
+	_, err := h.VariantDB.FindByID(r.Context(), product.ID.String(), variantID)
+	var prices []entity.VariantPrice
+	if err == nil {
+		prices, err = h.VariantDB.FindPrices(r.Context(), variantID)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return 0, false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list variant prices", "variant_id", variantID, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return 0, false
+	}
+	price := entity.VariantPriceAt(prices, productPrice, at)
+	if price == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return 0, false
+	}
+	return *price, true
+}
+
+// SchedulePrice godoc
+// @Summary 		 Schedule a product price
+// @Description 	 Schedules a price for a product from a future time, until ends_at when set. The latest started price in effect wins, so a sale with an end falls back to the price it covered
+// @Tags 			 prices
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param 			 request  body 	    dto.SchedulePriceInput  true  "price request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Param 			 Prefer   header    string  false  "return=minimal to leave the price out of the response"
+// @Success		 	 201      {object}  entity.ProductPrice
+// @Header			 201      {string}  Location "path of the prices of the product"
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/prices [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	var input dto.SchedulePriceInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	price, err := entity.ScheduleProductPrice(product, input.Amount, input.StartsAt, input.EndsAt, input.Note)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.PriceDB.Create(r.Context(), price); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to schedule product price", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeCreated(w, r, "/products/"+product.ID.String()+"/prices", price)
+}
+
+// CancelPrice godoc
+// @Summary 		 Cancel a scheduled product price
+// @Description 	 Deletes a price that has not taken effect yet. Prices that did are kept as history
+// @Tags 			 prices
+// @Param			 id	  	  	path   	string  	true		"product ID"   Format(uuid)
+// @Param			 price_id	path   	string  	true		"price ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 409      {object}  Error
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/prices/{price_id} [delete]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) CancelPrice(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	err := h.PriceDB.Delete(r.Context(), product.ID.String(), chi.URLParam(r, "price_id"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, database.ErrPriceStarted):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		h.Logger.ErrorContext(r.Context(), "failed to cancel product price", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
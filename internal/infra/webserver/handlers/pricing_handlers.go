@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/request"
+	"gorm.io/gorm"
+)
+
+type PricingHandler struct {
+	ProductDB   database.ProductInterface
+	VariantDB   database.VariantInterface
+	PriceDB     database.PriceInterface
+	PromotionDB database.PromotionInterface
+	Logger      *slog.Logger
+}
+
+func NewPricingHandler(productDB database.ProductInterface, variantDB database.VariantInterface, priceDB database.PriceInterface, promotionDB database.PromotionInterface, logger *slog.Logger) *PricingHandler {
+	return &PricingHandler{
+		ProductDB:   productDB,
+		VariantDB:   variantDB,
+		PriceDB:     priceDB,
+		PromotionDB: promotionDB,
+		Logger:      logger,
+	}
+}
+
+// Quote godoc
+// @Summary 		 Quote prices
+// @Description 	 Prices each line at the price of the product, or of its variant when it overrides it, at the given time or now, from the price history of both, with the best promotion in effect for it. Promotions do not stack: every line gets the largest single discount, explained in applied_promotions. Only tenant owners and editors quote the products that are not published
+// @Tags 			 pricing
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.QuoteInput  true  "quote request"
+// @Success		 	 200      {object}  dto.QuoteOutput
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /pricing/quote [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *PricingHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	var input dto.QuoteInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	output, ok := h.quote(w, r, input)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, output)
+}
+
+// quote prices the lines of input as Quote documents. It answers 422 when a
+// line cannot be priced, and 500 when the prices cannot be loaded.
+func (h *PricingHandler) quote(w http.ResponseWriter, r *http.Request, input dto.QuoteInput) (*dto.QuoteOutput, bool) {
+	at := time.Now().UTC()
+	if input.At != nil {
+		at = input.At.UTC()
+	}
+	promotions, err := h.PromotionDB.FindActive(r.Context(), at)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list active promotions", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	output := &dto.QuoteOutput{At: at, Lines: make([]dto.QuoteLineOutput, 0, len(input.Lines))}
+	var fieldErrs []request.FieldError
+	for i, line := range input.Lines {
+		unitPrice, product, err := h.unitPrice(r, line, input.At)
+		var lineErr *quoteLineError
+		if errors.As(err, &lineErr) {
+			field := fmt.Sprintf("lines[%d].%s", i, lineErr.field)
+			fieldErrs = append(fieldErrs, request.FieldError{Field: field, Message: field + " " + lineErr.message})
+			continue
+		}
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to price quote line", "product_id", line.ProductID, "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return nil, false
+		}
+		quote := entity.QuoteLine(product, unitPrice, line.Quantity, promotions, at)
+		output.Lines = append(output.Lines, dto.QuoteLineOutput{ProductID: line.ProductID, VariantID: line.VariantID, LineQuote: quote})
+		output.Subtotal += quote.Subtotal
+		output.Discount += quote.Discount
+	}
+	if len(fieldErrs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, ValidationError{Message: "validation failed", Errors: fieldErrs})
+		return nil, false
+	}
+	output.Subtotal = math.Round(output.Subtotal*100) / 100
+	output.Discount = math.Round(output.Discount*100) / 100
+	output.Total = math.Round((output.Subtotal-output.Discount)*100) / 100
+	return output, true
+}
+
+// quoteLineError is a quote line naming something that cannot be priced.
+type quoteLineError struct {
+	field, message string
+}
+
+func (e *quoteLineError) Error() string {
+	return e.field + " " + e.message
+}
+
+// unitPrice finds the product of line and its unit price, from the price
+// histories of the product and the variant when at is set. Lines naming missing products or variants fail
+// with a quoteLineError.
+func (h *PricingHandler) unitPrice(r *http.Request, line dto.QuoteLineInput, at *time.Time) (float64, *entity.Product, error) {
+	product, err := h.ProductDB.FindByID(r.Context(), line.ProductID)
+	if err == nil && !product.IsPublished() && !canSeeDrafts(r) {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil, &quoteLineError{"product_id", "does not exist"}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if at != nil {
+		prices, err := h.PriceDB.FindAllByProduct(r.Context(), line.ProductID)
+		if err != nil {
+			return 0, nil, err
+		}
+		price := entity.PriceAt(prices, *at)
+		if price == nil {
+			return 0, nil, &quoteLineError{"product_id", "had no price at that time"}
+		}
+		product.Price = price.Amount
+	}
+	if line.VariantID == "" {
+		return product.Price, product, nil
+	}
+	variant, err := h.VariantDB.FindByID(r.Context(), line.ProductID, line.VariantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil, &quoteLineError{"variant_id", "does not exist"}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if at == nil {
+		return variant.EffectivePrice(product), product, nil
+	}
+	prices, err := h.VariantDB.FindPrices(r.Context(), line.VariantID)
+	if err != nil {
+		return 0, nil, err
+	}
+	price := entity.VariantPriceAt(prices, product.Price, *at)
+	if price == nil {
+		return 0, nil, &quoteLineError{"variant_id", "did not exist at that time"}
+	}
+	return *price, product, nil
+}
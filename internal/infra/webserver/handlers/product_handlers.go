@@ -1,43 +1,74 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
 	"github.com/ivandersr/products-api-go/internal/dto"
 	"github.com/ivandersr/products-api-go/internal/entity"
 	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/storage"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
 	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
 )
 
 type ProductHandler struct {
-	ProductDB database.ProductInterface
+	ProductDB  database.ProductInterface
+	VariantDB  database.VariantInterface
+	CategoryDB database.CategoryInterface
+	PriceDB    database.PriceInterface
+	MediaDB    database.MediaInterface
+	Blobs      storage.Store
+	Media      MediaSettings
+	Logger     *slog.Logger
 }
 
-func NewProductHandler(db database.ProductInterface) *ProductHandler {
+func NewProductHandler(db database.ProductInterface, variantDB database.VariantInterface, categoryDB database.CategoryInterface, priceDB database.PriceInterface, mediaDB database.MediaInterface, blobs storage.Store, media MediaSettings, logger *slog.Logger) *ProductHandler {
 	return &ProductHandler{
-		ProductDB: db,
+		ProductDB:  db,
+		VariantDB:  variantDB,
+		CategoryDB: categoryDB,
+		PriceDB:    priceDB,
+		MediaDB:    mediaDB,
+		Blobs:      blobs,
+		Media:      media,
+		Logger:     logger,
 	}
 }
 
 // Create product godoc
 // @Summary 		 Create product
-// @Description 	 Creates a new product
+// @Description 	 Creates a new product in the tenant of the token, as a draft unless status is published. Drafts are published at publish_at and published products archived at unpublish_at. SKU and barcode are unique within the tenant. Options are the axes, such as size and color, its variants combine
 // @Tags 			 products
 // @Accept 		 	 json
 // @Produce		 	 json
 // @Param 			 request  body 	   dto.CreateProductInput  true  "product request"
-// @Success		 	 201
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Param 			 Prefer   header   string  false  "return=minimal to leave the product out of the response"
+// @Success		 	 201      {object} entity.Product
+// @Header			 201      {string} Location "path of the created product"
+// @Failure			 400      {object} Error
+// @Failure			 403
+// @Failure			 409      {object} Error
+// @Failure			 413      {object} Error
+// @Failure			 415      {object} Error
+// @Failure			 422      {object} ValidationError
 // @Failure		 	 500      {object} Error
 // @Router 		 	 /products [post]
 // @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var product dto.CreateProductInput
-	err := json.NewDecoder(r.Body).Decode(&product)
-	if err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+	if !decodeJSON(w, r, &product) {
 		return
 	}
 	newProduct, err := entity.NewProduct(product.Name, product.Price)
@@ -45,56 +76,216 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	err = h.ProductDB.Create(newProduct)
+	if err := newProduct.Schedule(product.PublishAt, product.UnpublishAt); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch product.Status {
+	case "", entity.ProductStatusDraft:
+	case entity.ProductStatusPublished:
+		newProduct.Publish()
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	setProduct(newProduct, &product)
+	if err := newProduct.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !h.checkAttributes(w, r, newProduct) {
+		return
+	}
+	newProduct.OwnerID = middlewares.UserFromContext(r.Context()).ID
+	err = h.ProductDB.Create(r.Context(), newProduct)
+	if errors.Is(err, database.ErrSKUAlreadyExists) || errors.Is(err, database.ErrBarcodeAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create product", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
+	writeCreated(w, r, "/products/"+newProduct.ID.String(), newProduct)
+}
+
+// setProduct copies the optional data of input onto product.
+func setProduct(product *entity.Product, input *dto.CreateProductInput) {
+	product.SKU, product.Barcode, product.Options = input.SKU, input.Barcode, input.Options
+	product.CategoryID = nil
+	if input.CategoryID != "" {
+		categoryID, _ := entityPkg.ParseID(input.CategoryID)
+		product.CategoryID = &categoryID
+	}
+	product.Attributes, product.Tags = input.Attributes, input.Tags
 }
 
 // GetProduct godoc
 // @Summary 		 Find a product
-// @Description 	 Returns a product by its ID
+// @Description 	 Returns a product by its ID. Only tenant owners and editors find the products that are not published
 // @Tags 			 products
 // @Produce		 	 json
 // @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param			 embed	  query   	string   	false       "variants to include the variants of the product"
 // @Success		 	 200 	  {object}  entity.Product
 // @Failure			 401
 // @Failure			 404
 // @Failure		 	 500      {object}  Error
 // @Router 		 	 /products/{id} [get]
 // @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	product, err := h.ProductDB.FindByID(id)
+	product, err := h.ProductDB.FindByID(r.Context(), id)
+	if err == nil && !product.IsPublished() && !canSeeDrafts(r) {
+		err = gorm.ErrRecordNotFound
+	}
 	if err != nil {
+		h.Logger.WarnContext(r.Context(), "product not found", "product_id", id, "error", err)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
+	products := []entity.Product{*product}
+	if !h.embedVariants(w, r, products) || !h.embedMedia(w, r, products) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(product)
+	json.NewEncoder(w).Encode(products[0])
+}
+
+// GetProductBySKU godoc
+// @Summary 		 Find a product by SKU
+// @Description 	 Returns the product of the tenant with a SKU. When the SKU is a variant's, returns its product with that variant as the only one in variants. Only tenant owners and editors find the products that are not published
+// @Tags 			 products
+// @Produce		 	 json
+// @Param			 sku	  path   	string  	true		"stock keeping unit"
+// @Success		 	 200 	  {object}  entity.Product
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/by-sku/{sku} [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) GetProductBySKU(w http.ResponseWriter, r *http.Request) {
+	h.findProduct(w, r, h.findBySKU, chi.URLParam(r, "sku"))
+}
+
+// findBySKU finds the product with sku or, failing that, the product of the
+// variant with sku, with that variant as its only variant.
+func (h *ProductHandler) findBySKU(ctx context.Context, sku string) (*entity.Product, error) {
+	product, err := h.ProductDB.FindBySKU(ctx, sku)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return product, err
+	}
+	variant, err := h.VariantDB.FindBySKU(ctx, sku)
+	if err != nil {
+		return nil, err
+	}
+	product, err = h.ProductDB.FindByID(ctx, variant.ProductID.String())
+	if err != nil {
+		return nil, err
+	}
+	product.Variants = []entity.Variant{*variant}
+	return product, nil
+}
+
+// GetProductByBarcode godoc
+// @Summary 		 Find a product by barcode
+// @Description 	 Returns the product of the tenant with a GTIN-8, GTIN-12 (UPC), GTIN-13 (EAN) or GTIN-14 barcode. Only tenant owners and editors find the products that are not published
+// @Tags 			 products
+// @Produce		 	 json
+// @Param			 code	  path   	string  	true		"GTIN barcode"
+// @Success		 	 200 	  {object}  entity.Product
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/by-barcode/{code} [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) GetProductByBarcode(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	if !entity.IsValidGTIN(code) {
+		writeError(w, http.StatusBadRequest, entity.ErrInvalidBarcode)
+		return
+	}
+	h.findProduct(w, r, h.ProductDB.FindByBarcode, code)
+}
+
+// findProduct answers with the product find returns for value, hiding the
+// unpublished ones from callers that cannot edit them.
+func (h *ProductHandler) findProduct(w http.ResponseWriter, r *http.Request, find func(context.Context, string) (*entity.Product, error), value string) {
+	product, err := find(r.Context(), value)
+	if err == nil && !product.IsPublished() && !canSeeDrafts(r) {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find product", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+}
+
+// canSeeDrafts reports whether the member calling sees the products that are
+// not published.
+func canSeeDrafts(r *http.Request) bool {
+	membership := middlewares.MembershipFromContext(r.Context())
+	return membership != nil && membership.CanSeeDrafts()
+}
+
+// pathProduct loads the product of the URL, answering 404 when there is
+// none or the caller cannot see it.
+func (h *ProductHandler) pathProduct(w http.ResponseWriter, r *http.Request) (*entity.Product, bool) {
+	product, err := h.ProductDB.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err == nil && !product.IsPublished() && !canSeeDrafts(r) {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find product", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return product, true
 }
 
 // UpdateProduct godoc
 // @Summary			Updates a product
-// @Description		Updates a product data by its ID
+// @Description		Replaces the data and schedule of a product by its ID, validated as on creation. The status changes through the publish, archive and unarchive actions only, options cannot drop values variants use, and a new price is recorded in the price history as in effect from now
 // @Tags			products
 // @Accept			json
 // @Produce			json
 // @Param			id	  	  path   	string  	true		"product ID"   Format(uuid)
 // @Param 			request   body 	    dto.CreateProductInput	true 		   "product request"
-// @Success			200
+// @Param 			Prefer    header    string  false  "return=minimal to answer 204 without the product"
+// @Success			200		  {object}  entity.Product
+// @Success			204
+// @Failure			400		  {object}  Error
 // @Failure			401
+// @Failure			403
 // @Failure			404
+// @Failure			409		  {object}  Error
+// @Failure			413		  {object}  Error
+// @Failure			415		  {object}  Error
+// @Failure			422		  {object}  ValidationError
 // @Failure 		500		  {object}  Error
 // @Router		    /products/{id} [put]
 // @Security 		ApiKeyAuth
+// @Security 		MachineKeyAuth
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
@@ -106,36 +297,66 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	product, err := h.ProductDB.FindByID(id)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+	product, ok := h.pathProduct(w, r)
+	if !ok {
 		return
 	}
-	err = json.NewDecoder(r.Body).Decode(&product)
-	if err != nil {
+	var input dto.CreateProductInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	// The body replaces the product data. The status only changes through
+	// the publish, archive and unarchive actions.
+	product.Name, product.Price = input.Name, input.Price
+	setProduct(product, &input)
+	if err := product.Schedule(input.PublishAt, input.UnpublishAt); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	err = h.ProductDB.Update(product)
+	if err := product.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !h.checkAttributes(w, r, product) {
+		return
+	}
+	variants, err := h.VariantDB.FindAllByProduct(r.Context(), id)
 	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list product variants", "product_id", id, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	if err := product.CheckVariants(variants); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	err = h.ProductDB.Update(r.Context(), product)
+	if errors.Is(err, database.ErrSKUAlreadyExists) || errors.Is(err, database.ErrBarcodeAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to update product", "product_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeUpdated(w, r, product)
 }
 
 // DeleteProduct godoc
 // @Summary			Deletes a product
-// @Description		Deletes a product by its ID
+// @Description		Deletes a product by its ID, along with its variants, prices and media
 // @Tags			products
 // @Produce			json
 // @Param			id	  	  path   	string  	true		"product ID"   Format(uuid)
 // @Success			204
 // @Failure			401
+// @Failure			403
 // @Failure			404
 // @Failure 		500		  {object}  Error
 // @Router		    /products/{id} [delete]
 // @Security 		ApiKeyAuth
+// @Security 		MachineKeyAuth
 func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	_, err := entityPkg.ParseID(id)
@@ -147,32 +368,45 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	_, err = h.ProductDB.FindByID(id)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+	if _, ok := h.pathProduct(w, r); !ok {
 		return
 	}
-	err = h.ProductDB.Delete(id)
+	// The media records go with the product, so their blobs are only
+	// deleted once nothing refers to them anymore.
+	media, err := h.MediaDB.FindAllByProduct(r.Context(), id)
+	if err == nil {
+		err = h.ProductDB.Delete(r.Context(), id)
+	}
 	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete product", "product_id", id, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	for _, item := range media {
+		h.deleteBlobs(r, item.Keys())
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // GetProducts godoc
 // @Summary 		 List Porducts
-// @Description 	 Returns a list of all products with optional pagination
+// @Description 	 Returns the products of the tenant of the token with optional pagination. Only tenant owners and editors list the products that are not published
 // @Tags 			 products
 // @Accept 		 	 json
 // @Produce		 	 json
 // @Param			 page	  query   	string   	   false      "page number"
 // @Param			 limit	  query   	string   	   false      "items per page"
+// @Param			 status	  query   	string   	   false      "draft, published or archived"
+// @Param			 embed	  query   	string   	   false      "variants to include the variants of each product"
+// @Param			 category	  query   	string   	   false      "category ID"  Format(uuid)
+// @Param			 attr.{name}	  query   	string   	   false      "products whose attribute equals the value, such as attr.voltage=220; append .gt, .gte, .lt or .lte to compare numbers, such as attr.voltage.gte=110; at most 10"
 // @Success		 	 200 	  {object}  database.PaginatedResponse
+// @Failure			 400
 // @Failure			 401
 // @Failure		 	 500      {object}  Error
 // @Router 		 	 /products [get]
 // @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
 func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 	pageStr := r.URL.Query().Get("page")
 	limitStr := r.URL.Query().Get("limit")
@@ -186,12 +420,143 @@ func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		limit = 0
 	}
 	sort := r.URL.Query().Get("sort")
-	products, err := h.ProductDB.FindAll(page, limit, sort)
+	filter := database.ProductFilter{Status: r.URL.Query().Get("status")}
+	if !canSeeDrafts(r) {
+		filter.Status = entity.ProductStatusPublished
+	} else if filter.Status != "" && !entity.IsValidProductStatus(filter.Status) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	filter.CategoryID = r.URL.Query().Get("category")
+	filter.Attributes, err = attributeFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	products, err := h.ProductDB.FindAll(r.Context(), filter, page, limit, sort)
+	if errors.Is(err, database.ErrInvalidFilter) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list products", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if !h.embedVariants(w, r, products.Data) || !h.embedMedia(w, r, products.Data) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(products)
 }
+
+// PublishProduct godoc
+// @Summary			Publish a product
+// @Description		Publishes a draft right away
+// @Tags			products
+// @Produce			json
+// @Param			id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param			Idempotency-Key  header  string  false  "retries with the same key replay the first response"
+// @Success			200		  {object}  entity.Product
+// @Failure			401
+// @Failure			403
+// @Failure			404
+// @Failure			409		  {object}  Error
+// @Failure 		500		  {object}  Error
+// @Router		    /products/{id}/publish [post]
+// @Security 		ApiKeyAuth
+// @Security 		MachineKeyAuth
+func (h *ProductHandler) PublishProduct(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, (*entity.Product).Publish)
+}
+
+// ArchiveProduct godoc
+// @Summary			Archive a product
+// @Description		Hides a published product right away
+// @Tags			products
+// @Produce			json
+// @Param			id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param			Idempotency-Key  header  string  false  "retries with the same key replay the first response"
+// @Success			200		  {object}  entity.Product
+// @Failure			401
+// @Failure			403
+// @Failure			404
+// @Failure			409		  {object}  Error
+// @Failure 		500		  {object}  Error
+// @Router		    /products/{id}/archive [post]
+// @Security 		ApiKeyAuth
+// @Security 		MachineKeyAuth
+func (h *ProductHandler) ArchiveProduct(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, (*entity.Product).Archive)
+}
+
+// UnarchiveProduct godoc
+// @Summary			Unarchive a product
+// @Description		Turns an archived product back into a draft
+// @Tags			products
+// @Produce			json
+// @Param			id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param			Idempotency-Key  header  string  false  "retries with the same key replay the first response"
+// @Success			200		  {object}  entity.Product
+// @Failure			401
+// @Failure			403
+// @Failure			404
+// @Failure			409		  {object}  Error
+// @Failure 		500		  {object}  Error
+// @Router		    /products/{id}/unarchive [post]
+// @Security 		ApiKeyAuth
+// @Security 		MachineKeyAuth
+func (h *ProductHandler) UnarchiveProduct(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, (*entity.Product).Unarchive)
+}
+
+// transition applies a status change to the product of the URL, answering
+// 409 when its current status does not allow it.
+func (h *ProductHandler) transition(w http.ResponseWriter, r *http.Request, change func(*entity.Product) error) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	id := product.ID.String()
+	from := product.Status
+	if err := change(product); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err := h.ProductDB.Update(r.Context(), product); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		h.Logger.ErrorContext(r.Context(), "failed to change product status", "product_id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "product status changed", "product_id", id, "from", from, "to", product.Status)
+	writeJSON(w, http.StatusOK, product)
+}
+
+// maxAttributeFilters caps the attr parameters of a listing, as each adds a
+// subquery to the product query.
+const maxAttributeFilters = 10
+
+// attributeFilters reads the attr.{name} and attr.{name}.{op} parameters of
+// a listing, failing with ErrInvalidFilter beyond maxAttributeFilters.
+func attributeFilters(query url.Values) ([]database.AttributeFilter, error) {
+	var filters []database.AttributeFilter
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok {
+			continue
+		}
+		name, op, _ := strings.Cut(name, ".")
+		for _, value := range values {
+			filters = append(filters, database.AttributeFilter{Name: name, Op: op, Value: value})
+		}
+	}
+	if len(filters) > maxAttributeFilters {
+		return nil, fmt.Errorf("%w: at most %d attribute filters", database.ErrInvalidFilter, maxAttributeFilters)
+	}
+	return filters, nil
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/storage"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newProductHandlerTest routes the product writes as the server does, minus
+// authentication, for a caller with role in a new tenant. Without the
+// RequireTenantEditor guard the handlers are exercised on their own.
+func newProductHandlerTest(t *testing.T, guarded bool) (*ProductHandler, http.Handler, func(role string) context.Context) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.ProductAttribute{}, &entity.ProductPrice{}, &entity.Variant{}, &entity.VariantPrice{}, &entity.Media{}, &entity.Category{})
+	handler := NewProductHandler(database.NewProductDB(db), database.NewVariantDB(db), database.NewCategoryDB(db), database.NewPriceDB(db), database.NewMediaDB(db),
+		storage.NewLocalStore(t.TempDir()), MediaSettings{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	router := chi.NewRouter()
+	if guarded {
+		router.Use(middlewares.RequireTenantEditor)
+	}
+	router.Put("/products/{id}", handler.UpdateProduct)
+	router.Delete("/products/{id}", handler.DeleteProduct)
+	router.Post("/products/{id}/publish", handler.PublishProduct)
+	router.Post("/products/{id}/archive", handler.ArchiveProduct)
+	router.Post("/products/{id}/unarchive", handler.UnarchiveProduct)
+	tenantID := entityPkg.NewID()
+	as := func(role string) context.Context {
+		membership, _ := entity.NewMembership(tenantID, entityPkg.NewID(), role)
+		return middlewares.WithMembership(database.WithTenant(context.Background(), tenantID), membership)
+	}
+	return handler, router, as
+}
+
+func productRequest(ctx context.Context, method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestMembersCannotWriteDrafts(t *testing.T) {
+	handler, router, as := newProductHandlerTest(t, false)
+	member, editor := as(entity.TenantRoleMember), as(entity.TenantRoleEditor)
+	draft, _ := entity.NewProduct("Shirt", 20)
+	assert.Nil(t, handler.ProductDB.Create(editor, draft))
+	target := "/products/" + draft.ID.String()
+
+	// The handlers do not find the drafts the member does not see.
+	for _, req := range []*http.Request{
+		productRequest(member, http.MethodPut, target, `{"name":"Tee","price":10}`),
+		productRequest(member, http.MethodPost, target+"/publish", ""),
+		productRequest(member, http.MethodPost, target+"/archive", ""),
+		productRequest(member, http.MethodPost, target+"/unarchive", ""),
+		productRequest(member, http.MethodDelete, target, ""),
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code, req.Method+" "+req.URL.Path)
+		assert.NotContains(t, rec.Body.String(), "Shirt")
+	}
+	found, err := handler.ProductDB.FindByID(editor, draft.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "Shirt", found.Name)
+	assert.Equal(t, entity.ProductStatusDraft, found.Status)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, productRequest(editor, http.MethodPost, target+"/publish", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestOnlyEditorsWriteProducts(t *testing.T) {
+	handler, router, as := newProductHandlerTest(t, true)
+	member, owner := as(entity.TenantRoleMember), as(entity.TenantRoleOwner)
+	product, _ := entity.NewProduct("Shirt", 20)
+	product.Publish()
+	assert.Nil(t, handler.ProductDB.Create(owner, product))
+	target := "/products/" + product.ID.String()
+
+	// Members cannot change even the products they see.
+	for _, req := range []*http.Request{
+		productRequest(member, http.MethodPut, target, `{"name":"Tee","price":10}`),
+		productRequest(member, http.MethodPost, target+"/archive", ""),
+		productRequest(member, http.MethodDelete, target, ""),
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, req.Method+" "+req.URL.Path)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, productRequest(owner, http.MethodDelete, target, ""))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestAttributeFilters(t *testing.T) {
+	query := url.Values{
+		"attr.voltage":     {"220"},
+		"attr.weight.gte":  {"1", "2"},
+		"category":         {"shoes"},
+		"attributes.color": {"red"},
+	}
+	filters, err := attributeFilters(query)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []database.AttributeFilter{
+		{Name: "voltage", Value: "220"},
+		{Name: "weight", Op: "gte", Value: "1"},
+		{Name: "weight", Op: "gte", Value: "2"},
+	}, filters)
+
+	query = url.Values{}
+	for i := 0; i < maxAttributeFilters; i++ {
+		query.Add(fmt.Sprintf("attr.a%d", i), "1")
+	}
+	filters, err = attributeFilters(query)
+	assert.Nil(t, err)
+	assert.Len(t, filters, maxAttributeFilters)
+	query.Add("attr.a0", "2")
+	_, err = attributeFilters(query)
+	assert.ErrorIs(t, err, database.ErrInvalidFilter)
+}
+
+func TestUpdateProductValidatesTheBody(t *testing.T) {
+	handler, router, as := newProductHandlerTest(t, true)
+	editor := as(entity.TenantRoleEditor)
+	product, _ := entity.NewProduct("Shirt", 20)
+	product.SKU = "SHIRT"
+	assert.Nil(t, handler.ProductDB.Create(editor, product))
+	target := "/products/" + product.ID.String()
+
+	for _, body := range []string{
+		`{"name":"Tee","price":10,"category_id":"shirts"}`,
+		`{"name":"Tee","price":0}`,
+		`{"price":10}`,
+		`{"name":"Tee","price":10,"tags":["` + strings.Repeat(`a","`, 20) + `a"]}`,
+		`{"name":"Tee","price":10,"variants":[{"sku":"TEE-S"}]}`,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, productRequest(editor, http.MethodPut, target, body))
+		assert.Contains(t, []int{http.StatusBadRequest, http.StatusUnprocessableEntity}, rec.Code, body)
+	}
+	found, _ := handler.ProductDB.FindByID(editor, product.ID.String())
+	assert.Equal(t, "Shirt", found.Name)
+
+	// The body replaces the data, and the status is left to the actions.
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, productRequest(editor, http.MethodPut, target, `{"name":"Tee","price":10,"status":"published"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	found, _ = handler.ProductDB.FindByID(editor, product.ID.String())
+	assert.Equal(t, "Tee", found.Name)
+	assert.Equal(t, 10.0, found.Price)
+	assert.Empty(t, found.SKU)
+	assert.Equal(t, entity.ProductStatusDraft, found.Status)
+	assert.Equal(t, product.CreatedAt.UTC(), found.CreatedAt.UTC())
+}
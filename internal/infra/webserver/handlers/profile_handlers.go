@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+)
+
+var ErrInvalidCurrentPassword = errors.New("current password is invalid")
+
+// GetMe godoc
+// @Summary 		 Current user
+// @Description 	 Returns the authenticated user
+// @Tags 			 users
+// @Produce		 	 json
+// @Success		 	 200      {object} entity.User
+// @Failure			 401
+// @Router 		 	 /users/me [get]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, middlewares.UserFromContext(r.Context()))
+}
+
+// UpdateMe godoc
+// @Summary 		 Update current user
+// @Description 	 Updates the name or email of the authenticated user. A new email has to be verified again, and the verification and password reset links sent to the previous one stop working
+// @Tags 			 users
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.UpdateUserInput  true  "user request"
+// @Success		 	 200      {object} entity.User
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 409      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me [patch]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	var input dto.UpdateUserInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	previousEmail := user.Email
+	if input.Name != nil {
+		user.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Email != nil {
+		if err := user.ChangeEmail(*input.Email); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if err := user.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err := h.UserDB.Update(user)
+	if errors.Is(err, database.ErrEmailAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to update user", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if user.Email != previousEmail {
+		// Links sent to the previous address must not verify the new one.
+		for _, purpose := range []string{entity.TokenPurposeEmailVerification, entity.TokenPurposePasswordReset} {
+			if err := h.UserTokenDB.Revoke(user.ID.String(), purpose); err != nil {
+				h.Logger.ErrorContext(r.Context(), "failed to revoke email tokens", "user_id", user.ID.String(), "purpose", purpose, "error", err)
+			}
+		}
+		h.sendVerificationEmail(r.Context(), user)
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ChangePassword godoc
+// @Summary 		 Change password
+// @Description 	 Changes the password of the authenticated user and invalidates every other session
+// @Tags 			 users
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.ChangePasswordInput  true  "change password request"
+// @Success		 	 200      {object} dto.GetJWTOutput
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 403      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/password [post]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	var input dto.ChangePasswordInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if !user.ValidatePassword(input.CurrentPassword) {
+		h.Logger.WarnContext(r.Context(), "invalid current password", "user_id", user.ID.String())
+		writeError(w, http.StatusForbidden, ErrInvalidCurrentPassword)
+		return
+	}
+	if err := h.PasswordPolicy.Validate(input.NewPassword); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := user.ChangePassword(input.NewPassword); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.UserDB.Update(user); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to change password", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.UserTokenDB.Revoke(user.ID.String(), entity.TokenPurposePasswordReset); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to revoke password reset tokens", "user_id", user.ID.String(), "error", err)
+	}
+	// The token used for this request is now invalid, hand out a fresh one.
+	tokenString, err := h.issueAccessToken(r, user, middlewares.AMRFromRequest(r))
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.GetJWTOutput{AccessToken: tokenString})
+}
+
+// DeleteMe godoc
+// @Summary 		 Delete current user
+// @Description 	 Deletes the account of the authenticated user
+// @Tags 			 users
+// @Success		 	 204
+// @Failure			 401
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me [delete]
+// @Security 		 ApiKeyAuth
+func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	if err := h.UserDB.Delete(user.ID.String()); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete user", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"gorm.io/gorm"
+)
+
+type PromotionHandler struct {
+	PromotionDB database.PromotionInterface
+	Logger      *slog.Logger
+}
+
+func NewPromotionHandler(promotionDB database.PromotionInterface, logger *slog.Logger) *PromotionHandler {
+	return &PromotionHandler{
+		PromotionDB: promotionDB,
+		Logger:      logger,
+	}
+}
+
+// ListPromotions godoc
+// @Summary 		 List promotions
+// @Description 	 Returns the promotions of the tenant of the token, newest first
+// @Tags 			 promotions
+// @Produce		 	 json
+// @Success		 	 200 	  {object}  dto.PromotionsOutput
+// @Failure			 401
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /promotions [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *PromotionHandler) ListPromotions(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.PromotionDB.FindAll(r.Context())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list promotions", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.PromotionsOutput{Data: promotions})
+}
+
+// CreatePromotion godoc
+// @Summary 		 Create promotion
+// @Description 	 Creates a percentage_off, fixed_off, buy_x_get_y or tiered promotion for the products, categories or tags it targets, in effect between starts_at and ends_at when set
+// @Tags 			 promotions
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	    dto.PromotionInput  true  "promotion request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Param 			 Prefer   header    string  false  "return=minimal to leave the promotion out of the response"
+// @Success		 	 201      {object}  entity.Promotion
+// @Header			 201      {string}  Location "path of the created promotion"
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /promotions [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var input dto.PromotionInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	promotion := entity.NewPromotion(input.Name, input.Type)
+	if err := setPromotion(promotion, &input); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.PromotionDB.Create(r.Context(), promotion); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create promotion", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeCreated(w, r, "/promotions/"+promotion.ID.String(), promotion)
+}
+
+// GetPromotion godoc
+// @Summary 		 Find a promotion
+// @Description 	 Returns a promotion of the tenant of the token
+// @Tags 			 promotions
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"promotion ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.Promotion
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /promotions/{id} [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *PromotionHandler) GetPromotion(w http.ResponseWriter, r *http.Request) {
+	promotion, ok := h.pathPromotion(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, promotion)
+}
+
+// UpdatePromotion godoc
+// @Summary 		 Update promotion
+// @Description 	 Replaces the rule, targets and window of a promotion
+// @Tags 			 promotions
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"promotion ID"   Format(uuid)
+// @Param 			 request  body 	    dto.PromotionInput  true  "promotion request"
+// @Param 			 Prefer   header    string  false  "return=minimal to answer 204 without the promotion"
+// @Success		 	 200      {object}  entity.Promotion
+// @Success		 	 204
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 404
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /promotions/{id} [put]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *PromotionHandler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	promotion, ok := h.pathPromotion(w, r)
+	if !ok {
+		return
+	}
+	var input dto.PromotionInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	replaced := entity.NewPromotion(input.Name, input.Type)
+	replaced.ID, replaced.CreatedAt = promotion.ID, promotion.CreatedAt
+	if err := setPromotion(replaced, &input); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.PromotionDB.Update(r.Context(), replaced); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to update promotion", "promotion_id", promotion.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeUpdated(w, r, replaced)
+}
+
+// DeletePromotion godoc
+// @Summary 		 Delete promotion
+// @Description 	 Deletes a promotion, which stops applying to quotes
+// @Tags 			 promotions
+// @Param			 id	  	  path   	string  	true		"promotion ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /promotions/{id} [delete]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *PromotionHandler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	err := h.PromotionDB.Delete(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete promotion", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setPromotion(promotion *entity.Promotion, input *dto.PromotionInput) error {
+	promotion.Percent, promotion.Amount = input.Percent, input.Amount
+	promotion.BuyQuantity, promotion.GetQuantity, promotion.GetPercent = input.BuyQuantity, input.GetQuantity, input.GetPercent
+	promotion.Tiers = input.Tiers
+	promotion.ProductIDs, promotion.CategoryIDs, promotion.Tags = input.ProductIDs, input.CategoryIDs, input.Tags
+	if err := promotion.Schedule(input.StartsAt, input.EndsAt); err != nil {
+		return err
+	}
+	return promotion.Validate()
+}
+
+// pathPromotion finds the promotion of the URL, answering 404 when the
+// tenant of the token has none with that ID.
+func (h *PromotionHandler) pathPromotion(w http.ResponseWriter, r *http.Request) (*entity.Promotion, bool) {
+	promotion, err := h.PromotionDB.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find promotion", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return promotion, true
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// queryInt reads an integer query parameter, defaulting to 0.
+func queryInt(r *http.Request, name string) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/request"
+)
+
+// ValidationError lists every invalid field of a request body.
+type ValidationError struct {
+	Message string               `json:"message"`
+	Errors  []request.FieldError `json:"errors"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Message: err.Error()})
+}
+
+// writeCreated answers 201 with the location and representation of the
+// created resource, leaving the body out when the client prefers a minimal
+// return.
+func writeCreated(w http.ResponseWriter, r *http.Request, location string, v interface{}) {
+	w.Header().Set("Location", location)
+	if prefersMinimal(w, r) {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// writeUpdated answers 200 with the representation of the updated resource,
+// or 204 when the client prefers a minimal return.
+func writeUpdated(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if prefersMinimal(w, r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// prefersMinimal reports whether the Prefer header of r asks for
+// return=minimal (RFC 7240), in which case it acknowledges the preference
+// with Preference-Applied.
+func prefersMinimal(w http.ResponseWriter, r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(preference), "=")
+			if strings.EqualFold(strings.TrimSpace(name), "return") && strings.EqualFold(strings.Trim(strings.TrimSpace(value), `"`), "minimal") {
+				w.Header().Set("Preference-Applied", "return=minimal")
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeJSON decodes and validates the JSON body of r into v. Otherwise it
+// answers 415 for other content types, 413 for bodies over the limit, 422
+// with every broken rule for invalid values and 400 for anything else, and
+// returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := request.DecodeJSON(r, v)
+	if err == nil {
+		return true
+	}
+	var maxBytesErr *http.MaxBytesError
+	var validationErrs request.ValidationErrors
+	switch {
+	case errors.Is(err, request.ErrUnsupportedMediaType):
+		writeError(w, http.StatusUnsupportedMediaType, err)
+	case errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body is too large"))
+	case errors.As(err, &validationErrs):
+		writeJSON(w, http.StatusUnprocessableEntity, ValidationError{Message: "validation failed", Errors: validationErrs})
+	default:
+		writeError(w, http.StatusBadRequest, err)
+	}
+	return false
+}
+
+// writeCachedJSON writes v as a public response caches may keep for maxAge
+// and serve stale for as long while revalidating. It answers 304 when the
+// If-None-Match header of the request matches the ETag of v.
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, v interface{}, maxAge time.Duration) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	seconds := int(maxAge.Seconds())
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", seconds, seconds))
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(body, '\n'))
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testResource struct {
+	ID string `json:"id"`
+}
+
+func TestWriteCreated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeCreated(rec, httptest.NewRequest(http.MethodPost, "/products", nil), "/products/1", testResource{ID: "1"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/products/1", rec.Header().Get("Location"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"1"}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Preference-Applied"))
+
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set("Prefer", "return=minimal")
+	rec = httptest.NewRecorder()
+	writeCreated(rec, req, "/products/1", testResource{ID: "1"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/products/1", rec.Header().Get("Location"))
+	assert.Equal(t, "return=minimal", rec.Header().Get("Preference-Applied"))
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestWriteUpdated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeUpdated(rec, httptest.NewRequest(http.MethodPut, "/products/1", nil), testResource{ID: "1"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":"1"}`, rec.Body.String())
+
+	req := httptest.NewRequest(http.MethodPut, "/products/1", nil)
+	req.Header.Set("Prefer", "return=minimal")
+	rec = httptest.NewRecorder()
+	writeUpdated(rec, req, testResource{ID: "1"})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "return=minimal", rec.Header().Get("Preference-Applied"))
+	assert.Empty(t, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Location"))
+}
+
+func TestPrefersMinimal(t *testing.T) {
+	tests := []struct {
+		prefer []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{"return=minimal"}, true},
+		{[]string{`Return = "Minimal"`}, true},
+		{[]string{"respond-async, return=minimal"}, true},
+		{[]string{"respond-async", "return=minimal"}, true},
+		{[]string{"return=representation"}, false},
+		{[]string{"minimal"}, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		for _, prefer := range tt.prefer {
+			req.Header.Add("Prefer", prefer)
+		}
+		rec := httptest.NewRecorder()
+		assert.Equal(t, tt.want, prefersMinimal(rec, req), tt.prefer)
+		if tt.want {
+			assert.Equal(t, "return=minimal", rec.Header().Get("Preference-Applied"))
+		} else {
+			assert.Empty(t, rec.Header().Get("Preference-Applied"))
+		}
+	}
+}
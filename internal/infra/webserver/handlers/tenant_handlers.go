@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotAMember             = errors.New("not a member of the tenant")
+	ErrCannotSwitchImpersonal = errors.New("impersonation tokens cannot switch tenant")
+)
+
+type TenantHandler struct {
+	Users        *UserHandler
+	TenantDB     database.TenantInterface
+	MembershipDB database.MembershipInterface
+	Logger       *slog.Logger
+}
+
+func NewTenantHandler(users *UserHandler, tenantDB database.TenantInterface, membershipDB database.MembershipInterface, logger *slog.Logger) *TenantHandler {
+	return &TenantHandler{
+		Users:        users,
+		TenantDB:     tenantDB,
+		MembershipDB: membershipDB,
+		Logger:       logger,
+	}
+}
+
+// ListMyTenants godoc
+// @Summary 		 List my tenants
+// @Description 	 Lists the tenants the authenticated user is a member of, with the role in each
+// @Tags 			 tenants
+// @Produce		 	 json
+// @Success		 	 200      {array}  dto.TenantMembershipOutput
+// @Failure			 401
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/tenants [get]
+// @Security 		 ApiKeyAuth
+func (h *TenantHandler) ListMyTenants(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	memberships, err := h.MembershipDB.FindAllByUser(user.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list tenant memberships", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	output := []dto.TenantMembershipOutput{}
+	for _, membership := range memberships {
+		tenant, err := h.TenantDB.FindByID(membership.TenantID.String())
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to load tenant", "tenant_id", membership.TenantID.String(), "error", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		output = append(output, dto.TenantMembershipOutput{Tenant: *tenant, Role: membership.Role})
+	}
+	writeJSON(w, http.StatusOK, output)
+}
+
+// SwitchTenant godoc
+// @Summary 		 Switch tenant
+// @Description 	 Issues an access token for another tenant of the authenticated user. Users of a single tenant get it in every token already
+// @Tags 			 tenants
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.SwitchTenantInput  true  "tenant request"
+// @Success		 	 200      {object} dto.GetJWTOutput
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 403      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /users/me/tenant [post]
+// @Security 		 ApiKeyAuth
+func (h *TenantHandler) SwitchTenant(w http.ResponseWriter, r *http.Request) {
+	user := middlewares.UserFromContext(r.Context())
+	if middlewares.ImpersonatorFromContext(r.Context()) != "" {
+		writeError(w, http.StatusForbidden, ErrCannotSwitchImpersonal)
+		return
+	}
+	var input dto.SwitchTenantInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if _, err := entityPkg.ParseID(input.TenantID); err != nil {
+		writeError(w, http.StatusBadRequest, entity.ErrInvalidID)
+		return
+	}
+	if _, err := h.MembershipDB.Find(input.TenantID, user.ID.String()); err != nil {
+		writeError(w, http.StatusForbidden, ErrNotAMember)
+		return
+	}
+	expiresIn := r.Context().Value("jwtExpiresIn").(int)
+	tokenString, err := h.Users.encodeToken(r, user, map[string]interface{}{
+		"amr":                   middlewares.AMRFromRequest(r),
+		middlewares.TenantClaim: input.TenantID,
+	}, time.Duration(expiresIn)*time.Second)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", user.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.GetJWTOutput{AccessToken: tokenString})
+}
+
+// ListMembers godoc
+// @Summary 		 List tenant members
+// @Description 	 Lists the members of the tenant of the token
+// @Tags 			 tenants
+// @Produce		 	 json
+// @Success		 	 200      {array}  dto.MemberOutput
+// @Failure			 401
+// @Failure			 403
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /tenant/members [get]
+// @Security 		 ApiKeyAuth
+func (h *TenantHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := database.TenantFromContext(r.Context())
+	memberships, err := h.MembershipDB.FindAllByTenant(tenantID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list tenant members", "tenant_id", tenantID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	output := []dto.MemberOutput{}
+	for _, membership := range memberships {
+		user, err := h.Users.UserDB.FindByID(membership.UserID.String())
+		if err != nil {
+			continue
+		}
+		output = append(output, memberOutput(&membership, user))
+	}
+	writeJSON(w, http.StatusOK, output)
+}
+
+// AddMember godoc
+// @Summary 		 Add a tenant member
+// @Description 	 Adds a registered user to the tenant of the token as an owner, an editor, or a member, the default. Owners and editors also see the products that are not published, and are the only ones who change products. Only owners can manage members
+// @Tags 			 tenants
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param 			 request  body 	   dto.AddMemberInput  true  "member request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Success		 	 201      {object} dto.MemberOutput
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 409      {object} Error
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /tenant/members [post]
+// @Security 		 ApiKeyAuth
+func (h *TenantHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := database.TenantFromContext(r.Context())
+	var input dto.AddMemberInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.Role == "" {
+		input.Role = entity.TenantRoleMember
+	}
+	user, err := h.Users.UserDB.FindByEmail(input.Email)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	membership, err := entity.NewMembership(tenantID, user.ID, input.Role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err = h.MembershipDB.Create(membership)
+	if errors.Is(err, database.ErrAlreadyMember) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to add tenant member", "tenant_id", tenantID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "tenant member added", "tenant_id", tenantID.String(), "user_id", user.ID.String(), "role", membership.Role)
+	writeJSON(w, http.StatusCreated, memberOutput(membership, user))
+}
+
+// RemoveMember godoc
+// @Summary 		 Remove a tenant member
+// @Description 	 Removes a user from the tenant of the token, revoking its access to the tenant's products right away. Owners cannot remove themselves
+// @Tags 			 tenants
+// @Param			 user_id	path     string  true  "user ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 400      {object} Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /tenant/members/{user_id} [delete]
+// @Security 		 ApiKeyAuth
+func (h *TenantHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := database.TenantFromContext(r.Context())
+	userID := chi.URLParam(r, "user_id")
+	if _, err := entityPkg.ParseID(userID); err != nil {
+		writeError(w, http.StatusBadRequest, entity.ErrInvalidID)
+		return
+	}
+	if userID == middlewares.UserFromContext(r.Context()).ID.String() {
+		writeError(w, http.StatusBadRequest, ErrCannotTargetSelf)
+		return
+	}
+	err := h.MembershipDB.Delete(tenantID.String(), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to remove tenant member", "tenant_id", tenantID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Logger.InfoContext(r.Context(), "tenant member removed", "tenant_id", tenantID.String(), "user_id", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func memberOutput(membership *entity.Membership, user *entity.User) dto.MemberOutput {
+	return dto.MemberOutput{
+		UserID:    user.ID.String(),
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      membership.Role,
+		CreatedAt: membership.CreatedAt,
+	}
+}
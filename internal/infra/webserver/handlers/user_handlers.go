@@ -2,64 +2,149 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/jwtauth"
 	"github.com/ivandersr/products-api-go/internal/dto"
 	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/auth"
 	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/mail"
+	"github.com/ivandersr/products-api-go/internal/infra/ratelimit"
+	"github.com/ivandersr/products-api-go/internal/infra/webserver/middlewares"
+	"github.com/ivandersr/products-api-go/pkg/signedtoken"
 )
 
 type UserHandler struct {
-	UserDB database.UserInterface
+	UserDB         database.UserInterface
+	UserTokenDB    database.UserTokenInterface
+	RecoveryCodeDB database.RecoveryCodeInterface
+	MFAPolicyDB    database.MFAPolicyInterface
+	MembershipDB   database.MembershipInterface
+	Signer         *signedtoken.Signer
+	Mailer         mail.Mailer
+	PasswordPolicy *entity.PasswordPolicy
+	Lockout        *ratelimit.Lockout
+	Settings       UserSettings
+	Logger         *slog.Logger
 }
 
 type Error struct {
 	Message string `json:"message"`
 }
 
-func NewUserHandler(db database.UserInterface) *UserHandler {
+func NewUserHandler(
+	db database.UserInterface,
+	tokenDB database.UserTokenInterface,
+	recoveryCodeDB database.RecoveryCodeInterface,
+	mfaPolicyDB database.MFAPolicyInterface,
+	membershipDB database.MembershipInterface,
+	signer *signedtoken.Signer,
+	mailer mail.Mailer,
+	passwordPolicy *entity.PasswordPolicy,
+	lockout *ratelimit.Lockout,
+	settings UserSettings,
+	logger *slog.Logger,
+) *UserHandler {
 	return &UserHandler{
-		UserDB: db,
+		UserDB:         db,
+		UserTokenDB:    tokenDB,
+		RecoveryCodeDB: recoveryCodeDB,
+		MFAPolicyDB:    mfaPolicyDB,
+		MembershipDB:   membershipDB,
+		Signer:         signer,
+		Mailer:         mailer,
+		PasswordPolicy: passwordPolicy,
+		Lockout:        lockout,
+		Settings:       settings,
+		Logger:         logger,
 	}
 }
 
 // GetJWT godoc
 // @Summary 		 Generate JWT
-// @Description 	 Generates a token to authenticate for requests
+// @Description 	 Generates a token to authenticate for requests. Users with two-factor authentication get an mfa_token to exchange at /users/token/mfa instead
 // @Tags 			 users
 // @Accept 		 	 json
 // @Produce		 	 json
 // @Param 			 request  body 	   dto.GetJWTInput  true  "user request"
 // @Success		 	 200	  {object} dto.GetJWTOutput
 // @Failure		 	 500      {object} Error
+// @Failure 		 400      {object} Error
 // @Failure 		 401
+// @Failure 		 403      {object} Error
+// @Failure 		 415      {object} Error
+// @Failure 		 422      {object} ValidationError
+// @Failure 		 429
 // @Router 		 	 /users/token [post]
 func (h *UserHandler) GetJWT(w http.ResponseWriter, r *http.Request) {
-	jwt := r.Context().Value("jwt").(*jwtauth.JWTAuth)
-	jwtExpiresIn := r.Context().Value("jwtExpiresIn").(int)
 	var user dto.GetJWTInput
-	err := json.NewDecoder(r.Body).Decode(&user)
+	if !decodeJSON(w, r, &user) {
+		return
+	}
+	lockoutKey := h.loginLockoutKey(r, user.Email)
+	lockedFor, err := h.Lockout.Locked(r.Context(), lockoutKey)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		h.Logger.ErrorContext(r.Context(), "failed to check login lockout", "error", err)
+	}
+	if lockedFor > 0 {
+		h.Logger.WarnContext(r.Context(), "login attempt on locked account", "email", user.Email)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
 		return
 	}
 	foundUser, err := h.UserDB.FindByEmail(user.Email)
 	if err != nil {
+		h.loginFailed(r, lockoutKey)
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	if !foundUser.ValidatePassword(user.Password) {
+		h.Logger.WarnContext(r.Context(), "invalid password", "user_id", foundUser.ID.String())
+		h.loginFailed(r, lockoutKey)
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
-	_, tokenString, _ := jwt.Encode(map[string]interface{}{
-		"sub": foundUser.ID.String(),
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Second * time.Duration(jwtExpiresIn)).Unix(),
-	})
+	if err := h.Lockout.Reset(r.Context(), lockoutKey); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to reset login lockout", "error", err)
+	}
+	if err := h.Lockout.Trust(r.Context(), loginAccount(user.Email), middlewares.KeyByIP(r)); err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to trust login client", "error", err)
+	}
+	if foundUser.PasswordRehashed() {
+		// Logging in still succeeds, the hash is upgraded on the next try.
+		if err := h.UserDB.Update(foundUser); err != nil {
+			h.Logger.ErrorContext(r.Context(), "failed to save rehashed password", "user_id", foundUser.ID.String(), "error", err)
+		} else {
+			h.Logger.InfoContext(r.Context(), "password rehashed", "user_id", foundUser.ID.String())
+		}
+	}
+	if foundUser.IsDisabled() {
+		h.Logger.WarnContext(r.Context(), "login attempt on disabled account", "user_id", foundUser.ID.String())
+		writeError(w, http.StatusForbidden, ErrAccountDisabled)
+		return
+	}
+	if h.Settings.RequireEmailVerification && !foundUser.IsEmailVerified() {
+		writeError(w, http.StatusForbidden, ErrEmailNotVerified)
+		return
+	}
+	if foundUser.IsMFAEnabled() {
+		h.startMFAChallenge(w, r, foundUser)
+		return
+	}
+	tokenString, err := h.issueAccessToken(r, foundUser, []string{middlewares.AMRPassword})
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to encode jwt", "user_id", foundUser.ID.String(), "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
 	accessToken := dto.GetJWTOutput{AccessToken: tokenString}
 	w.Header().Set("Content-Type", "application/json")
@@ -67,6 +152,92 @@ func (h *UserHandler) GetJWT(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(accessToken)
 }
 
+// issueAccessToken signs a JWT for user with the settings carried by the
+// request context, recording the authentication methods used in amr.
+func (h *UserHandler) issueAccessToken(r *http.Request, user *entity.User, amr []string) (string, error) {
+	jwtExpiresIn := r.Context().Value("jwtExpiresIn").(int)
+	return h.encodeToken(r, user, map[string]interface{}{"amr": amr}, time.Second*time.Duration(jwtExpiresIn))
+}
+
+// encodeToken signs a JWT for user valid for expiresIn, adding extra claims.
+// Unless extra sets it, the tenant claim comes from defaultTenant.
+func (h *UserHandler) encodeToken(r *http.Request, user *entity.User, extra map[string]interface{}, expiresIn time.Duration) (string, error) {
+	jwt := r.Context().Value("jwt").(*auth.JWTAuth)
+	claims := map[string]interface{}{
+		"sub":  user.ID.String(),
+		"ver":  user.TokenVersion,
+		"role": user.Role,
+		"iat":  time.Now().Unix(),
+		"exp":  time.Now().Add(expiresIn).Unix(),
+	}
+	if tenantID := h.defaultTenant(r, user); tenantID != "" {
+		claims[middlewares.TenantClaim] = tenantID
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	_, tokenString, err := jwt.Encode(claims)
+	return tokenString, err
+}
+
+// defaultTenant returns the tenant a new token of user is issued for: the
+// tenant of the token authenticating the request when it is the user's own
+// and the user is still a member, or else the user's only tenant. Users of
+// several tenants pick one with SwitchTenant.
+func (h *UserHandler) defaultTenant(r *http.Request, user *entity.User) string {
+	if token, _, err := jwtauth.FromContext(r.Context()); err == nil && token != nil && token.Subject() == user.ID.String() {
+		if tenantID := middlewares.TenantClaimFromRequest(r); tenantID != "" {
+			if _, err := h.MembershipDB.Find(tenantID, user.ID.String()); err == nil {
+				return tenantID
+			}
+		}
+	}
+	memberships, err := h.MembershipDB.FindAllByUser(user.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list tenant memberships", "user_id", user.ID.String(), "error", err)
+		return ""
+	}
+	if len(memberships) != 1 {
+		return ""
+	}
+	return memberships[0].TenantID.String()
+}
+
+// loginAccount is the account the login attempts on email count towards.
+// Unknown emails are counted like known ones so that the lockout does not
+// tell whether an account exists.
+func loginAccount(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginLockoutKey identifies the login attempts the request counts towards:
+// the account, from wherever they come. Clients that logged in to the account
+// before are exempt from its lock so that failing logins from elsewhere does
+// not lock the owner out, and get a lockout of their own on it instead.
+func (h *UserHandler) loginLockoutKey(r *http.Request, email string) string {
+	account, client := loginAccount(email), middlewares.KeyByIP(r)
+	trusted, err := h.Lockout.Trusted(r.Context(), account, client)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to check trusted login client", "error", err)
+	}
+	if trusted {
+		return account + ":" + client
+	}
+	return account
+}
+
+// loginFailed counts a failed login towards the lockout under key.
+func (h *UserHandler) loginFailed(r *http.Request, key string) {
+	lockedFor, err := h.Lockout.Fail(r.Context(), key)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to record login failure", "error", err)
+		return
+	}
+	if lockedFor > 0 {
+		h.Logger.WarnContext(r.Context(), "login locked after repeated failures", "key", key, "locked_for", lockedFor)
+	}
+}
+
 // Create user godoc
 // @Summary 		 Create user
 // @Description 	 Creates authenticatable user
@@ -74,14 +245,24 @@ func (h *UserHandler) GetJWT(w http.ResponseWriter, r *http.Request) {
 // @Accept 		 	 json
 // @Produce		 	 json
 // @Param 			 request  body 	   dto.CreateUserInput  true  "user request"
-// @Success		 	 201
+// @Param 			 Prefer   header   string  false  "return=minimal to leave the user out of the response"
+// @Success		 	 201      {object} entity.User
+// @Header			 201      {string} Location "path the user reads their profile from once logged in"
+// @Failure		 	 400      {object} Error
+// @Failure		 	 409      {object} Error
+// @Failure		 	 415      {object} Error
+// @Failure		 	 422      {object} ValidationError
+// @Failure		 	 429
 // @Failure		 	 500      {object} Error
 // @Router 		 	 /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user dto.CreateUserInput
-	err := json.NewDecoder(r.Body).Decode(&user)
+	if !decodeJSON(w, r, &user) {
+		return
+	}
+	err := h.PasswordPolicy.Validate(user.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.WriteHeader(http.StatusBadRequest)
 		error := Error{Message: err.Error()}
 		json.NewEncoder(w).Encode(error)
 		return
@@ -94,11 +275,20 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	err = h.UserDB.Create(newUser)
+	if errors.Is(err, database.ErrEmailAlreadyExists) {
+		w.WriteHeader(http.StatusConflict)
+		error := Error{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
 	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to create user", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		error := Error{Message: err.Error()}
 		json.NewEncoder(w).Encode(error)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
+	h.sendVerificationEmail(r.Context(), newUser)
+	// Users only reach their own profile, so that is where the account lives.
+	writeCreated(w, r, "/users/me", newUser)
 }
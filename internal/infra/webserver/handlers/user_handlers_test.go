@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/auth"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/ivandersr/products-api-go/internal/infra/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLoginLockoutIsPerAccount(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.Membership{})
+	lockout := ratelimit.NewLockout(ratelimit.NewMemoryStore(), 3, time.Hour, time.Minute, time.Hour, 24*time.Hour)
+	handler := NewUserHandler(database.NewUserDB(db), nil, nil, nil, database.NewMembershipDB(db), nil, nil, nil, lockout,
+		UserSettings{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	user, _ := entity.NewUser("John", "john@example.com", "Sup3r-secret!pass")
+	assert.Nil(t, handler.UserDB.Create(user))
+	login := func(ip, password string) int {
+		req := httptest.NewRequest(http.MethodPost, "/users/token", strings.NewReader(`{"email":"John@example.com","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		ctx := context.WithValue(req.Context(), "jwt", auth.NewHMAC([]byte("secret"), "", ""))
+		ctx = context.WithValue(ctx, "jwtExpiresIn", 300)
+		rec := httptest.NewRecorder()
+		handler.GetJWT(rec, req.WithContext(ctx))
+		return rec.Code
+	}
+
+	// The owner logged in from home before.
+	assert.Equal(t, http.StatusOK, login("10.0.0.1", "Sup3r-secret!pass"))
+
+	// Guesses from many clients add up on the account.
+	assert.Equal(t, http.StatusUnauthorized, login("10.0.1.1", "guess"))
+	assert.Equal(t, http.StatusUnauthorized, login("10.0.1.2", "guess"))
+	assert.Equal(t, http.StatusUnauthorized, login("10.0.1.3", "guess"))
+	assert.Equal(t, http.StatusTooManyRequests, login("10.0.1.4", "guess"))
+	assert.Equal(t, http.StatusTooManyRequests, login("10.0.1.5", "Sup3r-secret!pass"))
+
+	// The owner still logs in from home, where failures lock only that client.
+	assert.Equal(t, http.StatusOK, login("10.0.0.1", "Sup3r-secret!pass"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("10.0.0.1", "typo"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, login("10.0.0.1", "Sup3r-secret!pass"))
+}
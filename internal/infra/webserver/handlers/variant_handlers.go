@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"gorm.io/gorm"
+)
+
+// ListVariants godoc
+// @Summary 		 List product variants
+// @Description 	 Returns the variants of a product. Only tenant owners and editors find the variants of the products that are not published
+// @Tags 			 variants
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Success		 	 200 	  {object}  dto.VariantsOutput
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/variants [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) ListVariants(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	variants, err := h.VariantDB.FindAllByProduct(r.Context(), product.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list product variants", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dto.VariantsOutput{Data: variants})
+}
+
+// CreateVariant godoc
+// @Summary 		 Create a product variant
+// @Description 	 Adds the variant with one value of every option of the product. Its price overrides the product price when set
+// @Tags 			 variants
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param 			 request  body 	    dto.VariantInput  true  "variant request"
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key and body replay the first response"
+// @Param 			 Prefer   header    string  false  "return=minimal to leave the variant out of the response"
+// @Success		 	 201      {object}  entity.Variant
+// @Header			 201      {string}  Location "path of the created variant"
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 409      {object}  Error
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/variants [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	var input dto.VariantInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	variant, err := entity.NewVariant(product, input.Options)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	variant.SKU, variant.Price, variant.Stock = input.SKU, input.Price, input.Stock
+	if !h.saveVariant(w, r, product, variant, h.VariantDB.Create) {
+		return
+	}
+	writeCreated(w, r, "/products/"+product.ID.String()+"/variants/"+variant.ID.String(), variant)
+}
+
+// GenerateVariants godoc
+// @Summary 		 Generate product variants
+// @Description 	 Creates a variant for every combination of option values the product has no variant for yet. Variants of a product with a SKU get the product SKU suffixed with their values
+// @Tags 			 variants
+// @Produce		 	 json
+// @Param			 id	  	  path   	string  	true		"product ID"   Format(uuid)
+// @Param 			 Idempotency-Key  header  string  false  "retries with the same key replay the first response"
+// @Success		 	 201      {object}  dto.VariantsOutput
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 409      {object}  Error
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/variants/generate [post]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) GenerateVariants(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	if len(product.Options) == 0 {
+		writeError(w, http.StatusBadRequest, entity.ErrProductHasNoOptions)
+		return
+	}
+	existing, err := h.VariantDB.FindAllByProduct(r.Context(), product.ID.String())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to list product variants", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	generated := product.GenerateVariants(existing)
+	err = h.VariantDB.CreateMany(r.Context(), generated)
+	if errors.Is(err, database.ErrVariantAlreadyExists) || errors.Is(err, database.ErrSKUAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to generate product variants", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	output := dto.VariantsOutput{Data: []entity.Variant{}}
+	for _, variant := range generated {
+		output.Data = append(output.Data, *variant)
+	}
+	writeJSON(w, http.StatusCreated, output)
+}
+
+// GetVariant godoc
+// @Summary 		 Find a product variant
+// @Description 	 Returns a variant of a product. Only tenant owners and editors find the variants of the products that are not published
+// @Tags 			 variants
+// @Produce		 	 json
+// @Param			 id	  	  		path   	string  	true		"product ID"   Format(uuid)
+// @Param			 variant_id	  	path   	string  	true		"variant ID"   Format(uuid)
+// @Success		 	 200 	  {object}  entity.Variant
+// @Failure			 401
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/variants/{variant_id} [get]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) GetVariant(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	variant, ok := h.findVariant(w, r, product)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, variant)
+}
+
+// UpdateVariant godoc
+// @Summary 		 Update a product variant
+// @Description 	 Replaces the options, SKU, price and stock of a variant
+// @Tags 			 variants
+// @Accept 		 	 json
+// @Produce		 	 json
+// @Param			 id	  	  		path   	string  	true		"product ID"   Format(uuid)
+// @Param			 variant_id	  	path   	string  	true		"variant ID"   Format(uuid)
+// @Param 			 request  body 	    dto.VariantInput  true  "variant request"
+// @Param 			 Prefer   header    string  false  "return=minimal to answer 204 without the variant"
+// @Success		 	 200      {object}  entity.Variant
+// @Success		 	 204
+// @Failure			 400      {object}  Error
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure			 409      {object}  Error
+// @Failure			 413      {object}  Error
+// @Failure			 415      {object}  Error
+// @Failure			 422      {object}  ValidationError
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/variants/{variant_id} [put]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	variant, ok := h.findVariant(w, r, product)
+	if !ok {
+		return
+	}
+	var input dto.VariantInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if err := variant.SetOptions(product, input.Options); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	variant.SKU, variant.Price, variant.Stock = input.SKU, input.Price, input.Stock
+	if !h.saveVariant(w, r, product, variant, h.VariantDB.Update) {
+		return
+	}
+	writeUpdated(w, r, variant)
+}
+
+// DeleteVariant godoc
+// @Summary 		 Delete a product variant
+// @Tags 			 variants
+// @Param			 id	  	  		path   	string  	true		"product ID"   Format(uuid)
+// @Param			 variant_id	  	path   	string  	true		"variant ID"   Format(uuid)
+// @Success		 	 204
+// @Failure			 401
+// @Failure			 403
+// @Failure			 404
+// @Failure		 	 500      {object}  Error
+// @Router 		 	 /products/{id}/variants/{variant_id} [delete]
+// @Security 		 ApiKeyAuth
+// @Security 		 MachineKeyAuth
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.pathProduct(w, r)
+	if !ok {
+		return
+	}
+	err := h.VariantDB.Delete(r.Context(), product.ID.String(), chi.URLParam(r, "variant_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to delete product variant", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ProductHandler) findVariant(w http.ResponseWriter, r *http.Request, product *entity.Product) (*entity.Variant, bool) {
+	variant, err := h.VariantDB.FindByID(r.Context(), product.ID.String(), chi.URLParam(r, "variant_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to find product variant", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return variant, true
+}
+
+// saveVariant validates variant and stores it with save, answering 400 for
+// invalid variants and 409 for taken options or SKUs.
+func (h *ProductHandler) saveVariant(w http.ResponseWriter, r *http.Request, product *entity.Product, variant *entity.Variant, save func(context.Context, *entity.Variant) error) bool {
+	if err := variant.Validate(product); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	err := save(r.Context(), variant)
+	if errors.Is(err, database.ErrVariantAlreadyExists) || errors.Is(err, database.ErrSKUAlreadyExists) {
+		writeError(w, http.StatusConflict, err)
+		return false
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to save product variant", "product_id", product.ID.String(), "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	return true
+}
+
+// embedVariants loads the variants of products into them when the embed
+// query parameter asks for them.
+func (h *ProductHandler) embedVariants(w http.ResponseWriter, r *http.Request, products []entity.Product) bool {
+	if r.URL.Query().Get("embed") != "variants" || len(products) == 0 {
+		return true
+	}
+	ids := make([]string, len(products))
+	byProduct := map[string][]entity.Variant{}
+	for i, product := range products {
+		ids[i] = product.ID.String()
+	}
+	variants, err := h.VariantDB.FindAllByProducts(r.Context(), ids)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to embed product variants", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	for _, variant := range variants {
+		byProduct[variant.ProductID.String()] = append(byProduct[variant.ProductID.String()], variant)
+	}
+	for i := range products {
+		products[i].Variants = byProduct[products[i].ID.String()]
+	}
+	return true
+}
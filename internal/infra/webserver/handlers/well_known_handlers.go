@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/auth"
+)
+
+type WellKnownHandler struct {
+	TokenAuth *auth.JWTAuth
+	Logger    *slog.Logger
+}
+
+func NewWellKnownHandler(tokenAuth *auth.JWTAuth, logger *slog.Logger) *WellKnownHandler {
+	return &WellKnownHandler{
+		TokenAuth: tokenAuth,
+		Logger:    logger,
+	}
+}
+
+// JWKS godoc
+// @Summary 		 JSON Web Key Set
+// @Description 	 Returns the public keys verifying the access tokens issued by the API
+// @Tags 			 auth
+// @Produce		 	 json
+// @Success		 	 200
+// @Failure		 	 500      {object} Error
+// @Router 		 	 /.well-known/jwks.json [get]
+func (h *WellKnownHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := h.TokenAuth.JWKS()
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "failed to build jwks", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, set)
+}
+
+// OpenIDConfiguration godoc
+// @Summary 		 OpenID Connect discovery
+// @Description 	 Describes the OAuth2 authorization server and its endpoints (OpenID Connect Discovery 1.0)
+// @Tags 			 auth
+// @Produce		 	 json
+// @Success		 	 200      {object} dto.OpenIDConfigurationOutput
+// @Router 		 	 /.well-known/openid-configuration [get]
+func (h *WellKnownHandler) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	issuer := strings.TrimSuffix(h.TokenAuth.Issuer, "/")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, dto.OpenIDConfigurationOutput{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		UserInfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   entity.OAuthScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{entity.GrantAuthorizationCode, entity.GrantClientCredentials, entity.GrantRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{h.TokenAuth.Algorithm().String()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{entity.PKCEMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "name", "email", "email_verified"},
+	})
+}
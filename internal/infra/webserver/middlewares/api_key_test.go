@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.APIKey{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	db.Create(user)
+	keyDB := database.NewAPIKeyDB(db)
+	readKey, readPlain, _ := entity.NewAPIKey(user.ID, "reader", []string{entity.ScopeProductsRead}, nil)
+	keyDB.Create(readKey)
+	expiresAt := time.Now().Add(time.Hour)
+	expiredKey, expiredPlain, _ := entity.NewAPIKey(user.ID, "expired", nil, &expiresAt)
+	past := time.Now().Add(-time.Minute)
+	expiredKey.ExpiresAt = &past
+	keyDB.Create(expiredKey)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	var loaded *entity.User
+	chain := func(scope string) http.Handler {
+		return jwtauth.Verifier(tokenAuth)(APIKeyAuth(keyDB, logger)(jwtauth.Authenticator(Session(database.NewUserDB(db))(RequireScope(scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			loaded = UserFromContext(r.Context())
+		}))))))
+	}
+	serve := func(scope, apiKey string) int {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		chain(scope).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(entity.ScopeProductsRead, readPlain))
+	assert.Equal(t, user.ID, loaded.ID)
+	assert.Equal(t, http.StatusForbidden, serve(entity.ScopeProductsWrite, readPlain))
+	assert.Equal(t, http.StatusUnauthorized, serve(entity.ScopeProductsRead, readPlain+"x"))
+	assert.Equal(t, http.StatusUnauthorized, serve(entity.ScopeProductsRead, expiredPlain))
+	assert.Equal(t, http.StatusUnauthorized, serve(entity.ScopeProductsRead, "garbage"))
+	assert.Equal(t, http.StatusUnauthorized, serve(entity.ScopeProductsRead, ""))
+
+	stored, _ := keyDB.FindByPrefix(readKey.Prefix)
+	assert.NotNil(t, stored.LastUsedAt)
+}
+
+func TestAPIKeyAuthRejectsDisabledUsers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.APIKey{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	user.Disable()
+	db.Create(user)
+	keyDB := database.NewAPIKeyDB(db)
+	key, plain, _ := entity.NewAPIKey(user.ID, "job", nil, nil)
+	keyDB.Create(key)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := APIKeyAuth(keyDB, logger)(Session(database.NewUserDB(db))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set(APIKeyHeader, plain)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestAPIKeyAuthCarriesTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.APIKey{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	db.Create(user)
+	keyDB := database.NewAPIKeyDB(db)
+	key, plain, _ := entity.NewAPIKey(user.ID, "job", nil, nil)
+	tenantID := entityPkg.NewID()
+	key.TenantID = &tenantID
+	keyDB.Create(key)
+
+	var claimed string
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := APIKeyAuth(keyDB, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claimed = TenantClaimFromRequest(r)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set(APIKeyHeader, plain)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, tenantID.String(), claimed)
+}
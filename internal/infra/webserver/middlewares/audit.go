@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+// AuditImpersonation records every state changing request performed with an
+// impersonation token in the audit log, attributed to the admin.
+func AuditImpersonation(auditLogs database.AuditLogInterface, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			impersonator := ImpersonatorFromContext(r.Context())
+			if impersonator == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			actorID, err := entityPkg.ParseID(impersonator)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			user := UserFromContext(r.Context())
+			log := entity.NewAuditLog(actorID, entity.AuditImpersonatedCall, user.ID.String(),
+				fmt.Sprintf("%s %s", r.Method, r.URL.Path), middleware.GetReqID(r.Context()))
+			if err := auditLogs.Create(log); err != nil {
+				logger.ErrorContext(r.Context(), "failed to write audit log", "action", log.Action, "error", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
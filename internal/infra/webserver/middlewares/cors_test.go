@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveCORS(opts CORSOptions, method string, headers map[string]string) *httptest.ResponseRecorder {
+	handler := CORS(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(method, "/catalog/acme/products", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSWithAnyOrigin(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodGet},
+		ExposedHeaders: []string{"ETag"},
+		MaxAge:         time.Hour,
+	}
+
+	rec := serveCORS(opts, http.MethodGet, map[string]string{"Origin": "https://shop.example.com"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "ETag", rec.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+
+	rec = serveCORS(opts, http.MethodOptions, map[string]string{"Origin": "https://shop.example.com", "Access-Control-Request-Method": http.MethodGet})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "3600", rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = serveCORS(opts, http.MethodOptions, map[string]string{"Origin": "https://shop.example.com", "Access-Control-Request-Method": http.MethodDelete})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serveCORS(opts, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSWithAllowedOrigins(t *testing.T) {
+	opts := CORSOptions{
+		AllowedOrigins:   []string{"https://shop.example.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+	}
+
+	rec := serveCORS(opts, http.MethodOptions, map[string]string{"Origin": "https://shop.example.com", "Access-Control-Request-Method": http.MethodPost})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://shop.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Authorization, Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = serveCORS(opts, http.MethodGet, map[string]string{"Origin": "https://evil.example.com"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	rec = serveCORS(opts, http.MethodOptions, map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": http.MethodGet})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
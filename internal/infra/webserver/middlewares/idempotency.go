@@ -0,0 +1,179 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/infra/idempotency"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client chosen key of a POST request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks responses replayed from the store.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// idempotencyLockTTL bounds how long a crashed request keeps its key
+	// reserved.
+	idempotencyLockTTL = time.Minute
+)
+
+// Idempotency makes POST requests carrying an Idempotency-Key safe to retry.
+// The first request with a key runs and its response is stored for ttl;
+// retries with the same body get that response back, while reusing the key
+// for a different body or while the first request is still running answers
+// 409. Keys are scoped to the caller and its tenant. Server errors, panics
+// and handlers writing no response are not stored so they can be retried,
+// and store failures are logged and the request is let through. It has to
+// run after the authentication and authorization middlewares, so that their
+// 401 and 403 responses are not stored either.
+func Idempotency(store idempotency.Store, ttl time.Duration, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key = idempotencyScope(r) + ":" + key
+			fingerprint := requestFingerprint(r, body)
+			record, reserved, err := store.Reserve(r.Context(), key, fingerprint, idempotencyLockTTL)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "idempotency store failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !reserved {
+				switch {
+				case record.Fingerprint != fingerprint:
+					http.Error(w, "Idempotency-Key was already used for a different request", http.StatusConflict)
+				case !record.Completed:
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "a request with this Idempotency-Key is still being processed", http.StatusConflict)
+				default:
+					replay(w, record)
+				}
+				return
+			}
+
+			before := w.Header().Clone()
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			// The key is settled even when the client went away mid-request.
+			ctx := context.WithoutCancel(r.Context())
+			release := func() {
+				if err := store.Release(ctx, key); err != nil {
+					logger.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+				}
+			}
+			defer func() {
+				// A panicking handler leaves no response worth replaying, so
+				// the key is released before the panic reaches Recoverer.
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+				if !rec.wroteHeader || rec.status >= http.StatusInternalServerError {
+					release()
+					return
+				}
+				record := &idempotency.Record{
+					Fingerprint: fingerprint,
+					Status:      rec.status,
+					Header:      changedHeaders(before, w.Header()),
+					Body:        rec.body.Bytes(),
+				}
+				if err := store.Complete(ctx, key, record, ttl); err != nil {
+					logger.ErrorContext(ctx, "failed to store idempotent response", "error", err)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// idempotencyScope identifies the caller and tenant a key belongs to.
+func idempotencyScope(r *http.Request) string {
+	caller := KeyByUser(r)
+	if caller == "" {
+		caller = KeyByAPIKey(r)
+	}
+	if caller == "" {
+		caller = KeyByIP(r)
+	}
+	return "idempotency:" + caller + ":" + TenantClaimFromRequest(r)
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// changedHeaders returns the headers the handler set, leaving out the ones
+// set by earlier middlewares such as RateLimit-*.
+func changedHeaders(before, after http.Header) http.Header {
+	changed := http.Header{}
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			changed[name] = values
+		}
+	}
+	return changed
+}
+
+func replay(w http.ResponseWriter, record *idempotency.Record) {
+	for name, values := range record.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivandersr/products-api-go/internal/infra/idempotency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := Idempotency(idempotency.NewMemoryStore(), time.Hour, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Location", "/products/"+strconv.Itoa(calls))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+
+	serve := func(method, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/products", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		rec.Header().Set("RateLimit-Remaining", "9")
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "a", `{"name":"a"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/products/1", rec.Header().Get("Location"))
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+
+	rec = serve(http.MethodPost, "a", `{"name":"a"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/products/1", rec.Header().Get("Location"))
+	assert.Equal(t, `{"name":"a"}`, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "a", `{"name":"b"}`).Code)
+	assert.Equal(t, 1, calls)
+
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "", `{"name":"a"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPut, "a", `{"name":"a"}`).Code)
+	assert.Equal(t, 3, calls)
+
+	assert.Equal(t, http.StatusInternalServerError, serve(http.MethodPost, "b", "fail").Code)
+	assert.Equal(t, http.StatusInternalServerError, serve(http.MethodPost, "b", "fail").Code)
+	assert.Equal(t, 5, calls)
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, strings.Repeat("k", 256), "{}").Code)
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var handler http.Handler
+	handler = Idempotency(store, time.Hour, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, "a")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader("{}"))
+	req.Header.Set(IdempotencyKeyHeader, "a")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestIdempotencyReleasesKeyWithoutResponse(t *testing.T) {
+	calls := 0
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := Idempotency(idempotency.NewMemoryStore(), time.Hour, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "panic" {
+			panic("boom")
+		}
+	}))
+	serve := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, body)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.PanicsWithValue(t, "boom", func() { serve("panic") })
+	assert.PanicsWithValue(t, "boom", func() { serve("panic") })
+	assert.Equal(t, 2, calls)
+
+	serve("empty")
+	serve("empty")
+	assert.Equal(t, 4, calls)
+}
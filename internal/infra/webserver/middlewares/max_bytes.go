@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// MaxBytes caps request bodies at limit bytes and answers 413 when they are
+// larger. Bodies announcing a larger Content-Length are rejected right away;
+// otherwise reading stops at the limit and the response of the handler, which
+// sees a read error, is replaced with the 413.
+func MaxBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit)}
+			r.Body = body
+			next.ServeHTTP(&limitedBodyWriter{ResponseWriter: w, body: body}, r)
+		})
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+type limitedBodyWriter struct {
+	http.ResponseWriter
+	body        *limitedBody
+	wroteHeader bool
+	replaced    bool
+}
+
+func (w *limitedBodyWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.body.exceeded {
+		w.replaced = true
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *limitedBodyWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
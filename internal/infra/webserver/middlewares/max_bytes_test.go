@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBytes(t *testing.T) {
+	// The handler answers 400 on decoding errors like most handlers do.
+	handler := MaxBytes(32)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	serve := func(body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+		if chunked {
+			req.Body = io.NopCloser(strings.NewReader(body))
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, serve(`{"name":"widget"}`, false).Code)
+	assert.Equal(t, http.StatusCreated, serve(`{"name":"widget"}`, true).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(`{"name":`, true).Code)
+
+	large := `{"name":"` + strings.Repeat("a", 64) + `"}`
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(large, false).Code)
+	rec := serve(large, true)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, "Request Entity Too Large\n", rec.Body.String())
+}
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+)
+
+// Authentication method references (RFC 8176) carried in the amr claim.
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	AMRMFA      = "mfa"
+)
+
+var ErrMFARequired = errors.New("two-factor authentication required")
+
+// AMRFromRequest returns the authentication methods of the verified JWT.
+func AMRFromRequest(r *http.Request) []string {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return nil
+	}
+	values, _ := claims["amr"].([]interface{})
+	amr := make([]string, 0, len(values))
+	for _, value := range values {
+		if method, ok := value.(string); ok {
+			amr = append(amr, method)
+		}
+	}
+	return amr
+}
+
+// RequireMFA answers 403 when the role of the user loaded by Session must use
+// two-factor authentication and the session was opened without it. Requests
+// under impersonation are let through, as they are issued from sessions that
+// already passed this check. API keys carry no amr, so they are only accepted
+// while their owner is enrolled: keys created before the role was covered by
+// a policy stop working until the owner enrolls.
+func RequireMFA(policies database.MFAPolicyInterface, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := UserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if ImpersonatorFromContext(r.Context()) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			required, err := policies.IsRequired(user.Role)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "failed to load mfa policy", "role", user.Role, "error", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			passed := slices.Contains(AMRFromRequest(r), AMRMFA)
+			if APIKeyFromContext(r.Context()) != nil {
+				passed = user.IsMFAEnabled()
+			}
+			if required && !passed {
+				http.Error(w, ErrMFARequired.Error(), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRequireMFA(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.MFAPolicy{})
+	admin, _ := entity.NewUser("John", "j@j.com", "123456")
+	admin.ChangeRole(entity.RoleAdmin)
+	db.Create(admin)
+	user, _ := entity.NewUser("Jane", "jane@j.com", "123456")
+	db.Create(user)
+	policyDB := database.NewMFAPolicyDB(db)
+	policy, _ := entity.NewMFAPolicy(entity.RoleAdmin, true)
+	policyDB.Save(policy)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := jwtauth.Verifier(tokenAuth)(Session(database.NewUserDB(db))(RequireMFA(policyDB, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+	serve := func(u *entity.User, amr []string) int {
+		_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": u.ID.String(), "ver": u.TokenVersion, "amr": amr})
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve(admin, []string{AMRPassword}))
+	assert.Equal(t, http.StatusOK, serve(admin, []string{AMRPassword, AMROTP, AMRMFA}))
+	assert.Equal(t, http.StatusOK, serve(user, []string{AMRPassword}))
+}
+
+func TestRequireMFAWithAPIKeys(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.APIKey{}, &entity.MFAPolicy{})
+	admin, _ := entity.NewUser("John", "j@j.com", "123456")
+	admin.ChangeRole(entity.RoleAdmin)
+	db.Create(admin)
+	keyDB := database.NewAPIKeyDB(db)
+	key, plain, _ := entity.NewAPIKey(admin.ID, "job", nil, nil)
+	keyDB.Create(key)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policyDB := database.NewMFAPolicyDB(db)
+	handler := APIKeyAuth(keyDB, logger)(Session(database.NewUserDB(db))(RequireMFA(policyDB, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		req.Header.Set(APIKeyHeader, plain)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+
+	// A key created before the role was covered stops working until its
+	// owner enrolls.
+	policy, _ := entity.NewMFAPolicy(entity.RoleAdmin, true)
+	policyDB.Save(policy)
+	assert.Equal(t, http.StatusForbidden, serve())
+
+	now := time.Now()
+	admin.MFAEnabledAt = &now
+	db.Save(admin)
+	assert.Equal(t, http.StatusOK, serve())
+}
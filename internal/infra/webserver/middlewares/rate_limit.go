@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/infra/ratelimit"
+)
+
+// KeyFunc identifies the client a request is counted against. An empty key
+// skips to the next KeyFunc of a policy.
+type KeyFunc func(r *http.Request) string
+
+// RateLimitPolicy is a named limit applied to the clients identified by Keys.
+// The first KeyFunc returning a non empty key is used.
+type RateLimitPolicy struct {
+	Name  string
+	Limit ratelimit.Limit
+	Keys  []KeyFunc
+}
+
+// KeyByIP identifies clients by their remote address. Put chi's RealIP
+// middleware first when running behind a proxy.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// KeyByUser identifies clients by the subject of their verified JWT.
+func KeyByUser(r *http.Request) string {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil || token.Subject() == "" {
+		return ""
+	}
+	return "user:" + token.Subject()
+}
+
+// KeyByAPIKey identifies clients by a digest of their X-API-Key header.
+func KeyByAPIKey(r *http.Request) string {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "apikey:" + hex.EncodeToString(sum[:8])
+}
+
+// RateLimit rejects requests with 429 once the client exhausted the policy's
+// token bucket. Store failures are logged and the request is let through.
+func RateLimit(store ratelimit.Store, policy RateLimitPolicy, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := ""
+			for _, keyFn := range policy.Keys {
+				if key = keyFn(r); key != "" {
+					break
+				}
+			}
+			if key == "" {
+				key = KeyByIP(r)
+			}
+			res, err := store.Take(r.Context(), "ratelimit:"+policy.Name+":"+key, policy.Limit)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "rate limit store failed", "policy", policy.Name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(res.ResetAfter))
+			if !res.Allowed {
+				logger.WarnContext(r.Context(), "rate limit exceeded", "policy", policy.Name, "key", key)
+				TooManyRequests(w, res.RetryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TooManyRequests writes a 429 response telling the client when to retry.
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", seconds(retryAfter))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// seconds rounds d up to whole seconds as used by Retry-After and RateLimit-Reset.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
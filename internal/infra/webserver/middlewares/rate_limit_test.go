@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/infra/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	policy := RateLimitPolicy{
+		Name:  "test",
+		Limit: ratelimit.Limit{Rate: 0.5, Burst: 2},
+		Keys:  []KeyFunc{KeyByAPIKey, KeyByIP},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := RateLimit(ratelimit.NewMemoryStore(), policy, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/token", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("10.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, serve("10.0.0.1:5678").Code)
+
+	rec = serve("10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, serve("10.0.0.2:1234").Code)
+}
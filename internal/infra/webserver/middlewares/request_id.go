@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/ivandersr/products-api-go/pkg/entity"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID reuses the X-Request-ID sent by the client or generates a new one,
+// stores it in the request context and echoes it back in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = entity.NewID().String()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), middleware.RequestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
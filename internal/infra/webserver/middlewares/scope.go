@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/jwtauth"
+)
+
+// Claims of the access tokens issued to OAuth clients (RFC 9068).
+const (
+	ScopeClaim    = "scope"
+	ClientIDClaim = "client_id"
+)
+
+// ScopesFromRequest returns the scopes the request is restricted to and
+// whether it is restricted at all. API keys without scopes and first-party
+// sessions are not.
+func ScopesFromRequest(r *http.Request) ([]string, bool) {
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		return key.Scopes, len(key.Scopes) > 0
+	}
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return nil, false
+	}
+	scope, ok := claims[ScopeClaim].(string)
+	if !ok {
+		return nil, false
+	}
+	return strings.Fields(scope), true
+}
+
+// HasScope reports whether the request may act with scope.
+func HasScope(r *http.Request, scope string) bool {
+	scopes, restricted := ScopesFromRequest(r)
+	return !restricted || slices.Contains(scopes, scope)
+}
+
+// RequireScope answers 403 when the request was authenticated by an API key
+// or an OAuth access token lacking scope.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r, scope) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireFirstParty answers 403 to access tokens issued to OAuth clients, so
+// third-party applications cannot manage the account they act for.
+func RequireFirstParty(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, claims, err := jwtauth.FromContext(r.Context()); err == nil && claims[ClientIDClaim] != nil {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
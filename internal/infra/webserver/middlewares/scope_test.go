@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireScopeWithOAuthTokens(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := jwtauth.Verifier(tokenAuth)(RequireScope("products:write")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	serve := func(claims map[string]interface{}) int {
+		_, token, _ := tokenAuth.Encode(claims)
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(map[string]interface{}{"sub": "user"}))
+	assert.Equal(t, http.StatusOK, serve(map[string]interface{}{"sub": "user", ScopeClaim: "openid products:write"}))
+	assert.Equal(t, http.StatusForbidden, serve(map[string]interface{}{"sub": "user", ScopeClaim: "openid products:read"}))
+}
+
+func TestRequireFirstParty(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := jwtauth.Verifier(tokenAuth)(RequireFirstParty(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	serve := func(claims map[string]interface{}) int {
+		_, token, _ := tokenAuth.Encode(claims)
+		req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(map[string]interface{}{"sub": "user"}))
+	assert.Equal(t, http.StatusForbidden, serve(map[string]interface{}{"sub": "user", ClientIDClaim: "client"}))
+}
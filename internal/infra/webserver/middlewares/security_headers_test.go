@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := SecurityHeaders(SecurityHeadersOptions{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: APIContentSecurityPolicy,
+	})
+	rec := httptest.NewRecorder()
+	handler(noop).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "max-age=31536000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, APIContentSecurityPolicy, rec.Header().Get("Content-Security-Policy"))
+
+	rec = httptest.NewRecorder()
+	handler(ContentSecurityPolicy("default-src 'self'")(noop)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/index.html", nil))
+	assert.Equal(t, "default-src 'self'", rec.Header().Get("Content-Security-Policy"))
+
+	rec = httptest.NewRecorder()
+	SecurityHeaders(SecurityHeadersOptions{})(noop).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+	assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+}
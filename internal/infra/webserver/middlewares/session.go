@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+)
+
+type contextKey struct {
+	name string
+}
+
+var (
+	userCtxKey         = &contextKey{"user"}
+	impersonatorCtxKey = &contextKey{"impersonator"}
+)
+
+// Session loads the user behind the verified JWT into the request context.
+// It answers 401 when the user no longer exists or when the token was issued
+// before its sessions were invalidated, for instance by a password change,
+// and 403 when the user was disabled. API keys outlive sessions, so requests
+// authenticated by APIKeyAuth skip the session version check. Impersonation
+// tokens are checked the same way against the admin in their act claim, who
+// must also still be an admin.
+func Session(users database.UserInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil || token == nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			user, err := users.FindByID(token.Subject())
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if user.IsDisabled() {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if version, _ := claims["ver"].(float64); APIKeyFromContext(r.Context()) == nil && int(version) != user.TokenVersion {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			ctx := WithUser(r.Context(), user)
+			if act, ok := claims["act"].(map[string]interface{}); ok {
+				impersonatorID, _ := act["sub"].(string)
+				impersonator, err := users.FindByID(impersonatorID)
+				if err != nil {
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+				if impersonator.IsDisabled() || !impersonator.IsAdmin() {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+				if version, _ := act["ver"].(float64); int(version) != impersonator.TokenVersion {
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+				ctx = context.WithValue(ctx, impersonatorCtxKey, impersonatorID)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// WithUser returns a copy of ctx carrying the user of the request, as Session
+// does.
+func WithUser(ctx context.Context, user *entity.User) context.Context {
+	return context.WithValue(ctx, userCtxKey, user)
+}
+
+// UserFromContext returns the user loaded by Session.
+func UserFromContext(ctx context.Context) *entity.User {
+	user, _ := ctx.Value(userCtxKey).(*entity.User)
+	return user
+}
+
+// ImpersonatorFromContext returns the ID of the admin impersonating the
+// current user, or an empty string.
+func ImpersonatorFromContext(ctx context.Context) string {
+	impersonator, _ := ctx.Value(impersonatorCtxKey).(string)
+	return impersonator
+}
+
+// RequireRole answers 403 unless the user loaded by Session has one of roles.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := UserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			for _, role := range roles {
+				if user.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSession(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.UserToken{}, &entity.APIKey{}, &entity.RecoveryCode{},
+		&entity.OAuthConsent{}, &entity.OAuthAuthorizationCode{}, &entity.OAuthRefreshToken{}, &entity.Membership{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	db.Create(user)
+	userDB := database.NewUserDB(db)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	var loaded *entity.User
+	handler := jwtauth.Verifier(tokenAuth)(Session(userDB)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loaded = UserFromContext(r.Context())
+	})))
+	serve := func(version int) int {
+		_, token, _ := tokenAuth.Encode(map[string]interface{}{"sub": user.ID.String(), "ver": version})
+		req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(user.TokenVersion))
+	assert.Equal(t, user.ID, loaded.ID)
+
+	user.ChangePassword("654321")
+	userDB.Update(user)
+	assert.Equal(t, http.StatusUnauthorized, serve(user.TokenVersion-1))
+	assert.Equal(t, http.StatusOK, serve(user.TokenVersion))
+
+	user.Disable()
+	userDB.Update(user)
+	assert.Equal(t, http.StatusForbidden, serve(user.TokenVersion))
+
+	userDB.Delete(user.ID.String())
+	assert.Equal(t, http.StatusUnauthorized, serve(user.TokenVersion))
+}
+
+func TestSessionImpersonation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.UserToken{}, &entity.APIKey{}, &entity.RecoveryCode{},
+		&entity.OAuthConsent{}, &entity.OAuthAuthorizationCode{}, &entity.OAuthRefreshToken{}, &entity.Membership{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	admin, _ := entity.NewUser("Jane", "jane@j.com", "123456")
+	admin.ChangeRole(entity.RoleAdmin)
+	db.Create(user)
+	db.Create(admin)
+	userDB := database.NewUserDB(db)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	adminVersion := admin.TokenVersion
+	var impersonator string
+	handler := jwtauth.Verifier(tokenAuth)(Session(userDB)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		impersonator = ImpersonatorFromContext(r.Context())
+	})))
+	serve := func() int {
+		_, token, _ := tokenAuth.Encode(map[string]interface{}{
+			"sub": user.ID.String(),
+			"ver": user.TokenVersion,
+			"act": map[string]interface{}{"sub": admin.ID.String(), "ver": adminVersion},
+		})
+		req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, admin.ID.String(), impersonator)
+
+	admin.ChangeRole(entity.RoleUser)
+	userDB.Update(admin)
+	assert.Equal(t, http.StatusForbidden, serve())
+
+	admin.ChangeRole(entity.RoleAdmin)
+	admin.Disable()
+	userDB.Update(admin)
+	assert.Equal(t, http.StatusForbidden, serve())
+
+	admin.Enable()
+	admin.InvalidateSessions()
+	userDB.Update(admin)
+	assert.Equal(t, http.StatusUnauthorized, serve())
+
+	userDB.Delete(admin.ID.String())
+	assert.Equal(t, http.StatusUnauthorized, serve())
+}
+
+func TestRequireRole(t *testing.T) {
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	handler := RequireRole(entity.RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		req = req.WithContext(context.WithValue(req.Context(), userCtxKey, user))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, serve())
+	user.ChangeRole(entity.RoleAdmin)
+	assert.Equal(t, http.StatusOK, serve())
+}
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// TenantClaim holds the ID of the tenant a token was issued for.
+const TenantClaim = "tid"
+
+var membershipCtxKey = &contextKey{"membership"}
+
+// TenantClaimFromRequest returns the tenant the token of the request was
+// issued for, or an empty string.
+func TenantClaimFromRequest(r *http.Request) string {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return ""
+	}
+	tenantID, _ := claims[TenantClaim].(string)
+	return tenantID
+}
+
+// RequireTenant scopes the tenant aware repositories to the tenant of the
+// token. It answers 403 when the token has no tenant or when the user loaded
+// by Session is no longer a member of it, so removed members lose access
+// right away. It has to run after Session.
+func RequireTenant(memberships database.MembershipInterface, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID, err := entityPkg.ParseID(TenantClaimFromRequest(r))
+			user := UserFromContext(r.Context())
+			if err != nil || user == nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			membership, err := memberships.Find(tenantID.String(), user.ID.String())
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				logger.ErrorContext(r.Context(), "failed to load tenant membership", "user_id", user.ID.String(), "error", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			ctx := database.WithTenant(r.Context(), tenantID)
+			next.ServeHTTP(w, r.WithContext(WithMembership(ctx, membership)))
+		})
+	}
+}
+
+// WithMembership returns a copy of ctx carrying the membership of the caller
+// in the tenant, as RequireTenant does.
+func WithMembership(ctx context.Context, membership *entity.Membership) context.Context {
+	return context.WithValue(ctx, membershipCtxKey, membership)
+}
+
+// MembershipFromContext returns the membership loaded by RequireTenant.
+func MembershipFromContext(ctx context.Context) *entity.Membership {
+	membership, _ := ctx.Value(membershipCtxKey).(*entity.Membership)
+	return membership
+}
+
+// RequireTenantOwner answers 403 unless the user owns the tenant loaded by
+// RequireTenant.
+func RequireTenantOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if membership := MembershipFromContext(r.Context()); membership == nil || !membership.IsOwner() {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireTenantEditor answers 403 unless the user owns or edits the products
+// of the tenant loaded by RequireTenant.
+func RequireTenantEditor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if membership := MembershipFromContext(r.Context()); membership == nil || !membership.CanEditProducts() {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/ivandersr/products-api-go/internal/entity"
+	"github.com/ivandersr/products-api-go/internal/infra/database"
+	entityPkg "github.com/ivandersr/products-api-go/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRequireTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.Membership{})
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	db.Create(user)
+	acme, globex := entityPkg.NewID(), entityPkg.NewID()
+	membership, _ := entity.NewMembership(acme, user.ID, entity.TenantRoleMember)
+	db.Create(membership)
+	membershipDB := database.NewMembershipDB(db)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	var scoped entityPkg.ID
+	var loaded *entity.Membership
+	handler := jwtauth.Verifier(tokenAuth)(Session(database.NewUserDB(db))(RequireTenant(membershipDB, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scoped, _ = database.TenantFromContext(r.Context())
+		loaded = MembershipFromContext(r.Context())
+	}))))
+	serve := func(tenantID string) int {
+		claims := map[string]interface{}{"sub": user.ID.String(), "ver": user.TokenVersion}
+		if tenantID != "" {
+			claims[TenantClaim] = tenantID
+		}
+		_, token, _ := tokenAuth.Encode(claims)
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(acme.String()))
+	assert.Equal(t, acme, scoped)
+	assert.Equal(t, entity.TenantRoleMember, loaded.Role)
+	assert.Equal(t, http.StatusForbidden, serve(""))
+	assert.Equal(t, http.StatusForbidden, serve("acme"))
+	assert.Equal(t, http.StatusForbidden, serve(globex.String()))
+
+	membershipDB.Delete(acme.String(), user.ID.String())
+	assert.Equal(t, http.StatusForbidden, serve(acme.String()))
+}
+
+func TestRequireTenantOwner(t *testing.T) {
+	handler := RequireTenantOwner(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(membership *entity.Membership) int {
+		req := httptest.NewRequest(http.MethodPost, "/tenant/members", nil)
+		if membership != nil {
+			req = req.WithContext(context.WithValue(req.Context(), membershipCtxKey, membership))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	owner, _ := entity.NewMembership(entityPkg.NewID(), entityPkg.NewID(), entity.TenantRoleOwner)
+	member, _ := entity.NewMembership(entityPkg.NewID(), entityPkg.NewID(), entity.TenantRoleMember)
+	assert.Equal(t, http.StatusOK, serve(owner))
+	assert.Equal(t, http.StatusForbidden, serve(member))
+	assert.Equal(t, http.StatusForbidden, serve(nil))
+}
+
+func TestRequireTenantEditor(t *testing.T) {
+	handler := RequireTenantEditor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(role string) int {
+		req := httptest.NewRequest(http.MethodPut, "/products/1", nil)
+		if role != "" {
+			membership, _ := entity.NewMembership(entityPkg.NewID(), entityPkg.NewID(), role)
+			req = req.WithContext(WithMembership(req.Context(), membership))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(entity.TenantRoleOwner))
+	assert.Equal(t, http.StatusOK, serve(entity.TenantRoleEditor))
+	assert.Equal(t, http.StatusForbidden, serve(entity.TenantRoleMember))
+	assert.Equal(t, http.StatusForbidden, serve(""))
+}
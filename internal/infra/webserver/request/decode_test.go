@@ -0,0 +1,71 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newJSONRequest(contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var input dto.CreateProductInput
+	err := DecodeJSON(newJSONRequest("application/json; charset=utf-8", `{"name":"Widget","price":10}`), &input)
+	assert.Nil(t, err)
+	assert.Equal(t, "Widget", input.Name)
+	assert.Equal(t, 10.0, input.Price)
+}
+
+func TestDecodeJSONRejectsMalformedBodies(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		err         string
+	}{
+		{"", `{"name":"Widget","price":10}`, ErrUnsupportedMediaType.Error()},
+		{"text/plain", `{"name":"Widget","price":10}`, ErrUnsupportedMediaType.Error()},
+		{"application/json", ``, ErrEmptyBody.Error()},
+		{"application/json", `{"name":"Widget"`, "request body is malformed JSON"},
+		{"application/json", `{"name":"Widget",}`, "request body is malformed JSON at offset 18"},
+		{"application/json", `{"name":"Widget","price":"10"}`, "price must be a number"},
+		{"application/json", `["Widget"]`, "request body must be a JSON object"},
+		{"application/json", `{"name":"Widget","price":10,"stock":3}`, `unknown field "stock"`},
+		{"application/json", `{"name":"Widget","price":10} {}`, ErrTrailingData.Error()},
+		{"application/json", `{"name":"Widget","price":10} garbage`, ErrTrailingData.Error()},
+	} {
+		var input dto.CreateProductInput
+		err := DecodeJSON(newJSONRequest(tc.contentType, tc.body), &input)
+		assert.EqualError(t, err, tc.err, tc.body)
+	}
+}
+
+func TestDecodeJSONReportsOversizedBodies(t *testing.T) {
+	req := newJSONRequest("application/json", `{"name":"`+strings.Repeat("a", 64)+`","price":10}`)
+	req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, 32)
+	var input dto.CreateProductInput
+	var maxBytesErr *http.MaxBytesError
+	assert.True(t, errors.As(DecodeJSON(req, &input), &maxBytesErr))
+}
+
+func TestDecodeJSONValidates(t *testing.T) {
+	var input dto.CreateUserInput
+	err := DecodeJSON(newJSONRequest("application/json", `{"name":"","email":"john","password":""}`), &input)
+	var errs ValidationErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, ValidationErrors{
+		{Field: "name", Message: "name is required"},
+		{Field: "email", Message: "email must be a valid email address"},
+		{Field: "password", Message: "password is required"},
+	}, errs)
+}
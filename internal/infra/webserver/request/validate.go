@@ -0,0 +1,102 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+// FieldError is a rule broken by a field, named after its JSON key.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every rule broken by a value.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Validate checks v against the rules of its validate struct tags, which
+// also document the request bodies in the OpenAPI schema. It returns
+// ValidationErrors when any rule is broken.
+func Validate(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	err := validate.Struct(v)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	errs := make(ValidationErrors, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		// The namespace starts with the name of the validated struct.
+		field := fieldErr.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		errs[i] = FieldError{Field: field, Message: message(field, fieldErr)}
+	}
+	return errs
+}
+
+func message(field string, err validator.FieldError) string {
+	unit := ""
+	switch err.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+	switch err.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s%s", field, err.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s%s", field, err.Param(), unit)
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s%s", field, err.Param(), unit)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, err.Param())
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, err.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(err.Param(), " ", ", "))
+	default:
+		return fmt.Sprintf("%s is invalid (%s)", field, err.Tag())
+	}
+}
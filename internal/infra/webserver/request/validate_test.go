@@ -0,0 +1,34 @@
+package request
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ivandersr/products-api-go/internal/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCreateProductInput(t *testing.T) {
+	assert.Nil(t, Validate(&dto.CreateProductInput{Name: "Widget", Price: 10, Status: "published"}))
+
+	err := Validate(&dto.CreateProductInput{Name: strings.Repeat("a", 256), Price: -1, Status: "archived"})
+	assert.Equal(t, ValidationErrors{
+		{Field: "name", Message: "name must be at most 255 characters"},
+		{Field: "price", Message: "price must be greater than 0"},
+		{Field: "status", Message: "status must be one of: draft, published"},
+	}, err)
+	assert.Equal(t, "name must be at most 255 characters; price must be greater than 0; status must be one of: draft, published", err.Error())
+}
+
+func TestValidateGetJWTInput(t *testing.T) {
+	assert.Nil(t, Validate(&dto.GetJWTInput{Email: "john@example.com", Password: "123456"}))
+	assert.Equal(t, ValidationErrors{
+		{Field: "email", Message: "email is required"},
+		{Field: "password", Message: "password is required"},
+	}, Validate(&dto.GetJWTInput{}))
+}
+
+func TestValidateIgnoresValuesWithoutRules(t *testing.T) {
+	assert.Nil(t, Validate(&dto.EmailInput{}))
+	assert.Nil(t, Validate(&map[string]string{}))
+}
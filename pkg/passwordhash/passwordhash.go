@@ -0,0 +1,182 @@
+// Package passwordhash hashes passwords with bcrypt or Argon2id. Hashes are
+// self-describing: bcrypt in its modular crypt format and Argon2id in the PHC
+// string format, so any of them can be verified whatever the configured
+// hasher, and hashes made with other parameters can be detected and upgraded.
+package passwordhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmUnknown  = "unknown"
+)
+
+var (
+	ErrInvalidHash      = errors.New("invalid password hash")
+	ErrInvalidAlgorithm = errors.New("invalid password hash algorithm")
+	ErrInvalidParams    = errors.New("invalid password hash parameters")
+
+	encoding = base64.RawStdEncoding
+)
+
+// Hasher hashes new passwords and tells which stored hashes were not made
+// with its algorithm and parameters. MaxPasswordLength is the number of bytes
+// of a password the hasher takes into account, or 0 when it uses them all.
+type Hasher interface {
+	Hash(password string) (string, error)
+	NeedsRehash(hash string) bool
+	MaxPasswordLength() int
+}
+
+// Verify reports whether password matches hash, whichever algorithm made it.
+func Verify(hash, password string) bool {
+	switch Identify(hash) {
+	case AlgorithmBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case AlgorithmArgon2id:
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	default:
+		return false
+	}
+}
+
+// Identify returns the algorithm that made hash.
+func Identify(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return AlgorithmBcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return AlgorithmArgon2id
+	default:
+		return AlgorithmUnknown
+	}
+}
+
+// New returns the hasher of algorithm: bcrypt with bcryptCost, or Argon2id
+// with argon2Params.
+func New(algorithm string, bcryptCost int, argon2Params Argon2idParams) (Hasher, error) {
+	switch algorithm {
+	case AlgorithmBcrypt:
+		return NewBcrypt(bcryptCost)
+	case AlgorithmArgon2id:
+		return NewArgon2id(argon2Params)
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+}
+
+type Bcrypt struct {
+	Cost int
+}
+
+func NewBcrypt(cost int) (*Bcrypt, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, ErrInvalidParams
+	}
+	return &Bcrypt{Cost: cost}, nil
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b *Bcrypt) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.Cost
+}
+
+// MaxPasswordLength is 72: bcrypt ignores the bytes past it.
+func (b *Bcrypt) MaxPasswordLength() int {
+	return 72
+}
+
+// Argon2idParams are the Argon2id cost parameters (RFC 9106). Memory is in
+// KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2idParams is the second recommended option of RFC 9106 with
+// the memory of the OWASP recommendation.
+var DefaultArgon2idParams = Argon2idParams{Memory: 64 * 1024, Iterations: 3, Parallelism: 4}
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+type Argon2id struct {
+	Params Argon2idParams
+}
+
+func NewArgon2id(params Argon2idParams) (*Argon2id, error) {
+	if params.Iterations < 1 || params.Parallelism < 1 || params.Memory < 8*uint32(params.Parallelism) {
+		return nil, ErrInvalidParams
+	}
+	return &Argon2id{Params: params}, nil
+}
+
+// Hash returns $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>.
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Params.Iterations, a.Params.Memory, a.Params.Parallelism, argon2idKeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		a.Params.Memory, a.Params.Iterations, a.Params.Parallelism,
+		encoding.EncodeToString(salt), encoding.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	return err != nil || params != a.Params || len(salt) != argon2idSaltLength || len(key) != argon2idKeyLength
+}
+
+// MaxPasswordLength is 0: Argon2id hashes passwords of any length whole.
+func (a *Argon2id) MaxPasswordLength() int {
+	return 0
+}
+
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,98 @@
+package passwordhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestBcrypt(t *testing.T) {
+	hasher, err := NewBcrypt(bcrypt.MinCost)
+	assert.Nil(t, err)
+	hash, err := hasher.Hash("123456")
+	assert.Nil(t, err)
+	assert.Equal(t, AlgorithmBcrypt, Identify(hash))
+	assert.True(t, Verify(hash, "123456"))
+	assert.False(t, Verify(hash, "1234567"))
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.Equal(t, 72, hasher.MaxPasswordLength())
+
+	stronger, err := NewBcrypt(bcrypt.MinCost + 1)
+	assert.Nil(t, err)
+	assert.True(t, stronger.NeedsRehash(hash))
+
+	_, err = NewBcrypt(bcrypt.MaxCost + 1)
+	assert.Equal(t, ErrInvalidParams, err)
+}
+
+func TestArgon2id(t *testing.T) {
+	hasher, err := NewArgon2id(testArgon2idParams)
+	assert.Nil(t, err)
+	hash, err := hasher.Hash("123456")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"))
+	assert.Equal(t, AlgorithmArgon2id, Identify(hash))
+	assert.True(t, Verify(hash, "123456"))
+	assert.False(t, Verify(hash, "1234567"))
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.Zero(t, hasher.MaxPasswordLength())
+
+	long := strings.Repeat("a", 100)
+	longHash, err := hasher.Hash(long)
+	assert.Nil(t, err)
+	assert.False(t, Verify(longHash, long[:72]))
+
+	other, err := hasher.Hash("123456")
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, other)
+
+	stronger, err := NewArgon2id(Argon2idParams{Memory: 128, Iterations: 1, Parallelism: 1})
+	assert.Nil(t, err)
+	assert.True(t, stronger.NeedsRehash(hash))
+	assert.True(t, Verify(hash, "123456"))
+
+	_, err = NewArgon2id(Argon2idParams{Memory: 8, Iterations: 1, Parallelism: 2})
+	assert.Equal(t, ErrInvalidParams, err)
+}
+
+func TestArgon2idRejectsMalformedHashes(t *testing.T) {
+	hasher, err := NewArgon2id(testArgon2idParams)
+	assert.Nil(t, err)
+	hash, err := hasher.Hash("123456")
+	assert.Nil(t, err)
+	for _, malformed := range []string{
+		strings.Replace(hash, "v=19", "v=16", 1),
+		strings.Replace(hash, "m=64", "m=x", 1),
+		hash[:strings.LastIndex(hash, "$")],
+		hash[:strings.LastIndex(hash, "$")] + "$",
+		hash + "!",
+	} {
+		assert.False(t, Verify(malformed, "123456"), malformed)
+		assert.True(t, hasher.NeedsRehash(malformed), malformed)
+	}
+}
+
+func TestHashersUpgradeEachOther(t *testing.T) {
+	bcryptHasher, err := New(AlgorithmBcrypt, bcrypt.MinCost, testArgon2idParams)
+	assert.Nil(t, err)
+	argon2idHasher, err := New(AlgorithmArgon2id, bcrypt.MinCost, testArgon2idParams)
+	assert.Nil(t, err)
+
+	legacy, err := bcryptHasher.Hash("123456")
+	assert.Nil(t, err)
+	assert.True(t, argon2idHasher.NeedsRehash(legacy))
+	upgraded, err := argon2idHasher.Hash("123456")
+	assert.Nil(t, err)
+	assert.True(t, bcryptHasher.NeedsRehash(upgraded))
+	assert.True(t, Verify(legacy, "123456"))
+	assert.True(t, Verify(upgraded, "123456"))
+
+	_, err = New("md5", bcrypt.MinCost, testArgon2idParams)
+	assert.Equal(t, ErrInvalidAlgorithm, err)
+	assert.Equal(t, AlgorithmUnknown, Identify("5f4dcc3b5aa765d61d8327deb882cf99"))
+	assert.False(t, Verify("", ""))
+}
@@ -0,0 +1,29 @@
+package signedtoken
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewSigner("secret")
+	token := signer.Sign("reset", "abc")
+	id, err := signer.Verify("reset", token)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", id)
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	signer := NewSigner("secret")
+	token := signer.Sign("reset", "abc")
+
+	_, err := signer.Verify("verify", token)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = NewSigner("other").Verify("reset", token)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = signer.Verify("reset", "YWJk"+token[4:])
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = signer.Verify("reset", "garbage")
+	assert.Equal(t, ErrInvalidToken, err)
+}